@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/larryhudson/go-todo-list-claude/internal/database"
+)
+
+// maxConnectDelay caps the wait between connection attempts
+const maxConnectDelay = 30 * time.Second
+
+// connectRetry controls how often opening the database is attempted at
+// startup, for deployments where the database volume may not be ready yet
+type connectRetry struct {
+	// attempts is the total number of tries; 1 fails on the first error
+	attempts int
+
+	// delay is the wait before the second attempt. It doubles after each
+	// further failure, up to maxConnectDelay.
+	delay time.Duration
+
+	// sleep waits between attempts; tests replace it
+	sleep func(time.Duration)
+}
+
+// connectRetryFromEnv reads DB_CONNECT_ATTEMPTS and DB_CONNECT_DELAY. By
+// default a single attempt is made, so configuration mistakes still fail
+// fast.
+func connectRetryFromEnv() (connectRetry, error) {
+	retry := connectRetry{attempts: 1, delay: time.Second, sleep: time.Sleep}
+
+	if v := os.Getenv("DB_CONNECT_ATTEMPTS"); v != "" {
+		attempts, err := strconv.Atoi(v)
+		if err != nil || attempts < 1 {
+			return retry, fmt.Errorf("invalid DB_CONNECT_ATTEMPTS %q", v)
+		}
+		retry.attempts = attempts
+	}
+
+	if v := os.Getenv("DB_CONNECT_DELAY"); v != "" {
+		delay, err := time.ParseDuration(v)
+		if err != nil || delay <= 0 {
+			return retry, fmt.Errorf("invalid DB_CONNECT_DELAY %q", v)
+		}
+		retry.delay = delay
+	}
+
+	return retry, nil
+}
+
+// open calls connect until it succeeds or the attempts run out, logging
+// each failure, and returns the last error if none succeeded
+func (c connectRetry) open(connect func() (*database.DB, error)) (*database.DB, error) {
+	delay := c.delay
+	for attempt := 1; ; attempt++ {
+		db, err := connect()
+		if err == nil {
+			return db, nil
+		}
+		if attempt >= c.attempts {
+			return nil, err
+		}
+
+		slog.Warn("Database connection failed, retrying",
+			"attempt", attempt, "attempts", c.attempts, "retryIn", delay, "err", err)
+		c.sleep(delay)
+		delay = min(2*delay, maxConnectDelay)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/larryhudson/go-todo-list-claude/internal/database"
+)
+
+func TestConnectRetry_Open(t *testing.T) {
+	errUnavailable := errors.New("unable to open database file")
+
+	tests := []struct {
+		name       string
+		attempts   int
+		failures   int
+		wantErr    bool
+		wantCalls  int
+		wantSleeps []time.Duration
+	}{
+		{"first attempt succeeds", 3, 0, false, 1, nil},
+		{"succeeds after retries", 5, 3, false, 4, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second}},
+		{"gives up after the last attempt", 3, 10, true, 3, []time.Duration{time.Second, 2 * time.Second}},
+		{"single attempt fails fast", 1, 1, true, 1, nil},
+		{"backoff is capped", 8, 7, false, 8, []time.Duration{
+			time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second,
+			16 * time.Second, 30 * time.Second, 30 * time.Second,
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sleeps []time.Duration
+			retry := connectRetry{
+				attempts: tt.attempts,
+				delay:    time.Second,
+				sleep:    func(d time.Duration) { sleeps = append(sleeps, d) },
+			}
+
+			calls := 0
+			db, err := retry.open(func() (*database.DB, error) {
+				calls++
+				if calls <= tt.failures {
+					return nil, errUnavailable
+				}
+				return &database.DB{}, nil
+			})
+
+			if tt.wantErr {
+				if !errors.Is(err, errUnavailable) {
+					t.Errorf("Expected the last connection error, got %v", err)
+				}
+			} else if err != nil || db == nil {
+				t.Errorf("Expected a database, got %v, %v", db, err)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("Expected %d attempts, got %d", tt.wantCalls, calls)
+			}
+			if len(sleeps) != len(tt.wantSleeps) {
+				t.Fatalf("Expected waits %v, got %v", tt.wantSleeps, sleeps)
+			}
+			for i := range sleeps {
+				if sleeps[i] != tt.wantSleeps[i] {
+					t.Errorf("Expected waits %v, got %v", tt.wantSleeps, sleeps)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestConnectRetryFromEnv(t *testing.T) {
+	retry, err := connectRetryFromEnv()
+	if err != nil || retry.attempts != 1 {
+		t.Errorf("Expected a single attempt by default, got %+v, %v", retry, err)
+	}
+
+	t.Setenv("DB_CONNECT_ATTEMPTS", "5")
+	t.Setenv("DB_CONNECT_DELAY", "250ms")
+	retry, err = connectRetryFromEnv()
+	if err != nil || retry.attempts != 5 || retry.delay != 250*time.Millisecond {
+		t.Errorf("Expected 5 attempts 250ms apart, got %+v, %v", retry, err)
+	}
+
+	for _, v := range []string{"0", "-1", "many"} {
+		t.Setenv("DB_CONNECT_ATTEMPTS", v)
+		if _, err := connectRetryFromEnv(); err == nil {
+			t.Errorf("Expected an error for DB_CONNECT_ATTEMPTS=%s", v)
+		}
+	}
+}
@@ -7,25 +7,43 @@
 package main
 
 import (
-	"embed"
-	"log"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
+	_ "time/tzdata" // tz query parameters must resolve even without system zoneinfo
 
 	"github.com/larryhudson/go-todo-list-claude/internal/database"
 	"github.com/larryhudson/go-todo-list-claude/internal/handlers"
+	"github.com/larryhudson/go-todo-list-claude/internal/logging"
+	"github.com/larryhudson/go-todo-list-claude/internal/models"
+	"github.com/larryhudson/go-todo-list-claude/internal/seed"
 )
 
-//go:embed migrations/*.sql
-var migrationsFS embed.FS
+// Build information, set at build time with -ldflags, e.g.
+// -X main.version=1.2.0 -X main.commit=$(git rev-parse HEAD); see the
+// Makefile's build target
+var (
+	version   = "dev"
+	commit    = "unknown"
+	buildTime = "unknown"
+)
 
 // corsMiddleware adds CORS headers to responses
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PATCH, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, POST, PUT, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, If-Modified-Since, X-Confirm-Delete-All")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Next-Cursor, X-Max-Page-Size, Last-Modified")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -36,54 +54,464 @@ func corsMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// middlewareConfig selects the optional middleware applied to every route
+type middlewareConfig struct {
+	// basePath is the normalized base path the routes are mounted under
+	basePath string
+
+	// readOnly rejects requests that could change data
+	readOnly bool
+
+	// maxConcurrent bounds the requests processed at once; 0 means no limit
+	maxConcurrent int
+
+	// snakeCase names JSON members in snake_case, in requests and responses
+	snakeCase bool
+}
+
+// withMiddleware wraps the router in the middleware shared by every route
+func withMiddleware(mux http.Handler, cfg middlewareConfig) http.Handler {
+	handler := mux
+	if cfg.readOnly {
+		handler = handlers.ReadOnly(handler)
+	}
+
+	// Health checks skip the concurrency limit so a busy server is not
+	// reported as down
+	handler = handlers.MaxConcurrent(handler, cfg.maxConcurrent, func(r *http.Request) bool {
+		return r.URL.Path == healthPath || r.URL.Path == cfg.basePath+healthPath
+	})
+
+	handler = handlers.PrettyJSON(handler)
+	if cfg.snakeCase {
+		handler = handlers.SnakeCaseJSON(handler)
+	}
+
+	return corsMiddleware(handler)
+}
+
+// serverTimeouts holds the HTTP server's timeouts; 0 disables a timeout
+type serverTimeouts struct {
+	read, write, idle, readHeader time.Duration
+
+	// request bounds how long a handler may run, independent of the
+	// connection-level timeouts above
+	request time.Duration
+}
+
+// serverTimeoutsFromEnv reads the server timeouts from READ_TIMEOUT,
+// WRITE_TIMEOUT, IDLE_TIMEOUT, READ_HEADER_TIMEOUT and REQUEST_TIMEOUT,
+// keeping the defaults for any that are unset
+func serverTimeoutsFromEnv() (serverTimeouts, error) {
+	timeouts := serverTimeouts{
+		read:       15 * time.Second,
+		write:      15 * time.Second,
+		idle:       60 * time.Second,
+		readHeader: 5 * time.Second,
+		// Shorter than write, so the 503 can still reach the client
+		request: 10 * time.Second,
+	}
+
+	vars := []struct {
+		name string
+		dest *time.Duration
+	}{
+		{"READ_TIMEOUT", &timeouts.read},
+		{"WRITE_TIMEOUT", &timeouts.write},
+		{"IDLE_TIMEOUT", &timeouts.idle},
+		{"READ_HEADER_TIMEOUT", &timeouts.readHeader},
+		{"REQUEST_TIMEOUT", &timeouts.request},
+	}
+	for _, v := range vars {
+		s := os.Getenv(v.name)
+		if s == "" {
+			continue
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil || d < 0 {
+			return timeouts, fmt.Errorf("invalid %s %q", v.name, s)
+		}
+		*v.dest = d
+	}
+
+	return timeouts, nil
+}
+
+// fatalf logs a startup error and exits. Unlike log.Fatalf it logs at error
+// level, so the message is not filtered out by a strict LOG_LEVEL.
+func fatalf(format string, args ...any) {
+	slog.Error(fmt.Sprintf(format, args...))
+	os.Exit(1)
+}
+
 func main() {
+	seedCount := flag.Int("seed", 0, "Insert up to N sample todos for development and exit")
+	migrateStatus := flag.Bool("migrate-status", false, "Print applied and pending migrations without running them, then exit")
+	flag.Parse()
+
+	// Configure logging before anything else logs. Setting the default also
+	// routes the standard log package through the same handler.
+	logConfig, err := logging.ConfigFromEnv(logging.IsTerminal(os.Stdout))
+	if err != nil {
+		fatalf("Invalid logging configuration: %v", err)
+	}
+	slog.SetDefault(logging.New(os.Stdout, logConfig))
+
+	// Load server timeouts first so a bad value fails before the database is touched
+	timeouts, err := serverTimeoutsFromEnv()
+	if err != nil {
+		fatalf("Invalid server configuration: %v", err)
+	}
+
 	// Get database path from environment or use default
 	dbPath := os.Getenv("DB_PATH")
 	if dbPath == "" {
 		dbPath = "./todos.db"
 	}
 
-	// Initialize database
-	db, err := database.New(dbPath)
+	// Load connection pool settings
+	dbConfig, err := database.ConfigFromEnv()
+	if err != nil {
+		fatalf("Invalid database configuration: %v", err)
+	}
+
+	retry, err := connectRetryFromEnv()
+	if err != nil {
+		fatalf("Invalid database configuration: %v", err)
+	}
+
+	// exitCode is set when the server stops on an error. Exiting from the
+	// first deferred call lets the cleanup deferred after it run first.
+	exitCode := 0
+	defer func() {
+		if exitCode != 0 {
+			os.Exit(exitCode)
+		}
+	}()
+
+	// Initialize database, retrying if asked to while its volume comes up
+	db, err := retry.open(func() (*database.DB, error) {
+		return database.New(dbPath, dbConfig)
+	})
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		fatalf("Failed to connect to database: %v", err)
 	}
 	defer func() {
 		if err := db.Close(); err != nil {
-			log.Printf("Error closing database: %v", err)
+			slog.Error("Error closing database", "err", err)
 		}
 	}()
 
 	// Run migrations
-	migrator := database.NewMigrator(db, migrationsFS)
+	migrator := database.NewMigrator(db, database.Migrations)
+	if *migrateStatus {
+		statuses, err := migrator.Status()
+		if err != nil {
+			fatalf("Failed to read migration status: %v", err)
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.Applied {
+				state = "applied"
+			}
+			fmt.Printf("%-8s %s\n", state, status.Filename)
+		}
+		return
+	}
+
 	if err := migrator.Run(); err != nil {
-		log.Fatalf("Failed to run migrations: %v", err)
+		fatalf("Failed to run migrations: %v", err)
+	}
+
+	// Fail fast if the schema still differs from what the code expects
+	if err := db.Validate(); err != nil {
+		fatalf("Database schema is out of date: %v", err)
 	}
 
 	// Create repository and handler
-	todoRepo := database.NewTodoRepository(db)
+	todoRepo, err := database.NewTodoRepository(db)
+	if err != nil {
+		fatalf("Failed to create todo repository: %v", err)
+	}
+	defer func() {
+		if err := todoRepo.Close(); err != nil {
+			slog.Error("Error closing todo repository", "err", err)
+		}
+	}()
+
+	// Apply the configured default sort; request parameters still override it
+	defaultSortBy := "created_at"
+	if v := os.Getenv("DEFAULT_SORT_BY"); v != "" {
+		column, ok := handlers.SortColumn(v)
+		if !ok {
+			fatalf("Invalid DEFAULT_SORT_BY %q", v)
+		}
+		defaultSortBy = column
+	}
+	defaultSortOrder := strings.ToLower(os.Getenv("DEFAULT_SORT_ORDER"))
+	if defaultSortOrder == "" {
+		defaultSortOrder = "desc"
+	}
+	if err := todoRepo.SetDefaultSort(defaultSortBy, defaultSortOrder); err != nil {
+		fatalf("Invalid default sort: %v", err)
+	}
+
+	// Reject duplicate titles only when asked to; some users want duplicates
+	uniqueTitles := false
+	if v := os.Getenv("UNIQUE_TITLES"); v != "" {
+		uniqueTitles, err = strconv.ParseBool(v)
+		if err != nil {
+			fatalf("Invalid UNIQUE_TITLES %q", v)
+		}
+	}
+	if err := todoRepo.SetUniqueTitles(context.Background(), uniqueTitles); err != nil {
+		fatalf("Failed to configure unique titles: %v", err)
+	}
+
+	// Slugs follow title changes unless old links must keep working
+	if v := os.Getenv("STABLE_SLUGS"); v != "" {
+		stableSlugs, err := strconv.ParseBool(v)
+		if err != nil {
+			fatalf("Invalid STABLE_SLUGS %q", v)
+		}
+		todoRepo.SetStableSlugs(stableSlugs)
+	}
+
+	// Enable the read cache when a size is configured
+	if sizeStr := os.Getenv("TODO_CACHE_SIZE"); sizeStr != "" {
+		size, err := strconv.Atoi(sizeStr)
+		if err != nil || size < 0 {
+			fatalf("Invalid TODO_CACHE_SIZE %q", sizeStr)
+		}
+
+		ttl := time.Minute
+		if ttlStr := os.Getenv("TODO_CACHE_TTL"); ttlStr != "" {
+			ttl, err = time.ParseDuration(ttlStr)
+			if err != nil || ttl < 0 {
+				fatalf("Invalid TODO_CACHE_TTL %q", ttlStr)
+			}
+		}
+
+		if size > 0 {
+			todoRepo.SetCache(database.NewTodoCache(size, ttl))
+			slog.Info("Todo cache enabled", "size", size, "ttl", ttl)
+		}
+	}
+
+	// Seed development data and exit when requested
+	if *seedCount > 0 {
+		created, err := seed.Run(context.Background(), todoRepo, *seedCount)
+		if err != nil {
+			fatalf("Failed to seed database: %v", err)
+		}
+		slog.Info("Seeded todos", "count", created)
+		return
+	}
+
+	// Preload the most recently updated todos so the first reads after a
+	// restart are served from the cache. The timeout bounds how long a slow
+	// database can hold up startup; on failure the cache fills as usual.
+	if v := os.Getenv("TODO_CACHE_WARM"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			fatalf("Invalid TODO_CACHE_WARM %q", v)
+		}
+
+		timeout := 5 * time.Second
+		if v := os.Getenv("TODO_CACHE_WARM_TIMEOUT"); v != "" {
+			timeout, err = time.ParseDuration(v)
+			if err != nil || timeout <= 0 {
+				fatalf("Invalid TODO_CACHE_WARM_TIMEOUT %q", v)
+			}
+		}
+
+		if n > 0 {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			start := time.Now()
+			warmed, err := todoRepo.WarmCache(ctx, n)
+			cancel()
+			if err != nil {
+				slog.Warn("Failed to warm todo cache", "err", err)
+			} else {
+				slog.Info("Warmed todo cache", "count", warmed, "duration", time.Since(start))
+			}
+		}
+	}
+
+	// Tombstones let sync clients learn about deletions, so keep them unless
+	// a retention period is configured
+	var purger *tombstonePurger
+	if v := os.Getenv("TOMBSTONE_RETENTION"); v != "" {
+		retention, err := time.ParseDuration(v)
+		if err != nil || retention < 0 {
+			fatalf("Invalid TOMBSTONE_RETENTION %q", v)
+		}
+
+		interval := time.Hour
+		if v := os.Getenv("TOMBSTONE_PURGE_INTERVAL"); v != "" {
+			interval, err = time.ParseDuration(v)
+			if err != nil || interval <= 0 {
+				fatalf("Invalid TOMBSTONE_PURGE_INTERVAL %q", v)
+			}
+		}
+
+		if retention > 0 {
+			purger = &tombstonePurger{repo: todoRepo, interval: interval, retention: retention}
+			slog.Info("Tombstone purge enabled", "retention", retention, "interval", interval)
+		}
+	}
+
+	attachmentRepo := database.NewAttachmentRepository(db)
+	commentRepo := database.NewCommentRepository(db)
+	dependencyRepo := database.NewDependencyRepository(db)
+
 	todoHandler := handlers.NewTodoHandler(todoRepo)
+	todoHandler.SetAttachmentRepository(attachmentRepo)
+	todoHandler.SetCommentRepository(commentRepo)
+	todoHandler.SetDependencyRepository(dependencyRepo)
+
+	// DELETE /api/todos wipes everything, so it must be switched on explicitly
+	if v := os.Getenv("ALLOW_DELETE_ALL"); v != "" {
+		allow, err := strconv.ParseBool(v)
+		if err != nil {
+			fatalf("Invalid ALLOW_DELETE_ALL %q", v)
+		}
+		todoHandler.SetAllowDeleteAll(allow)
+		if allow {
+			slog.Warn("DELETE /api/todos is enabled")
+		}
+	}
 
-	// Create router
-	mux := http.NewServeMux()
+	// Cap page sizes so a huge limit cannot exhaust memory
+	if v := os.Getenv("MAX_PAGE_SIZE"); v != "" {
+		size, err := strconv.Atoi(v)
+		if err != nil || size <= 0 {
+			fatalf("Invalid MAX_PAGE_SIZE %q", v)
+		}
+		todoHandler.SetMaxPageSize(size)
+	}
 
-	// Register routes
-	mux.HandleFunc("GET /api/todos", todoHandler.GetAllTodos)
-	mux.HandleFunc("GET /api/todos/{id}", todoHandler.GetTodo)
-	mux.HandleFunc("POST /api/todos", todoHandler.CreateTodo)
-	mux.HandleFunc("PATCH /api/todos/{id}", todoHandler.UpdateTodo)
-	mux.HandleFunc("DELETE /api/todos/{id}", todoHandler.DeleteTodo)
+	// Recording views costs a write per GET /api/todos/{id}, so it is opt-in
+	if v := os.Getenv("TRACK_ACCESS"); v != "" {
+		track, err := strconv.ParseBool(v)
+		if err != nil {
+			fatalf("Invalid TRACK_ACCESS %q", v)
+		}
+		todoHandler.SetTrackAccess(track)
+	}
 
-	// Health check endpoint
-	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		if _, err := w.Write([]byte("OK")); err != nil {
-			log.Printf("Error writing health check response: %v", err)
+	// Lists stay bare arrays by default for existing clients
+	if v := os.Getenv("RESPONSE_ENVELOPE"); v != "" {
+		envelope, err := strconv.ParseBool(v)
+		if err != nil {
+			fatalf("Invalid RESPONSE_ENVELOPE %q", v)
 		}
+		todoHandler.SetEnvelope(envelope)
+	}
+
+	// Sync clients older than the retention must start over
+	if purger != nil {
+		todoHandler.SetTombstoneRetention(purger.retention)
+	}
+
+	// Server-side defaults only fill fields a create request leaves empty
+	todoHandler.SetDefaults(models.TodoDefaults{
+		Description: os.Getenv("DEFAULT_DESCRIPTION"),
 	})
 
-	// Wrap with CORS middleware
-	handler := corsMiddleware(mux)
+	attachmentHandler := handlers.NewAttachmentHandler(attachmentRepo, todoRepo)
+	commentHandler := handlers.NewCommentHandler(commentRepo, todoRepo)
+	dependencyHandler := handlers.NewDependencyHandler(dependencyRepo, todoRepo)
+
+	// VACUUM locks the database while it runs, so it must be switched on explicitly
+	adminHandler := handlers.NewAdminHandler(db)
+	if v := os.Getenv("ALLOW_VACUUM"); v != "" {
+		allow, err := strconv.ParseBool(v)
+		if err != nil {
+			fatalf("Invalid ALLOW_VACUUM %q", v)
+		}
+		adminHandler.SetAllowVacuum(allow)
+	}
+
+	// The migration history reveals the schema version, so it is opt-in too
+	if v := os.Getenv("ALLOW_MIGRATIONS"); v != "" {
+		allow, err := strconv.ParseBool(v)
+		if err != nil {
+			fatalf("Invalid ALLOW_MIGRATIONS %q", v)
+		}
+		adminHandler.SetAllowMigrations(allow)
+	}
+
+	// Bound the health check's database ping so a stuck database fails the
+	// probe promptly instead of hanging it
+	healthHandler := handlers.NewHealthHandler(db)
+	if v := os.Getenv("HEALTH_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			fatalf("Invalid HEALTH_TIMEOUT %q", v)
+		}
+		healthHandler.SetTimeout(d)
+	}
+
+	versionHandler := handlers.NewVersionHandler(handlers.BuildInfo{
+		Version:   version,
+		Commit:    commit,
+		BuildTime: buildTime,
+	})
+
+	// Cursors are signed with a random key unless one is configured, so
+	// restarts and other instances need the secret to accept them
+	if v := os.Getenv("CURSOR_SECRET"); v != "" {
+		todoHandler.SetCursorKey([]byte(v))
+	} else {
+		slog.Warn("CURSOR_SECRET is not set; pagination cursors will not survive a restart or work across instances")
+	}
+
+	// Create router, optionally mounted under a base path
+	basePath, err := normalizeBasePath(os.Getenv("BASE_PATH"))
+	if err != nil {
+		fatalf("Invalid BASE_PATH: %v", err)
+	}
+	mux := newRouter(basePath, timeouts.request, todoHandler, attachmentHandler, commentHandler, dependencyHandler, adminHandler, healthHandler, versionHandler)
+
+	// A read-only server serves lists and lookups but rejects every write
+	readOnly := false
+	if v := os.Getenv("READ_ONLY"); v != "" {
+		readOnly, err = strconv.ParseBool(v)
+		if err != nil {
+			fatalf("Invalid READ_ONLY %q", v)
+		}
+		if readOnly {
+			slog.Info("Read-only mode enabled")
+		}
+	}
+
+	// Bound in-flight requests so a burst is turned away instead of queueing
+	// behind the database
+	maxConcurrent := 0
+	if v := os.Getenv("MAX_CONCURRENT"); v != "" {
+		maxConcurrent, err = strconv.Atoi(v)
+		if err != nil || maxConcurrent < 0 {
+			fatalf("Invalid MAX_CONCURRENT %q", v)
+		}
+	}
+
+	snakeCase := false
+	switch v := os.Getenv("JSON_NAMING"); v {
+	case "", "camel":
+	case "snake":
+		snakeCase = true
+	default:
+		fatalf("Invalid JSON_NAMING %q", v)
+	}
+
+	// Wrap with middleware
+	handler := withMiddleware(mux, middlewareConfig{
+		basePath:      basePath,
+		readOnly:      readOnly,
+		maxConcurrent: maxConcurrent,
+		snakeCase:     snakeCase,
+	})
 
 	// Start server
 	port := os.Getenv("PORT")
@@ -95,14 +523,50 @@ func main() {
 	server := &http.Server{
 		Addr:              ":" + port,
 		Handler:           handler,
-		ReadTimeout:       15 * time.Second,
-		WriteTimeout:      15 * time.Second,
-		IdleTimeout:       60 * time.Second,
-		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       timeouts.read,
+		WriteTimeout:      timeouts.write,
+		IdleTimeout:       timeouts.idle,
+		ReadHeaderTimeout: timeouts.readHeader,
+	}
+
+	// Shut down gracefully on interrupt so deferred cleanup runs
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Stop the purge job before the deferred database close runs
+	var purgeDone chan struct{}
+	if purger != nil {
+		purgeDone = make(chan struct{})
+		go func() {
+			defer close(purgeDone)
+			purger.run(ctx)
+		}()
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("Server starting", "port", port)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Server failed to start", "err", err)
+			exitCode = 1
+		}
+	case <-ctx.Done():
+		slog.Info("Shutting down server")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			slog.Error("Error shutting down server", "err", err)
+		}
 	}
 
-	log.Printf("Server starting on port %s", port)
-	if err := server.ListenAndServe(); err != nil {
-		log.Fatalf("Server failed to start: %v", err)
+	// ctx is still live if the server failed on its own, so cancel it first
+	if purgeDone != nil {
+		stop()
+		<-purgeDone
 	}
 }
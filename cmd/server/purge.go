@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/larryhudson/go-todo-list-claude/internal/database"
+)
+
+// tombstonePurger removes deletion tombstones once they are older than
+// retention, so the sync log does not grow forever
+type tombstonePurger struct {
+	repo      *database.TodoRepository
+	interval  time.Duration
+	retention time.Duration
+}
+
+// purge removes the tombstones that have outlived the retention period
+func (p *tombstonePurger) purge(ctx context.Context) {
+	purged, err := p.repo.PurgeTombstones(ctx, time.Now().Add(-p.retention))
+	if err != nil {
+		slog.Error("Failed to purge tombstones", "err", err)
+		return
+	}
+	if purged > 0 {
+		slog.Info("Purged tombstones", "count", purged)
+	}
+}
+
+// run purges immediately and then every interval until ctx is canceled
+func (p *tombstonePurger) run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.purge(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/larryhudson/go-todo-list-claude/internal/handlers"
+)
+
+// apiPrefix is the path under which the API routes are registered, after
+// any configured base path
+const apiPrefix = "/api"
+
+// healthPath is the health check route. It is served at the root as well as
+// under the base path, so probes can reach the service directly.
+const healthPath = "/health"
+
+// versionPath reports the running build. Like the health check it is served
+// at the root as well as under the base path.
+const versionPath = "/version"
+
+// normalizeBasePath validates a BASE_PATH value, returning it without a
+// trailing slash. An empty value means routes are served from the root.
+func normalizeBasePath(basePath string) (string, error) {
+	basePath = strings.TrimRight(basePath, "/")
+	if basePath == "" {
+		return "", nil
+	}
+	if !strings.HasPrefix(basePath, "/") {
+		return "", fmt.Errorf("base path %q must start with /", basePath)
+	}
+	if strings.ContainsAny(basePath, "{} ") {
+		return "", fmt.Errorf("base path %q must not contain braces or spaces", basePath)
+	}
+	return basePath, nil
+}
+
+// getOrHead dispatches HEAD requests to head and the rest to get. A GET
+// pattern also matches HEAD, and a separate "HEAD /api/todos/{id}" pattern
+// would conflict with more specific GET routes such as /api/todos/count.
+func getOrHead(get, head http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			head(w, r)
+			return
+		}
+		get(w, r)
+	}
+}
+
+// newRouter registers every route under basePath, which must already be
+// normalized. API requests taking longer than requestTimeout are answered
+// with 503, except for streaming and maintenance routes; 0 disables the
+// limit. Unknown paths get a JSON 404, and methods a path does not support
+// a JSON 405.
+func newRouter(basePath string, requestTimeout time.Duration, todoHandler *handlers.TodoHandler, attachmentHandler *handlers.AttachmentHandler, commentHandler *handlers.CommentHandler, dependencyHandler *handlers.DependencyHandler, adminHandler *handlers.AdminHandler, healthHandler *handlers.HealthHandler, versionHandler *handlers.VersionHandler) http.Handler {
+	mux := http.NewServeMux()
+
+	api := basePath + apiPrefix
+	route := func(method, path string, handler http.HandlerFunc) {
+		mux.Handle(method+" "+api+path, handlers.Timeout(handler, requestTimeout))
+	}
+	// untimedRoute is for responses that stream and would be held back, or
+	// cut short, by the timeout's buffering. Their database reads are still
+	// bounded by the query timeout.
+	untimedRoute := func(method, path string, handler http.HandlerFunc) {
+		mux.HandleFunc(method+" "+api+path, handler)
+	}
+
+	// Register routes
+	untimedRoute("GET", "/todos", todoHandler.GetAllTodos)
+	route("GET", "/todos/count", todoHandler.CountTodos)
+	route("GET", "/todos/focused", todoHandler.GetFocusedTodo)
+	untimedRoute("GET", "/todos/drafts", todoHandler.GetDrafts)
+	route("GET", "/todos/random", todoHandler.GetRandomTodo)
+	route("GET", "/todos/recent", todoHandler.GetRecent)
+	route("GET", "/todos/reminders", todoHandler.GetReminders)
+	route("GET", "/todos/schedule", todoHandler.GetSchedule)
+	route("GET", "/todos/sync", todoHandler.SyncTodos)
+	untimedRoute("GET", "/todos/export", todoHandler.ExportTodos)
+	route("GET", "/todos/{id}", getOrHead(todoHandler.GetTodo, todoHandler.HeadTodo))
+	route("POST", "/todos", todoHandler.CreateTodo)
+	route("POST", "/todos/batch", todoHandler.BatchCreateTodos)
+	route("PATCH", "/todos/batch", todoHandler.BatchUpdateTodos)
+	route("POST", "/todos/batch-get", todoHandler.BatchGetTodos)
+	route("PATCH", "/todos/bulk", todoHandler.BulkUpdateTodos)
+	route("POST", "/todos/bulk-toggle", todoHandler.BulkToggleTodos)
+	route("POST", "/todos/complete-matching", todoHandler.CompleteMatchingTodos)
+	route("PATCH", "/todos/{id}", todoHandler.UpdateTodo)
+	route("PUT", "/todos/by-external/{externalId}", todoHandler.UpsertTodo)
+	route("DELETE", "/todos", todoHandler.DeleteAllTodos)
+	route("DELETE", "/todos/{id}", todoHandler.DeleteTodo)
+	route("POST", "/todos/{id}/complete", todoHandler.CompleteTodo)
+	route("POST", "/todos/{id}/incomplete", todoHandler.IncompleteTodo)
+	route("POST", "/todos/{id}/move", todoHandler.MoveTodo)
+	route("POST", "/todos/{id}/publish", todoHandler.PublishTodo)
+	route("POST", "/todos/{id}/snooze", todoHandler.SnoozeTodo)
+	route("POST", "/todos/{id}/focus", todoHandler.FocusTodo)
+	route("DELETE", "/todos/{id}/focus", todoHandler.UnfocusTodo)
+	route("GET", "/todos/{id}/attachments", attachmentHandler.ListAttachments)
+	route("POST", "/todos/{id}/attachments", attachmentHandler.CreateAttachment)
+	route("DELETE", "/attachments/{id}", attachmentHandler.DeleteAttachment)
+	route("GET", "/todos/{id}/comments", commentHandler.ListComments)
+	route("POST", "/todos/{id}/comments", commentHandler.CreateComment)
+	route("DELETE", "/comments/{id}", commentHandler.DeleteComment)
+	route("GET", "/todos/{id}/dependencies", dependencyHandler.ListDependencies)
+	route("POST", "/todos/{id}/dependencies", dependencyHandler.AddDependency)
+	route("DELETE", "/todos/{id}/dependencies/{dependsOn}", dependencyHandler.RemoveDependency)
+
+	// Maintenance endpoints live outside the API prefix and may run for
+	// longer than any request timeout
+	mux.HandleFunc("POST "+basePath+"/admin/vacuum", adminHandler.Vacuum)
+	mux.HandleFunc("GET "+basePath+"/admin/migrations", adminHandler.Migrations)
+
+	// Health check endpoint. The database ping has its own short timeout.
+	mux.HandleFunc("GET "+healthPath, healthHandler.Health)
+	mux.HandleFunc("GET "+versionPath, versionHandler.GetVersion)
+	if basePath != "" {
+		mux.HandleFunc("GET "+basePath+healthPath, healthHandler.Health)
+		mux.HandleFunc("GET "+basePath+versionPath, versionHandler.GetVersion)
+	}
+
+	return handlers.RouteErrors(mux)
+}
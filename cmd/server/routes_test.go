@@ -0,0 +1,309 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/larryhudson/go-todo-list-claude/internal/database"
+	"github.com/larryhudson/go-todo-list-claude/internal/handlers"
+)
+
+// setupRouter builds the full router over an in-memory database
+func setupRouter(t *testing.T, basePath string) http.Handler {
+	t.Helper()
+
+	db, err := database.New(":memory:", database.DefaultConfig())
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	if err := db.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize test database: %v", err)
+	}
+	repo, err := database.NewTodoRepository(db)
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := repo.Close(); err != nil {
+			t.Errorf("Failed to close repository: %v", err)
+		}
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	})
+
+	attachments := database.NewAttachmentRepository(db)
+	comments := database.NewCommentRepository(db)
+	deps := database.NewDependencyRepository(db)
+
+	return newRouter(basePath, 0,
+		handlers.NewTodoHandler(repo),
+		handlers.NewAttachmentHandler(attachments, repo),
+		handlers.NewCommentHandler(comments, repo),
+		handlers.NewDependencyHandler(deps, repo),
+		handlers.NewAdminHandler(db),
+		handlers.NewHealthHandler(db),
+		handlers.NewVersionHandler(handlers.BuildInfo{Version: "test"}),
+	)
+}
+
+// TestNewRouter builds the router, which panics if any two patterns
+// conflict, and checks that routes sharing a prefix reach the right handler
+func TestNewRouter(t *testing.T) {
+	router := setupRouter(t, "")
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"POST", "/api/todos", `{"title":"Routed"}`, http.StatusCreated},
+		{"GET", "/api/todos/count", "", http.StatusOK},
+		{"GET", "/api/todos/1", "", http.StatusOK},
+		{"HEAD", "/api/todos/1", "", http.StatusOK},
+		{"HEAD", "/api/todos/2", "", http.StatusNotFound},
+		{"GET", "/health", "", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		if tt.body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		if w.Code != tt.status {
+			t.Errorf("%s %s: expected status %d, got %d", tt.method, tt.path, tt.status, w.Code)
+		}
+	}
+}
+
+func TestRouter_BasePath(t *testing.T) {
+	router := setupRouter(t, "/todos")
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"POST", "/todos/api/todos", `{"title":"Mounted"}`, http.StatusCreated},
+		{"GET", "/todos/api/todos/1", "", http.StatusOK},
+		{"HEAD", "/todos/api/todos/1", "", http.StatusOK},
+		{"HEAD", "/todos/api/todos/2", "", http.StatusNotFound},
+		{"GET", "/todos/api/todos/count", "", http.StatusOK},
+		{"GET", "/todos/health", "", http.StatusOK},
+		{"GET", "/health", "", http.StatusOK},
+		{"GET", "/todos/version", "", http.StatusOK},
+		{"GET", "/version", "", http.StatusOK},
+		{"GET", "/api/todos", "", http.StatusNotFound},
+		{"POST", "/todos/admin/vacuum", "", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		if tt.body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		if w.Code != tt.status {
+			t.Errorf("%s %s: expected status %d, got %d", tt.method, tt.path, tt.status, w.Code)
+		}
+	}
+}
+
+func TestNormalizeBasePath(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"", "", false},
+		{"/", "", false},
+		{"/todos", "/todos", false},
+		{"/todos/", "/todos", false},
+		{"/svc/todos", "/svc/todos", false},
+		{"todos", "", true},
+		{"/{id}", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := normalizeBasePath(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("normalizeBasePath(%q) = %q, %v; want %q, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestRouter_NoBasePath(t *testing.T) {
+	router := setupRouter(t, "")
+
+	for _, path := range []string{"/api/todos", "/api/todos/count", "/health"} {
+		req := httptest.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("GET %s: expected status 200, got %d", path, w.Code)
+		}
+	}
+}
+
+func TestRouter_MethodNotAllowed(t *testing.T) {
+	router := setupRouter(t, "")
+
+	tests := []struct {
+		method string
+		path   string
+		allow  []string
+	}{
+		{"PUT", "/api/todos", []string{"GET", "POST", "DELETE"}},
+		{"POST", "/api/todos/1", []string{"GET", "PATCH", "DELETE"}},
+		{"DELETE", "/health", []string{"GET"}},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("%s %s: expected status 405, got %d", tt.method, tt.path, w.Code)
+			continue
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%s %s: expected JSON content type, got %q", tt.method, tt.path, ct)
+		}
+		var resp handlers.ErrorResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Errorf("%s %s: failed to decode response: %v", tt.method, tt.path, err)
+		} else if resp.Error != "Method not allowed" {
+			t.Errorf("%s %s: expected error %q, got %q", tt.method, tt.path, "Method not allowed", resp.Error)
+		}
+		allow := w.Header().Get("Allow")
+		for _, method := range tt.allow {
+			if !strings.Contains(allow, method) {
+				t.Errorf("%s %s: expected Allow to include %s, got %q", tt.method, tt.path, method, allow)
+			}
+		}
+	}
+}
+
+func TestRouter_NotFound(t *testing.T) {
+	router := setupRouter(t, "/todos")
+
+	tests := []struct {
+		path    string
+		status  int
+		message string
+	}{
+		{"/todos/api/todoss", http.StatusNotFound, "Not found"},
+		{"/api/todos", http.StatusNotFound, "Not found"},
+		{"/todos/api/todos/99", http.StatusNotFound, "Todo not found"},
+		{"/todos/api/todos", http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		if w.Code != tt.status {
+			t.Errorf("GET %s: expected status %d, got %d", tt.path, tt.status, w.Code)
+			continue
+		}
+		if tt.message == "" {
+			continue
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("GET %s: expected JSON content type, got %q", tt.path, ct)
+		}
+		var resp handlers.ErrorResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Errorf("GET %s: failed to decode response: %v", tt.path, err)
+		} else if resp.Error != tt.message {
+			t.Errorf("GET %s: expected error %q, got %q", tt.path, tt.message, resp.Error)
+		}
+	}
+}
+
+func TestWithMiddleware_ReadOnly(t *testing.T) {
+	tests := []struct {
+		method   string
+		path     string
+		body     string
+		readOnly bool
+		status   int
+	}{
+		{"POST", "/api/todos", `{"title":"Writable"}`, false, http.StatusCreated},
+		{"GET", "/api/todos", "", true, http.StatusOK},
+		{"HEAD", "/api/todos/1", "", true, http.StatusOK},
+		{"PATCH", "/api/todos/batch", `[{"id":1,"starred":true}]`, false, http.StatusOK},
+		{"GET", "/health", "", true, http.StatusOK},
+		{"OPTIONS", "/api/todos", "", true, http.StatusOK},
+		{"POST", "/api/todos", `{"title":"Blocked"}`, true, http.StatusForbidden},
+		{"POST", "/api/todos/batch", `{"todos":[{"title":"Blocked"}]}`, true, http.StatusForbidden},
+		{"PATCH", "/api/todos/1", `{"completed":true}`, true, http.StatusForbidden},
+		{"DELETE", "/api/todos/1", "", true, http.StatusForbidden},
+		{"POST", "/admin/vacuum", "", true, http.StatusForbidden},
+	}
+
+	router := setupRouter(t, "")
+	for _, tt := range tests {
+		handler := withMiddleware(router, middlewareConfig{readOnly: tt.readOnly})
+
+		req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		if tt.body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		if w.Code != tt.status {
+			t.Errorf("%s %s (read-only %v): expected status %d, got %d", tt.method, tt.path, tt.readOnly, tt.status, w.Code)
+		}
+		if w.Code == http.StatusForbidden && !strings.Contains(w.Body.String(), "read-only") {
+			t.Errorf("%s %s: expected a read-only error, got %s", tt.method, tt.path, w.Body.String())
+		}
+	}
+}
+
+func TestRouter_SnakeCaseJSON(t *testing.T) {
+	handler := withMiddleware(setupRouter(t, ""), middlewareConfig{snakeCase: true})
+
+	req := httptest.NewRequest("POST", "/api/todos", strings.NewReader(`{"title":"Call dentist","remind_at":"2030-01-02T09:30:00Z"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// Through the request timeout and ?pretty=true as well
+	req = httptest.NewRequest("GET", "/api/todos/1?pretty=true", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	body := w.Body.String()
+	for _, want := range []string{`"public_id": "`, `"remind_at": "2030-01-02T09:30:00Z"`, `"created_at": "`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected %s in %s", want, body)
+		}
+	}
+	if strings.Contains(body, "createdAt") {
+		t.Errorf("Expected no camelCase keys, got %s", body)
+	}
+}
@@ -0,0 +1,114 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/larryhudson/go-todo-list-claude/internal/models"
+)
+
+// attachmentColumns lists the columns read by scanAttachment, in order
+const attachmentColumns = "id, todo_id, name, url, content_type, created_at"
+
+// scanAttachment scans a row selected with attachmentColumns into a
+func scanAttachment(row rowScanner, a *models.Attachment) error {
+	return row.Scan(
+		&a.ID,
+		&a.TodoID,
+		&a.Name,
+		&a.URL,
+		&a.ContentType,
+		&a.CreatedAt,
+	)
+}
+
+// AttachmentRepository handles database operations for attachments
+type AttachmentRepository struct {
+	db *DB
+}
+
+// NewAttachmentRepository creates a new AttachmentRepository
+func NewAttachmentRepository(db *DB) *AttachmentRepository {
+	return &AttachmentRepository{db: db}
+}
+
+// Create adds an attachment to a todo
+func (r *AttachmentRepository) Create(ctx context.Context, todoID int64, req models.CreateAttachmentRequest) (*models.Attachment, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		INSERT INTO attachments (todo_id, name, url, content_type, created_at)
+		VALUES (?, ?, ?, ?, ?)
+		RETURNING ` + attachmentColumns
+
+	var attachment models.Attachment
+	err := scanAttachment(r.db.QueryRowContext(ctx, query, todoID, req.Name, req.URL, req.ContentType, utcNow()), &attachment)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create attachment: %w", mapConstraintError(err))
+	}
+
+	return &attachment, nil
+}
+
+// ListByTodo returns a todo's attachments, oldest first
+func (r *AttachmentRepository) ListByTodo(ctx context.Context, todoID int64) ([]models.Attachment, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT ` + attachmentColumns + `
+		FROM attachments
+		WHERE todo_id = ?
+		ORDER BY created_at ASC, id ASC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, todoID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query attachments: %w", err)
+	}
+
+	var attachments []models.Attachment
+	for rows.Next() {
+		var attachment models.Attachment
+		if err := scanAttachment(rows, &attachment); err != nil {
+			return nil, fmt.Errorf("failed to scan attachment: %w", err)
+		}
+		attachments = append(attachments, attachment)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating attachments: %w", err)
+	}
+
+	// Check for errors from closing rows
+	if err = rows.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close rows: %w", err)
+	}
+
+	return attachments, nil
+}
+
+// Delete deletes an attachment by ID. It returns sql.ErrNoRows if the
+// attachment does not exist.
+func (r *AttachmentRepository) Delete(ctx context.Context, id int64) error {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, "DELETE FROM attachments WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete attachment: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
@@ -0,0 +1,144 @@
+package database
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/larryhudson/go-todo-list-claude/internal/models"
+)
+
+// TodoCache is a concurrency-safe LRU cache of todos keyed by id. Entries
+// expire after a fixed TTL. Readers that fill the cache from the database
+// use Generation and PutIfCurrent, so a read that raced an update cannot
+// leave the old row behind, even when entries never expire.
+type TodoCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	order   *list.List
+	entries map[int64]*list.Element
+	// generation counts invalidations, so a fill can tell whether one
+	// happened while it read from the database
+	generation uint64
+}
+
+// cacheEntry is a cached todo and its expiry time
+type cacheEntry struct {
+	todo      models.Todo
+	expiresAt time.Time
+}
+
+// NewTodoCache creates a cache holding at most size todos for up to ttl
+// each (a ttl of 0 means entries never expire)
+func NewTodoCache(size int, ttl time.Duration) *TodoCache {
+	return &TodoCache{
+		size:    size,
+		ttl:     ttl,
+		order:   list.New(),
+		entries: make(map[int64]*list.Element),
+	}
+}
+
+// Get returns a copy of the cached todo, if present and not expired
+func (c *TodoCache) Get(id int64) (*models.Todo, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[id]
+	if !ok {
+		return nil, false
+	}
+
+	entry := elem.Value.(*cacheEntry)
+	if c.ttl > 0 && time.Now().After(entry.expiresAt) {
+		c.order.Remove(elem)
+		delete(c.entries, id)
+		return nil, false
+	}
+
+	c.order.MoveToFront(elem)
+	todo := entry.todo
+	return &todo, true
+}
+
+// Put stores a copy of todo, evicting the least recently used entry when full
+func (c *TodoCache) Put(todo models.Todo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.put(todo)
+}
+
+// Generation returns a token to read before loading todos from the
+// database, to pass to PutIfCurrent once they are loaded
+func (c *TodoCache) Generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.generation
+}
+
+// PutIfCurrent stores a copy of todo like Put, unless the cache has been
+// invalidated or cleared since generation was read, in which case todo may
+// predate the write that invalidated it and is dropped. It reports whether
+// todo was stored.
+func (c *TodoCache) PutIfCurrent(todo models.Todo, generation uint64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return false
+	}
+	c.put(todo)
+	return true
+}
+
+// put stores todo; the caller holds c.mu
+func (c *TodoCache) put(todo models.Todo) {
+	entry := &cacheEntry{todo: todo, expiresAt: time.Now().Add(c.ttl)}
+
+	if elem, ok := c.entries[todo.ID]; ok {
+		elem.Value = entry
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[todo.ID] = c.order.PushFront(entry)
+
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).todo.ID)
+	}
+}
+
+// Invalidate removes a todo from the cache
+func (c *TodoCache) Invalidate(id int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	if elem, ok := c.entries[id]; ok {
+		c.order.Remove(elem)
+		delete(c.entries, id)
+	}
+}
+
+// Clear removes every todo from the cache
+func (c *TodoCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	c.order.Init()
+	clear(c.entries)
+}
+
+// Len returns the number of cached todos
+func (c *TodoCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.order.Len()
+}
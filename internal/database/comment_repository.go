@@ -0,0 +1,126 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/larryhudson/go-todo-list-claude/internal/models"
+)
+
+// commentColumns lists the columns read by scanComment, in order
+const commentColumns = "id, todo_id, body, created_at"
+
+// scanComment scans a row selected with commentColumns into c
+func scanComment(row rowScanner, c *models.Comment) error {
+	return row.Scan(
+		&c.ID,
+		&c.TodoID,
+		&c.Body,
+		&c.CreatedAt,
+	)
+}
+
+// CommentRepository handles database operations for comments
+type CommentRepository struct {
+	db *DB
+}
+
+// NewCommentRepository creates a new CommentRepository
+func NewCommentRepository(db *DB) *CommentRepository {
+	return &CommentRepository{db: db}
+}
+
+// Create adds a comment to a todo
+func (r *CommentRepository) Create(ctx context.Context, todoID int64, req models.CreateCommentRequest) (*models.Comment, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		INSERT INTO comments (todo_id, body, created_at)
+		VALUES (?, ?, ?)
+		RETURNING ` + commentColumns
+
+	var comment models.Comment
+	err := scanComment(r.db.QueryRowContext(ctx, query, todoID, req.Body, utcNow()), &comment)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create comment: %w", mapConstraintError(err))
+	}
+
+	return &comment, nil
+}
+
+// ListByTodo returns a todo's comments, newest first
+func (r *CommentRepository) ListByTodo(ctx context.Context, todoID int64) ([]models.Comment, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT ` + commentColumns + `
+		FROM comments
+		WHERE todo_id = ?
+		ORDER BY created_at DESC, id DESC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, todoID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query comments: %w", err)
+	}
+
+	var comments []models.Comment
+	for rows.Next() {
+		var comment models.Comment
+		if err := scanComment(rows, &comment); err != nil {
+			return nil, fmt.Errorf("failed to scan comment: %w", err)
+		}
+		comments = append(comments, comment)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating comments: %w", err)
+	}
+
+	// Check for errors from closing rows
+	if err = rows.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close rows: %w", err)
+	}
+
+	return comments, nil
+}
+
+// CountByTodo returns the number of comments on a todo
+func (r *CommentRepository) CountByTodo(ctx context.Context, todoID int64) (int64, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	var count int64
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM comments WHERE todo_id = ?", todoID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count comments: %w", err)
+	}
+
+	return count, nil
+}
+
+// Delete deletes a comment by ID. It returns sql.ErrNoRows if the comment
+// does not exist.
+func (r *CommentRepository) Delete(ctx context.Context, id int64) error {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, "DELETE FROM comments WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete comment: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
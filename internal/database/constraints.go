@@ -0,0 +1,120 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// Length limits enforced by the todos table's CHECK constraints
+const (
+	MaxTitleLength       = 200
+	MaxDescriptionLength = 5000
+)
+
+// ConstraintKind identifies the kind of schema constraint a write violated
+type ConstraintKind int
+
+const (
+	ConstraintOther ConstraintKind = iota
+	ConstraintCheck
+	ConstraintNotNull
+	ConstraintUnique
+	ConstraintForeignKey
+)
+
+// ConstraintError reports a write the schema rejected, such as an oversized
+// title. Its message describes the violated rule without exposing SQL and is
+// safe to show clients.
+type ConstraintError struct {
+	Kind    ConstraintKind
+	Message string
+}
+
+func (e *ConstraintError) Error() string {
+	return e.Message
+}
+
+// constraintMessages describes the named CHECK constraints in the schema
+var constraintMessages = map[string]string{
+	"todos_title_length":       fmt.Sprintf("title must be at most %d characters", MaxTitleLength),
+	"todos_description_length": fmt.Sprintf("description must be at most %d characters", MaxDescriptionLength),
+	"todos_estimate_minutes":   "estimateMinutes must not be negative",
+}
+
+// uniqueMessages describes unique columns whose conflicts deserve a more
+// specific message than the generic one
+var uniqueMessages = map[string]string{
+	"todos.title": "a todo with this title already exists",
+}
+
+// AsConstraintError reports whether err is, or wraps, a constraint
+// violation, translating SQLite errors that have not been mapped yet
+func AsConstraintError(err error) (*ConstraintError, bool) {
+	var constraintErr *ConstraintError
+	if errors.As(err, &constraintErr) {
+		return constraintErr, true
+	}
+	if errors.As(mapConstraintError(err), &constraintErr) {
+		return constraintErr, true
+	}
+	return nil, false
+}
+
+// mapConstraintError translates a SQLite constraint violation into a
+// *ConstraintError, leaving any other error unchanged
+func mapConstraintError(err error) error {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) || sqliteErr.Code != sqlite3.ErrConstraint {
+		return err
+	}
+
+	// SQLite reports the violated constraint after a colon, e.g.
+	// "NOT NULL constraint failed: todos.title"
+	_, detail, _ := strings.Cut(sqliteErr.Error(), ": ")
+
+	switch sqliteErr.ExtendedCode {
+	case sqlite3.ErrConstraintCheck:
+		if msg, ok := constraintMessages[detail]; ok {
+			return &ConstraintError{Kind: ConstraintCheck, Message: msg}
+		}
+		return &ConstraintError{Kind: ConstraintCheck, Message: "a value is not allowed"}
+	case sqlite3.ErrConstraintNotNull:
+		return &ConstraintError{Kind: ConstraintNotNull, Message: columnNames(detail) + " is required"}
+	case sqlite3.ErrConstraintUnique, sqlite3.ErrConstraintPrimaryKey:
+		if msg, ok := uniqueMessages[detail]; ok {
+			return &ConstraintError{Kind: ConstraintUnique, Message: msg}
+		}
+		return &ConstraintError{Kind: ConstraintUnique, Message: columnNames(detail) + " must be unique"}
+	case sqlite3.ErrConstraintForeignKey:
+		return &ConstraintError{Kind: ConstraintForeignKey, Message: "a referenced record does not exist"}
+	default:
+		return &ConstraintError{Kind: ConstraintOther, Message: "constraint violation"}
+	}
+}
+
+// columnNames strips table names from a list such as "todos.a, todos.b"
+func columnNames(detail string) string {
+	columns := strings.Split(detail, ", ")
+	for i, column := range columns {
+		if _, name, ok := strings.Cut(column, "."); ok {
+			columns[i] = name
+		}
+	}
+	return strings.Join(columns, ", ")
+}
+
+// checkLengths applies the todos table's length constraints in Go, for
+// stores that do not have the schema to enforce them
+func checkLengths(title, description string) error {
+	if utf8.RuneCountInString(title) > MaxTitleLength {
+		return &ConstraintError{Kind: ConstraintCheck, Message: constraintMessages["todos_title_length"]}
+	}
+	if utf8.RuneCountInString(description) > MaxDescriptionLength {
+		return &ConstraintError{Kind: ConstraintCheck, Message: constraintMessages["todos_description_length"]}
+	}
+	return nil
+}
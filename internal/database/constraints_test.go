@@ -0,0 +1,266 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/larryhudson/go-todo-list-claude/internal/models"
+)
+
+func TestCreate_OversizedTitleIsConstraintError(t *testing.T) {
+	for _, s := range stores {
+		t.Run(s.name, func(t *testing.T) {
+			store := s.new(t)
+			ctx := context.Background()
+
+			_, err := store.Create(ctx, models.CreateTodoRequest{Title: strings.Repeat("a", MaxTitleLength+1)})
+			var constraintErr *ConstraintError
+			if !errors.As(err, &constraintErr) {
+				t.Fatalf("Expected a ConstraintError, got %v", err)
+			}
+			if constraintErr.Message != "title must be at most 200 characters" {
+				t.Errorf("Unexpected message %q", constraintErr.Message)
+			}
+
+			// Limits count characters, not bytes
+			todo, err := store.Create(ctx, models.CreateTodoRequest{Title: strings.Repeat("é", MaxTitleLength)})
+			if err != nil {
+				t.Fatalf("Expected a title at the limit to be accepted, got %v", err)
+			}
+
+			description := strings.Repeat("a", MaxDescriptionLength+1)
+			_, err = store.Update(ctx, todo.ID, models.UpdateTodoRequest{Description: &description})
+			if !errors.As(err, &constraintErr) || constraintErr.Message != "description must be at most 5000 characters" {
+				t.Errorf("Expected a description ConstraintError, got %v", err)
+			}
+		})
+	}
+}
+
+func TestLengthConstraints_DirectInsert(t *testing.T) {
+	repo := setupTestRepo(t)
+
+	_, err := repo.db.ExecContext(context.Background(),
+		"INSERT INTO todos (title) VALUES (?)", strings.Repeat("a", MaxTitleLength+1))
+	var constraintErr *ConstraintError
+	if !errors.As(mapConstraintError(err), &constraintErr) {
+		t.Fatalf("Expected the CHECK constraint to reject the insert, got %v", err)
+	}
+}
+
+// migrationsBefore returns the migrations whose filenames sort before
+// prefix, to set up a database as it was before a later migration
+func migrationsBefore(t *testing.T, prefix string) fstest.MapFS {
+	t.Helper()
+
+	before := fstest.MapFS{}
+	files, err := fs.Glob(Migrations, "migrations/*.sql")
+	if err != nil {
+		t.Fatalf("Failed to list migrations: %v", err)
+	}
+	for _, file := range files {
+		if path.Base(file) >= prefix {
+			continue
+		}
+		data, err := fs.ReadFile(Migrations, file)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+		before[file] = &fstest.MapFile{Data: data}
+	}
+	return before
+}
+
+func TestLengthConstraintsMigration_PreservesData(t *testing.T) {
+	db, err := New(":memory:", DefaultConfig())
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	// Apply every migration before the constraints
+	if err := NewMigrator(db, migrationsBefore(t, "008")).Run(); err != nil {
+		t.Fatalf("Failed to run earlier migrations: %v", err)
+	}
+
+	ctx := context.Background()
+	setup := []string{
+		"INSERT INTO todos (title, description) VALUES ('Keep', 'kept')",
+		"INSERT INTO todos (title) VALUES ('Deleted')",
+		"INSERT INTO todos (title) VALUES ('" + strings.Repeat("a", MaxTitleLength+10) + "')",
+		"DELETE FROM todos WHERE title = 'Deleted'",
+		"INSERT INTO attachments (todo_id, name, url) VALUES (1, 'a.txt', 'https://example.com/a.txt')",
+	}
+	for _, stmt := range setup {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			t.Fatalf("Failed to execute %q: %v", stmt, err)
+		}
+	}
+
+	if err := NewMigrator(db, Migrations).Run(); err != nil {
+		t.Fatalf("Failed to run migrations: %v", err)
+	}
+
+	var attachments int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM attachments").Scan(&attachments); err != nil {
+		t.Fatalf("Failed to count attachments: %v", err)
+	}
+	if attachments != 1 {
+		t.Errorf("Expected attachments to survive the rebuild, got %d", attachments)
+	}
+
+	var titleLength int
+	if err := db.QueryRowContext(ctx, "SELECT length(title) FROM todos WHERE id = 3").Scan(&titleLength); err != nil {
+		t.Fatalf("Failed to read truncated todo: %v", err)
+	}
+	if titleLength != MaxTitleLength {
+		t.Errorf("Expected the oversized title to be truncated to %d, got %d", MaxTitleLength, titleLength)
+	}
+
+	// Ids are not reused and deletions are still recorded
+	var id int64
+	if err := db.QueryRowContext(ctx, "INSERT INTO todos (title) VALUES ('New') RETURNING id").Scan(&id); err != nil {
+		t.Fatalf("Failed to insert todo: %v", err)
+	}
+	if id != 4 {
+		t.Errorf("Expected the next id to be 4, got %d", id)
+	}
+
+	if _, err := db.ExecContext(ctx, "DELETE FROM todos WHERE id = 1"); err != nil {
+		t.Fatalf("Failed to delete todo: %v", err)
+	}
+	var tombstones, orphans int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM deleted_todos WHERE id = 1").Scan(&tombstones); err != nil {
+		t.Fatalf("Failed to count tombstones: %v", err)
+	}
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM attachments").Scan(&orphans); err != nil {
+		t.Fatalf("Failed to count attachments: %v", err)
+	}
+	if tombstones != 1 || orphans != 0 {
+		t.Errorf("Expected a tombstone and cascaded attachment delete, got %d tombstones and %d attachments", tombstones, orphans)
+	}
+}
+
+func TestEstimateConstraintMigration(t *testing.T) {
+	db := newMigrationTestDB(t)
+	if err := NewMigrator(db, migrationsBefore(t, "023")).Run(); err != nil {
+		t.Fatalf("Failed to run earlier migrations: %v", err)
+	}
+
+	ctx := context.Background()
+	setup := []string{
+		"INSERT INTO todos (title, estimate_minutes, slug) VALUES ('Negative', -15, 'negative')",
+		"INSERT INTO todos (title, estimate_minutes) VALUES ('Estimated', 30)",
+		"INSERT INTO todo_dependencies (todo_id, depends_on_id) VALUES (2, 1)",
+	}
+	for _, stmt := range setup {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			t.Fatalf("Failed to execute %q: %v", stmt, err)
+		}
+	}
+
+	if err := NewMigrator(db, Migrations).Run(); err != nil {
+		t.Fatalf("Failed to run migrations: %v", err)
+	}
+
+	var estimates, dependencies int
+	var slug string
+	if err := db.QueryRowContext(ctx, "SELECT SUM(estimate_minutes) FROM todos").Scan(&estimates); err != nil {
+		t.Fatalf("Failed to sum estimates: %v", err)
+	}
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM todo_dependencies").Scan(&dependencies); err != nil {
+		t.Fatalf("Failed to count dependencies: %v", err)
+	}
+	if err := db.QueryRowContext(ctx, "SELECT slug FROM todos WHERE id = 1").Scan(&slug); err != nil {
+		t.Fatalf("Failed to read slug: %v", err)
+	}
+	if estimates != 30 || dependencies != 1 || slug != "negative" {
+		t.Errorf("Expected the negative estimate cleared and other data kept, got estimates %d, %d dependencies, slug %q", estimates, dependencies, slug)
+	}
+
+	_, err := db.ExecContext(ctx, "UPDATE todos SET estimate_minutes = -1 WHERE id = 2")
+	constraintErr, ok := AsConstraintError(err)
+	if !ok || constraintErr.Message != "estimateMinutes must not be negative" {
+		t.Errorf("Expected the CHECK constraint to reject a negative estimate, got %v", err)
+	}
+
+	// The rebuilt table keeps its triggers
+	if err := db.QueryRowContext(ctx, "INSERT INTO todos (title) VALUES ('New') RETURNING id").Scan(new(int64)); err != nil {
+		t.Fatalf("Failed to insert todo: %v", err)
+	}
+	if err := db.QueryRowContext(ctx, "SELECT slug FROM todos WHERE title = 'New'").Scan(&slug); err != nil {
+		t.Fatalf("Failed to read slug: %v", err)
+	}
+	if slug != "todo--3" {
+		t.Errorf("Expected the slug trigger to assign todo--3, got %q", slug)
+	}
+}
+
+func TestSetUniqueTitles(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("sqlite", func(t *testing.T) {
+		repo := setupTestRepo(t)
+		testUniqueTitles(t, repo, func(enabled bool) error { return repo.SetUniqueTitles(ctx, enabled) })
+	})
+	t.Run("memory", func(t *testing.T) {
+		store := NewMemoryTodoStore()
+		testUniqueTitles(t, store, store.SetUniqueTitles)
+	})
+}
+
+func testUniqueTitles(t *testing.T, store TodoStore, setUniqueTitles func(bool) error) {
+	ctx := context.Background()
+
+	for range 2 {
+		if _, err := store.Create(ctx, models.CreateTodoRequest{Title: "Same"}); err != nil {
+			t.Fatalf("Expected duplicates to be allowed by default, got %v", err)
+		}
+	}
+
+	if err := setUniqueTitles(true); err == nil {
+		t.Fatal("Expected enabling unique titles to fail while duplicates exist")
+	}
+
+	todos, err := store.GetAll(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list todos: %v", err)
+	}
+	if _, err := store.Delete(ctx, todos[0].ID); err != nil {
+		t.Fatalf("Failed to delete todo: %v", err)
+	}
+	if err := setUniqueTitles(true); err != nil {
+		t.Fatalf("Failed to enable unique titles: %v", err)
+	}
+
+	_, err = store.Create(ctx, models.CreateTodoRequest{Title: "Same"})
+	constraintErr, ok := AsConstraintError(err)
+	if !ok || constraintErr.Kind != ConstraintUnique {
+		t.Errorf("Expected a unique ConstraintError creating a duplicate, got %v", err)
+	}
+
+	other, err := store.Create(ctx, models.CreateTodoRequest{Title: "Other"})
+	if err != nil {
+		t.Fatalf("Failed to create todo: %v", err)
+	}
+	title := "Same"
+	if _, err := store.Update(ctx, other.ID, models.UpdateTodoRequest{Title: &title}); err == nil {
+		t.Error("Expected renaming to a duplicate title to fail")
+	}
+
+	if err := setUniqueTitles(false); err != nil {
+		t.Fatalf("Failed to disable unique titles: %v", err)
+	}
+	if _, err := store.Create(ctx, models.CreateTodoRequest{Title: "Same"}); err != nil {
+		t.Errorf("Expected duplicates to be allowed again, got %v", err)
+	}
+}
@@ -3,48 +3,280 @@ package database
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/mattn/go-sqlite3"
 )
 
 // DB wraps the database connection
 type DB struct {
 	*sql.DB
+	queryTimeout time.Duration
 }
 
-// New creates a new database connection
-func New(dataSourceName string) (*DB, error) {
-	db, err := sql.Open("sqlite3", dataSourceName)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
+// Config holds connection pool settings for the database.
+//
+// SQLite only allows a single writer at a time, so the default pool keeps
+// one open connection. Raising MaxOpenConns mostly helps concurrent readers;
+// if you need that, prefer a separate read-only pool over widening the
+// pool used for writes, otherwise writers will contend for the file lock.
+type Config struct {
+	// MaxOpenConns is the maximum number of open connections (0 means unlimited)
+	MaxOpenConns int
+	// MaxIdleConns is the maximum number of idle connections kept in the pool
+	MaxIdleConns int
+	// ConnMaxLifetime is the maximum time a connection may be reused (0 means forever)
+	ConnMaxLifetime time.Duration
+	// QueryTimeout bounds each repository operation (0 disables the timeout)
+	QueryTimeout time.Duration
+	// AutoVacuum sets SQLite's auto_vacuum mode: "none", "full" or
+	// "incremental". Empty leaves the database's current mode alone.
+	AutoVacuum string
+	// CacheSizeMB is the page cache SQLite keeps per connection, in MiB
+	// (0 keeps SQLite's default of about 2 MiB)
+	CacheSizeMB int
+	// MmapSizeMB is how much of the database file each connection may map
+	// into memory, in MiB (0 disables memory-mapped I/O)
+	MmapSizeMB int
+}
+
+// DefaultConfig returns the default pool configuration (a single writer)
+func DefaultConfig() Config {
+	return Config{
+		MaxOpenConns:    1,
+		MaxIdleConns:    1,
+		ConnMaxLifetime: 0,
+		QueryTimeout:    5 * time.Second,
+	}
+}
+
+// ConfigFromEnv builds a Config from environment variables, falling back to
+// DefaultConfig for any that are unset. Recognised variables are
+// DB_MAX_OPEN_CONNS, DB_MAX_IDLE_CONNS, DB_CONN_MAX_LIFETIME,
+// DB_QUERY_TIMEOUT (the latter two are durations such as "30m"),
+// DB_AUTO_VACUUM, DB_CACHE_SIZE_MB and DB_MMAP_SIZE_MB.
+func ConfigFromEnv() (Config, error) {
+	cfg := DefaultConfig()
+
+	if v := os.Getenv("DB_MAX_OPEN_CONNS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return cfg, fmt.Errorf("invalid DB_MAX_OPEN_CONNS %q", v)
+		}
+		cfg.MaxOpenConns = n
+	}
+
+	if v := os.Getenv("DB_MAX_IDLE_CONNS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return cfg, fmt.Errorf("invalid DB_MAX_IDLE_CONNS %q", v)
+		}
+		cfg.MaxIdleConns = n
+	}
+
+	if v := os.Getenv("DB_CONN_MAX_LIFETIME"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return cfg, fmt.Errorf("invalid DB_CONN_MAX_LIFETIME %q", v)
+		}
+		cfg.ConnMaxLifetime = d
+	}
+
+	if v := os.Getenv("DB_QUERY_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return cfg, fmt.Errorf("invalid DB_QUERY_TIMEOUT %q", v)
+		}
+		cfg.QueryTimeout = d
+	}
+
+	if v := os.Getenv("DB_AUTO_VACUUM"); v != "" {
+		v = strings.ToLower(v)
+		if !validAutoVacuumModes[v] {
+			return cfg, fmt.Errorf("invalid DB_AUTO_VACUUM %q", v)
+		}
+		cfg.AutoVacuum = v
+	}
+
+	if v := os.Getenv("DB_CACHE_SIZE_MB"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return cfg, fmt.Errorf("invalid DB_CACHE_SIZE_MB %q", v)
+		}
+		cfg.CacheSizeMB = n
 	}
 
+	if v := os.Getenv("DB_MMAP_SIZE_MB"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return cfg, fmt.Errorf("invalid DB_MMAP_SIZE_MB %q", v)
+		}
+		cfg.MmapSizeMB = n
+	}
+
+	return cfg, nil
+}
+
+// New creates a new database connection. For file-backed databases the
+// parent directory is created if it does not already exist.
+//
+// Every SQLite connection to an in-memory database gets its own, empty
+// database, so for in-memory DSNs the pool is pinned to a single connection
+// that is never recycled, regardless of cfg.
+func New(dataSourceName string, cfg Config) (*DB, error) {
+	if path, ok := filePath(dataSourceName); ok {
+		if err := ensureWritable(path); err != nil {
+			return nil, err
+		}
+	} else {
+		cfg.MaxOpenConns = 1
+		cfg.MaxIdleConns = 1
+		cfg.ConnMaxLifetime = 0
+	}
+
+	db := sql.OpenDB(&connector{
+		dsn: dataSourceName,
+		driver: &sqlite3.SQLiteDriver{
+			ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+				return configureConn(conn, cfg)
+			},
+		},
+	})
+
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+
 	if err := db.PingContext(context.Background()); err != nil {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	return &DB{db}, nil
+	wrapped := &DB{DB: db, queryTimeout: cfg.QueryTimeout}
+
+	if cfg.AutoVacuum != "" {
+		if err := wrapped.setAutoVacuum(context.Background(), cfg.AutoVacuum); err != nil {
+			_ = db.Close()
+			return nil, err
+		}
+	}
+
+	return wrapped, nil
 }
 
-// Initialize creates the database schema
-func (db *DB) Initialize() error {
-	schema := `
-	CREATE TABLE IF NOT EXISTS todos (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		title TEXT NOT NULL,
-		description TEXT,
-		completed BOOLEAN NOT NULL DEFAULT 0,
-		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
-		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_todos_completed ON todos(completed);
-	CREATE INDEX IF NOT EXISTS idx_todos_created_at ON todos(created_at);
-	`
-
-	_, err := db.ExecContext(context.Background(), schema)
+// connector opens SQLite connections through a driver with a connect hook
+type connector struct {
+	dsn    string
+	driver *sqlite3.SQLiteDriver
+}
+
+// Connect implements driver.Connector
+func (c *connector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+// Driver implements driver.Connector
+func (c *connector) Driver() driver.Driver {
+	return c.driver
+}
+
+// configureConn applies per-connection settings. SQLite pragmas such as
+// foreign_keys and cache_size are scoped to a connection, so they must be
+// set on each one the pool opens.
+func configureConn(conn *sqlite3.SQLiteConn, cfg Config) error {
+	if _, err := conn.Exec("PRAGMA foreign_keys = ON", nil); err != nil {
+		return fmt.Errorf("failed to enable foreign keys: %w", err)
+	}
+
+	// A negative cache_size is in KiB rather than pages
+	if cfg.CacheSizeMB > 0 {
+		if _, err := conn.Exec(fmt.Sprintf("PRAGMA cache_size = %d", -cfg.CacheSizeMB*1024), nil); err != nil {
+			return fmt.Errorf("failed to set cache size: %w", err)
+		}
+	}
+	if _, err := conn.Exec(fmt.Sprintf("PRAGMA mmap_size = %d", int64(cfg.MmapSizeMB)<<20), nil); err != nil {
+		return fmt.Errorf("failed to set mmap size: %w", err)
+	}
+
+	return nil
+}
+
+// filePath extracts the file system path from a SQLite data source name.
+// It reports false for in-memory databases.
+func filePath(dataSourceName string) (string, bool) {
+	path, params, _ := strings.Cut(dataSourceName, "?")
+	path = strings.TrimPrefix(path, "file:")
+
+	if path == "" || path == ":memory:" || strings.Contains(params, "mode=memory") {
+		return "", false
+	}
+
+	return path, true
+}
+
+// ensureWritable creates the parent directory of path and checks that the
+// database file can be opened for writing
+func ensureWritable(path string) error {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return fmt.Errorf("database path %s is a directory", path)
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return fmt.Errorf("failed to create database directory %s: %w", dir, err)
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600) // #nosec G304 -- path comes from configuration
 	if err != nil {
+		return fmt.Errorf("database path %s is not writable: %w", path, err)
+	}
+
+	return f.Close()
+}
+
+// withTimeout derives a context bounded by the configured query timeout
+func (db *DB) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if db.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, db.queryTimeout)
+}
+
+// canStream reports whether a query may keep its rows open while a slow
+// consumer reads them: the pool must have a connection left for other
+// requests, and no query timeout may end the query part way through
+func (db *DB) canStream() bool {
+	if db.queryTimeout > 0 {
+		return false
+	}
+	maxOpen := db.Stats().MaxOpenConnections
+	return maxOpen == 0 || maxOpen > 1
+}
+
+// utcNow returns the current time in UTC. All timestamps are stored in UTC
+// so that their text form sorts and compares the same whatever the server's
+// local zone is.
+func utcNow() time.Time {
+	return time.Now().UTC()
+}
+
+// utcTime converts an optional timestamp to UTC for storage
+func utcTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	utc := t.UTC()
+	return &utc
+}
+
+// Initialize creates the database schema by applying the embedded migrations
+func (db *DB) Initialize() error {
+	if err := NewMigrator(db, Migrations).Run(); err != nil {
 		return fmt.Errorf("failed to create schema: %w", err)
 	}
 
@@ -0,0 +1,445 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/larryhudson/go-todo-list-claude/internal/models"
+)
+
+func TestNew_AppliesPoolConfig(t *testing.T) {
+	cfg := Config{
+		MaxOpenConns:    3,
+		MaxIdleConns:    2,
+		ConnMaxLifetime: time.Minute,
+	}
+
+	db, err := New(filepath.Join(t.TempDir(), "todos.db"), cfg)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	if got := db.Stats().MaxOpenConnections; got != 3 {
+		t.Errorf("Expected MaxOpenConnections 3, got %d", got)
+	}
+
+	if idle := db.Stats().Idle; idle > 2 {
+		t.Errorf("Expected at most 2 idle connections, got %d", idle)
+	}
+}
+
+func TestConfigFromEnv_Defaults(t *testing.T) {
+	t.Setenv("DB_MAX_OPEN_CONNS", "")
+	t.Setenv("DB_MAX_IDLE_CONNS", "")
+	t.Setenv("DB_CONN_MAX_LIFETIME", "")
+
+	cfg, err := ConfigFromEnv()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if cfg != DefaultConfig() {
+		t.Errorf("Expected default config %+v, got %+v", DefaultConfig(), cfg)
+	}
+}
+
+func TestConfigFromEnv_Overrides(t *testing.T) {
+	t.Setenv("DB_MAX_OPEN_CONNS", "4")
+	t.Setenv("DB_MAX_IDLE_CONNS", "2")
+	t.Setenv("DB_CONN_MAX_LIFETIME", "30m")
+
+	cfg, err := ConfigFromEnv()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if cfg.MaxOpenConns != 4 {
+		t.Errorf("Expected MaxOpenConns 4, got %d", cfg.MaxOpenConns)
+	}
+	if cfg.MaxIdleConns != 2 {
+		t.Errorf("Expected MaxIdleConns 2, got %d", cfg.MaxIdleConns)
+	}
+	if cfg.ConnMaxLifetime != 30*time.Minute {
+		t.Errorf("Expected ConnMaxLifetime 30m, got %v", cfg.ConnMaxLifetime)
+	}
+}
+
+func TestConfigFromEnv_Invalid(t *testing.T) {
+	t.Setenv("DB_MAX_OPEN_CONNS", "lots")
+
+	if _, err := ConfigFromEnv(); err == nil {
+		t.Error("Expected error for invalid DB_MAX_OPEN_CONNS")
+	}
+}
+
+func TestWithTimeout_InterruptsSlowQuery(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.QueryTimeout = 50 * time.Millisecond
+
+	db, err := New(":memory:", cfg)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	ctx, cancel := db.withTimeout(context.Background())
+	defer cancel()
+
+	// An unbounded recursive CTE never finishes on its own
+	query := `
+		WITH RECURSIVE counter(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM counter)
+		SELECT count(*) FROM counter
+	`
+	var count int64
+	start := time.Now()
+	err = db.QueryRowContext(ctx, query).Scan(&count)
+	if err == nil {
+		t.Fatal("Expected slow query to fail")
+	}
+	if !errors.Is(err, context.DeadlineExceeded) && !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected query to be interrupted promptly, took %v", elapsed)
+	}
+}
+
+func TestConfigFromEnv_QueryTimeout(t *testing.T) {
+	t.Setenv("DB_QUERY_TIMEOUT", "250ms")
+
+	cfg, err := ConfigFromEnv()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if cfg.QueryTimeout != 250*time.Millisecond {
+		t.Errorf("Expected QueryTimeout 250ms, got %v", cfg.QueryTimeout)
+	}
+}
+
+func TestNew_CreatesParentDirectory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "nested", "todos.db")
+
+	db, err := New(path, DefaultConfig())
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("Expected database file to exist: %v", err)
+	}
+}
+
+func TestNew_PathIsDirectory(t *testing.T) {
+	dir := t.TempDir()
+
+	db, err := New(dir, DefaultConfig())
+	if err == nil {
+		_ = db.Close()
+		t.Fatal("Expected error when database path is a directory")
+	}
+	if !strings.Contains(err.Error(), "is a directory") {
+		t.Errorf("Expected directory error, got %v", err)
+	}
+}
+
+func TestFilePath_Memory(t *testing.T) {
+	tests := []struct {
+		dsn  string
+		path string
+		ok   bool
+	}{
+		{":memory:", "", false},
+		{"file::memory:?cache=shared", "", false},
+		{"file:todos?mode=memory&cache=shared", "", false},
+		{"./data/todos.db", "./data/todos.db", true},
+		{"file:data/todos.db?_busy_timeout=5000", "data/todos.db", true},
+	}
+
+	for _, tt := range tests {
+		path, ok := filePath(tt.dsn)
+		if path != tt.path || ok != tt.ok {
+			t.Errorf("filePath(%q) = (%q, %v), want (%q, %v)", tt.dsn, path, ok, tt.path, tt.ok)
+		}
+	}
+}
+
+func TestNew_MemoryPinsSingleConnection(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MaxOpenConns = 4
+	cfg.MaxIdleConns = 0
+	cfg.ConnMaxLifetime = time.Millisecond
+
+	db, err := New(":memory:", cfg)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	if got := db.Stats().MaxOpenConnections; got != 1 {
+		t.Errorf("Expected MaxOpenConnections 1 for in-memory database, got %d", got)
+	}
+
+	if err := db.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+
+	repo, err := NewTodoRepository(db)
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+	defer func() {
+		if err := repo.Close(); err != nil {
+			t.Errorf("Failed to close repository: %v", err)
+		}
+	}()
+
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		if _, err := repo.Create(ctx, models.CreateTodoRequest{Title: "Persisted"}); err != nil {
+			t.Fatalf("Failed to create todo: %v", err)
+		}
+		// Outlive the configured lifetime between operations
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	todos, err := repo.GetAll(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get todos: %v", err)
+	}
+	if len(todos) != 5 {
+		t.Errorf("Expected 5 todos to survive across operations, got %d", len(todos))
+	}
+}
+
+func TestMigrator_DryRun(t *testing.T) {
+	db, err := New(":memory:", DefaultConfig())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	migrator := NewMigrator(db, Migrations)
+	files, err := migrator.migrationFiles()
+	if err != nil {
+		t.Fatalf("Failed to list migrations: %v", err)
+	}
+
+	pending, err := migrator.DryRun()
+	if err != nil {
+		t.Fatalf("DryRun failed: %v", err)
+	}
+	if len(pending) != len(files) || pending[0] != "001_initial_schema.sql" {
+		t.Errorf("Expected all %d migrations pending, got %v", len(files), pending)
+	}
+
+	// A dry run must not create the migrations table
+	exists, err := migrator.migrationsTableExists(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to check migrations table: %v", err)
+	}
+	if exists {
+		t.Error("Expected DryRun not to create the migrations table")
+	}
+
+	if err := migrator.Run(); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	pending, err = migrator.DryRun()
+	if err != nil {
+		t.Fatalf("DryRun failed: %v", err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("Expected no pending migrations after Run, got %v", pending)
+	}
+
+	statuses, err := migrator.Status()
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	for _, status := range statuses {
+		if !status.Applied {
+			t.Errorf("Expected %s to be applied", status.Filename)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	db, err := New(":memory:", DefaultConfig())
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	if err := db.Validate(); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Errorf("Expected an error for a missing table, got %v", err)
+	}
+
+	// A schema from before the starred and remind_at columns were added
+	schema := `CREATE TABLE todos (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		title TEXT NOT NULL,
+		description TEXT,
+		completed BOOLEAN NOT NULL DEFAULT 0,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL
+	)`
+	if _, err := db.ExecContext(context.Background(), schema); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	err = db.Validate()
+	if err == nil {
+		t.Fatal("Expected an error for an incomplete schema")
+	}
+	if !strings.Contains(err.Error(), "missing columns: public_id, external_id, slug, starred, remind_at") {
+		t.Errorf("Expected the missing columns to be listed, got %v", err)
+	}
+}
+
+func TestValidate_MigratedSchema(t *testing.T) {
+	db, err := New(":memory:", DefaultConfig())
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	if err := db.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+
+	if err := db.Validate(); err != nil {
+		t.Errorf("Expected a migrated schema to be valid, got %v", err)
+	}
+}
+
+func TestNew_AutoVacuum(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.AutoVacuum = "incremental"
+
+	db, err := New(filepath.Join(t.TempDir(), "todos.db"), cfg)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	// 2 is incremental
+	var mode int
+	if err := db.QueryRowContext(context.Background(), "PRAGMA auto_vacuum").Scan(&mode); err != nil {
+		t.Fatalf("Failed to read auto_vacuum: %v", err)
+	}
+	if mode != 2 {
+		t.Errorf("Expected auto_vacuum 2 (incremental), got %d", mode)
+	}
+
+	cfg.AutoVacuum = "sometimes"
+	if _, err := New(filepath.Join(t.TempDir(), "other.db"), cfg); err == nil {
+		t.Error("Expected an error for an invalid auto_vacuum mode")
+	}
+}
+
+func TestNew_CacheAndMmapSize(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MaxOpenConns = 2
+	cfg.CacheSizeMB = 16
+	cfg.MmapSizeMB = 64
+
+	db, err := New(filepath.Join(t.TempDir(), "todos.db"), cfg)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	// Hold one connection so the pragmas are also read from a second one
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	defer func() {
+		if err := conn.Close(); err != nil {
+			t.Errorf("Failed to close connection: %v", err)
+		}
+	}()
+
+	for _, q := range []interface {
+		QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	}{conn, db} {
+		var cacheSize, mmapSize int64
+		if err := q.QueryRowContext(ctx, "PRAGMA cache_size").Scan(&cacheSize); err != nil {
+			t.Fatalf("Failed to read cache_size: %v", err)
+		}
+		if err := q.QueryRowContext(ctx, "PRAGMA mmap_size").Scan(&mmapSize); err != nil {
+			t.Fatalf("Failed to read mmap_size: %v", err)
+		}
+		if cacheSize != -16*1024 {
+			t.Errorf("Expected cache_size -16384 (16 MiB), got %d", cacheSize)
+		}
+		if mmapSize != 64<<20 {
+			t.Errorf("Expected mmap_size %d, got %d", 64<<20, mmapSize)
+		}
+	}
+}
+
+func TestConfigFromEnv_CacheAndMmapSize(t *testing.T) {
+	t.Setenv("DB_CACHE_SIZE_MB", "32")
+	t.Setenv("DB_MMAP_SIZE_MB", "256")
+
+	cfg, err := ConfigFromEnv()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cfg.CacheSizeMB != 32 || cfg.MmapSizeMB != 256 {
+		t.Errorf("Expected 32 MiB cache and 256 MiB mmap, got %d and %d", cfg.CacheSizeMB, cfg.MmapSizeMB)
+	}
+
+	t.Setenv("DB_MMAP_SIZE_MB", "-1")
+	if _, err := ConfigFromEnv(); err == nil {
+		t.Error("Expected an error for a negative DB_MMAP_SIZE_MB")
+	}
+}
@@ -0,0 +1,197 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrDependencyCycle is returned by DependencyRepository.Add when the new
+// dependency would make a todo depend, directly or not, on itself
+var ErrDependencyCycle = errors.New("dependency would create a cycle")
+
+// BlockedError is returned when a write would complete todos that depend on
+// incomplete todos. BlockedBy lists those todos in id order.
+type BlockedError struct {
+	BlockedBy []int64
+}
+
+func (e *BlockedError) Error() string {
+	return "todo is blocked by incomplete dependencies"
+}
+
+// DependencyRepository handles database operations for dependencies
+// between todos
+type DependencyRepository struct {
+	db *DB
+}
+
+// NewDependencyRepository creates a new DependencyRepository
+func NewDependencyRepository(db *DB) *DependencyRepository {
+	return &DependencyRepository{db: db}
+}
+
+// Add records that todoID cannot be completed before dependsOnID. Adding a
+// dependency that already exists does nothing. It returns
+// ErrDependencyCycle if dependsOnID already depends on todoID, or is
+// todoID.
+func (r *DependencyRepository) Add(ctx context.Context, todoID, dependsOnID int64) (err error) {
+	if todoID == dependsOnID {
+		return ErrDependencyCycle
+	}
+
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				err = fmt.Errorf("rollback failed: %v (original error: %w)", rbErr, err)
+			}
+		}
+	}()
+
+	// Walk everything dependsOnID depends on; reaching todoID means the new
+	// edge would close a loop
+	query := `
+		WITH RECURSIVE reachable(id) AS (
+			SELECT depends_on_id FROM todo_dependencies WHERE todo_id = ?
+			UNION
+			SELECT d.depends_on_id FROM todo_dependencies d JOIN reachable ON d.todo_id = reachable.id
+		)
+		SELECT EXISTS (SELECT 1 FROM reachable WHERE id = ?)
+	`
+	var cycle bool
+	if err = tx.QueryRowContext(ctx, query, dependsOnID, todoID).Scan(&cycle); err != nil {
+		return fmt.Errorf("failed to check for cycles: %w", err)
+	}
+	if cycle {
+		return ErrDependencyCycle
+	}
+
+	query = "INSERT OR IGNORE INTO todo_dependencies (todo_id, depends_on_id) VALUES (?, ?)"
+	if _, err = tx.ExecContext(ctx, query, todoID, dependsOnID); err != nil {
+		return fmt.Errorf("failed to add dependency: %w", mapConstraintError(err))
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// Remove deletes a dependency. It returns sql.ErrNoRows if todoID does not
+// depend on dependsOnID.
+func (r *DependencyRepository) Remove(ctx context.Context, todoID, dependsOnID int64) error {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, "DELETE FROM todo_dependencies WHERE todo_id = ? AND depends_on_id = ?", todoID, dependsOnID)
+	if err != nil {
+		return fmt.Errorf("failed to remove dependency: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// BlockedBy returns the ids of the todos todoID depends on, in id order
+func (r *DependencyRepository) BlockedBy(ctx context.Context, todoID int64) ([]int64, error) {
+	return r.queryIDs(ctx, "SELECT depends_on_id FROM todo_dependencies WHERE todo_id = ? ORDER BY depends_on_id", todoID)
+}
+
+// Blocking returns the ids of the todos that depend on todoID, in id order
+func (r *DependencyRepository) Blocking(ctx context.Context, todoID int64) ([]int64, error) {
+	return r.queryIDs(ctx, "SELECT todo_id FROM todo_dependencies WHERE depends_on_id = ? ORDER BY todo_id", todoID)
+}
+
+// incompleteBlockers returns the ids of the incomplete todos that any of
+// todoIDs depend on, in id order, leaving out todos among todoIDs
+// themselves so that completing a todo along with its dependencies is
+// allowed
+func incompleteBlockers(ctx context.Context, q querier, todoIDs ...int64) ([]int64, error) {
+	if len(todoIDs) == 0 {
+		return nil, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(todoIDs)), ", ")
+	query := `
+		SELECT DISTINCT d.depends_on_id
+		FROM todo_dependencies d
+		JOIN todos t ON t.id = d.depends_on_id
+		WHERE d.todo_id IN (` + placeholders + `)
+		  AND d.depends_on_id NOT IN (` + placeholders + `)
+		  AND t.completed = 0
+		ORDER BY d.depends_on_id
+	`
+	args := make([]interface{}, 0, 2*len(todoIDs))
+	for range 2 {
+		for _, id := range todoIDs {
+			args = append(args, id)
+		}
+	}
+
+	return scanIDs(ctx, q, query, args...)
+}
+
+// checkBlockers returns a *BlockedError if completing todoIDs together
+// would complete a todo that depends on an incomplete one, the rule
+// CompleteMatching applies with its NOT EXISTS clause
+func checkBlockers(ctx context.Context, q querier, todoIDs ...int64) error {
+	blockers, err := incompleteBlockers(ctx, q, todoIDs...)
+	if err != nil {
+		return err
+	}
+	if len(blockers) > 0 {
+		return &BlockedError{BlockedBy: blockers}
+	}
+	return nil
+}
+
+// queryIDs runs a query selecting a single id column
+func (r *DependencyRepository) queryIDs(ctx context.Context, query string, args ...interface{}) ([]int64, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	return scanIDs(ctx, r.db, query, args...)
+}
+
+// scanIDs runs a query selecting a single id column on q
+func scanIDs(ctx context.Context, q querier, query string, args ...interface{}) ([]int64, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query dependencies: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan dependency: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating dependencies: %w", err)
+	}
+
+	return ids, nil
+}
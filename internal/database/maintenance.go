@@ -0,0 +1,72 @@
+package database
+
+import (
+	"context"
+	"fmt"
+)
+
+// validAutoVacuumModes lists the accepted values of Config.AutoVacuum
+var validAutoVacuumModes = map[string]bool{
+	"none":        true,
+	"full":        true,
+	"incremental": true,
+}
+
+// setAutoVacuum records the auto_vacuum mode in the database header. SQLite
+// only applies a new mode to a database without tables, or at the next
+// VACUUM, so on an existing file it takes effect once Vacuum has run.
+func (db *DB) setAutoVacuum(ctx context.Context, mode string) error {
+	if !validAutoVacuumModes[mode] {
+		return fmt.Errorf("invalid auto_vacuum mode %q: must be none, full or incremental", mode)
+	}
+	if _, err := db.ExecContext(ctx, "PRAGMA auto_vacuum = "+mode); err != nil {
+		return fmt.Errorf("failed to set auto_vacuum: %w", err)
+	}
+	return nil
+}
+
+// Size returns the size of the database in bytes, counted in pages so it
+// works for in-memory databases too
+func (db *DB) Size(ctx context.Context) (int64, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	var size int64
+	query := "SELECT page_count * page_size FROM pragma_page_count(), pragma_page_size()"
+	if err := db.QueryRowContext(ctx, query).Scan(&size); err != nil {
+		return 0, fmt.Errorf("failed to get database size: %w", err)
+	}
+	return size, nil
+}
+
+// Vacuum rebuilds the database file to return the space freed by deletes,
+// reporting its size before and after. VACUUM cannot run inside a
+// transaction and holds an exclusive lock until it finishes, so other
+// queries wait for it. It is not bounded by the query timeout, since it may
+// take a while on a large database; ctx still cancels it.
+func (db *DB) Vacuum(ctx context.Context) (before, after int64, err error) {
+	if before, err = db.Size(ctx); err != nil {
+		return 0, 0, err
+	}
+
+	// Run on a dedicated connection outside of any transaction
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	if _, err := conn.ExecContext(ctx, "VACUUM"); err != nil {
+		return 0, 0, fmt.Errorf("failed to vacuum database: %w", err)
+	}
+
+	var size int64
+	query := "SELECT page_count * page_size FROM pragma_page_count(), pragma_page_size()"
+	if err := conn.QueryRowContext(ctx, query).Scan(&size); err != nil {
+		return 0, 0, fmt.Errorf("failed to get database size: %w", err)
+	}
+
+	return before, size, nil
+}
@@ -0,0 +1,1084 @@
+package database
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/larryhudson/go-todo-list-claude/internal/models"
+)
+
+// MemoryTodoStore is a TodoStore kept in memory, for tests and experiments
+// that should not need SQLite. It is safe for concurrent use.
+//
+// It mirrors TodoRepository's behavior with one difference: search terms
+// are matched as plain case-insensitive substrings, so the LIKE wildcards %
+// and _ have no special meaning.
+type MemoryTodoStore struct {
+	mu      sync.RWMutex
+	todos   map[int64]models.Todo
+	deleted map[int64]tombstone // reported by Changes
+	nextID  int64
+
+	// accessed holds when each todo was last viewed, for Recent
+	accessed map[int64]time.Time
+
+	// uniqueTitles rejects todos whose title matches an existing one
+	uniqueTitles bool
+
+	// stableSlugs keeps a todo's slug when its title changes
+	stableSlugs bool
+
+	// Ordering applied when a query does not specify one
+	defaultSortBy    string
+	defaultSortOrder string
+}
+
+// tombstone records a deleted todo for Changes
+type tombstone struct {
+	publicID  string
+	deletedAt time.Time
+}
+
+// NewMemoryTodoStore creates an empty MemoryTodoStore
+func NewMemoryTodoStore() *MemoryTodoStore {
+	return &MemoryTodoStore{
+		todos:            make(map[int64]models.Todo),
+		deleted:          make(map[int64]tombstone),
+		nextID:           1,
+		accessed:         make(map[int64]time.Time),
+		defaultSortBy:    "created_at",
+		defaultSortOrder: "desc",
+	}
+}
+
+// SetDefaultSort sets the ordering used when a query does not specify
+// one. sortBy is a column name and sortOrder is "asc" or "desc".
+func (s *MemoryTodoStore) SetDefaultSort(sortBy, sortOrder string) error {
+	if !validSortFields[sortBy] {
+		return fmt.Errorf("invalid sort field: %s", sortBy)
+	}
+	if sortOrder != "asc" && sortOrder != "desc" {
+		return fmt.Errorf("invalid sort order: %s", sortOrder)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.defaultSortBy = sortBy
+	s.defaultSortOrder = sortOrder
+	return nil
+}
+
+// SetUniqueTitles enables or disables rejecting todos whose title matches
+// an existing one, like TodoRepository.SetUniqueTitles
+func (s *MemoryTodoStore) SetUniqueTitles(enabled bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if enabled {
+		seen := make(map[string]bool, len(s.todos))
+		for _, todo := range s.todos {
+			if seen[todo.Title] {
+				return fmt.Errorf("failed to enforce unique titles, existing todos share the title %q", todo.Title)
+			}
+			seen[todo.Title] = true
+		}
+	}
+
+	s.uniqueTitles = enabled
+	return nil
+}
+
+// SetStableSlugs sets whether a todo keeps its slug when its title
+// changes, like TodoRepository.SetStableSlugs
+func (s *MemoryTodoStore) SetStableSlugs(enabled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stableSlugs = enabled
+}
+
+// uniqueSlug returns a slug for title that no todo other than id uses; the
+// caller must hold the lock
+func (s *MemoryTodoStore) uniqueSlug(title string, id int64) string {
+	used := make(map[string]bool, len(s.todos))
+	for _, todo := range s.todos {
+		if todo.ID != id {
+			used[todo.Slug] = true
+		}
+	}
+	return pickSlug(slugify(title), func(slug string) bool { return used[slug] || reservedSlugs[slug] })
+}
+
+// checkTitle rejects a title already used by a todo other than id when
+// unique titles are enforced; the caller must hold the lock
+func (s *MemoryTodoStore) checkTitle(id int64, title string) error {
+	if !s.uniqueTitles {
+		return nil
+	}
+	for _, todo := range s.todos {
+		if todo.ID != id && todo.Title == title {
+			return &ConstraintError{Kind: ConstraintUnique, Message: uniqueMessages["todos.title"]}
+		}
+	}
+	return nil
+}
+
+// create inserts a todo; the caller must hold the write lock
+func (s *MemoryTodoStore) create(req models.CreateTodoRequest, now time.Time) models.Todo {
+	todo := models.Todo{
+		ID:              s.nextID,
+		PublicID:        newPublicID(),
+		Slug:            s.uniqueSlug(req.Title, 0),
+		Title:           req.Title,
+		Description:     req.Description,
+		RemindAt:        utcTime(req.RemindAt),
+		ScheduledAt:     utcTime(req.ScheduledAt),
+		Position:        s.lastPosition() + positionGap,
+		Draft:           req.Draft,
+		Metadata:        req.Metadata,
+		EstimateMinutes: req.EstimateMinutes,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	s.nextID++
+	s.todos[todo.ID] = todo
+	return todo
+}
+
+// lastPosition returns the highest position in use, or 0 when there are no
+// todos; the caller must hold the lock
+func (s *MemoryTodoStore) lastPosition() int64 {
+	var last int64
+	for _, todo := range s.todos {
+		last = max(last, todo.Position)
+	}
+	return last
+}
+
+// Create creates a new todo
+func (s *MemoryTodoStore) Create(_ context.Context, req models.CreateTodoRequest) (*models.Todo, error) {
+	if err := checkLengths(req.Title, req.Description); err != nil {
+		return nil, fmt.Errorf("failed to create todo: %w", err)
+	}
+	metadata, err := cloneMetadata(req.Metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create todo: %w", err)
+	}
+	req.Metadata = metadata
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkTitle(0, req.Title); err != nil {
+		return nil, fmt.Errorf("failed to create todo: %w", err)
+	}
+
+	todo := s.create(req, utcNow())
+	return &todo, nil
+}
+
+// CreateMany creates several todos at once
+func (s *MemoryTodoStore) CreateMany(_ context.Context, reqs []models.CreateTodoRequest) ([]models.Todo, error) {
+	reqs = slices.Clone(reqs)
+	for i, req := range reqs {
+		if err := checkLengths(req.Title, req.Description); err != nil {
+			return nil, fmt.Errorf("failed to create todo %d: %w", i, err)
+		}
+		metadata, err := cloneMetadata(req.Metadata)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create todo %d: %w", i, err)
+		}
+		reqs[i].Metadata = metadata
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Check every title before creating any, so a conflict creates nothing
+	titles := make(map[string]bool, len(reqs))
+	for i, req := range reqs {
+		err := s.checkTitle(0, req.Title)
+		if err == nil && s.uniqueTitles && titles[req.Title] {
+			err = &ConstraintError{Kind: ConstraintUnique, Message: uniqueMessages["todos.title"]}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to create todo %d: %w", i, err)
+		}
+		titles[req.Title] = true
+	}
+
+	now := utcNow()
+	created := make([]models.Todo, 0, len(reqs))
+	for _, req := range reqs {
+		created = append(created, s.create(req, now))
+	}
+	return created, nil
+}
+
+// GetAll returns every todo in the default order
+func (s *MemoryTodoStore) GetAll(ctx context.Context) ([]models.Todo, error) {
+	return s.Search(ctx, FilterOptions{})
+}
+
+// matches reports whether todo passes the filters in opts
+func matches(todo models.Todo, opts FilterOptions) bool {
+	if opts.Search != "" {
+		term := strings.ToLower(opts.Search)
+		if !strings.Contains(strings.ToLower(todo.Title), term) &&
+			!strings.Contains(strings.ToLower(todo.Description), term) {
+			return false
+		}
+	}
+	if opts.Completed != nil && todo.Completed != *opts.Completed {
+		return false
+	}
+	if opts.Starred != nil && todo.Starred != *opts.Starred {
+		return false
+	}
+	if todo.Draft != opts.Drafts {
+		return false
+	}
+	if opts.CreatedAfter != nil && todo.CreatedAt.Before(*opts.CreatedAfter) {
+		return false
+	}
+	if opts.CreatedBefore != nil && !todo.CreatedAt.Before(*opts.CreatedBefore) {
+		return false
+	}
+	if opts.UpdatedAfter != nil && todo.UpdatedAt.Before(*opts.UpdatedAfter) {
+		return false
+	}
+	if opts.UpdatedBefore != nil && !todo.UpdatedAt.Before(*opts.UpdatedBefore) {
+		return false
+	}
+	return true
+}
+
+// compareTodos orders todos like orderBy, breaking remaining ties by ID
+func compareTodos(a, b models.Todo, sortBy, sortOrder string) int {
+	var c int
+	switch sortBy {
+	case "updated_at":
+		c = a.UpdatedAt.Compare(b.UpdatedAt)
+	case "title":
+		c = strings.Compare(a.Title, b.Title)
+	case "position":
+		c = cmp.Compare(a.Position, b.Position)
+	case "estimate_minutes":
+		c = cmp.Compare(a.EstimateMinutes, b.EstimateMinutes)
+	case "starred":
+		if a.Starred != b.Starred {
+			c = -1
+			if a.Starred {
+				c = 1
+			}
+		}
+	default:
+		c = a.CreatedAt.Compare(b.CreatedAt)
+	}
+	if c == 0 && sortBy == "starred" {
+		// Newest first within each group, whatever the direction
+		c = b.CreatedAt.Compare(a.CreatedAt)
+		if c == 0 {
+			c = cmp.Compare(b.ID, a.ID)
+		}
+		return c
+	}
+	if c == 0 {
+		c = cmp.Compare(a.ID, b.ID)
+	}
+	if sortOrder == "desc" {
+		c = -c
+	}
+	return c
+}
+
+// relevance scores how well todo matches a search term, using the same
+// ranks as TodoRepository's relevanceOrder
+func relevance(todo models.Todo, term string) int {
+	term = strings.ToLower(term)
+	title := strings.ToLower(todo.Title)
+	description := strings.ToLower(todo.Description)
+	switch {
+	case title == term:
+		return 5
+	case strings.HasPrefix(title, term):
+		return 4
+	case strings.Contains(title, term):
+		return 3
+	case strings.HasPrefix(description, term):
+		return 2
+	default:
+		return 1
+	}
+}
+
+// compareRelevance orders todos by relevance to term in sortOrder, newest
+// first within a rank
+func compareRelevance(a, b models.Todo, term, sortOrder string) int {
+	c := cmp.Compare(relevance(a, term), relevance(b, term))
+	if sortOrder == "desc" {
+		c = -c
+	}
+	if c == 0 {
+		c = b.CreatedAt.Compare(a.CreatedAt)
+	}
+	if c == 0 {
+		c = cmp.Compare(b.ID, a.ID)
+	}
+	return c
+}
+
+// Search returns the todos matching opts, applying the same validation,
+// ordering and keyset pagination as TodoRepository.Search
+func (s *MemoryTodoStore) Search(_ context.Context, opts FilterOptions) ([]models.Todo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sortBy := s.defaultSortBy
+	if opts.SortBy == SortRelevance {
+		if opts.Search == "" {
+			return nil, ErrRelevanceWithoutSearch
+		}
+		sortBy = SortRelevance
+	} else if opts.SortBy != "" {
+		if !validSortFields[opts.SortBy] {
+			return nil, fmt.Errorf("invalid sort field: %s", opts.SortBy)
+		}
+		sortBy = opts.SortBy
+	}
+
+	sortOrder := s.defaultSortOrder
+	if sortBy == SortRelevance {
+		sortOrder = "desc"
+	}
+	if opts.SortOrder != "" {
+		if opts.SortOrder != "asc" && opts.SortOrder != "desc" {
+			return nil, fmt.Errorf("invalid sort order: %s", opts.SortOrder)
+		}
+		sortOrder = opts.SortOrder
+	}
+
+	if opts.After != nil && sortBy != "created_at" {
+		return nil, fmt.Errorf("cursor pagination requires sorting by created_at")
+	}
+
+	var todos []models.Todo
+	for _, todo := range s.todos {
+		if !matches(todo, opts) {
+			continue
+		}
+		if opts.After != nil {
+			c := todo.CreatedAt.Compare(opts.After.CreatedAt)
+			if c == 0 {
+				c = cmp.Compare(todo.ID, opts.After.ID)
+			}
+			if (sortOrder == "asc" && c <= 0) || (sortOrder == "desc" && c >= 0) {
+				continue
+			}
+		}
+		todos = append(todos, todo)
+	}
+
+	slices.SortFunc(todos, func(a, b models.Todo) int {
+		if sortBy == SortRelevance {
+			return compareRelevance(a, b, opts.Search, sortOrder)
+		}
+		return compareTodos(a, b, sortBy, sortOrder)
+	})
+
+	if opts.Limit > 0 && len(todos) > opts.Limit {
+		todos = todos[:opts.Limit]
+	}
+
+	return todos, nil
+}
+
+// Stream calls fn with each todo matching opts, in the same order as
+// Search, stopping at the first error fn returns
+func (s *MemoryTodoStore) Stream(ctx context.Context, opts FilterOptions, fn func(models.Todo) error) error {
+	todos, err := s.Search(ctx, opts)
+	if err != nil {
+		return err
+	}
+	for _, todo := range todos {
+		if err := fn(todo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Random returns a randomly chosen todo matching the filters in opts, or nil
+// and no error if none match
+func (s *MemoryTodoStore) Random(_ context.Context, opts FilterOptions) (*models.Todo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var candidates []models.Todo
+	for _, todo := range s.todos {
+		if matches(todo, opts) {
+			candidates = append(candidates, todo)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	todo := candidates[rand.IntN(len(candidates))]
+	return &todo, nil
+}
+
+// Count returns the number of todos matching the filters in opts
+func (s *MemoryTodoStore) Count(_ context.Context, opts FilterOptions) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var count int64
+	for _, todo := range s.todos {
+		if matches(todo, opts) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// RemainingMinutes returns the summed estimates of the incomplete todos
+// matching the filters in opts
+func (s *MemoryTodoStore) RemainingMinutes(_ context.Context, opts FilterOptions) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var minutes int64
+	for _, todo := range s.todos {
+		if matches(todo, opts) && !todo.Completed {
+			minutes += todo.EstimateMinutes
+		}
+	}
+	return minutes, nil
+}
+
+// Reminders returns the incomplete todos whose reminder falls between from
+// and to, soonest first. Todos snoozed past from are left out unless
+// includeSnoozed is set.
+func (s *MemoryTodoStore) Reminders(_ context.Context, from, to time.Time, includeSnoozed bool) ([]models.Todo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var todos []models.Todo
+	for _, todo := range s.todos {
+		if todo.Completed || todo.Draft || todo.RemindAt == nil {
+			continue
+		}
+		if todo.RemindAt.Before(from) || todo.RemindAt.After(to) {
+			continue
+		}
+		if !includeSnoozed && todo.SnoozedUntil != nil && todo.SnoozedUntil.After(from) {
+			continue
+		}
+		todos = append(todos, todo)
+	}
+
+	slices.SortFunc(todos, func(a, b models.Todo) int {
+		if c := a.RemindAt.Compare(*b.RemindAt); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return todos, nil
+}
+
+// Schedule returns the todos, other than drafts, scheduled at or after from
+// and before to, in scheduled order
+func (s *MemoryTodoStore) Schedule(_ context.Context, from, to time.Time) ([]models.Todo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var todos []models.Todo
+	for _, todo := range s.todos {
+		if todo.Draft || todo.ScheduledAt == nil {
+			continue
+		}
+		if todo.ScheduledAt.Before(from) || !todo.ScheduledAt.Before(to) {
+			continue
+		}
+		todos = append(todos, todo)
+	}
+
+	slices.SortFunc(todos, func(a, b models.Todo) int {
+		if c := a.ScheduledAt.Compare(*b.ScheduledAt); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return todos, nil
+}
+
+// Changes returns the todos updated or deleted after since, in the order
+// they changed, like TodoRepository.Changes
+func (s *MemoryTodoStore) Changes(_ context.Context, since time.Time, after *SyncCursor, limit int) ([]models.TodoChange, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var changes []models.TodoChange
+	for _, todo := range s.todos {
+		changes = append(changes, models.TodoChange{Todo: todo})
+	}
+	for id, t := range s.deleted {
+		changes = append(changes, models.TodoChange{
+			Todo:    models.Todo{ID: id, PublicID: t.publicID, CreatedAt: t.deletedAt, UpdatedAt: t.deletedAt},
+			Deleted: true,
+		})
+	}
+
+	compare := func(updatedAt time.Time, id int64, c models.TodoChange) int {
+		if r := updatedAt.Compare(c.UpdatedAt); r != 0 {
+			return r
+		}
+		return cmp.Compare(id, c.ID)
+	}
+
+	changes = slices.DeleteFunc(changes, func(c models.TodoChange) bool {
+		if !c.UpdatedAt.After(since) {
+			return true
+		}
+		return after != nil && compare(after.UpdatedAt, after.ID, c) >= 0
+	})
+	slices.SortFunc(changes, func(a, b models.TodoChange) int {
+		return compare(a.UpdatedAt, a.ID, b)
+	})
+
+	if limit > 0 && len(changes) > limit {
+		changes = changes[:limit]
+	}
+
+	return changes, nil
+}
+
+// GetByID returns a todo by ID, or nil if it does not exist
+func (s *MemoryTodoStore) GetByID(_ context.Context, id int64) (*models.Todo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	todo, ok := s.todos[id]
+	if !ok {
+		return nil, nil
+	}
+	return &todo, nil
+}
+
+// GetByPublicID returns the todo with the given public id, or nil if there
+// is none
+func (s *MemoryTodoStore) GetByPublicID(_ context.Context, publicID string) (*models.Todo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, todo := range s.todos {
+		if todo.PublicID == publicID {
+			return &todo, nil
+		}
+	}
+	return nil, nil
+}
+
+// GetBySlug returns the todo with the given slug, or nil if there is none
+func (s *MemoryTodoStore) GetBySlug(_ context.Context, slug string) (*models.Todo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, todo := range s.todos {
+		if todo.Slug == slug {
+			return &todo, nil
+		}
+	}
+	return nil, nil
+}
+
+// GetByExternalID returns the todo with the given external id, or nil if
+// there is none
+func (s *MemoryTodoStore) GetByExternalID(_ context.Context, externalID string) (*models.Todo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, todo := range s.todos {
+		if todo.ExternalID != nil && *todo.ExternalID == externalID {
+			return &todo, nil
+		}
+	}
+	return nil, nil
+}
+
+// GetByIDs returns the todos with the given ids in the order the ids were
+// given, skipping ids that do not exist
+func (s *MemoryTodoStore) GetByIDs(_ context.Context, ids []int64) ([]models.Todo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var todos []models.Todo
+	for _, id := range ids {
+		if todo, ok := s.todos[id]; ok {
+			todos = append(todos, todo)
+		}
+	}
+	return todos, nil
+}
+
+// Update updates a todo, returning nil if it does not exist
+func (s *MemoryTodoStore) Update(_ context.Context, id int64, req models.UpdateTodoRequest) (*models.Todo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	todo, ok := s.todos[id]
+	if !ok {
+		return nil, nil
+	}
+	if req.IfUnmodifiedSince != nil && todo.UpdatedAt.After(*req.IfUnmodifiedSince) {
+		return nil, ErrStaleUpdate
+	}
+
+	todo, err := s.apply(todo, req, utcNow())
+	if err != nil {
+		return nil, err
+	}
+	s.todos[id] = todo
+
+	return &todo, nil
+}
+
+// apply returns todo with the changes in req made, checking the result
+// against the table's constraints; the caller must hold the lock
+func (s *MemoryTodoStore) apply(todo models.Todo, req models.UpdateTodoRequest, now time.Time) (models.Todo, error) {
+	todo.UpdatedAt = now
+	if req.Title != nil {
+		if *req.Title != todo.Title && !s.stableSlugs {
+			todo.Slug = s.uniqueSlug(*req.Title, todo.ID)
+		}
+		todo.Title = *req.Title
+	}
+	if req.Description != nil {
+		todo.Description = *req.Description
+	}
+	if req.Completed != nil {
+		todo.Completed = *req.Completed
+	}
+	if req.Starred != nil {
+		todo.Starred = *req.Starred
+	}
+	if req.RemindAt != nil {
+		todo.RemindAt = utcTime(req.RemindAt)
+	}
+	if req.ClearRemindAt {
+		todo.RemindAt = nil
+	}
+	if req.ScheduledAt != nil {
+		todo.ScheduledAt = utcTime(req.ScheduledAt)
+	}
+	if req.ClearScheduledAt {
+		todo.ScheduledAt = nil
+	}
+	if req.SnoozedUntil != nil {
+		todo.SnoozedUntil = utcTime(req.SnoozedUntil)
+	}
+	if req.Draft != nil {
+		todo.Draft = *req.Draft
+	}
+	if req.Metadata == nil && req.MetadataPatch != nil {
+		req.Metadata = todo.Metadata.Merge(req.MetadataPatch)
+	}
+	if req.Metadata != nil {
+		metadata, err := cloneMetadata(req.Metadata)
+		if err != nil {
+			return todo, fmt.Errorf("failed to update todo: %w", err)
+		}
+		todo.Metadata = metadata
+	}
+	if req.EstimateMinutes != nil {
+		todo.EstimateMinutes = *req.EstimateMinutes
+	}
+	if err := checkLengths(todo.Title, todo.Description); err != nil {
+		return todo, fmt.Errorf("failed to update todo: %w", err)
+	}
+	if err := s.checkTitle(todo.ID, todo.Title); err != nil {
+		return todo, fmt.Errorf("failed to update todo: %w", err)
+	}
+	return todo, nil
+}
+
+// Upsert creates a todo with externalID from req or, if one exists, replaces
+// its fields with req's. It reports whether the todo was created.
+func (s *MemoryTodoStore) Upsert(_ context.Context, externalID string, req models.UpsertTodoRequest) (*models.Todo, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := utcNow()
+	for id, todo := range s.todos {
+		if todo.ExternalID == nil || *todo.ExternalID != externalID {
+			continue
+		}
+		metadata := req.Metadata
+		if metadata == nil {
+			// An empty object removes the todo's metadata
+			metadata = models.Metadata{}
+		}
+		todo, err := s.apply(todo, models.UpdateTodoRequest{
+			Title:            &req.Title,
+			Description:      &req.Description,
+			Completed:        &req.Completed,
+			Starred:          &req.Starred,
+			RemindAt:         req.RemindAt,
+			ClearRemindAt:    req.RemindAt == nil,
+			ScheduledAt:      req.ScheduledAt,
+			ClearScheduledAt: req.ScheduledAt == nil,
+			Draft:            &req.Draft,
+			Metadata:         metadata,
+			EstimateMinutes:  &req.EstimateMinutes,
+		}, now)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to upsert todo: %w", err)
+		}
+		s.todos[id] = todo
+		return &todo, false, nil
+	}
+
+	if err := checkLengths(req.Title, req.CreateDescription()); err != nil {
+		return nil, false, fmt.Errorf("failed to upsert todo: %w", err)
+	}
+	if err := s.checkTitle(0, req.Title); err != nil {
+		return nil, false, fmt.Errorf("failed to upsert todo: %w", err)
+	}
+	metadata, err := cloneMetadata(req.Metadata)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to upsert todo: %w", err)
+	}
+
+	todo := s.create(models.CreateTodoRequest{
+		Title:           req.Title,
+		Description:     req.CreateDescription(),
+		RemindAt:        req.RemindAt,
+		ScheduledAt:     req.ScheduledAt,
+		Draft:           req.Draft,
+		Metadata:        metadata,
+		EstimateMinutes: req.EstimateMinutes,
+	}, now)
+	todo.ExternalID = &externalID
+	todo.Completed = req.Completed
+	todo.Starred = req.Starred
+	s.todos[todo.ID] = todo
+	return &todo, true, nil
+}
+
+// UpdateMany applies each update in items, in order, returning the updated
+// todos and the ids that do not exist. If an update fails, or with atomic
+// any id is missing, none of the updates are kept.
+func (s *MemoryTodoStore) UpdateMany(_ context.Context, items []models.BatchUpdateItem, atomic bool) (updated []models.Todo, notFound []int64, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Earlier items are undone from the originals if a later one fails
+	originals := make(map[int64]models.Todo)
+	defer func() {
+		if err != nil {
+			for id, todo := range originals {
+				s.todos[id] = todo
+			}
+		}
+	}()
+
+	now := utcNow()
+	for _, item := range items {
+		id := int64(item.ID)
+		todo, ok := s.todos[id]
+		if !ok {
+			notFound = append(notFound, id)
+			continue
+		}
+		if item.IfUnmodifiedSince != nil && todo.UpdatedAt.After(*item.IfUnmodifiedSince) {
+			return nil, nil, fmt.Errorf("failed to update todo %d: %w", id, ErrStaleUpdate)
+		}
+		if _, seen := originals[id]; !seen {
+			originals[id] = todo
+		}
+
+		if todo, err = s.apply(todo, item.UpdateTodoRequest, now); err != nil {
+			return nil, nil, err
+		}
+		s.todos[id] = todo
+		updated = append(updated, todo)
+	}
+
+	if atomic && len(notFound) > 0 {
+		return nil, notFound, ErrTodosNotFound
+	}
+
+	return updated, notFound, nil
+}
+
+// delete removes a todo and records its tombstone; the caller must hold
+// the write lock
+func (s *MemoryTodoStore) delete(id int64, now time.Time) {
+	s.deleted[id] = tombstone{publicID: s.todos[id].PublicID, deletedAt: now}
+	delete(s.todos, id)
+	delete(s.accessed, id)
+}
+
+// RecordAccess notes that the todo with id was just viewed, for Recent. It
+// does nothing if the todo does not exist.
+func (s *MemoryTodoStore) RecordAccess(_ context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.todos[id]; ok {
+		s.accessed[id] = utcNow()
+	}
+	return nil
+}
+
+// Recent returns up to limit todos that have been viewed, most recently
+// viewed first
+func (s *MemoryTodoStore) Recent(_ context.Context, limit int) ([]models.Todo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var todos []models.Todo
+	for id := range s.accessed {
+		todos = append(todos, s.todos[id])
+	}
+	slices.SortFunc(todos, func(a, b models.Todo) int {
+		if c := s.accessed[b.ID].Compare(s.accessed[a.ID]); c != 0 {
+			return c
+		}
+		return cmp.Compare(b.ID, a.ID)
+	})
+
+	if len(todos) > limit {
+		todos = todos[:limit]
+	}
+	return todos, nil
+}
+
+// Delete deletes a todo by ID and returns the deleted todo. It returns
+// sql.ErrNoRows if the todo does not exist.
+func (s *MemoryTodoStore) Delete(_ context.Context, id int64) (*models.Todo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	todo, ok := s.todos[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	s.delete(id, utcNow())
+
+	return &todo, nil
+}
+
+// DeleteAll deletes every todo and returns how many were deleted
+func (s *MemoryTodoStore) DeleteAll(_ context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := utcNow()
+	deleted := int64(len(s.todos))
+	for id := range s.todos {
+		s.delete(id, now)
+	}
+	return deleted, nil
+}
+
+// SetCompleted sets the completed flag on each of the given todos, returning
+// the updated todos along with any ids that do not exist
+func (s *MemoryTodoStore) SetCompleted(_ context.Context, ids []int64, completed bool) (updated []models.Todo, notFound []int64, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := utcNow()
+	for _, id := range ids {
+		todo, ok := s.todos[id]
+		if !ok {
+			notFound = append(notFound, id)
+			continue
+		}
+		todo.Completed = completed
+		todo.UpdatedAt = now
+		s.todos[id] = todo
+		updated = append(updated, todo)
+	}
+
+	return updated, notFound, nil
+}
+
+// SetFocus sets whether the todo with id is the focused todo, returning it,
+// or nil and no error if it does not exist. Focusing a todo unfocuses any
+// other, so at most one is focused at a time.
+func (s *MemoryTodoStore) SetFocus(_ context.Context, id int64, focused bool) (*models.Todo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	todo, ok := s.todos[id]
+	if !ok {
+		return nil, nil
+	}
+
+	now := utcNow()
+	if focused {
+		for otherID, other := range s.todos {
+			if other.Focused && otherID != id {
+				other.Focused = false
+				other.UpdatedAt = now
+				s.todos[otherID] = other
+			}
+		}
+	}
+
+	todo.Focused = focused
+	todo.UpdatedAt = now
+	s.todos[id] = todo
+	return &todo, nil
+}
+
+// Focused returns the focused todo, or nil and no error if there is none
+func (s *MemoryTodoStore) Focused(_ context.Context) (*models.Todo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, todo := range s.todos {
+		if todo.Focused {
+			return &todo, nil
+		}
+	}
+	return nil, nil
+}
+
+// ToggleCompleted flips the completed flag of each todo in ids to its
+// opposite, returning the updated todos and the ids that do not exist
+func (s *MemoryTodoStore) ToggleCompleted(_ context.Context, ids []int64) (updated []models.Todo, notFound []int64, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := utcNow()
+	for _, id := range ids {
+		todo, ok := s.todos[id]
+		if !ok {
+			notFound = append(notFound, id)
+			continue
+		}
+		todo.Completed = !todo.Completed
+		todo.UpdatedAt = now
+		s.todos[id] = todo
+		updated = append(updated, todo)
+	}
+
+	return updated, notFound, nil
+}
+
+// CompleteMatching marks every incomplete todo matching the filters in opts
+// as completed and returns how many it completed. The memory store has no
+// dependencies, so no todo is skipped as blocked.
+func (s *MemoryTodoStore) CompleteMatching(_ context.Context, opts FilterOptions) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := utcNow()
+	var completed int64
+	for id, todo := range s.todos {
+		if todo.Completed || !matches(todo, opts) {
+			continue
+		}
+		todo.Completed = true
+		todo.UpdatedAt = now
+		s.todos[id] = todo
+		completed++
+	}
+
+	return completed, nil
+}
+
+// Move places a todo directly after the todo with id after, or first in
+// the list when after is nil, like TodoRepository.Move
+func (s *MemoryTodoStore) Move(_ context.Context, id int64, after *int64) (*models.Todo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	todo, ok := s.todos[id]
+	if !ok {
+		return nil, nil
+	}
+	if after != nil {
+		if _, ok := s.todos[*after]; !ok {
+			return nil, ErrMoveAfterNotFound
+		}
+		if *after == id {
+			return &todo, nil
+		}
+	}
+
+	now := utcNow()
+	position, ok := positionBetween(s.moveNeighbours(id, after))
+	if !ok {
+		s.rebalancePositions(now)
+		position, _ = positionBetween(s.moveNeighbours(id, after))
+	}
+
+	todo = s.todos[id]
+	todo.Position = position
+	todo.UpdatedAt = now
+	s.todos[id] = todo
+
+	return &todo, nil
+}
+
+// ordered returns every todo sorted by (position, id); the caller must hold
+// the lock
+func (s *MemoryTodoStore) ordered() []models.Todo {
+	todos := make([]models.Todo, 0, len(s.todos))
+	for _, todo := range s.todos {
+		todos = append(todos, todo)
+	}
+	slices.SortFunc(todos, func(a, b models.Todo) int {
+		return compareTodos(a, b, "position", "asc")
+	})
+	return todos
+}
+
+// moveNeighbours returns the positions id would sit between after being
+// moved, like the SQLite version; the caller must hold the lock
+func (s *MemoryTodoStore) moveNeighbours(id int64, after *int64) (prev, next *int64) {
+	todos := slices.DeleteFunc(s.ordered(), func(todo models.Todo) bool {
+		return todo.ID == id
+	})
+
+	i := 0
+	if after != nil {
+		i = slices.IndexFunc(todos, func(todo models.Todo) bool {
+			return todo.ID == *after
+		})
+		prev = &todos[i].Position
+		i++
+	}
+	if i < len(todos) {
+		next = &todos[i].Position
+	}
+	return prev, next
+}
+
+// rebalancePositions respaces every todo positionGap apart, keeping their
+// order, and stamps respaced todos with now; the caller must hold the write
+// lock
+func (s *MemoryTodoStore) rebalancePositions(now time.Time) {
+	for i, todo := range s.ordered() {
+		position := int64(i+1) * positionGap
+		if todo.Position != position {
+			todo.Position = position
+			todo.UpdatedAt = now
+			s.todos[todo.ID] = todo
+		}
+	}
+}
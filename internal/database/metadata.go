@@ -0,0 +1,80 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/larryhudson/go-todo-list-claude/internal/models"
+)
+
+// MaxMetadataBytes caps the size of a todo's metadata once encoded as JSON
+const MaxMetadataBytes = 4096
+
+// errMetadataTooLarge is returned for metadata over MaxMetadataBytes
+var errMetadataTooLarge = &ConstraintError{
+	Kind:    ConstraintCheck,
+	Message: fmt.Sprintf("metadata must be at most %d bytes", MaxMetadataBytes),
+}
+
+// encodeMetadata returns the JSON stored in the metadata column, or nil for
+// empty metadata so that the column stays NULL
+func encodeMetadata(m models.Metadata) (*string, error) {
+	if len(m) == 0 {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode metadata: %w", err)
+	}
+	if len(data) > MaxMetadataBytes {
+		return nil, errMetadataTooLarge
+	}
+
+	s := string(data)
+	return &s, nil
+}
+
+// cloneMetadata returns a copy of m as it would read back from the
+// database, so stores without one hold the same values and share no maps
+// with callers
+func cloneMetadata(m models.Metadata) (models.Metadata, error) {
+	encoded, err := encodeMetadata(m)
+	if err != nil || encoded == nil {
+		return nil, err
+	}
+
+	var clone models.Metadata
+	if err := json.Unmarshal([]byte(*encoded), &clone); err != nil {
+		return nil, fmt.Errorf("failed to decode metadata: %w", err)
+	}
+	return clone, nil
+}
+
+// metadataColumn scans the metadata column into a todo
+type metadataColumn struct {
+	dest *models.Metadata
+}
+
+// Scan implements sql.Scanner
+func (c metadataColumn) Scan(src interface{}) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		*c.dest = nil
+		return nil
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return fmt.Errorf("unexpected metadata type %T", src)
+	}
+
+	var m models.Metadata
+	if err := json.Unmarshal(data, &m); err != nil {
+		return fmt.Errorf("failed to decode metadata: %w", err)
+	}
+	*c.dest = m
+	return nil
+}
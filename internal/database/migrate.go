@@ -2,26 +2,57 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"embed"
 	"fmt"
+	"io/fs"
+	"log/slog"
 	"sort"
 	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
 )
 
+// Migrations holds the SQL migration files applied by Migrator
+//
+//go:embed migrations/*.sql
+var Migrations embed.FS
+
 // Migrator handles database migrations
 type Migrator struct {
 	db *DB
-	fs embed.FS
+	fs fs.FS
 }
 
-// NewMigrator creates a new Migrator
-func NewMigrator(db *DB, fs embed.FS) *Migrator {
+// NewMigrator creates a new Migrator reading *.sql files from the
+// migrations directory of fs
+func NewMigrator(db *DB, fs fs.FS) *Migrator {
 	return &Migrator{
 		db: db,
 		fs: fs,
 	}
 }
 
+// MigrationStatus reports whether a migration file has been applied
+type MigrationStatus struct {
+	Filename string
+	Applied  bool
+}
+
+// AppliedMigration records when a migration file was applied
+type AppliedMigration struct {
+	Filename  string    `json:"filename"`
+	AppliedAt time.Time `json:"appliedAt"`
+}
+
+// MigrationHistory lists the applied migrations and the pending migration
+// files, each sorted by filename
+type MigrationHistory struct {
+	Applied []AppliedMigration
+	Pending []string
+}
+
 // Run executes all pending migrations
 func (m *Migrator) Run() error {
 	// Create migrations table if it doesn't exist
@@ -29,30 +60,20 @@ func (m *Migrator) Run() error {
 		return fmt.Errorf("failed to create migrations table: %w", err)
 	}
 
-	// Get list of migration files
-	entries, err := m.fs.ReadDir("migrations")
+	migrationFiles, err := m.migrationFiles()
 	if err != nil {
-		return fmt.Errorf("failed to read migrations directory: %w", err)
-	}
-
-	// Sort migration files by name
-	var migrationFiles []string
-	for _, entry := range entries {
-		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".sql") {
-			migrationFiles = append(migrationFiles, entry.Name())
-		}
+		return err
 	}
-	sort.Strings(migrationFiles)
 
 	// Get already applied migrations
-	applied, err := m.getAppliedMigrations()
+	applied, err := m.getAppliedMigrations(context.Background())
 	if err != nil {
 		return fmt.Errorf("failed to get applied migrations: %w", err)
 	}
 
 	// Apply pending migrations
 	for _, filename := range migrationFiles {
-		if applied[filename] {
+		if _, ok := applied[filename]; ok {
 			continue
 		}
 
@@ -64,6 +85,126 @@ func (m *Migrator) Run() error {
 	return nil
 }
 
+// Status reports every migration file in order and whether it has been
+// applied. Unlike Run it never writes to the database: if the migrations
+// table does not exist yet it is not created, and every migration is
+// reported as pending.
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	ctx := context.Background()
+	migrationFiles, err := m.migrationFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	exists, err := m.migrationsTableExists(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check migrations table: %w", err)
+	}
+
+	applied := map[string]time.Time{}
+	if exists {
+		if applied, err = m.getAppliedMigrations(ctx); err != nil {
+			return nil, fmt.Errorf("failed to get applied migrations: %w", err)
+		}
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrationFiles))
+	for _, filename := range migrationFiles {
+		_, ok := applied[filename]
+		statuses = append(statuses, MigrationStatus{Filename: filename, Applied: ok})
+	}
+
+	return statuses, nil
+}
+
+// History returns the rows of the migrations table along with the
+// migration files that have not been applied yet. Applied migrations whose
+// file no longer exists are still listed. Like Status it does not create
+// the migrations table. The queries are cancelled with ctx.
+func (m *Migrator) History(ctx context.Context) (*MigrationHistory, error) {
+	migrationFiles, err := m.migrationFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	exists, err := m.migrationsTableExists(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check migrations table: %w", err)
+	}
+
+	applied := map[string]time.Time{}
+	if exists {
+		if applied, err = m.getAppliedMigrations(ctx); err != nil {
+			return nil, fmt.Errorf("failed to get applied migrations: %w", err)
+		}
+	}
+
+	history := &MigrationHistory{
+		Applied: make([]AppliedMigration, 0, len(applied)),
+		Pending: []string{},
+	}
+	for filename, appliedAt := range applied {
+		history.Applied = append(history.Applied, AppliedMigration{Filename: filename, AppliedAt: appliedAt})
+	}
+	sort.Slice(history.Applied, func(i, j int) bool {
+		return history.Applied[i].Filename < history.Applied[j].Filename
+	})
+	for _, filename := range migrationFiles {
+		if _, ok := applied[filename]; !ok {
+			history.Pending = append(history.Pending, filename)
+		}
+	}
+
+	return history, nil
+}
+
+// DryRun returns the filenames of the migrations Run would apply, in order,
+// without executing them. Like Status it does not create the migrations
+// table.
+func (m *Migrator) DryRun() ([]string, error) {
+	statuses, err := m.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []string
+	for _, status := range statuses {
+		if !status.Applied {
+			pending = append(pending, status.Filename)
+		}
+	}
+
+	return pending, nil
+}
+
+// migrationFiles returns the names of the migration files, sorted by name
+func (m *Migrator) migrationFiles() ([]string, error) {
+	entries, err := fs.ReadDir(m.fs, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations directory: %w", err)
+	}
+
+	var migrationFiles []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".sql") {
+			migrationFiles = append(migrationFiles, entry.Name())
+		}
+	}
+	sort.Strings(migrationFiles)
+
+	return migrationFiles, nil
+}
+
+// migrationsTableExists reports whether the migrations tracking table exists
+func (m *Migrator) migrationsTableExists(ctx context.Context) (bool, error) {
+	var count int
+	query := "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'"
+	if err := m.db.QueryRowContext(ctx, query).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
 // createMigrationsTable creates the migrations tracking table
 func (m *Migrator) createMigrationsTable() error {
 	query := `
@@ -77,10 +218,11 @@ func (m *Migrator) createMigrationsTable() error {
 	return err
 }
 
-// getAppliedMigrations returns a set of already applied migration filenames
-func (m *Migrator) getAppliedMigrations() (map[string]bool, error) {
-	query := "SELECT filename FROM schema_migrations"
-	rows, err := m.db.QueryContext(context.Background(), query)
+// getAppliedMigrations returns the already applied migration filenames,
+// mapped to when each was applied
+func (m *Migrator) getAppliedMigrations(ctx context.Context) (map[string]time.Time, error) {
+	query := "SELECT filename, applied_at FROM schema_migrations"
+	rows, err := m.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
@@ -90,26 +232,45 @@ func (m *Migrator) getAppliedMigrations() (map[string]bool, error) {
 		}
 	}()
 
-	applied := make(map[string]bool)
+	applied := make(map[string]time.Time)
 	for rows.Next() {
 		var filename string
-		if err := rows.Scan(&filename); err != nil {
+		var appliedAt time.Time
+		if err := rows.Scan(&filename, &appliedAt); err != nil {
 			return nil, err
 		}
-		applied[filename] = true
+		applied[filename] = appliedAt.UTC()
 	}
 
 	return applied, rows.Err()
 }
 
-// applyMigration applies a single migration file
+// applyMigration applies a single migration file. Its statements run one at
+// a time in a transaction, unless the file opts out with noTransactionDirective.
 func (m *Migrator) applyMigration(filename string) error {
 	// Read migration file
-	content, err := m.fs.ReadFile("migrations/" + filename)
+	content, err := fs.ReadFile(m.fs, "migrations/"+filename)
 	if err != nil {
 		return fmt.Errorf("failed to read migration file: %w", err)
 	}
 
+	statements := splitStatements(string(content))
+	if hasNoTransactionDirective(string(content)) {
+		err = m.applyWithoutTransaction(filename, statements)
+	} else {
+		err = m.applyInTransaction(filename, statements)
+	}
+	if err != nil {
+		return err
+	}
+
+	slog.Info("Applied migration", "filename", filename)
+	return nil
+}
+
+// applyInTransaction runs a migration's statements and records it as
+// applied in a single transaction
+func (m *Migrator) applyInTransaction(filename string, statements []string) (err error) {
 	ctx := context.Background()
 
 	// Begin transaction
@@ -126,8 +287,10 @@ func (m *Migrator) applyMigration(filename string) error {
 	}()
 
 	// Execute migration SQL
-	if _, err = tx.ExecContext(ctx, string(content)); err != nil {
-		return fmt.Errorf("failed to execute migration SQL: %w", err)
+	for i, stmt := range statements {
+		if _, err = tx.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to execute statement %d: %w", i+1, err)
+		}
 	}
 
 	// Record migration as applied
@@ -141,6 +304,80 @@ func (m *Migrator) applyMigration(filename string) error {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	fmt.Printf("Applied migration: %s\n", filename)
+	return nil
+}
+
+// applyWithoutTransaction runs a migration's statements one by one outside a
+// transaction, then records it as applied. A failure part way through leaves
+// the earlier statements applied, so such migrations should be idempotent.
+func (m *Migrator) applyWithoutTransaction(filename string, statements []string) (err error) {
+	ctx := context.Background()
+
+	// Pin a single connection so connection-scoped statements such as
+	// PRAGMAs affect the statements that follow them
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	// The connection goes back to the pool afterwards, so a failure must not
+	// leave it inside the migration's own transaction or with foreign keys
+	// switched off
+	var foreignKeys bool
+	if err = conn.QueryRowContext(ctx, "PRAGMA foreign_keys").Scan(&foreignKeys); err != nil {
+		return fmt.Errorf("failed to read foreign_keys: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if resetErr := resetConn(ctx, conn, foreignKeys); resetErr != nil {
+				err = fmt.Errorf("failed to reset connection: %v (original error: %w)", resetErr, err)
+			}
+		}
+	}()
+
+	for i, stmt := range statements {
+		if _, err = conn.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to execute statement %d: %w", i+1, err)
+		}
+	}
+
+	query := "INSERT INTO schema_migrations (filename) VALUES (?)"
+	if _, err = conn.ExecContext(ctx, query, filename); err != nil {
+		return fmt.Errorf("failed to record migration: %w", err)
+	}
+
+	return nil
+}
+
+// resetConn rolls back any transaction a failed migration left open on conn
+// and restores its foreign_keys setting, which cannot change while a
+// transaction is open
+func resetConn(ctx context.Context, conn *sql.Conn, foreignKeys bool) error {
+	var inTransaction bool
+	err := conn.Raw(func(driverConn interface{}) error {
+		if c, ok := driverConn.(*sqlite3.SQLiteConn); ok {
+			inTransaction = !c.AutoCommit()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if inTransaction {
+		if _, err := conn.ExecContext(ctx, "ROLLBACK"); err != nil {
+			return fmt.Errorf("failed to roll back: %w", err)
+		}
+	}
+
+	pragma := "PRAGMA foreign_keys = OFF"
+	if foreignKeys {
+		pragma = "PRAGMA foreign_keys = ON"
+	}
+	if _, err := conn.ExecContext(ctx, pragma); err != nil {
+		return fmt.Errorf("failed to restore foreign_keys: %w", err)
+	}
 	return nil
 }
@@ -0,0 +1,145 @@
+package database
+
+import "strings"
+
+const (
+	// statementBeginMarker and statementEndMarker wrap a statement that must
+	// not be split on semicolons, such as a CREATE TRIGGER body
+	statementBeginMarker = "-- +migrate StatementBegin"
+	statementEndMarker   = "-- +migrate StatementEnd"
+
+	// noTransactionDirective, among the comments at the top of a migration,
+	// runs it outside a transaction, e.g. for VACUUM or PRAGMA foreign_keys
+	noTransactionDirective = "-- migrate:no-transaction"
+)
+
+// hasNoTransactionDirective reports whether the comment lines at the top of
+// a migration include noTransactionDirective
+func hasNoTransactionDirective(content string) bool {
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == noTransactionDirective {
+			return true
+		}
+		if line != "" && !strings.HasPrefix(line, "--") {
+			return false
+		}
+	}
+	return false
+}
+
+// splitStatements splits a migration into individual statements. Text
+// between statementBeginMarker and statementEndMarker lines is kept as one
+// statement; elsewhere statements end at semicolons outside of quotes and
+// comments. Statements consisting only of comments are dropped.
+func splitStatements(content string) []string {
+	var statements []string
+	var segment strings.Builder
+
+	flushSegment := func() {
+		statements = append(statements, splitOnSemicolons(segment.String())...)
+		segment.Reset()
+	}
+
+	inBlock := false
+	for _, line := range strings.SplitAfter(content, "\n") {
+		switch strings.TrimSpace(line) {
+		case statementBeginMarker:
+			flushSegment()
+			inBlock = true
+		case statementEndMarker:
+			if stmt := strings.TrimSpace(segment.String()); stmt != "" {
+				statements = append(statements, stmt)
+			}
+			segment.Reset()
+			inBlock = false
+		default:
+			segment.WriteString(line)
+		}
+	}
+	if inBlock {
+		// An unterminated block runs to the end of the file
+		if stmt := strings.TrimSpace(segment.String()); stmt != "" {
+			statements = append(statements, stmt)
+		}
+	} else {
+		flushSegment()
+	}
+
+	return statements
+}
+
+// splitOnSemicolons splits SQL at semicolons that are not inside a quoted
+// string, quoted identifier or comment
+func splitOnSemicolons(sql string) []string {
+	const (
+		normal = iota
+		singleQuote
+		doubleQuote
+		lineComment
+		blockComment
+	)
+
+	var statements []string
+	var current strings.Builder
+	hasCode := false // whether current contains anything besides comments
+
+	flush := func() {
+		if stmt := strings.TrimSpace(current.String()); hasCode && stmt != "" {
+			statements = append(statements, stmt)
+		}
+		current.Reset()
+		hasCode = false
+	}
+
+	state := normal
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		switch state {
+		case normal:
+			switch {
+			case c == ';':
+				flush()
+				continue
+			case c == '\'':
+				state = singleQuote
+				hasCode = true
+			case c == '"':
+				state = doubleQuote
+				hasCode = true
+			case c == '-' && i+1 < len(sql) && sql[i+1] == '-':
+				state = lineComment
+			case c == '/' && i+1 < len(sql) && sql[i+1] == '*':
+				state = blockComment
+				current.WriteByte(c)
+				i++
+				c = sql[i]
+			case c != ' ' && c != '\t' && c != '\n' && c != '\r':
+				hasCode = true
+			}
+		case singleQuote:
+			if c == '\'' {
+				state = normal
+			}
+		case doubleQuote:
+			if c == '"' {
+				state = normal
+			}
+		case lineComment:
+			if c == '\n' {
+				state = normal
+			}
+		case blockComment:
+			if c == '*' && i+1 < len(sql) && sql[i+1] == '/' {
+				state = normal
+				current.WriteByte(c)
+				i++
+				c = sql[i]
+			}
+		}
+		current.WriteByte(c)
+	}
+	flush()
+
+	return statements
+}
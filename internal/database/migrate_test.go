@@ -0,0 +1,252 @@
+package database
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want []string
+	}{
+		{
+			name: "semicolons",
+			sql:  "CREATE TABLE a (id INTEGER);\nCREATE TABLE b (id INTEGER);\n",
+			want: []string{"CREATE TABLE a (id INTEGER)", "CREATE TABLE b (id INTEGER)"},
+		},
+		{
+			name: "semicolons in strings and comments",
+			sql:  "-- first; statement\nINSERT INTO a VALUES ('x;y', \"z;\"); /* ; */ SELECT 1;",
+			want: []string{"-- first; statement\nINSERT INTO a VALUES ('x;y', \"z;\")", "/* ; */ SELECT 1"},
+		},
+		{
+			name: "escaped quote",
+			sql:  "INSERT INTO a VALUES ('it''s; fine');",
+			want: []string{"INSERT INTO a VALUES ('it''s; fine')"},
+		},
+		{
+			name: "comment only trailer",
+			sql:  "SELECT 1;\n-- done\n",
+			want: []string{"SELECT 1"},
+		},
+		{
+			name: "statement block",
+			sql: "CREATE TABLE a (id INTEGER);\n" +
+				"-- +migrate StatementBegin\n" +
+				"CREATE TRIGGER t AFTER INSERT ON a BEGIN\n  SELECT 1;\n  SELECT 2;\nEND;\n" +
+				"-- +migrate StatementEnd\n" +
+				"SELECT 3;\n",
+			want: []string{
+				"CREATE TABLE a (id INTEGER)",
+				"CREATE TRIGGER t AFTER INSERT ON a BEGIN\n  SELECT 1;\n  SELECT 2;\nEND;",
+				"SELECT 3",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		if got := splitStatements(tt.sql); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHasNoTransactionDirective(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want bool
+	}{
+		{"-- migrate:no-transaction\nVACUUM;", true},
+		{"-- Reclaim space\n\n-- migrate:no-transaction\nVACUUM;", true},
+		{"VACUUM;\n-- migrate:no-transaction\n", false},
+		{"VACUUM;", false},
+	}
+
+	for _, tt := range tests {
+		if got := hasNoTransactionDirective(tt.sql); got != tt.want {
+			t.Errorf("hasNoTransactionDirective(%q) = %v, want %v", tt.sql, got, tt.want)
+		}
+	}
+}
+
+// newMigrationTestDB opens an empty in-memory database
+func newMigrationTestDB(t *testing.T) *DB {
+	t.Helper()
+
+	db, err := New(":memory:", DefaultConfig())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	})
+	return db
+}
+
+func TestMigrator_MultiStatement(t *testing.T) {
+	db := newMigrationTestDB(t)
+
+	migrations := fstest.MapFS{
+		"migrations/001_notes.sql": {Data: []byte(`
+CREATE TABLE notes (id INTEGER PRIMARY KEY, body TEXT NOT NULL, edits INTEGER NOT NULL DEFAULT 0);
+INSERT INTO notes (body) VALUES ('first; note');
+
+-- +migrate StatementBegin
+CREATE TRIGGER notes_edited AFTER UPDATE OF body ON notes BEGIN
+    UPDATE notes SET edits = edits + 1 WHERE id = NEW.id;
+END;
+-- +migrate StatementEnd
+`)},
+		"migrations/002_broken.sql": {Data: []byte(`
+INSERT INTO notes (body) VALUES ('second');
+INSERT INTO missing_table VALUES (1);
+`)},
+	}
+
+	err := NewMigrator(db, migrations).Run()
+	if err == nil {
+		t.Fatal("Expected the broken migration to fail")
+	}
+
+	ctx := context.Background()
+	var count int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM notes").Scan(&count); err != nil {
+		t.Fatalf("Failed to count notes: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected the failed migration to be rolled back leaving 1 note, got %d", count)
+	}
+
+	if _, err := db.ExecContext(ctx, "UPDATE notes SET body = 'edited' WHERE id = 1"); err != nil {
+		t.Fatalf("Failed to update note: %v", err)
+	}
+	var edits int
+	if err := db.QueryRowContext(ctx, "SELECT edits FROM notes WHERE id = 1").Scan(&edits); err != nil {
+		t.Fatalf("Failed to read note: %v", err)
+	}
+	if edits != 1 {
+		t.Errorf("Expected the trigger to count 1 edit, got %d", edits)
+	}
+}
+
+func TestMigrator_NoTransaction(t *testing.T) {
+	db := newMigrationTestDB(t)
+
+	// VACUUM cannot run inside a transaction
+	migrations := fstest.MapFS{
+		"migrations/001_notes.sql":  {Data: []byte("CREATE TABLE notes (id INTEGER PRIMARY KEY);")},
+		"migrations/002_vacuum.sql": {Data: []byte("-- migrate:no-transaction\nVACUUM;")},
+	}
+
+	migrator := NewMigrator(db, migrations)
+	if err := migrator.Run(); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	pending, err := migrator.DryRun()
+	if err != nil {
+		t.Fatalf("DryRun failed: %v", err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("Expected the no-transaction migration to be recorded, pending: %v", pending)
+	}
+
+	// Without the directive the same migration fails
+	db = newMigrationTestDB(t)
+	migrations["migrations/002_vacuum.sql"] = &fstest.MapFile{Data: []byte("VACUUM;")}
+	if err := NewMigrator(db, migrations).Run(); err == nil {
+		t.Error("Expected VACUUM inside a transaction to fail")
+	}
+}
+
+func TestMigrator_NoTransactionFailure(t *testing.T) {
+	db := newMigrationTestDB(t)
+
+	// Fails inside its own transaction with foreign keys off, like a
+	// failed table rebuild
+	migrations := fstest.MapFS{
+		"migrations/001_rebuild.sql": {Data: []byte(`-- migrate:no-transaction
+PRAGMA foreign_keys = OFF;
+BEGIN;
+CREATE TABLE notes_new (id INTEGER PRIMARY KEY);
+INSERT INTO notes_new SELECT id FROM notes;
+COMMIT;
+PRAGMA foreign_keys = ON;
+`)},
+	}
+	if err := NewMigrator(db, migrations).Run(); err == nil {
+		t.Fatal("Expected the migration to fail")
+	}
+
+	ctx := context.Background()
+	var foreignKeys bool
+	if err := db.QueryRowContext(ctx, "PRAGMA foreign_keys").Scan(&foreignKeys); err != nil {
+		t.Fatalf("Failed to read foreign_keys: %v", err)
+	}
+	if !foreignKeys {
+		t.Error("Expected foreign keys to be switched back on")
+	}
+
+	// The half-done transaction was rolled back, so a new one can start
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("Failed to begin transaction: %v", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	var tables int
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master WHERE name = 'notes_new'").Scan(&tables); err != nil {
+		t.Fatalf("Failed to query schema: %v", err)
+	}
+	if tables != 0 {
+		t.Error("Expected the migration's table to be rolled back")
+	}
+}
+
+func TestMigrator_History(t *testing.T) {
+	db := newMigrationTestDB(t)
+
+	migrations := fstest.MapFS{
+		"migrations/002_tags.sql":  {Data: []byte("CREATE TABLE tags (id INTEGER PRIMARY KEY);")},
+		"migrations/001_notes.sql": {Data: []byte("CREATE TABLE notes (id INTEGER PRIMARY KEY);")},
+	}
+	migrator := NewMigrator(db, migrations)
+
+	history, err := migrator.History(context.Background())
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	if len(history.Applied) != 0 || !reflect.DeepEqual(history.Pending, []string{"001_notes.sql", "002_tags.sql"}) {
+		t.Errorf("Expected everything pending before the first run, got %+v", history)
+	}
+
+	if err := migrator.Run(); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	migrations["migrations/003_links.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE links (id INTEGER PRIMARY KEY);")}
+
+	history, err = migrator.History(context.Background())
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	var applied []string
+	for _, migration := range history.Applied {
+		applied = append(applied, migration.Filename)
+		if migration.AppliedAt.IsZero() {
+			t.Errorf("Expected %s to have an applied time", migration.Filename)
+		}
+	}
+	if !reflect.DeepEqual(applied, []string{"001_notes.sql", "002_tags.sql"}) {
+		t.Errorf("Expected the first two migrations applied, got %v", applied)
+	}
+	if !reflect.DeepEqual(history.Pending, []string{"003_links.sql"}) {
+		t.Errorf("Expected the new migration pending, got %v", history.Pending)
+	}
+}
@@ -0,0 +1,4 @@
+-- Add starred flag for pinning todos
+ALTER TABLE todos ADD COLUMN starred BOOLEAN NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_todos_starred ON todos(starred);
@@ -0,0 +1,4 @@
+-- Optional time at which to remind the user about a todo
+ALTER TABLE todos ADD COLUMN remind_at DATETIME;
+
+CREATE INDEX IF NOT EXISTS idx_todos_remind_at ON todos(remind_at);
@@ -0,0 +1,11 @@
+-- Rewrite timestamps stored with a local offset in UTC, matching how they
+-- are written from now on. Sub-millisecond precision is dropped for these
+-- existing rows.
+UPDATE todos SET
+    created_at = strftime('%Y-%m-%d %H:%M:%f', created_at) || '+00:00',
+    updated_at = strftime('%Y-%m-%d %H:%M:%f', updated_at) || '+00:00',
+    remind_at = strftime('%Y-%m-%d %H:%M:%f', remind_at) || '+00:00';
+
+UPDATE attachments SET created_at = strftime('%Y-%m-%d %H:%M:%f', created_at) || '+00:00';
+
+UPDATE comments SET created_at = strftime('%Y-%m-%d %H:%M:%f', created_at) || '+00:00';
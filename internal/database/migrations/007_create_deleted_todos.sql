@@ -0,0 +1,22 @@
+-- Tombstones for deleted todos, so sync clients can learn about deletions
+CREATE TABLE IF NOT EXISTS deleted_todos (
+    id INTEGER PRIMARY KEY,
+    deleted_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_deleted_todos_deleted_at ON deleted_todos(deleted_at);
+
+CREATE INDEX IF NOT EXISTS idx_todos_updated_at ON todos(updated_at);
+
+-- Record every deletion, however it happens. SQLite's clock only has
+-- millisecond precision, while todos are stamped by the server with finer
+-- precision. A truncated deletion time can land before an update made earlier
+-- in the same millisecond, or before a sync client's serverTime, hiding the
+-- deletion from that client. Rounding up to the next millisecond keeps every
+-- tombstone after the moment of deletion.
+-- +migrate StatementBegin
+CREATE TRIGGER IF NOT EXISTS todos_record_deletion AFTER DELETE ON todos BEGIN
+    INSERT OR REPLACE INTO deleted_todos (id, deleted_at)
+    VALUES (OLD.id, strftime('%Y-%m-%d %H:%M:%f', 'now', '+0.001 seconds') || '+00:00');
+END;
+-- +migrate StatementEnd
@@ -0,0 +1,58 @@
+-- Enforce maximum title and description lengths in the schema, so writes
+-- that bypass the API are held to the same limits.
+--
+-- SQLite cannot add a CHECK constraint to an existing table, so the table is
+-- rebuilt. Foreign keys must be off while the old table is dropped, or the
+-- drop would cascade to attachments and comments, and that pragma has no
+-- effect inside a transaction.
+-- migrate:no-transaction
+
+PRAGMA foreign_keys = OFF;
+
+BEGIN;
+
+CREATE TABLE todos_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    title TEXT NOT NULL,
+    description TEXT,
+    completed BOOLEAN NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    starred BOOLEAN NOT NULL DEFAULT 0,
+    remind_at DATETIME,
+    CONSTRAINT todos_title_length CHECK (length(title) <= 200),
+    CONSTRAINT todos_description_length CHECK (length(description) <= 5000)
+);
+
+-- Existing rows over the limits are truncated rather than failing startup
+INSERT INTO todos_new (id, title, description, completed, created_at, updated_at, starred, remind_at)
+SELECT id, substr(title, 1, 200), substr(description, 1, 5000), completed, created_at, updated_at, starred, remind_at
+FROM todos;
+
+-- Keep the AUTOINCREMENT high-water mark, so ids of deleted todos that sync
+-- clients hold tombstones for are never reused
+DELETE FROM sqlite_sequence WHERE name = 'todos_new';
+INSERT INTO sqlite_sequence (name, seq)
+SELECT 'todos_new', seq FROM sqlite_sequence WHERE name = 'todos';
+
+DROP TABLE todos;
+
+ALTER TABLE todos_new RENAME TO todos;
+
+CREATE INDEX IF NOT EXISTS idx_todos_completed ON todos(completed);
+CREATE INDEX IF NOT EXISTS idx_todos_created_at ON todos(created_at);
+CREATE INDEX IF NOT EXISTS idx_todos_starred ON todos(starred);
+CREATE INDEX IF NOT EXISTS idx_todos_remind_at ON todos(remind_at);
+CREATE INDEX IF NOT EXISTS idx_todos_updated_at ON todos(updated_at);
+
+-- Dropping the table dropped its trigger too
+-- +migrate StatementBegin
+CREATE TRIGGER IF NOT EXISTS todos_record_deletion AFTER DELETE ON todos BEGIN
+    INSERT OR REPLACE INTO deleted_todos (id, deleted_at)
+    VALUES (OLD.id, strftime('%Y-%m-%d %H:%M:%f', 'now', '+0.001 seconds') || '+00:00');
+END;
+-- +migrate StatementEnd
+
+COMMIT;
+
+PRAGMA foreign_keys = ON;
@@ -0,0 +1,8 @@
+-- Manual ordering. Positions are spaced 1000 apart so a todo can be moved
+-- between two others by rewriting only its own row; existing todos keep
+-- the order they were created in.
+ALTER TABLE todos ADD COLUMN position INTEGER NOT NULL DEFAULT 0;
+
+UPDATE todos SET position = id * 1000;
+
+CREATE INDEX IF NOT EXISTS idx_todos_position ON todos(position);
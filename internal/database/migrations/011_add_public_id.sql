@@ -0,0 +1,33 @@
+-- Random public ids, so clients can refer to todos without exposing the
+-- sequential integer ids. The application assigns them on create; the
+-- trigger covers rows inserted by anything else.
+ALTER TABLE todos ADD COLUMN public_id TEXT;
+
+UPDATE todos SET public_id = lower(
+    hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' ||
+    substr('89ab', 1 + abs(random()) % 4, 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_todos_public_id ON todos(public_id);
+
+-- +migrate StatementBegin
+CREATE TRIGGER todos_assign_public_id AFTER INSERT ON todos WHEN NEW.public_id IS NULL BEGIN
+    UPDATE todos SET public_id = lower(
+        hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' ||
+        substr('89ab', 1 + abs(random()) % 4, 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6))
+    ) WHERE id = NEW.id;
+END;
+-- +migrate StatementEnd
+
+-- Keep the public id in tombstones, so sync clients that only know todos
+-- by public id can match deletions
+ALTER TABLE deleted_todos ADD COLUMN public_id TEXT;
+
+DROP TRIGGER IF EXISTS todos_record_deletion;
+
+-- +migrate StatementBegin
+CREATE TRIGGER todos_record_deletion AFTER DELETE ON todos BEGIN
+    INSERT OR REPLACE INTO deleted_todos (id, public_id, deleted_at)
+    VALUES (OLD.id, OLD.public_id, strftime('%Y-%m-%d %H:%M:%f', 'now', '+0.001 seconds') || '+00:00');
+END;
+-- +migrate StatementEnd
@@ -0,0 +1,5 @@
+-- Draft flag for todos that should stay out of the main list until they
+-- are published
+ALTER TABLE todos ADD COLUMN draft BOOLEAN NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_todos_draft ON todos(draft);
@@ -0,0 +1,5 @@
+-- When each todo was last viewed, for a "recently viewed" list. It is kept
+-- out of updated_at so viewing a todo does not count as changing it.
+ALTER TABLE todos ADD COLUMN last_accessed_at DATETIME;
+
+CREATE INDEX IF NOT EXISTS idx_todos_last_accessed_at ON todos(last_accessed_at);
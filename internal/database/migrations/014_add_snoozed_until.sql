@@ -0,0 +1,2 @@
+-- Time until which a todo is snoozed and kept out of reminders
+ALTER TABLE todos ADD COLUMN snoozed_until DATETIME;
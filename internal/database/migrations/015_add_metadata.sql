@@ -0,0 +1,2 @@
+-- Arbitrary client-defined fields for a todo, stored as a JSON object
+ALTER TABLE todos ADD COLUMN metadata TEXT;
@@ -0,0 +1,10 @@
+-- Todos that must be completed before another can be: todo_id is blocked by
+-- depends_on_id. Rows go away with either todo.
+CREATE TABLE IF NOT EXISTS todo_dependencies (
+    todo_id INTEGER NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+    depends_on_id INTEGER NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+    PRIMARY KEY (todo_id, depends_on_id),
+    CHECK (todo_id != depends_on_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_todo_dependencies_depends_on_id ON todo_dependencies(depends_on_id);
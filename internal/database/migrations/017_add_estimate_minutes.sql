@@ -0,0 +1,2 @@
+-- Estimated effort for a todo in minutes, for planning
+ALTER TABLE todos ADD COLUMN estimate_minutes INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,6 @@
+-- An id assigned by an external system the todo is synced from, so it can
+-- be created or updated in one call. NULLs do not conflict, so todos
+-- created here are unaffected.
+ALTER TABLE todos ADD COLUMN external_id TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_todos_external_id ON todos(external_id);
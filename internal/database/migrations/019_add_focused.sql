@@ -0,0 +1,5 @@
+-- The todo currently being worked on. At most one todo is focused; the
+-- partial index enforces it even if the application misbehaves.
+ALTER TABLE todos ADD COLUMN focused BOOLEAN NOT NULL DEFAULT 0;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_todos_focused ON todos(focused) WHERE focused = 1;
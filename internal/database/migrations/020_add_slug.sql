@@ -0,0 +1,15 @@
+-- Human-readable ids for shareable URLs, derived from the title. The
+-- application assigns them on create; slugs cannot be derived from titles
+-- in SQL, so existing todos and rows inserted by anything else get one
+-- from their integer id.
+ALTER TABLE todos ADD COLUMN slug TEXT;
+
+UPDATE todos SET slug = 'todo-' || id;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_todos_slug ON todos(slug);
+
+-- +migrate StatementBegin
+CREATE TRIGGER todos_assign_slug AFTER INSERT ON todos WHEN NEW.slug IS NULL BEGIN
+    UPDATE todos SET slug = 'todo-' || NEW.id WHERE id = NEW.id;
+END;
+-- +migrate StatementEnd
@@ -0,0 +1,4 @@
+-- When the todo is planned to be worked on, for agenda views
+ALTER TABLE todos ADD COLUMN scheduled_at DATETIME;
+
+CREATE INDEX IF NOT EXISTS idx_todos_scheduled_at ON todos(scheduled_at) WHERE scheduled_at IS NOT NULL;
@@ -0,0 +1,12 @@
+-- Rows inserted without a slug got 'todo-' || id, which a todo titled with
+-- that number also gets from its title, so the trigger's update could fail
+-- on the unique index. Titles never produce two hyphens in a row, so the
+-- fallback cannot collide with a title's slug. Existing slugs are kept so
+-- links to them keep working.
+DROP TRIGGER IF EXISTS todos_assign_slug;
+
+-- +migrate StatementBegin
+CREATE TRIGGER todos_assign_slug AFTER INSERT ON todos WHEN NEW.slug IS NULL BEGIN
+    UPDATE todos SET slug = 'todo--' || NEW.id WHERE id = NEW.id;
+END;
+-- +migrate StatementEnd
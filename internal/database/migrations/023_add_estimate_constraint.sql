@@ -0,0 +1,99 @@
+-- Reject negative estimates in the schema, so writes that bypass the API
+-- are held to the same rule as the handlers.
+--
+-- SQLite cannot add a CHECK constraint to an existing table, so the table is
+-- rebuilt as in 008, with foreign keys off so the drop does not cascade to
+-- attachments, comments and dependencies.
+-- migrate:no-transaction
+
+PRAGMA foreign_keys = OFF;
+
+BEGIN;
+
+CREATE TABLE todos_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    title TEXT NOT NULL,
+    description TEXT,
+    completed BOOLEAN NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    starred BOOLEAN NOT NULL DEFAULT 0,
+    remind_at DATETIME,
+    position INTEGER NOT NULL DEFAULT 0,
+    public_id TEXT,
+    draft BOOLEAN NOT NULL DEFAULT 0,
+    last_accessed_at DATETIME,
+    snoozed_until DATETIME,
+    metadata TEXT,
+    estimate_minutes INTEGER NOT NULL DEFAULT 0,
+    external_id TEXT,
+    focused BOOLEAN NOT NULL DEFAULT 0,
+    slug TEXT,
+    scheduled_at DATETIME,
+    CONSTRAINT todos_title_length CHECK (length(title) <= 200),
+    CONSTRAINT todos_description_length CHECK (length(description) <= 5000),
+    CONSTRAINT todos_estimate_minutes CHECK (estimate_minutes >= 0)
+);
+
+-- Existing negative estimates are cleared rather than failing startup
+INSERT INTO todos_new (
+    id, title, description, completed, created_at, updated_at, starred, remind_at,
+    position, public_id, draft, last_accessed_at, snoozed_until, metadata,
+    estimate_minutes, external_id, focused, slug, scheduled_at
+)
+SELECT
+    id, title, description, completed, created_at, updated_at, starred, remind_at,
+    position, public_id, draft, last_accessed_at, snoozed_until, metadata,
+    max(estimate_minutes, 0), external_id, focused, slug, scheduled_at
+FROM todos;
+
+-- Keep the AUTOINCREMENT high-water mark, so ids of deleted todos that sync
+-- clients hold tombstones for are never reused
+DELETE FROM sqlite_sequence WHERE name = 'todos_new';
+INSERT INTO sqlite_sequence (name, seq)
+SELECT 'todos_new', seq FROM sqlite_sequence WHERE name = 'todos';
+
+DROP TABLE todos;
+
+ALTER TABLE todos_new RENAME TO todos;
+
+CREATE INDEX IF NOT EXISTS idx_todos_completed ON todos(completed);
+CREATE INDEX IF NOT EXISTS idx_todos_created_at ON todos(created_at);
+CREATE INDEX IF NOT EXISTS idx_todos_starred ON todos(starred);
+CREATE INDEX IF NOT EXISTS idx_todos_remind_at ON todos(remind_at);
+CREATE INDEX IF NOT EXISTS idx_todos_updated_at ON todos(updated_at);
+CREATE INDEX IF NOT EXISTS idx_todos_position ON todos(position);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_todos_public_id ON todos(public_id);
+CREATE INDEX IF NOT EXISTS idx_todos_draft ON todos(draft);
+CREATE INDEX IF NOT EXISTS idx_todos_last_accessed_at ON todos(last_accessed_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_todos_external_id ON todos(external_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_todos_focused ON todos(focused) WHERE focused = 1;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_todos_slug ON todos(slug);
+CREATE INDEX IF NOT EXISTS idx_todos_scheduled_at ON todos(scheduled_at) WHERE scheduled_at IS NOT NULL;
+
+-- Dropping the table dropped its triggers too
+-- +migrate StatementBegin
+CREATE TRIGGER todos_assign_public_id AFTER INSERT ON todos WHEN NEW.public_id IS NULL BEGIN
+    UPDATE todos SET public_id = lower(
+        hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' ||
+        substr('89ab', 1 + abs(random()) % 4, 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6))
+    ) WHERE id = NEW.id;
+END;
+-- +migrate StatementEnd
+
+-- +migrate StatementBegin
+CREATE TRIGGER todos_record_deletion AFTER DELETE ON todos BEGIN
+    INSERT OR REPLACE INTO deleted_todos (id, public_id, deleted_at)
+    VALUES (OLD.id, OLD.public_id, strftime('%Y-%m-%d %H:%M:%f', 'now', '+0.001 seconds') || '+00:00');
+END;
+-- +migrate StatementEnd
+
+-- +migrate StatementBegin
+CREATE TRIGGER todos_assign_slug AFTER INSERT ON todos WHEN NEW.slug IS NULL BEGIN
+    UPDATE todos SET slug = 'todo--' || NEW.id WHERE id = NEW.id;
+END;
+-- +migrate StatementEnd
+
+COMMIT;
+
+PRAGMA foreign_keys = ON;
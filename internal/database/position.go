@@ -0,0 +1,30 @@
+package database
+
+import "errors"
+
+// positionGap is the spacing between neighbouring positions after a todo is
+// appended or the list is rebalanced. Moving a todo halves a gap, so about
+// ten moves into the same spot are possible before a rebalance is needed.
+const positionGap = 1000
+
+// ErrMoveAfterNotFound is returned by Move when the todo to move after does
+// not exist
+var ErrMoveAfterNotFound = errors.New("todo to move after does not exist")
+
+// positionBetween returns a position strictly between prev and next, where
+// a nil bound is an end of the list. ok is false when the two positions are
+// adjacent and the list must be rebalanced first.
+func positionBetween(prev, next *int64) (position int64, ok bool) {
+	switch {
+	case prev == nil && next == nil:
+		return positionGap, true
+	case prev == nil:
+		return *next - positionGap, true
+	case next == nil:
+		return *prev + positionGap, true
+	case *next-*prev < 2:
+		return 0, false
+	default:
+		return *prev + (*next-*prev)/2, true
+	}
+}
@@ -0,0 +1,18 @@
+package database
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+// newPublicID returns a random version 4 UUID in its lowercase string form
+func newPublicID() string {
+	var b [16]byte
+	// crypto/rand.Read never returns an error
+	_, _ = rand.Read(b[:])
+
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,51 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Validate checks that the schema has every column the repositories query,
+// so a database the migrations did not bring up to date fails at startup
+// with a clear message instead of at request time
+func (db *DB) Validate() error {
+	ctx, cancel := db.withTimeout(context.Background())
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, "SELECT name FROM pragma_table_info('todos')")
+	if err != nil {
+		return fmt.Errorf("failed to read todos schema: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return fmt.Errorf("failed to scan column: %w", err)
+		}
+		existing[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating columns: %w", err)
+	}
+
+	if len(existing) == 0 {
+		return fmt.Errorf("table todos does not exist")
+	}
+
+	var missing []string
+	for _, column := range strings.Split(todoColumns, ", ") {
+		if !existing[column] {
+			missing = append(missing, column)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("table todos is missing columns: %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
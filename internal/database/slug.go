@@ -0,0 +1,147 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// maxSlugLength caps the length of a slug, in runes, before any suffix
+const maxSlugLength = 60
+
+// maxSlugAttempts bounds how many slugs a write tries when concurrent
+// writers keep claiming the one it picked
+const maxSlugAttempts = 5
+
+// reservedSlugs are the fixed routes under /api/todos/, which take
+// precedence over /api/todos/{id}, so a todo with one of these slugs could
+// not be looked up by it
+var reservedSlugs = map[string]bool{
+	"batch": true, "batch-get": true, "bulk": true, "bulk-toggle": true,
+	"complete-matching": true, "count": true, "drafts": true, "export": true,
+	"focused": true, "random": true, "recent": true, "reminders": true,
+	"schedule": true, "sync": true,
+}
+
+// slugify converts a title to the base of a URL slug: lowercase letters and
+// digits with a single hyphen between each run, e.g. "Buy groceries!"
+// becomes buy-groceries. A result that is empty or only digits, which
+// would read as an integer id, is prefixed with "todo".
+func slugify(title string) string {
+	var b strings.Builder
+	n := 0
+	hyphen := false
+	for _, c := range strings.ToLower(title) {
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+			hyphen = b.Len() > 0
+			continue
+		}
+		if hyphen {
+			if n+2 > maxSlugLength {
+				break
+			}
+			b.WriteByte('-')
+			hyphen = false
+			n++
+		}
+		if n == maxSlugLength {
+			break
+		}
+		b.WriteRune(c)
+		n++
+	}
+
+	slug := b.String()
+	if strings.IndexFunc(slug, func(c rune) bool { return !unicode.IsDigit(c) }) < 0 {
+		if slug == "" {
+			return "todo"
+		}
+		return "todo-" + slug
+	}
+	return slug
+}
+
+// pickSlug returns base, or base with the lowest numeric suffix from 2
+// upwards, whichever taken does not report as in use
+func pickSlug(base string, taken func(string) bool) string {
+	if !taken(base) {
+		return base
+	}
+	for i := 2; ; i++ {
+		if slug := fmt.Sprintf("%s-%d", base, i); !taken(slug) {
+			return slug
+		}
+	}
+}
+
+// querier is implemented by *DB and *sql.Tx
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// uniqueSlug returns a slug for title that no todo other than id uses and
+// that is not in claimed
+func uniqueSlug(ctx context.Context, q querier, title string, id int64, claimed ...string) (string, error) {
+	base := slugify(title)
+
+	// Slugs hold only letters, digits and hyphens, so base needs no
+	// escaping in the LIKE pattern
+	rows, err := q.QueryContext(ctx, "SELECT slug FROM todos WHERE (slug = ? OR slug LIKE ?) AND id != ?", base, base+"-%", id)
+	if err != nil {
+		return "", fmt.Errorf("failed to check slugs: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	used := make(map[string]bool)
+	for _, slug := range claimed {
+		used[slug] = true
+	}
+	for rows.Next() {
+		var slug string
+		if err := rows.Scan(&slug); err != nil {
+			return "", fmt.Errorf("failed to scan slug: %w", err)
+		}
+		used[slug] = true
+	}
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("error iterating slugs: %w", err)
+	}
+
+	return pickSlug(base, func(slug string) bool { return used[slug] || reservedSlugs[slug] }), nil
+}
+
+// withUniqueSlug calls write with a slug for title that no todo other than
+// id uses. Another connection can take the same slug between the check and
+// the write, so a write that fails on the slug's unique index is retried
+// with the next free slug. q should be the transaction write runs in, so
+// that the check and the write see the same todos; a failed statement does
+// not end a SQLite transaction, so the retry can run in it too.
+func withUniqueSlug(ctx context.Context, q querier, title string, id int64, write func(slug string) error) error {
+	var claimed []string
+	for attempt := 1; ; attempt++ {
+		slug, err := uniqueSlug(ctx, q, title, id, claimed...)
+		if err != nil {
+			return err
+		}
+
+		err = write(slug)
+		if err == nil || attempt == maxSlugAttempts || !isSlugConflict(err) {
+			return err
+		}
+		claimed = append(claimed, slug)
+	}
+}
+
+// isSlugConflict reports whether err is a violation of the unique index on
+// todos.slug
+func isSlugConflict(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) &&
+		sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique &&
+		strings.HasSuffix(sqliteErr.Error(), "todos.slug")
+}
@@ -0,0 +1,166 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/larryhudson/go-todo-list-claude/internal/models"
+)
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		title, want string
+	}{
+		{"Buy groceries", "buy-groceries"},
+		{"  Buy   GROCERIES!!  ", "buy-groceries"},
+		{"Email Bob: re/ Q3 report", "email-bob-re-q3-report"},
+		{"Crème brûlée", "crème-brûlée"},
+		{"snake_case-and--hyphens", "snake-case-and-hyphens"},
+		{"2024", "todo-2024"},
+		{"1 2 3", "1-2-3"},
+		{"!!!", "todo"},
+		{strings.Repeat("a", 100), strings.Repeat("a", maxSlugLength)},
+		{strings.Repeat("ab ", 40), strings.TrimSuffix(strings.Repeat("ab-", 20), "-")},
+	}
+
+	for _, tt := range tests {
+		if got := slugify(tt.title); got != tt.want {
+			t.Errorf("slugify(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestPickSlug(t *testing.T) {
+	used := map[string]bool{"buy-groceries": true, "buy-groceries-2": true, "buy-groceries-4": true}
+	taken := func(slug string) bool { return used[slug] }
+
+	if got := pickSlug("walk-dog", taken); got != "walk-dog" {
+		t.Errorf("Expected a free base to be used as is, got %q", got)
+	}
+	if got := pickSlug("buy-groceries", taken); got != "buy-groceries-3" {
+		t.Errorf("Expected the lowest free suffix, got %q", got)
+	}
+}
+
+func TestWithUniqueSlug_RetriesConflict(t *testing.T) {
+	repo := setupTestRepo(t)
+	ctx := context.Background()
+
+	insert := func(slug string) error {
+		_, err := repo.db.ExecContext(ctx, "INSERT INTO todos (title, slug) VALUES (?, ?)", "Buy milk", slug)
+		return err
+	}
+
+	// Another writer claims the picked slug between the check and the write
+	var tried []string
+	err := withUniqueSlug(ctx, repo.db, "Buy milk", 0, func(slug string) error {
+		tried = append(tried, slug)
+		if len(tried) == 1 {
+			if err := insert(slug); err != nil {
+				t.Fatalf("Failed to insert competing todo: %v", err)
+			}
+		}
+		return insert(slug)
+	})
+	if err != nil {
+		t.Fatalf("Expected the write to be retried with another slug, got %v", err)
+	}
+	if want := []string{"buy-milk", "buy-milk-2"}; strings.Join(tried, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected slugs %v to be tried, got %v", want, tried)
+	}
+
+	// Other errors are not retried
+	calls := 0
+	err = withUniqueSlug(ctx, repo.db, "Buy milk", 0, func(slug string) error {
+		calls++
+		return errors.New("disk on fire")
+	})
+	if err == nil || calls != 1 {
+		t.Errorf("Expected one call and the error back, got %d calls and %v", calls, err)
+	}
+}
+
+func TestCreate_ConcurrentSameTitle(t *testing.T) {
+	db, err := New(filepath.Join(t.TempDir(), "todos.db"), DefaultConfig())
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if err := db.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	repo, err := NewTodoRepository(db)
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+	t.Cleanup(func() { _ = repo.Close() })
+
+	const n = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := repo.Create(context.Background(), models.CreateTodoRequest{Title: "Standup"})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Expected every concurrent create to succeed, got %v", err)
+		}
+	}
+	todos, err := repo.GetAll(context.Background())
+	if err != nil {
+		t.Fatalf("GetAll failed: %v", err)
+	}
+	seen := make(map[string]bool)
+	for _, todo := range todos {
+		if seen[todo.Slug] {
+			t.Errorf("Expected unique slugs, got %s twice", todo.Slug)
+		}
+		seen[todo.Slug] = true
+	}
+	if len(todos) != n {
+		t.Errorf("Expected %d todos, got %d", n, len(todos))
+	}
+}
+
+func TestSlugTrigger_FallbackCannotCollide(t *testing.T) {
+	repo := setupTestRepo(t)
+	ctx := context.Background()
+
+	// A title that is just the next id gets the old fallback's slug
+	if _, err := repo.Create(ctx, models.CreateTodoRequest{Title: "2"}); err != nil {
+		t.Fatalf("Failed to create todo: %v", err)
+	}
+	first, err := repo.GetByID(ctx, 1)
+	if err != nil || first == nil {
+		t.Fatalf("Failed to get todo: %v", err)
+	}
+	if first.Slug != "todo-2" {
+		t.Fatalf("Expected slug todo-2, got %s", first.Slug)
+	}
+
+	// Rows inserted outside the repository get a slug from the trigger
+	result, err := repo.db.ExecContext(ctx, "INSERT INTO todos (title) VALUES (?)", "Imported")
+	if err != nil {
+		t.Fatalf("Expected the trigger's slug not to collide, got %v", err)
+	}
+	id, _ := result.LastInsertId()
+	var slug string
+	if err := repo.db.QueryRowContext(ctx, "SELECT slug FROM todos WHERE id = ?", id).Scan(&slug); err != nil {
+		t.Fatalf("Failed to read slug: %v", err)
+	}
+	if slug != "todo--2" {
+		t.Errorf("Expected fallback slug todo--2, got %s", slug)
+	}
+}
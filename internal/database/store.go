@@ -0,0 +1,83 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/larryhudson/go-todo-list-claude/internal/models"
+)
+
+// ErrStaleUpdate is returned by Update when the request's IfUnmodifiedSince
+// is older than the todo's last update
+var ErrStaleUpdate = errors.New("todo has been modified since ifUnmodifiedSince")
+
+// ErrTodosNotFound is returned by an atomic UpdateMany when some of the
+// todos to update do not exist
+var ErrTodosNotFound = errors.New("some todos do not exist")
+
+// TodoStore is the set of todo operations the HTTP handlers depend on.
+// TodoRepository implements it on SQLite and MemoryTodoStore in memory.
+type TodoStore interface {
+	Create(ctx context.Context, req models.CreateTodoRequest) (*models.Todo, error)
+	CreateMany(ctx context.Context, reqs []models.CreateTodoRequest) ([]models.Todo, error)
+	GetAll(ctx context.Context) ([]models.Todo, error)
+	Search(ctx context.Context, opts FilterOptions) ([]models.Todo, error)
+	// Stream calls fn with each todo Search would return, stopping at the
+	// first error fn returns
+	Stream(ctx context.Context, opts FilterOptions, fn func(models.Todo) error) error
+	Count(ctx context.Context, opts FilterOptions) (int64, error)
+	// RemainingMinutes sums the estimates of the incomplete todos matching opts
+	RemainingMinutes(ctx context.Context, opts FilterOptions) (int64, error)
+	// Random returns nil and no error if no todo matches opts
+	Random(ctx context.Context, opts FilterOptions) (*models.Todo, error)
+	// GetByID returns nil and no error if the todo does not exist
+	GetByID(ctx context.Context, id int64) (*models.Todo, error)
+	// GetByPublicID returns nil and no error if no todo has publicID
+	GetByPublicID(ctx context.Context, publicID string) (*models.Todo, error)
+	// GetBySlug returns nil and no error if no todo has slug
+	GetBySlug(ctx context.Context, slug string) (*models.Todo, error)
+	// GetByExternalID returns nil and no error if no todo has externalID
+	GetByExternalID(ctx context.Context, externalID string) (*models.Todo, error)
+	// GetByIDs returns the existing todos among ids, in the order given
+	GetByIDs(ctx context.Context, ids []int64) ([]models.Todo, error)
+	// Update returns nil and no error if the todo does not exist, and
+	// ErrStaleUpdate if it changed after req.IfUnmodifiedSince
+	Update(ctx context.Context, id int64, req models.UpdateTodoRequest) (*models.Todo, error)
+	// Upsert creates or replaces the todo with externalID, reporting whether
+	// it was created. Only a created todo gets req.DefaultDescription.
+	Upsert(ctx context.Context, externalID string, req models.UpsertTodoRequest) (todo *models.Todo, created bool, err error)
+	// UpdateMany applies every update in one transaction, reporting ids
+	// that do not exist in notFound. With atomic, any missing id undoes
+	// the batch and ErrTodosNotFound is returned with notFound.
+	UpdateMany(ctx context.Context, items []models.BatchUpdateItem, atomic bool) (updated []models.Todo, notFound []int64, err error)
+	// Delete returns sql.ErrNoRows if the todo does not exist
+	Delete(ctx context.Context, id int64) (*models.Todo, error)
+	DeleteAll(ctx context.Context) (int64, error)
+	SetCompleted(ctx context.Context, ids []int64, completed bool) (updated []models.Todo, notFound []int64, err error)
+	// SetFocus returns nil and no error if the todo does not exist.
+	// Focusing a todo unfocuses every other.
+	SetFocus(ctx context.Context, id int64, focused bool) (*models.Todo, error)
+	// Focused returns nil and no error if no todo is focused
+	Focused(ctx context.Context) (*models.Todo, error)
+	// ToggleCompleted flips each todo's completed flag to its opposite
+	ToggleCompleted(ctx context.Context, ids []int64) (updated []models.Todo, notFound []int64, err error)
+	// CompleteMatching returns how many todos it completed, skipping todos
+	// that depend on an incomplete todo
+	CompleteMatching(ctx context.Context, opts FilterOptions) (int64, error)
+	Reminders(ctx context.Context, from, to time.Time, includeSnoozed bool) ([]models.Todo, error)
+	// Schedule returns the todos scheduled within [from, to), earliest first
+	Schedule(ctx context.Context, from, to time.Time) ([]models.Todo, error)
+	Changes(ctx context.Context, since time.Time, after *SyncCursor, limit int) ([]models.TodoChange, error)
+	// RecordAccess does nothing if the todo does not exist
+	RecordAccess(ctx context.Context, id int64) error
+	Recent(ctx context.Context, limit int) ([]models.Todo, error)
+	// Move returns nil and no error if the todo does not exist, and
+	// ErrMoveAfterNotFound if the todo to move after does not
+	Move(ctx context.Context, id int64, after *int64) (*models.Todo, error)
+}
+
+var (
+	_ TodoStore = (*TodoRepository)(nil)
+	_ TodoStore = (*MemoryTodoStore)(nil)
+)
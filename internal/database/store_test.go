@@ -0,0 +1,1123 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/larryhudson/go-todo-list-claude/internal/models"
+)
+
+// stores lists the TodoStore implementations that must behave alike
+var stores = []struct {
+	name string
+	new  func(t *testing.T) TodoStore
+}{
+	{"sqlite", func(t *testing.T) TodoStore { return setupTestRepo(t) }},
+	{"memory", func(*testing.T) TodoStore { return NewMemoryTodoStore() }},
+}
+
+func titles(todos []models.Todo) []string {
+	out := make([]string, len(todos))
+	for i, todo := range todos {
+		out[i] = todo.Title
+	}
+	return out
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestTodoStore_CRUD(t *testing.T) {
+	for _, s := range stores {
+		t.Run(s.name, func(t *testing.T) {
+			store := s.new(t)
+			ctx := context.Background()
+
+			created, err := store.Create(ctx, models.CreateTodoRequest{Title: "Buy milk", Description: "Semi-skimmed"})
+			if err != nil {
+				t.Fatalf("Failed to create todo: %v", err)
+			}
+			if created.ID == 0 || created.CreatedAt.Location() != time.UTC {
+				t.Errorf("Expected an ID and a UTC timestamp, got %+v", created)
+			}
+
+			got, err := store.GetByID(ctx, created.ID)
+			if err != nil || got == nil || got.Title != "Buy milk" {
+				t.Fatalf("Expected to read back the todo, got %+v, %v", got, err)
+			}
+
+			title := "Buy oat milk"
+			completed := true
+			updated, err := store.Update(ctx, created.ID, models.UpdateTodoRequest{Title: &title, Completed: &completed})
+			if err != nil || updated == nil {
+				t.Fatalf("Failed to update todo: %+v, %v", updated, err)
+			}
+			if updated.Title != title || !updated.Completed || updated.Description != "Semi-skimmed" {
+				t.Errorf("Unexpected todo after update: %+v", updated)
+			}
+
+			if todo, err := store.Update(ctx, 999, models.UpdateTodoRequest{Title: &title}); todo != nil || err != nil {
+				t.Errorf("Expected nil, nil updating a missing todo, got %+v, %v", todo, err)
+			}
+
+			deleted, err := store.Delete(ctx, created.ID)
+			if err != nil || deleted.ID != created.ID {
+				t.Fatalf("Failed to delete todo: %+v, %v", deleted, err)
+			}
+			if _, err := store.Delete(ctx, created.ID); !errors.Is(err, sql.ErrNoRows) {
+				t.Errorf("Expected sql.ErrNoRows deleting twice, got %v", err)
+			}
+			if todo, err := store.GetByID(ctx, created.ID); todo != nil || err != nil {
+				t.Errorf("Expected nil, nil reading a deleted todo, got %+v, %v", todo, err)
+			}
+		})
+	}
+}
+
+func TestTodoStore_Search(t *testing.T) {
+	for _, s := range stores {
+		t.Run(s.name, func(t *testing.T) {
+			store := s.new(t)
+			ctx := context.Background()
+
+			for _, title := range []string{"Walk dog", "Feed cat", "Wash DOG bed"} {
+				if _, err := store.Create(ctx, models.CreateTodoRequest{Title: title}); err != nil {
+					t.Fatalf("Failed to create todo: %v", err)
+				}
+			}
+
+			todos, err := store.Search(ctx, FilterOptions{Search: "dog", SortBy: "title", SortOrder: "asc"})
+			if err != nil {
+				t.Fatalf("Search failed: %v", err)
+			}
+			if want := []string{"Walk dog", "Wash DOG bed"}; !equalStrings(titles(todos), want) {
+				t.Errorf("Expected %v, got %v", want, titles(todos))
+			}
+
+			count, err := store.Count(ctx, FilterOptions{Search: "dog"})
+			if err != nil || count != 2 {
+				t.Errorf("Expected count 2, got %d, %v", count, err)
+			}
+
+			all, err := store.GetAll(ctx)
+			if err != nil {
+				t.Fatalf("GetAll failed: %v", err)
+			}
+			if want := []string{"Wash DOG bed", "Feed cat", "Walk dog"}; !equalStrings(titles(all), want) {
+				t.Errorf("Expected newest first %v, got %v", want, titles(all))
+			}
+
+			page, err := store.Search(ctx, FilterOptions{Limit: 2, After: &Cursor{CreatedAt: all[0].CreatedAt, ID: all[0].ID}})
+			if err != nil {
+				t.Fatalf("Paginated search failed: %v", err)
+			}
+			if want := []string{"Feed cat", "Walk dog"}; !equalStrings(titles(page), want) {
+				t.Errorf("Expected %v after the cursor, got %v", want, titles(page))
+			}
+
+			byIDs, err := store.GetByIDs(ctx, []int64{all[2].ID, 999, all[0].ID})
+			if err != nil {
+				t.Fatalf("GetByIDs failed: %v", err)
+			}
+			if want := []string{"Walk dog", "Wash DOG bed"}; !equalStrings(titles(byIDs), want) {
+				t.Errorf("Expected %v in the order requested, got %v", want, titles(byIDs))
+			}
+
+			if _, err := store.Search(ctx, FilterOptions{SortBy: "priority"}); err == nil {
+				t.Error("Expected an error for an invalid sort field")
+			}
+		})
+	}
+}
+
+func TestTodoStore_SetCompletedAndChanges(t *testing.T) {
+	for _, s := range stores {
+		t.Run(s.name, func(t *testing.T) {
+			store := s.new(t)
+			ctx := context.Background()
+			start := time.Now().Add(-time.Second)
+
+			created, err := store.CreateMany(ctx, []models.CreateTodoRequest{{Title: "One"}, {Title: "Two"}})
+			if err != nil || len(created) != 2 {
+				t.Fatalf("Failed to create todos: %+v, %v", created, err)
+			}
+
+			updated, notFound, err := store.SetCompleted(ctx, []int64{created[0].ID, 999}, true)
+			if err != nil {
+				t.Fatalf("SetCompleted failed: %v", err)
+			}
+			if len(updated) != 1 || !updated[0].Completed || len(notFound) != 1 || notFound[0] != 999 {
+				t.Errorf("Unexpected SetCompleted result: %+v, %v", updated, notFound)
+			}
+
+			// Tombstones are stored with millisecond precision, so let the
+			// clock move on before deleting
+			time.Sleep(2 * time.Millisecond)
+			if _, err := store.Delete(ctx, created[1].ID); err != nil {
+				t.Fatalf("Failed to delete todo: %v", err)
+			}
+
+			changes, err := store.Changes(ctx, start, nil, 0)
+			if err != nil {
+				t.Fatalf("Changes failed: %v", err)
+			}
+			if len(changes) != 2 {
+				t.Fatalf("Expected 2 changes, got %+v", changes)
+			}
+			if changes[0].ID != created[0].ID || changes[0].Deleted || changes[1].ID != created[1].ID || !changes[1].Deleted {
+				t.Errorf("Expected an update then a deletion, got %+v", changes)
+			}
+		})
+	}
+}
+
+func TestTodoStore_Reminders(t *testing.T) {
+	for _, s := range stores {
+		t.Run(s.name, func(t *testing.T) {
+			store := s.new(t)
+			ctx := context.Background()
+			now := time.Now()
+
+			at := func(d time.Duration) *time.Time {
+				t := now.Add(d)
+				return &t
+			}
+			reqs := []models.CreateTodoRequest{
+				{Title: "Later", RemindAt: at(2 * time.Hour)},
+				{Title: "Soon", RemindAt: at(time.Hour)},
+				{Title: "Tomorrow", RemindAt: at(30 * time.Hour)},
+				{Title: "Never"},
+			}
+			for _, req := range reqs {
+				if _, err := store.Create(ctx, req); err != nil {
+					t.Fatalf("Failed to create todo: %v", err)
+				}
+			}
+
+			todos, err := store.Reminders(ctx, now, now.Add(24*time.Hour), false)
+			if err != nil {
+				t.Fatalf("Reminders failed: %v", err)
+			}
+			if want := []string{"Soon", "Later"}; !equalStrings(titles(todos), want) {
+				t.Errorf("Expected %v, got %v", want, titles(todos))
+			}
+		})
+	}
+}
+
+func TestTodoStore_Schedule(t *testing.T) {
+	for _, s := range stores {
+		t.Run(s.name, func(t *testing.T) {
+			store := s.new(t)
+			ctx := context.Background()
+			monday := time.Date(2030, 1, 7, 0, 0, 0, 0, time.UTC)
+
+			at := func(d time.Duration) *time.Time {
+				t := monday.Add(d)
+				return &t
+			}
+			reqs := []models.CreateTodoRequest{
+				{Title: "Wednesday", ScheduledAt: at(50 * time.Hour)},
+				{Title: "Monday", ScheduledAt: at(9 * time.Hour)},
+				{Title: "Next Monday", ScheduledAt: at(7 * 24 * time.Hour)},
+				{Title: "Last Sunday", ScheduledAt: at(-time.Hour)},
+				{Title: "Draft", ScheduledAt: at(time.Hour), Draft: true},
+				{Title: "Unscheduled"},
+			}
+			var ids []int64
+			for _, req := range reqs {
+				todo, err := store.Create(ctx, req)
+				if err != nil {
+					t.Fatalf("Failed to create todo: %v", err)
+				}
+				ids = append(ids, todo.ID)
+			}
+
+			week := func() []string {
+				todos, err := store.Schedule(ctx, monday, monday.AddDate(0, 0, 7))
+				if err != nil {
+					t.Fatalf("Schedule failed: %v", err)
+				}
+				return titles(todos)
+			}
+			if want := []string{"Monday", "Wednesday"}; !equalStrings(week(), want) {
+				t.Errorf("Expected %v, got %v", want, week())
+			}
+
+			// Rescheduling and unscheduling move todos in and out of the window
+			if _, err := store.Update(ctx, ids[5], models.UpdateTodoRequest{ScheduledAt: at(24 * time.Hour)}); err != nil {
+				t.Fatalf("Failed to update todo: %v", err)
+			}
+			if _, err := store.Update(ctx, ids[0], models.UpdateTodoRequest{ClearScheduledAt: true}); err != nil {
+				t.Fatalf("Failed to update todo: %v", err)
+			}
+			if want := []string{"Monday", "Unscheduled"}; !equalStrings(week(), want) {
+				t.Errorf("Expected %v, got %v", want, week())
+			}
+		})
+	}
+}
+
+func TestTodoStore_Snooze(t *testing.T) {
+	for _, s := range stores {
+		t.Run(s.name, func(t *testing.T) {
+			store := s.new(t)
+			ctx := context.Background()
+			now := time.Now()
+
+			at := func(d time.Duration) *time.Time {
+				t := now.Add(d)
+				return &t
+			}
+			for _, title := range []string{"Water plants", "Pay rent"} {
+				if _, err := store.Create(ctx, models.CreateTodoRequest{Title: title, RemindAt: at(time.Hour)}); err != nil {
+					t.Fatalf("Failed to create todo: %v", err)
+				}
+			}
+
+			snoozed, err := store.Update(ctx, 1, models.UpdateTodoRequest{SnoozedUntil: at(30 * time.Minute)})
+			if err != nil || snoozed == nil || snoozed.SnoozedUntil == nil {
+				t.Fatalf("Expected the todo to be snoozed, got %+v, %v", snoozed, err)
+			}
+
+			todos, err := store.Reminders(ctx, now, now.Add(24*time.Hour), false)
+			if err != nil {
+				t.Fatalf("Reminders failed: %v", err)
+			}
+			if want := []string{"Pay rent"}; !equalStrings(titles(todos), want) {
+				t.Errorf("Expected the snoozed todo to be left out, got %v", titles(todos))
+			}
+
+			todos, err = store.Reminders(ctx, now, now.Add(24*time.Hour), true)
+			if err != nil {
+				t.Fatalf("Reminders failed: %v", err)
+			}
+			if len(todos) != 2 {
+				t.Errorf("Expected includeSnoozed to return both todos, got %v", titles(todos))
+			}
+
+			// Once the snooze has passed the todo is back
+			todos, err = store.Reminders(ctx, now.Add(45*time.Minute), now.Add(24*time.Hour), false)
+			if err != nil {
+				t.Fatalf("Reminders failed: %v", err)
+			}
+			if len(todos) != 2 {
+				t.Errorf("Expected the todo to reappear after its snooze, got %v", titles(todos))
+			}
+		})
+	}
+}
+
+func TestTodoStore_Metadata(t *testing.T) {
+	for _, s := range stores {
+		t.Run(s.name, func(t *testing.T) {
+			store := s.new(t)
+			ctx := context.Background()
+
+			metadata := models.Metadata{
+				"color": "teal",
+				"icon":  map[string]any{"name": "star", "size": 2.5},
+				"refs":  []any{"JIRA-1", map[string]any{"github": 42.0}},
+			}
+			created, err := store.Create(ctx, models.CreateTodoRequest{Title: "Tagged", Metadata: metadata})
+			if err != nil {
+				t.Fatalf("Failed to create todo: %v", err)
+			}
+			if !reflect.DeepEqual(created.Metadata, metadata) {
+				t.Errorf("Expected created metadata %v, got %v", metadata, created.Metadata)
+			}
+
+			got, err := store.GetByID(ctx, created.ID)
+			if err != nil || got == nil {
+				t.Fatalf("Failed to get todo: %v", err)
+			}
+			if !reflect.DeepEqual(got.Metadata, metadata) {
+				t.Errorf("Expected metadata %v to round-trip, got %v", metadata, got.Metadata)
+			}
+
+			replaced := models.Metadata{"color": "red"}
+			updated, err := store.Update(ctx, created.ID, models.UpdateTodoRequest{Metadata: replaced})
+			if err != nil || !reflect.DeepEqual(updated.Metadata, replaced) {
+				t.Errorf("Expected metadata to be replaced with %v, got %+v, %v", replaced, updated, err)
+			}
+
+			// Updates without metadata leave it alone
+			title := "Renamed"
+			updated, err = store.Update(ctx, created.ID, models.UpdateTodoRequest{Title: &title})
+			if err != nil || !reflect.DeepEqual(updated.Metadata, replaced) {
+				t.Errorf("Expected metadata to be kept, got %+v, %v", updated, err)
+			}
+
+			updated, err = store.Update(ctx, created.ID, models.UpdateTodoRequest{Metadata: models.Metadata{}})
+			if err != nil || updated.Metadata != nil {
+				t.Errorf("Expected an empty object to clear metadata, got %+v, %v", updated, err)
+			}
+
+			large := models.Metadata{"notes": strings.Repeat("x", MaxMetadataBytes)}
+			_, err = store.Create(ctx, models.CreateTodoRequest{Title: "Too much", Metadata: large})
+			if _, ok := AsConstraintError(err); !ok {
+				t.Errorf("Expected oversized metadata to be rejected, got %v", err)
+			}
+		})
+	}
+}
+
+func TestTodoStore_StableOrder(t *testing.T) {
+	for _, s := range stores {
+		t.Run(s.name, func(t *testing.T) {
+			store := s.new(t)
+			ctx := context.Background()
+
+			for i := 0; i < 5; i++ {
+				if _, err := store.Create(ctx, models.CreateTodoRequest{Title: "Same", Description: fmt.Sprint(i)}); err != nil {
+					t.Fatalf("Failed to create todo: %v", err)
+				}
+			}
+
+			descriptions := func(todos []models.Todo) []string {
+				out := make([]string, len(todos))
+				for i, todo := range todos {
+					out[i] = todo.Description
+				}
+				return out
+			}
+
+			for _, tt := range []struct {
+				order string
+				want  []string
+			}{
+				{"asc", []string{"0", "1", "2", "3", "4"}},
+				{"desc", []string{"4", "3", "2", "1", "0"}},
+			} {
+				for call := 0; call < 3; call++ {
+					todos, err := store.Search(ctx, FilterOptions{SortBy: "title", SortOrder: tt.order})
+					if err != nil {
+						t.Fatalf("Search failed: %v", err)
+					}
+					if got := descriptions(todos); !equalStrings(got, tt.want) {
+						t.Fatalf("%s, call %d: expected ties ordered by id %v, got %v", tt.order, call, tt.want, got)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestTodoStore_CompleteMatching(t *testing.T) {
+	for _, s := range stores {
+		t.Run(s.name, func(t *testing.T) {
+			store := s.new(t)
+			ctx := context.Background()
+
+			for _, title := range []string{"Buy milk", "Buy bread", "Walk dog", "Buy eggs"} {
+				if _, err := store.Create(ctx, models.CreateTodoRequest{Title: title}); err != nil {
+					t.Fatalf("Failed to create todo: %v", err)
+				}
+			}
+			completed := true
+			if _, err := store.Update(ctx, 4, models.UpdateTodoRequest{Completed: &completed}); err != nil {
+				t.Fatalf("Failed to update todo: %v", err)
+			}
+
+			updated, err := store.CompleteMatching(ctx, FilterOptions{Search: "buy"})
+			if err != nil {
+				t.Fatalf("CompleteMatching failed: %v", err)
+			}
+			if updated != 2 {
+				t.Errorf("Expected the 2 incomplete matches to be completed, got %d", updated)
+			}
+
+			incomplete := false
+			todos, err := store.Search(ctx, FilterOptions{Completed: &incomplete})
+			if err != nil {
+				t.Fatalf("Search failed: %v", err)
+			}
+			if want := []string{"Walk dog"}; !equalStrings(titles(todos), want) {
+				t.Errorf("Expected %v left incomplete, got %v", want, titles(todos))
+			}
+		})
+	}
+}
+
+func TestTodoStore_Estimate(t *testing.T) {
+	for _, s := range stores {
+		t.Run(s.name, func(t *testing.T) {
+			store := s.new(t)
+			ctx := context.Background()
+
+			for _, create := range []models.CreateTodoRequest{
+				{Title: "Write report", EstimateMinutes: 120},
+				{Title: "Reply to email", EstimateMinutes: 5},
+				{Title: "Unestimated"},
+				{Title: "Fix bug", EstimateMinutes: 60},
+			} {
+				if _, err := store.Create(ctx, create); err != nil {
+					t.Fatalf("Failed to create todo: %v", err)
+				}
+			}
+			completed := true
+			if _, err := store.Update(ctx, 4, models.UpdateTodoRequest{Completed: &completed}); err != nil {
+				t.Fatalf("Failed to update todo: %v", err)
+			}
+			estimate := int64(30)
+			if _, err := store.Update(ctx, 3, models.UpdateTodoRequest{EstimateMinutes: &estimate}); err != nil {
+				t.Fatalf("Failed to update todo: %v", err)
+			}
+
+			remaining, err := store.RemainingMinutes(ctx, FilterOptions{})
+			if err != nil {
+				t.Fatalf("RemainingMinutes failed: %v", err)
+			}
+			if remaining != 155 {
+				t.Errorf("Expected 155 minutes for the incomplete todos, got %d", remaining)
+			}
+
+			remaining, err = store.RemainingMinutes(ctx, FilterOptions{Search: "re"})
+			if err != nil {
+				t.Fatalf("RemainingMinutes failed: %v", err)
+			}
+			if remaining != 125 {
+				t.Errorf("Expected 125 minutes for the matching todos, got %d", remaining)
+			}
+
+			todos, err := store.Search(ctx, FilterOptions{SortBy: "estimate_minutes", SortOrder: "asc"})
+			if err != nil {
+				t.Fatalf("Search failed: %v", err)
+			}
+			if want := []string{"Reply to email", "Unestimated", "Fix bug", "Write report"}; !equalStrings(titles(todos), want) {
+				t.Errorf("Expected %v, got %v", want, titles(todos))
+			}
+		})
+	}
+}
+
+func TestTodoStore_UpdateMany(t *testing.T) {
+	for _, s := range stores {
+		t.Run(s.name, func(t *testing.T) {
+			store := s.new(t)
+			ctx := context.Background()
+
+			for _, title := range []string{"One", "Two", "Three"} {
+				if _, err := store.Create(ctx, models.CreateTodoRequest{Title: title}); err != nil {
+					t.Fatalf("Failed to create todo: %v", err)
+				}
+			}
+
+			rename := func(id int64, title string) models.BatchUpdateItem {
+				return models.BatchUpdateItem{ID: models.FlexibleID(id), UpdateTodoRequest: models.UpdateTodoRequest{Title: &title}}
+			}
+			all := func() []string {
+				todos, err := store.Search(ctx, FilterOptions{SortBy: "position", SortOrder: "asc"})
+				if err != nil {
+					t.Fatalf("Search failed: %v", err)
+				}
+				return titles(todos)
+			}
+
+			updated, notFound, err := store.UpdateMany(ctx, []models.BatchUpdateItem{rename(1, "First"), rename(9, "Nine"), rename(3, "Third")}, false)
+			if err != nil {
+				t.Fatalf("UpdateMany failed: %v", err)
+			}
+			if want := []string{"First", "Third"}; !equalStrings(titles(updated), want) {
+				t.Errorf("Expected %v updated, got %v", want, titles(updated))
+			}
+			if len(notFound) != 1 || notFound[0] != 9 {
+				t.Errorf("Expected notFound [9], got %v", notFound)
+			}
+
+			_, notFound, err = store.UpdateMany(ctx, []models.BatchUpdateItem{rename(2, "Second"), rename(9, "Nine")}, true)
+			if !errors.Is(err, ErrTodosNotFound) {
+				t.Fatalf("Expected ErrTodosNotFound, got %v", err)
+			}
+			if len(notFound) != 1 || notFound[0] != 9 {
+				t.Errorf("Expected notFound [9], got %v", notFound)
+			}
+			if want := []string{"First", "Two", "Third"}; !equalStrings(all(), want) {
+				t.Errorf("Expected the atomic batch to be undone, got %v", all())
+			}
+
+			// A failing item undoes the items before it
+			_, _, err = store.UpdateMany(ctx, []models.BatchUpdateItem{rename(2, "Second"), rename(3, strings.Repeat("a", MaxTitleLength+1))}, false)
+			if _, ok := AsConstraintError(err); !ok {
+				t.Fatalf("Expected a constraint error, got %v", err)
+			}
+			if want := []string{"First", "Two", "Third"}; !equalStrings(all(), want) {
+				t.Errorf("Expected the failed batch to be undone, got %v", all())
+			}
+		})
+	}
+}
+
+func TestTodoStore_MetadataPatch(t *testing.T) {
+	for _, s := range stores {
+		t.Run(s.name, func(t *testing.T) {
+			store := s.new(t)
+			ctx := context.Background()
+
+			created, err := store.Create(ctx, models.CreateTodoRequest{
+				Title:    "Paint fence",
+				Metadata: models.Metadata{"color": "white", "paint": map[string]any{"brand": "Acme", "litres": 2.0}},
+			})
+			if err != nil {
+				t.Fatalf("Failed to create todo: %v", err)
+			}
+
+			updated, err := store.Update(ctx, created.ID, models.UpdateTodoRequest{
+				MetadataPatch: models.Metadata{"color": nil, "paint": map[string]any{"litres": 3.0}},
+			})
+			if err != nil {
+				t.Fatalf("Update failed: %v", err)
+			}
+			want := models.Metadata{"paint": map[string]any{"brand": "Acme", "litres": 3.0}}
+			if !reflect.DeepEqual(updated.Metadata, want) {
+				t.Errorf("Expected metadata %v, got %v", want, updated.Metadata)
+			}
+
+			// Removing the last member removes the metadata
+			updated, err = store.Update(ctx, created.ID, models.UpdateTodoRequest{MetadataPatch: models.Metadata{"paint": nil}})
+			if err != nil {
+				t.Fatalf("Update failed: %v", err)
+			}
+			if updated.Metadata != nil {
+				t.Errorf("Expected metadata to be removed, got %v", updated.Metadata)
+			}
+		})
+	}
+}
+
+func TestTodoStore_Upsert(t *testing.T) {
+	for _, s := range stores {
+		t.Run(s.name, func(t *testing.T) {
+			store := s.new(t)
+			ctx := context.Background()
+
+			if _, err := store.Create(ctx, models.CreateTodoRequest{Title: "Local"}); err != nil {
+				t.Fatalf("Failed to create todo: %v", err)
+			}
+
+			remindAt := time.Date(2030, 1, 2, 9, 0, 0, 0, time.UTC)
+			todo, created, err := store.Upsert(ctx, "gh-42", models.UpsertTodoRequest{
+				Title:              "Synced",
+				RemindAt:           &remindAt,
+				ScheduledAt:        &remindAt,
+				Draft:              true,
+				Metadata:           models.Metadata{"source": "github"},
+				EstimateMinutes:    30,
+				DefaultDescription: "Imported",
+			})
+			if err != nil {
+				t.Fatalf("Upsert failed: %v", err)
+			}
+			if !created {
+				t.Error("Expected the first upsert to create the todo")
+			}
+			if todo.ExternalID == nil || *todo.ExternalID != "gh-42" || todo.Title != "Synced" || todo.EstimateMinutes != 30 || todo.Description != "Imported" {
+				t.Errorf("Unexpected created todo: %+v", todo)
+			}
+			if todo.ScheduledAt == nil || !todo.Draft || todo.Metadata["source"] != "github" {
+				t.Errorf("Expected scheduledAt, draft and metadata to be set, got %v, %t and %v", todo.ScheduledAt, todo.Draft, todo.Metadata)
+			}
+			id, position := todo.ID, todo.Position
+
+			found, err := store.GetByExternalID(ctx, "gh-42")
+			if err != nil {
+				t.Fatalf("GetByExternalID failed: %v", err)
+			}
+			if found == nil || found.ID != id {
+				t.Errorf("Expected GetByExternalID to find todo %d, got %+v", id, found)
+			}
+			if found, err := store.GetByExternalID(ctx, "gh-43"); err != nil || found != nil {
+				t.Errorf("Expected nil for an unknown external id, got %+v, %v", found, err)
+			}
+
+			todo, created, err = store.Upsert(ctx, "gh-42", models.UpsertTodoRequest{Title: "Synced again", Completed: true, DefaultDescription: "Imported"})
+			if err != nil {
+				t.Fatalf("Upsert failed: %v", err)
+			}
+			if created {
+				t.Error("Expected the second upsert to replace the todo")
+			}
+			if todo.ID != id || todo.Title != "Synced again" || !todo.Completed {
+				t.Errorf("Unexpected replaced todo: %+v", todo)
+			}
+			if todo.RemindAt != nil || todo.EstimateMinutes != 0 || todo.Description != "" {
+				t.Errorf("Expected remindAt, estimateMinutes and description to be replaced, got %v, %d and %q", todo.RemindAt, todo.EstimateMinutes, todo.Description)
+			}
+			if todo.ScheduledAt != nil || todo.Draft || len(todo.Metadata) != 0 {
+				t.Errorf("Expected scheduledAt, draft and metadata to be replaced, got %v, %t and %v", todo.ScheduledAt, todo.Draft, todo.Metadata)
+			}
+			if todo.Position != position {
+				t.Errorf("Expected position %d to be kept, got %d", position, todo.Position)
+			}
+
+			count, err := store.Count(ctx, FilterOptions{})
+			if err != nil {
+				t.Fatalf("Count failed: %v", err)
+			}
+			if count != 2 {
+				t.Errorf("Expected 2 todos, got %d", count)
+			}
+		})
+	}
+}
+
+func TestTodoStore_Slugs(t *testing.T) {
+	slugs := func(todos []models.Todo) []string {
+		out := make([]string, len(todos))
+		for i, todo := range todos {
+			out[i] = todo.Slug
+		}
+		return out
+	}
+
+	for _, s := range stores {
+		t.Run(s.name, func(t *testing.T) {
+			store := s.new(t)
+			ctx := context.Background()
+
+			first, err := store.Create(ctx, models.CreateTodoRequest{Title: "Buy groceries"})
+			if err != nil {
+				t.Fatalf("Failed to create todo: %v", err)
+			}
+			more, err := store.CreateMany(ctx, []models.CreateTodoRequest{{Title: "Buy  Groceries!"}, {Title: "buy groceries"}, {Title: "Count"}, {Title: "2024"}})
+			if err != nil {
+				t.Fatalf("Failed to create todos: %v", err)
+			}
+			want := []string{"buy-groceries-2", "buy-groceries-3", "count-2", "todo-2024"}
+			if first.Slug != "buy-groceries" || !equalStrings(slugs(more), want) {
+				t.Errorf("Expected slugs buy-groceries and %v, got %s and %v", want, first.Slug, slugs(more))
+			}
+
+			got, err := store.GetBySlug(ctx, "buy-groceries-3")
+			if err != nil {
+				t.Fatalf("GetBySlug failed: %v", err)
+			}
+			if got == nil || got.ID != more[1].ID {
+				t.Errorf("Expected todo %d by slug, got %+v", more[1].ID, got)
+			}
+			if got, err := store.GetBySlug(ctx, "missing"); err != nil || got != nil {
+				t.Errorf("Expected nil for an unknown slug, got %+v, %v", got, err)
+			}
+
+			// A freed slug is reused, and an unchanged title keeps its slug
+			if _, err := store.Delete(ctx, first.ID); err != nil {
+				t.Fatalf("Failed to delete todo: %v", err)
+			}
+			title := "Plan trip"
+			renamed, err := store.Update(ctx, more[0].ID, models.UpdateTodoRequest{Title: &title})
+			if err != nil {
+				t.Fatalf("Failed to update todo: %v", err)
+			}
+			if renamed.Slug != "plan-trip" {
+				t.Errorf("Expected the slug to follow the title, got %q", renamed.Slug)
+			}
+			title = "buy groceries"
+			same, err := store.Update(ctx, more[1].ID, models.UpdateTodoRequest{Title: &title})
+			if err != nil {
+				t.Fatalf("Failed to update todo: %v", err)
+			}
+			if same.Slug != "buy-groceries-3" {
+				t.Errorf("Expected an unchanged title to keep its slug, got %q", same.Slug)
+			}
+
+			// Renaming in a batch sees the earlier renames
+			a, b := "Pack", "pack"
+			updated, _, err := store.UpdateMany(ctx, []models.BatchUpdateItem{
+				{ID: models.FlexibleID(more[2].ID), UpdateTodoRequest: models.UpdateTodoRequest{Title: &a}},
+				{ID: models.FlexibleID(more[3].ID), UpdateTodoRequest: models.UpdateTodoRequest{Title: &b}},
+			}, false)
+			if err != nil {
+				t.Fatalf("UpdateMany failed: %v", err)
+			}
+			if want := []string{"pack", "pack-2"}; !equalStrings(slugs(updated), want) {
+				t.Errorf("Expected slugs %v, got %v", want, slugs(updated))
+			}
+
+			upserted, _, err := store.Upsert(ctx, "ext-1", models.UpsertTodoRequest{Title: "Pack"})
+			if err != nil {
+				t.Fatalf("Upsert failed: %v", err)
+			}
+			if upserted.Slug != "pack-3" {
+				t.Errorf("Expected slug pack-3, got %q", upserted.Slug)
+			}
+			upserted, _, err = store.Upsert(ctx, "ext-1", models.UpsertTodoRequest{Title: "Pack", Completed: true})
+			if err != nil {
+				t.Fatalf("Upsert failed: %v", err)
+			}
+			if upserted.Slug != "pack-3" {
+				t.Errorf("Expected the replaced todo to keep slug pack-3, got %q", upserted.Slug)
+			}
+
+			store.(interface{ SetStableSlugs(bool) }).SetStableSlugs(true)
+			title = "Unpack"
+			stable, err := store.Update(ctx, more[2].ID, models.UpdateTodoRequest{Title: &title})
+			if err != nil {
+				t.Fatalf("Failed to update todo: %v", err)
+			}
+			if stable.Title != "Unpack" || stable.Slug != "pack" {
+				t.Errorf("Expected stable slugs to keep pack, got %q for %q", stable.Slug, stable.Title)
+			}
+			upserted, _, err = store.Upsert(ctx, "ext-1", models.UpsertTodoRequest{Title: "Repack"})
+			if err != nil {
+				t.Fatalf("Upsert failed: %v", err)
+			}
+			if upserted.Slug != "pack-3" {
+				t.Errorf("Expected stable slugs to keep pack-3 on upsert, got %q", upserted.Slug)
+			}
+		})
+	}
+}
+
+func TestTodoStore_Focus(t *testing.T) {
+	for _, s := range stores {
+		t.Run(s.name, func(t *testing.T) {
+			store := s.new(t)
+			ctx := context.Background()
+
+			for _, title := range []string{"Write", "Review", "Ship"} {
+				if _, err := store.Create(ctx, models.CreateTodoRequest{Title: title}); err != nil {
+					t.Fatalf("Failed to create todo: %v", err)
+				}
+			}
+
+			focused := func() []string {
+				var names []string
+				todos, err := store.GetAll(ctx)
+				if err != nil {
+					t.Fatalf("GetAll failed: %v", err)
+				}
+				for _, todo := range todos {
+					if todo.Focused {
+						names = append(names, todo.Title)
+					}
+				}
+				return names
+			}
+
+			if todo, err := store.Focused(ctx); err != nil || todo != nil {
+				t.Fatalf("Expected no focused todo, got %+v, %v", todo, err)
+			}
+
+			for _, id := range []int64{1, 2, 2, 3} {
+				todo, err := store.SetFocus(ctx, id, true)
+				if err != nil {
+					t.Fatalf("SetFocus failed: %v", err)
+				}
+				if todo == nil || todo.ID != id || !todo.Focused {
+					t.Fatalf("Expected todo %d to be focused, got %+v", id, todo)
+				}
+				current, err := store.Focused(ctx)
+				if err != nil || current == nil || current.ID != id {
+					t.Errorf("Expected Focused to return todo %d, got %+v, %v", id, current, err)
+				}
+				if names := focused(); len(names) != 1 {
+					t.Errorf("Expected exactly one focused todo, got %v", names)
+				}
+			}
+
+			// A missing todo leaves the focus where it is
+			if todo, err := store.SetFocus(ctx, 99, true); err != nil || todo != nil {
+				t.Fatalf("Expected nil for a missing todo, got %+v, %v", todo, err)
+			}
+			if want := []string{"Ship"}; !equalStrings(focused(), want) {
+				t.Errorf("Expected %v focused, got %v", want, focused())
+			}
+
+			if _, err := store.SetFocus(ctx, 3, false); err != nil {
+				t.Fatalf("SetFocus failed: %v", err)
+			}
+			if todo, err := store.Focused(ctx); err != nil || todo != nil {
+				t.Errorf("Expected no focused todo after unfocusing, got %+v, %v", todo, err)
+			}
+		})
+	}
+}
+
+func TestTodoStore_Random(t *testing.T) {
+	for _, s := range stores {
+		t.Run(s.name, func(t *testing.T) {
+			store := s.new(t)
+			ctx := context.Background()
+			incomplete := false
+
+			todo, err := store.Random(ctx, FilterOptions{Completed: &incomplete})
+			if err != nil || todo != nil {
+				t.Fatalf("Expected no todo from an empty store, got %+v, %v", todo, err)
+			}
+
+			for _, title := range []string{"Done", "Open one", "Open two"} {
+				if _, err := store.Create(ctx, models.CreateTodoRequest{Title: title}); err != nil {
+					t.Fatalf("Failed to create todo: %v", err)
+				}
+			}
+			if _, _, err := store.SetCompleted(ctx, []int64{1}, true); err != nil {
+				t.Fatalf("Failed to complete todo: %v", err)
+			}
+
+			seen := map[string]bool{}
+			for range 50 {
+				todo, err := store.Random(ctx, FilterOptions{Completed: &incomplete})
+				if err != nil || todo == nil {
+					t.Fatalf("Expected a todo, got %+v, %v", todo, err)
+				}
+				if todo.Completed {
+					t.Fatalf("Expected an incomplete todo, got %+v", todo)
+				}
+				seen[todo.Title] = true
+			}
+			if len(seen) != 2 {
+				t.Errorf("Expected both incomplete todos to be picked, got %v", seen)
+			}
+
+			todo, err = store.Random(ctx, FilterOptions{Completed: &incomplete, Search: "two"})
+			if err != nil || todo == nil || todo.Title != "Open two" {
+				t.Errorf("Expected the filtered todo, got %+v, %v", todo, err)
+			}
+		})
+	}
+}
+
+func TestTodoStore_Drafts(t *testing.T) {
+	for _, s := range stores {
+		t.Run(s.name, func(t *testing.T) {
+			store := s.new(t)
+			ctx := context.Background()
+			soon := time.Now().Add(time.Hour)
+
+			reqs := []models.CreateTodoRequest{
+				{Title: "Published"},
+				{Title: "Half-baked idea", Draft: true, RemindAt: &soon},
+			}
+			for _, req := range reqs {
+				if _, err := store.Create(ctx, req); err != nil {
+					t.Fatalf("Failed to create todo: %v", err)
+				}
+			}
+
+			todos, err := store.Search(ctx, FilterOptions{})
+			if err != nil {
+				t.Fatalf("Search failed: %v", err)
+			}
+			if want := []string{"Published"}; !equalStrings(titles(todos), want) {
+				t.Errorf("Expected drafts to be hidden by default, got %v", titles(todos))
+			}
+
+			todos, err = store.Search(ctx, FilterOptions{Drafts: true})
+			if err != nil {
+				t.Fatalf("Search failed: %v", err)
+			}
+			if want := []string{"Half-baked idea"}; !equalStrings(titles(todos), want) {
+				t.Errorf("Expected only drafts, got %v", titles(todos))
+			}
+
+			if count, err := store.Count(ctx, FilterOptions{}); err != nil || count != 1 {
+				t.Errorf("Expected a count of 1, got %d, %v", count, err)
+			}
+			if todos, err := store.Reminders(ctx, time.Now(), soon.Add(time.Minute), false); err != nil || len(todos) != 0 {
+				t.Errorf("Expected drafts to have no reminders, got %v, %v", titles(todos), err)
+			}
+
+			draft := false
+			published, err := store.Update(ctx, 2, models.UpdateTodoRequest{Draft: &draft})
+			if err != nil || published == nil || published.Draft {
+				t.Fatalf("Expected the todo to be published, got %+v, %v", published, err)
+			}
+			if count, err := store.Count(ctx, FilterOptions{}); err != nil || count != 2 {
+				t.Errorf("Expected a count of 2 after publishing, got %d, %v", count, err)
+			}
+		})
+	}
+}
+
+func TestTodoStore_SearchRelevance(t *testing.T) {
+	for _, s := range stores {
+		t.Run(s.name, func(t *testing.T) {
+			store := s.new(t)
+			ctx := context.Background()
+
+			// Created worst match first, so creation order would reverse them
+			reqs := []models.CreateTodoRequest{
+				{Title: "Weekly review", Description: "Plan the garden beds"},
+				{Title: "Errands", Description: "garden centre on the way"},
+				{Title: "Water the garden"},
+				{Title: "Gardening gloves"},
+				{Title: "Garden"},
+			}
+			for _, req := range reqs {
+				if _, err := store.Create(ctx, req); err != nil {
+					t.Fatalf("Failed to create todo: %v", err)
+				}
+			}
+
+			todos, err := store.Search(ctx, FilterOptions{Search: "garden", SortBy: SortRelevance})
+			if err != nil {
+				t.Fatalf("Search failed: %v", err)
+			}
+			want := []string{"Garden", "Gardening gloves", "Water the garden", "Errands", "Weekly review"}
+			if !equalStrings(titles(todos), want) {
+				t.Errorf("Expected %v, got %v", want, titles(todos))
+			}
+
+			if _, err := store.Search(ctx, FilterOptions{SortBy: SortRelevance}); !errors.Is(err, ErrRelevanceWithoutSearch) {
+				t.Errorf("Expected ErrRelevanceWithoutSearch without a search term, got %v", err)
+			}
+		})
+	}
+}
+
+func TestTodoStore_Recent(t *testing.T) {
+	for _, s := range stores {
+		t.Run(s.name, func(t *testing.T) {
+			store := s.new(t)
+			ctx := context.Background()
+
+			for _, title := range []string{"First", "Second", "Third"} {
+				if _, err := store.Create(ctx, models.CreateTodoRequest{Title: title}); err != nil {
+					t.Fatalf("Failed to create todo: %v", err)
+				}
+			}
+
+			// Timestamps have millisecond precision, so space the views out
+			for _, id := range []int64{2, 1, 3, 2, 99} {
+				if err := store.RecordAccess(ctx, id); err != nil {
+					t.Fatalf("RecordAccess(%d) failed: %v", id, err)
+				}
+				time.Sleep(2 * time.Millisecond)
+			}
+
+			todos, err := store.Recent(ctx, 2)
+			if err != nil {
+				t.Fatalf("Recent failed: %v", err)
+			}
+			if want := []string{"Second", "Third"}; !equalStrings(titles(todos), want) {
+				t.Errorf("Expected %v, got %v", want, titles(todos))
+			}
+
+			// Viewing a todo is not an update
+			todo, err := store.GetByID(ctx, 2)
+			if err != nil || todo == nil {
+				t.Fatalf("Failed to get todo: %+v, %v", todo, err)
+			}
+			if !todo.UpdatedAt.Equal(todo.CreatedAt) {
+				t.Errorf("Expected updatedAt to be unchanged, got %v (created %v)", todo.UpdatedAt, todo.CreatedAt)
+			}
+		})
+	}
+}
+
+func TestTodoStore_Move(t *testing.T) {
+	for _, s := range stores {
+		t.Run(s.name, func(t *testing.T) {
+			store := s.new(t)
+			ctx := context.Background()
+
+			ids := make(map[string]int64)
+			for _, title := range []string{"A", "B", "C"} {
+				todo, err := store.Create(ctx, models.CreateTodoRequest{Title: title})
+				if err != nil {
+					t.Fatalf("Failed to create todo: %v", err)
+				}
+				ids[title] = todo.ID
+			}
+			order := func() []string {
+				t.Helper()
+				todos, err := store.Search(ctx, FilterOptions{SortBy: "position", SortOrder: "asc"})
+				if err != nil {
+					t.Fatalf("Search failed: %v", err)
+				}
+				return titles(todos)
+			}
+			move := func(title string, after *int64) {
+				t.Helper()
+				todo, err := store.Move(ctx, ids[title], after)
+				if err != nil || todo == nil {
+					t.Fatalf("Failed to move %s: %+v, %v", title, todo, err)
+				}
+			}
+
+			if got := order(); !equalStrings(got, []string{"A", "B", "C"}) {
+				t.Fatalf("Expected new todos in creation order, got %v", got)
+			}
+
+			move("C", nil)
+			if got := order(); !equalStrings(got, []string{"C", "A", "B"}) {
+				t.Errorf("Expected C first, got %v", got)
+			}
+
+			a := ids["A"]
+			move("B", &a)
+			move("C", &a)
+			if got := order(); !equalStrings(got, []string{"A", "C", "B"}) {
+				t.Errorf("Expected C between A and B, got %v", got)
+			}
+
+			// Repeatedly moving into the same gap exhausts it and forces a
+			// rebalance, which must keep the order intact
+			for i := 0; i < 20; i++ {
+				title, other := "C", "B"
+				if i%2 == 1 {
+					title, other = "B", "C"
+				}
+				move(title, &a)
+				if got := order(); !equalStrings(got, []string{"A", title, other}) {
+					t.Fatalf("Move %d: expected %s after A, got %v", i, title, got)
+				}
+			}
+
+			missing := int64(999)
+			if _, err := store.Move(ctx, ids["A"], &missing); !errors.Is(err, ErrMoveAfterNotFound) {
+				t.Errorf("Expected ErrMoveAfterNotFound, got %v", err)
+			}
+			if todo, err := store.Move(ctx, missing, nil); todo != nil || err != nil {
+				t.Errorf("Expected nil, nil moving a missing todo, got %+v, %v", todo, err)
+			}
+		})
+	}
+}
+
+func TestMemoryTodoStore_Concurrent(t *testing.T) {
+	store := NewMemoryTodoStore()
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			todo, err := store.Create(ctx, models.CreateTodoRequest{Title: "Concurrent"})
+			if err != nil {
+				t.Errorf("Failed to create todo: %v", err)
+				return
+			}
+			completed := true
+			if _, err := store.Update(ctx, todo.ID, models.UpdateTodoRequest{Completed: &completed}); err != nil {
+				t.Errorf("Failed to update todo: %v", err)
+			}
+			if _, err := store.GetAll(ctx); err != nil {
+				t.Errorf("Failed to list todos: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	count, err := store.Count(ctx, FilterOptions{})
+	if err != nil || count != 20 {
+		t.Errorf("Expected 20 todos, got %d, %v", count, err)
+	}
+}
@@ -3,260 +3,1702 @@ package database
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/larryhudson/go-todo-list-claude/internal/models"
 )
 
-// TodoRepository handles database operations for todos
-type TodoRepository struct {
-	db *DB
-}
+// todoColumns lists the columns read by scanTodo, in order
+const todoColumns = "id, public_id, external_id, slug, title, description, completed, starred, remind_at, snoozed_until, scheduled_at, position, draft, focused, metadata, estimate_minutes, created_at, updated_at"
 
-// NewTodoRepository creates a new TodoRepository
-func NewTodoRepository(db *DB) *TodoRepository {
-	return &TodoRepository{db: db}
-}
+const (
+	createTodoQuery = `
+		INSERT INTO todos (public_id, slug, title, description, completed, remind_at, scheduled_at, position, draft, metadata, estimate_minutes, created_at, updated_at)
+		VALUES (?, ?, ?, ?, 0, ?, ?, (SELECT COALESCE(MAX(position), 0) + 1000 FROM todos), ?, ?, ?, ?, ?)
+		RETURNING ` + todoColumns + `
+	`
 
-// Create creates a new todo
-func (r *TodoRepository) Create(req models.CreateTodoRequest) (*models.Todo, error) {
-	query := `
-		INSERT INTO todos (title, description, completed, created_at, updated_at)
-		VALUES (?, ?, 0, ?, ?)
-		RETURNING id, title, description, completed, created_at, updated_at
+	getTodoByIDQuery = `
+		SELECT ` + todoColumns + `
+		FROM todos
+		WHERE id = ?
 	`
 
-	now := time.Now()
-	var todo models.Todo
+	// upsertTodoQuery creates the todo with an external id or, if one
+	// exists, replaces its fields, in a single statement. The last
+	// parameter keeps the existing slug when set.
+	upsertTodoQuery = `
+		INSERT INTO todos (public_id, external_id, slug, title, description, completed, starred, remind_at, scheduled_at, position, draft, metadata, estimate_minutes, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, (SELECT COALESCE(MAX(position), 0) + 1000 FROM todos), ?, ?, ?, ?, ?)
+		ON CONFLICT(external_id) DO UPDATE SET
+			slug = CASE WHEN todos.title = excluded.title OR ? THEN todos.slug ELSE excluded.slug END,
+			title = excluded.title,
+			description = ?,
+			completed = excluded.completed,
+			starred = excluded.starred,
+			remind_at = excluded.remind_at,
+			scheduled_at = excluded.scheduled_at,
+			draft = excluded.draft,
+			metadata = excluded.metadata,
+			estimate_minutes = excluded.estimate_minutes,
+			updated_at = excluded.updated_at
+		RETURNING ` + todoColumns + `
+	`
+
+	deleteTodoQuery = `
+		DELETE FROM todos
+		WHERE id = ?
+		RETURNING ` + todoColumns + `
+	`
+)
 
-	err := r.db.QueryRowContext(context.Background(), query, req.Title, req.Description, now, now).Scan(
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// rowScanFunc adapts a function to rowScanner, e.g. to scan extra columns
+// selected after todoColumns
+type rowScanFunc func(dest ...interface{}) error
+
+// Scan implements rowScanner
+func (f rowScanFunc) Scan(dest ...interface{}) error {
+	return f(dest...)
+}
+
+// scanTodo scans a row selected with todoColumns into todo
+func scanTodo(row rowScanner, todo *models.Todo) error {
+	// The description column is nullable, and rows written by anything but
+	// the repository may hold NULL; it reads as an empty description
+	var description sql.NullString
+
+	err := row.Scan(
 		&todo.ID,
+		&todo.PublicID,
+		&todo.ExternalID,
+		&todo.Slug,
 		&todo.Title,
-		&todo.Description,
+		&description,
 		&todo.Completed,
+		&todo.Starred,
+		&todo.RemindAt,
+		&todo.SnoozedUntil,
+		&todo.ScheduledAt,
+		&todo.Position,
+		&todo.Draft,
+		&todo.Focused,
+		metadataColumn{&todo.Metadata},
+		&todo.EstimateMinutes,
 		&todo.CreatedAt,
 		&todo.UpdatedAt,
 	)
+	todo.Description = description.String
+	return err
+}
+
+// TodoRepository handles database operations for todos
+type TodoRepository struct {
+	db *DB
+
+	// Prepared statements for the hot paths; dynamic queries stay ad hoc
+	createStmt  *sql.Stmt
+	getByIDStmt *sql.Stmt
+	deleteStmt  *sql.Stmt
+
+	// cache serves GetByID when set; nil disables caching
+	cache *TodoCache
+
+	// stableSlugs keeps a todo's slug when its title changes, so old links
+	// keep working
+	stableSlugs bool
+
+	// Ordering applied when a query does not specify one
+	defaultSortBy    string
+	defaultSortOrder string
+}
+
+// NewTodoRepository creates a new TodoRepository, preparing its frequently
+// used statements. The schema must exist before calling it.
+func NewTodoRepository(db *DB) (*TodoRepository, error) {
+	r := &TodoRepository{
+		db:               db,
+		defaultSortBy:    "created_at",
+		defaultSortOrder: "desc",
+	}
+	ctx := context.Background()
+
+	var err error
+	if r.createStmt, err = db.PrepareContext(ctx, createTodoQuery); err != nil {
+		return nil, fmt.Errorf("failed to prepare create statement: %w", err)
+	}
+	if r.getByIDStmt, err = db.PrepareContext(ctx, getTodoByIDQuery); err != nil {
+		_ = r.Close()
+		return nil, fmt.Errorf("failed to prepare get statement: %w", err)
+	}
+	if r.deleteStmt, err = db.PrepareContext(ctx, deleteTodoQuery); err != nil {
+		_ = r.Close()
+		return nil, fmt.Errorf("failed to prepare delete statement: %w", err)
+	}
+
+	return r, nil
+}
+
+// SetDefaultSort sets the ordering used when a query does not specify
+// one. sortBy is a column name and sortOrder is "asc" or "desc".
+func (r *TodoRepository) SetDefaultSort(sortBy, sortOrder string) error {
+	if !validSortFields[sortBy] {
+		return fmt.Errorf("invalid sort field: %s", sortBy)
+	}
+	if sortOrder != "asc" && sortOrder != "desc" {
+		return fmt.Errorf("invalid sort order: %s", sortOrder)
+	}
+
+	r.defaultSortBy = sortBy
+	r.defaultSortOrder = sortOrder
+	return nil
+}
+
+// uniqueTitleIndex is the index that enforces unique titles when enabled
+const uniqueTitleIndex = "idx_todos_title_unique"
+
+// SetUniqueTitles enables or disables rejecting todos whose title matches an
+// existing one. The rule is a unique index rather than part of the schema
+// migrations, so it can follow configuration: enabling it creates the index,
+// which fails if duplicate titles already exist, and disabling it drops the
+// index again.
+func (r *TodoRepository) SetUniqueTitles(ctx context.Context, enabled bool) error {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	query := "DROP INDEX IF EXISTS " + uniqueTitleIndex
+	if enabled {
+		query = "CREATE UNIQUE INDEX IF NOT EXISTS " + uniqueTitleIndex + " ON todos(title)"
+	}
+
+	if _, err := r.db.ExecContext(ctx, query); err != nil {
+		if enabled {
+			return fmt.Errorf("failed to enforce unique titles, existing todos may share a title: %w", err)
+		}
+		return fmt.Errorf("failed to stop enforcing unique titles: %w", err)
+	}
+
+	return nil
+}
+
+// SetStableSlugs sets whether a todo keeps its slug when its title
+// changes. By default the slug follows the title.
+func (r *TodoRepository) SetStableSlugs(enabled bool) {
+	r.stableSlugs = enabled
+}
+
+// SetCache puts cache in front of GetByID. Passing nil disables caching.
+func (r *TodoRepository) SetCache(cache *TodoCache) {
+	r.cache = cache
+}
+
+// WarmCache loads up to n of the most recently updated todos into the
+// cache, so the first reads after a restart are served from memory. It
+// returns the number of todos cached, which is 0 when no cache is set.
+func (r *TodoRepository) WarmCache(ctx context.Context, n int) (int, error) {
+	if r.cache == nil || n <= 0 {
+		return 0, nil
+	}
+
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	// Loading more than the cache holds would only evict what was loaded
+	n = min(n, r.cache.size)
+	generation := r.cache.Generation()
+	todos, err := r.queryTodos(ctx, "SELECT "+todoColumns+" FROM todos ORDER BY updated_at DESC, id DESC LIMIT ?", n)
+	if err != nil {
+		return 0, fmt.Errorf("failed to warm cache: %w", err)
+	}
+
+	// Put the most recent last, so it is the last to be evicted. If a write
+	// invalidated the cache meanwhile, the loaded rows may be stale and are
+	// left for GetByID to load again.
+	for i := len(todos) - 1; i >= 0; i-- {
+		if !r.cache.PutIfCurrent(todos[i], generation) {
+			return len(todos) - 1 - i, nil
+		}
+	}
+
+	return len(todos), nil
+}
+
+// invalidate drops the given todos from the cache, if one is configured
+func (r *TodoRepository) invalidate(ids ...int64) {
+	if r.cache == nil {
+		return
+	}
+	for _, id := range ids {
+		r.cache.Invalidate(id)
+	}
+}
+
+// Close releases the repository's prepared statements
+func (r *TodoRepository) Close() error {
+	var firstErr error
+	for _, stmt := range []*sql.Stmt{r.createStmt, r.getByIDStmt, r.deleteStmt} {
+		if stmt == nil {
+			continue
+		}
+		if err := stmt.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
 
+// Create creates a new todo
+func (r *TodoRepository) Create(ctx context.Context, req models.CreateTodoRequest) (created *models.Todo, err error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	metadata, err := encodeMetadata(req.Metadata)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create todo: %w", err)
 	}
 
+	// The slug is picked and claimed in one transaction
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				err = fmt.Errorf("rollback failed: %v (original error: %w)", rbErr, err)
+			}
+		}
+	}()
+
+	stmt := tx.StmtContext(ctx, r.createStmt)
+	now := utcNow()
+	var todo models.Todo
+	err = withUniqueSlug(ctx, tx, req.Title, 0, func(slug string) error {
+		return scanTodo(stmt.QueryRowContext(ctx, newPublicID(), slug, req.Title, req.Description, utcTime(req.RemindAt), utcTime(req.ScheduledAt), req.Draft, metadata, req.EstimateMinutes, now, now), &todo)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create todo: %w", mapConstraintError(err))
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
 	return &todo, nil
 }
 
-// GetAll returns all todos
-func (r *TodoRepository) GetAll() ([]models.Todo, error) {
-	query := `
-		SELECT id, title, description, completed, created_at, updated_at
-		FROM todos
-		ORDER BY created_at DESC
-	`
+// CreateMany creates several todos in a single transaction. Either every
+// todo is created or, on error, none are.
+func (r *TodoRepository) CreateMany(ctx context.Context, reqs []models.CreateTodoRequest) (created []models.Todo, err error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
 
-	rows, err := r.db.QueryContext(context.Background(), query)
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query todos: %w", err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				err = fmt.Errorf("rollback failed: %v (original error: %w)", rbErr, err)
+			}
+		}
+	}()
+
+	stmt := tx.StmtContext(ctx, r.createStmt)
+	now := utcNow()
+	for i, req := range reqs {
+		var metadata *string
+		if metadata, err = encodeMetadata(req.Metadata); err != nil {
+			return nil, fmt.Errorf("failed to create todo %d: %w", i, err)
+		}
+
+		var todo models.Todo
+		err = withUniqueSlug(ctx, tx, req.Title, 0, func(slug string) error {
+			return scanTodo(stmt.QueryRowContext(ctx, newPublicID(), slug, req.Title, req.Description, utcTime(req.RemindAt), utcTime(req.ScheduledAt), req.Draft, metadata, req.EstimateMinutes, now, now), &todo)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create todo %d: %w", i, mapConstraintError(err))
+		}
+		created = append(created, todo)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
+	return created, nil
+}
+
+// queryTodos runs a query selecting todoColumns and scans every row
+func (r *TodoRepository) queryTodos(ctx context.Context, query string, args ...interface{}) ([]models.Todo, error) {
 	var todos []models.Todo
+	err := r.eachTodo(ctx, query, args, func(todo models.Todo) error {
+		todos = append(todos, todo)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return todos, nil
+}
+
+// eachTodo runs a query selecting todoColumns and calls fn with each row as
+// it is scanned, stopping at the first error fn returns
+func (r *TodoRepository) eachTodo(ctx context.Context, query string, args []interface{}, fn func(models.Todo) error) error {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query todos: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
 	for rows.Next() {
 		var todo models.Todo
-		err := rows.Scan(
-			&todo.ID,
-			&todo.Title,
-			&todo.Description,
-			&todo.Completed,
-			&todo.CreatedAt,
-			&todo.UpdatedAt,
-		)
+		err := scanTodo(rows, &todo)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan todo: %w", err)
+			return fmt.Errorf("failed to scan todo: %w", err)
+		}
+		if err := fn(todo); err != nil {
+			return err
 		}
-		todos = append(todos, todo)
 	}
 
 	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating todos: %w", err)
+		return fmt.Errorf("error iterating todos: %w", err)
 	}
 
 	// Check for errors from closing rows
 	if err = rows.Close(); err != nil {
-		return nil, fmt.Errorf("failed to close rows: %w", err)
+		return fmt.Errorf("failed to close rows: %w", err)
 	}
 
-	return todos, nil
+	return nil
+}
+
+// GetAll returns all todos
+func (r *TodoRepository) GetAll(ctx context.Context) ([]models.Todo, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT ` + todoColumns + `
+		FROM todos
+	`
+	query += orderBy(r.defaultSortBy, r.defaultSortOrder)
+
+	return r.queryTodos(ctx, query)
 }
 
 // FilterOptions contains filtering and sorting options
 type FilterOptions struct {
 	Search    string
 	Completed *bool
+	Starred   *bool
 	SortBy    string
 	SortOrder string
+
+	// Drafts selects draft todos instead of the published ones, which are
+	// all that is returned by default
+	Drafts bool
+
+	// Time range filters; After bounds are inclusive, Before bounds exclusive
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	UpdatedAfter  *time.Time
+	UpdatedBefore *time.Time
+
+	// Limit caps the number of returned todos (0 means no limit)
+	Limit int
+	// After resumes keyset pagination after the given todo. It is only
+	// valid when sorting by created_at.
+	After *Cursor
 }
 
-// Search searches and filters todos
-func (r *TodoRepository) Search(opts FilterOptions) ([]models.Todo, error) {
-	query := `
-		SELECT id, title, description, completed, created_at, updated_at
-		FROM todos
-		WHERE 1=1
-	`
+// Cursor identifies the last todo seen when paginating by (created_at, id)
+type Cursor struct {
+	CreatedAt time.Time
+	ID        int64
+}
+
+// validSortFields lists the columns todos may be sorted by
+var validSortFields = map[string]bool{
+	"created_at":       true,
+	"updated_at":       true,
+	"title":            true,
+	"starred":          true,
+	"position":         true,
+	"estimate_minutes": true,
+}
+
+// SortRelevance sorts search results by how well they match the search
+// term. It is not a column, so it is handled apart from validSortFields and
+// cannot be a default sort.
+const SortRelevance = "relevance"
+
+// ErrRelevanceWithoutSearch is returned when sorting by relevance without a
+// search term to rank against
+var ErrRelevanceWithoutSearch = errors.New("sorting by relevance requires a search term")
+
+// relevanceOrder ranks search matches, best first when descending: an exact
+// title match, then titles starting with the term, titles containing it,
+// descriptions starting with it, and finally descriptions containing it.
+// Newer todos come first within a rank. LIKE matches ASCII case-insensitively
+// like the search filter itself.
+const relevanceOrder = ` ORDER BY CASE
+		WHEN title LIKE ? THEN 5
+		WHEN title LIKE ? THEN 4
+		WHEN title LIKE ? THEN 3
+		WHEN description LIKE ? THEN 2
+		ELSE 1
+	END %s, created_at DESC, id DESC`
+
+// relevanceArgs returns the arguments for relevanceOrder
+func relevanceArgs(term string) []interface{} {
+	return []interface{}{term, term + "%", "%" + term + "%", term + "%"}
+}
+
+// orderBy builds an ORDER BY clause for a validated column and order. Rows
+// that tie on the column are ordered by id, so repeated queries and pages of
+// a paginated one always see the same order.
+func orderBy(sortBy, sortOrder string) string {
+	clause := fmt.Sprintf(` ORDER BY %s %s`, sortBy, strings.ToUpper(sortOrder))
+
+	// Starred todos float to the top, newest first within each group
+	if sortBy == "starred" {
+		return clause + `, created_at DESC, id DESC`
+	}
+
+	return clause + `, id ` + strings.ToUpper(sortOrder)
+}
+
+// buildFilter builds the WHERE conditions shared by Search and Count
+func buildFilter(opts FilterOptions) (string, []interface{}) {
+	where := "1=1"
 	var args []interface{}
 
 	// Add search filter
 	if opts.Search != "" {
-		query += ` AND (title LIKE ? OR description LIKE ?)`
+		where += ` AND (title LIKE ? OR description LIKE ?)`
 		searchTerm := "%" + opts.Search + "%"
 		args = append(args, searchTerm, searchTerm)
 	}
 
 	// Add completion filter
 	if opts.Completed != nil {
-		query += ` AND completed = ?`
+		where += ` AND completed = ?`
 		args = append(args, *opts.Completed)
 	}
 
-	// Add sorting
-	sortBy := "created_at"
-	if opts.SortBy != "" {
-		// Validate sort field to prevent SQL injection
-		validFields := map[string]bool{
-			"created_at": true,
-			"updated_at": true,
-			"title":      true,
-		}
-		if validFields[opts.SortBy] {
-			sortBy = opts.SortBy
-		}
-	}
-
-	sortOrder := "DESC"
-	if opts.SortOrder != "" && opts.SortOrder == "asc" {
-		sortOrder = "ASC"
+	// Add starred filter
+	if opts.Starred != nil {
+		where += ` AND starred = ?`
+		args = append(args, *opts.Starred)
 	}
 
-	query += fmt.Sprintf(` ORDER BY %s %s`, sortBy, sortOrder)
+	// Drafts stay out of results unless they are asked for
+	where += ` AND draft = ?`
+	args = append(args, opts.Drafts)
 
-	rows, err := r.db.QueryContext(context.Background(), query, args...)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query todos: %w", err)
+	// Add time range filters. Comparing via julianday normalizes the
+	// stored timestamps' offsets, so filters work regardless of time zone.
+	timeFilters := []struct {
+		clause string
+		value  *time.Time
+	}{
+		{` AND julianday(created_at) >= julianday(?)`, opts.CreatedAfter},
+		{` AND julianday(created_at) < julianday(?)`, opts.CreatedBefore},
+		{` AND julianday(updated_at) >= julianday(?)`, opts.UpdatedAfter},
+		{` AND julianday(updated_at) < julianday(?)`, opts.UpdatedBefore},
 	}
-
-	var todos []models.Todo
-	for rows.Next() {
-		var todo models.Todo
-		err := rows.Scan(
-			&todo.ID,
-			&todo.Title,
-			&todo.Description,
-			&todo.Completed,
-			&todo.CreatedAt,
-			&todo.UpdatedAt,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan todo: %w", err)
+	for _, f := range timeFilters {
+		if f.value != nil {
+			where += f.clause
+			args = append(args, *f.value)
 		}
-		todos = append(todos, todo)
 	}
 
-	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating todos: %w", err)
+	return where, args
+}
+
+// Count returns the number of todos matching the filters in opts. Sorting
+// and pagination options are ignored.
+func (r *TodoRepository) Count(ctx context.Context, opts FilterOptions) (int64, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	where, args := buildFilter(opts)
+	query := "SELECT COUNT(*) FROM todos WHERE " + where
+
+	var count int64
+	if err := r.db.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count todos: %w", err)
 	}
 
-	// Check for errors from closing rows
-	if err = rows.Close(); err != nil {
-		return nil, fmt.Errorf("failed to close rows: %w", err)
+	return count, nil
+}
+
+// RemainingMinutes returns the summed estimates of the incomplete todos
+// matching the filters in opts. Sorting and pagination options are ignored.
+func (r *TodoRepository) RemainingMinutes(ctx context.Context, opts FilterOptions) (int64, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	where, args := buildFilter(opts)
+	query := "SELECT COALESCE(SUM(estimate_minutes), 0) FROM todos WHERE " + where + " AND completed = 0"
+
+	var minutes int64
+	if err := r.db.QueryRowContext(ctx, query, args...).Scan(&minutes); err != nil {
+		return 0, fmt.Errorf("failed to sum estimates: %w", err)
 	}
 
-	return todos, nil
+	return minutes, nil
 }
 
-// GetByID returns a todo by ID
-func (r *TodoRepository) GetByID(id int64) (*models.Todo, error) {
-	query := `
-		SELECT id, title, description, completed, created_at, updated_at
-		FROM todos
-		WHERE id = ?
-	`
+// Random returns a randomly chosen todo matching the filters in opts, or nil
+// and no error if none match. Sorting and pagination in opts are ignored.
+func (r *TodoRepository) Random(ctx context.Context, opts FilterOptions) (*models.Todo, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
 
-	var todo models.Todo
-	err := r.db.QueryRowContext(context.Background(), query, id).Scan(
-		&todo.ID,
-		&todo.Title,
-		&todo.Description,
-		&todo.Completed,
-		&todo.CreatedAt,
-		&todo.UpdatedAt,
-	)
+	where, args := buildFilter(opts)
+	query := "SELECT " + todoColumns + " FROM todos WHERE " + where + " ORDER BY RANDOM() LIMIT 1"
 
+	var todo models.Todo
+	err := scanTodo(r.db.QueryRowContext(ctx, query, args...), &todo)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to get todo: %w", err)
+		return nil, fmt.Errorf("failed to get random todo: %w", err)
 	}
 
 	return &todo, nil
 }
 
-// Update updates a todo
-func (r *TodoRepository) Update(id int64, req models.UpdateTodoRequest) (*models.Todo, error) {
-	// First, get the existing todo
-	existing, err := r.GetByID(id)
+// Search searches and filters todos
+func (r *TodoRepository) Search(ctx context.Context, opts FilterOptions) ([]models.Todo, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	query, args, err := r.searchQuery(opts)
 	if err != nil {
 		return nil, err
 	}
-	if existing == nil {
-		return nil, nil
+
+	return r.queryTodos(ctx, query, args...)
+}
+
+// Stream calls fn with each todo matching opts, in the same order as
+// Search, stopping at the first error fn returns.
+//
+// While rows are open they hold a database connection, and fn runs at the
+// pace of whoever reads the response. Rows are therefore only passed to fn
+// as they are read when the pool has a connection to spare and no query
+// timeout could cut the list short; otherwise they are loaded first and the
+// connection released before fn is called.
+func (r *TodoRepository) Stream(ctx context.Context, opts FilterOptions, fn func(models.Todo) error) error {
+	query, args, err := r.searchQuery(opts)
+	if err != nil {
+		return err
 	}
 
-	// Build the update query dynamically
-	query := "UPDATE todos SET updated_at = ?"
-	args := []interface{}{time.Now()}
+	if r.db.canStream() {
+		return r.eachTodo(ctx, query, args, fn)
+	}
 
-	if req.Title != nil {
-		query += ", title = ?"
-		args = append(args, *req.Title)
+	ctx, cancel := r.db.withTimeout(ctx)
+	todos, err := r.queryTodos(ctx, query, args...)
+	cancel()
+	if err != nil {
+		return err
 	}
-	if req.Description != nil {
-		query += ", description = ?"
-		args = append(args, *req.Description)
+	for _, todo := range todos {
+		if err := fn(todo); err != nil {
+			return err
+		}
 	}
-	if req.Completed != nil {
-		query += ", completed = ?"
-		args = append(args, *req.Completed)
+	return nil
+}
+
+// searchQuery builds the query and arguments for Search and Stream
+func (r *TodoRepository) searchQuery(opts FilterOptions) (string, []interface{}, error) {
+	where, args := buildFilter(opts)
+	query := `
+		SELECT ` + todoColumns + `
+		FROM todos
+		WHERE ` + where + `
+	`
+
+	// Determine sorting, falling back to the configured defaults
+	sortBy := r.defaultSortBy
+	if opts.SortBy == SortRelevance {
+		if opts.Search == "" {
+			return "", nil, ErrRelevanceWithoutSearch
+		}
+		sortBy = SortRelevance
+	} else if opts.SortBy != "" {
+		// Validate sort field to prevent SQL injection
+		if !validSortFields[opts.SortBy] {
+			return "", nil, fmt.Errorf("invalid sort field: %s", opts.SortBy)
+		}
+		sortBy = opts.SortBy
 	}
 
-	query += " WHERE id = ?"
-	args = append(args, id)
+	sortOrder := r.defaultSortOrder
+	if sortBy == SortRelevance {
+		// Best matches first unless asked otherwise, whatever the default
+		sortOrder = "desc"
+	}
+	if opts.SortOrder != "" {
+		if opts.SortOrder != "asc" && opts.SortOrder != "desc" {
+			return "", nil, fmt.Errorf("invalid sort order: %s", opts.SortOrder)
+		}
+		sortOrder = opts.SortOrder
+	}
 
-	_, err = r.db.ExecContext(context.Background(), query, args...)
-	if err != nil {
-		return nil, fmt.Errorf("failed to update todo: %w", err)
+	// Add keyset pagination filter
+	if opts.After != nil {
+		if sortBy != "created_at" {
+			return "", nil, fmt.Errorf("cursor pagination requires sorting by created_at")
+		}
+		op := "<"
+		if sortOrder == "asc" {
+			op = ">"
+		}
+		// Compared as text, so the cursor must be bound in UTC like the column
+		query += fmt.Sprintf(` AND (created_at, id) %s (?, ?)`, op)
+		args = append(args, opts.After.CreatedAt.UTC(), opts.After.ID)
+	}
+
+	// Both orders end with id, so pages don't overlap or skip rows
+	if sortBy == SortRelevance {
+		query += fmt.Sprintf(relevanceOrder, strings.ToUpper(sortOrder))
+		args = append(args, relevanceArgs(opts.Search)...)
+	} else {
+		query += orderBy(sortBy, sortOrder)
+	}
+
+	if opts.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, opts.Limit)
 	}
 
-	// Return the updated todo
-	return r.GetByID(id)
+	return query, args, nil
 }
 
-// Delete deletes a todo by ID
-func (r *TodoRepository) Delete(id int64) error {
-	query := "DELETE FROM todos WHERE id = ?"
-	result, err := r.db.ExecContext(context.Background(), query, id)
-	if err != nil {
-		return fmt.Errorf("failed to delete todo: %w", err)
+// SyncCursor identifies a change's position in updated_at, id order
+type SyncCursor struct {
+	UpdatedAt time.Time
+	ID        int64
+}
+
+// Changes returns the todos updated or deleted after since, in the order
+// they changed. Deleted todos are reported from their tombstones with only
+// the ID and the deletion time as UpdatedAt. When after is set, results
+// resume after that change; limit caps the results when positive.
+func (r *TodoRepository) Changes(ctx context.Context, since time.Time, after *SyncCursor, limit int) ([]models.TodoChange, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT ` + todoColumns + `, deleted
+		FROM (
+			SELECT ` + todoColumns + `, 0 AS deleted FROM todos
+			UNION ALL
+			SELECT id, COALESCE(public_id, ''), NULL, '', '', '', 0, 0, NULL, NULL, NULL, 0, 0, 0, NULL, 0, deleted_at, deleted_at, 1 FROM deleted_todos
+		)
+		WHERE julianday(updated_at) > julianday(?)
+	`
+	args := []interface{}{since}
+
+	if after != nil {
+		query += ` AND (julianday(updated_at), id) > (julianday(?), ?)`
+		args = append(args, after.UpdatedAt, after.ID)
+	}
+
+	query += ` ORDER BY julianday(updated_at) ASC, id ASC`
+
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+		return nil, fmt.Errorf("failed to query changes: %w", err)
 	}
 
-	if rowsAffected == 0 {
-		return sql.ErrNoRows
+	var changes []models.TodoChange
+	for rows.Next() {
+		var change models.TodoChange
+		if err := scanTodo(rowScanFunc(func(dest ...interface{}) error {
+			return rows.Scan(append(dest, &change.Deleted)...)
+		}), &change.Todo); err != nil {
+			return nil, fmt.Errorf("failed to scan change: %w", err)
+		}
+		changes = append(changes, change)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating changes: %w", err)
 	}
 
+	// Check for errors from closing rows
+	if err = rows.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close rows: %w", err)
+	}
+
+	return changes, nil
+}
+
+// PurgeTombstones deletes the tombstones of todos deleted before cutoff
+// and returns how many were removed. Sync clients whose last sync is older
+// than cutoff will no longer learn about those deletions.
+func (r *TodoRepository) PurgeTombstones(ctx context.Context, cutoff time.Time) (int64, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, "DELETE FROM deleted_todos WHERE julianday(deleted_at) < julianday(?)", cutoff.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge tombstones: %w", err)
+	}
+
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return purged, nil
+}
+
+// GetByID returns a todo by ID
+func (r *TodoRepository) GetByID(ctx context.Context, id int64) (*models.Todo, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	var generation uint64
+	if r.cache != nil {
+		if todo, ok := r.cache.Get(id); ok {
+			return todo, nil
+		}
+		generation = r.cache.Generation()
+	}
+
+	var todo models.Todo
+	err := scanTodo(r.getByIDStmt.QueryRowContext(ctx, id), &todo)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get todo: %w", err)
+	}
+
+	// Writes invalidate after they commit, so a row read just before an
+	// update could otherwise be cached after the update's invalidation
+	if r.cache != nil {
+		r.cache.PutIfCurrent(todo, generation)
+	}
+
+	return &todo, nil
+}
+
+// GetByPublicID returns the todo with the given public id, or nil if there
+// is none
+func (r *TodoRepository) GetByPublicID(ctx context.Context, publicID string) (*models.Todo, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	var todo models.Todo
+	err := scanTodo(r.db.QueryRowContext(ctx, "SELECT "+todoColumns+" FROM todos WHERE public_id = ?", publicID), &todo)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get todo: %w", err)
+	}
+
+	return &todo, nil
+}
+
+// GetBySlug returns the todo with the given slug, or nil if there is none
+func (r *TodoRepository) GetBySlug(ctx context.Context, slug string) (*models.Todo, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	var todo models.Todo
+	err := scanTodo(r.db.QueryRowContext(ctx, "SELECT "+todoColumns+" FROM todos WHERE slug = ?", slug), &todo)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get todo: %w", err)
+	}
+
+	return &todo, nil
+}
+
+// GetByExternalID returns the todo with the given external id, or nil if
+// there is none
+func (r *TodoRepository) GetByExternalID(ctx context.Context, externalID string) (*models.Todo, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	var todo models.Todo
+	err := scanTodo(r.db.QueryRowContext(ctx, "SELECT "+todoColumns+" FROM todos WHERE external_id = ?", externalID), &todo)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get todo: %w", err)
+	}
+
+	return &todo, nil
+}
+
+// GetByIDs returns the todos with the given ids in a single query, in the
+// order the ids were given. Ids that do not exist are skipped.
+func (r *TodoRepository) GetByIDs(ctx context.Context, ids []int64) ([]models.Todo, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+
+	query := "SELECT " + todoColumns + " FROM todos WHERE id IN (" + placeholders + ")"
+	found, err := r.queryTodos(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[int64]models.Todo, len(found))
+	for _, todo := range found {
+		byID[todo.ID] = todo
+	}
+
+	todos := make([]models.Todo, 0, len(found))
+	for _, id := range ids {
+		if todo, ok := byID[id]; ok {
+			todos = append(todos, todo)
+		}
+	}
+
+	return todos, nil
+}
+
+// Reminders returns the incomplete todos whose reminder falls between from
+// and to, soonest first. Todos snoozed past from are left out unless
+// includeSnoozed is set.
+func (r *TodoRepository) Reminders(ctx context.Context, from, to time.Time, includeSnoozed bool) ([]models.Todo, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT ` + todoColumns + `
+		FROM todos
+		WHERE completed = 0
+		  AND draft = 0
+		  AND remind_at IS NOT NULL
+		  AND julianday(remind_at) >= julianday(?)
+		  AND julianday(remind_at) <= julianday(?)
+		  AND (? OR snoozed_until IS NULL OR julianday(snoozed_until) <= julianday(?))
+		ORDER BY julianday(remind_at) ASC, id ASC
+	`
+
+	return r.queryTodos(ctx, query, from, to, includeSnoozed, from)
+}
+
+// Schedule returns the todos, other than drafts, scheduled at or after from
+// and before to, in scheduled order
+func (r *TodoRepository) Schedule(ctx context.Context, from, to time.Time) ([]models.Todo, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT ` + todoColumns + `
+		FROM todos
+		WHERE draft = 0
+		  AND scheduled_at IS NOT NULL
+		  AND julianday(scheduled_at) >= julianday(?)
+		  AND julianday(scheduled_at) < julianday(?)
+		ORDER BY julianday(scheduled_at) ASC, id ASC
+	`
+
+	return r.queryTodos(ctx, query, from, to)
+}
+
+// RecordAccess notes that the todo with id was just viewed, for Recent. It
+// does not change updated_at, and does nothing if the todo does not exist.
+func (r *TodoRepository) RecordAccess(ctx context.Context, id int64) error {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	if _, err := r.db.ExecContext(ctx, "UPDATE todos SET last_accessed_at = ? WHERE id = ?", utcNow(), id); err != nil {
+		return fmt.Errorf("failed to record access: %w", err)
+	}
+	return nil
+}
+
+// Recent returns up to limit todos that have been viewed, most recently
+// viewed first
+func (r *TodoRepository) Recent(ctx context.Context, limit int) ([]models.Todo, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT ` + todoColumns + `
+		FROM todos
+		WHERE last_accessed_at IS NOT NULL
+		ORDER BY julianday(last_accessed_at) DESC, id DESC
+		LIMIT ?
+	`
+
+	return r.queryTodos(ctx, query, limit)
+}
+
+// resolveMetadataPatch replaces req.MetadataPatch with the metadata it
+// produces when merged into the current metadata of the todo with id, read
+// in tx so that a concurrent update cannot be lost. A missing todo is left
+// for the update to report.
+func resolveMetadataPatch(ctx context.Context, tx *sql.Tx, id int64, req *models.UpdateTodoRequest) error {
+	if req.MetadataPatch == nil || req.Metadata != nil {
+		return nil
+	}
+
+	var current models.Metadata
+	err := tx.QueryRowContext(ctx, "SELECT metadata FROM todos WHERE id = ?", id).Scan(metadataColumn{dest: &current})
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	req.Metadata = current.Merge(req.MetadataPatch)
+	req.MetadataPatch = nil
+	return nil
+}
+
+// updateStatement builds the UPDATE that applies req to the todo with id,
+// including its IfUnmodifiedSince precondition. A non-empty slug replaces
+// the todo's slug if the title changes.
+func updateStatement(id int64, req models.UpdateTodoRequest, slug string, now time.Time) (string, []interface{}, error) {
+	query := "UPDATE todos SET updated_at = ?"
+	args := []interface{}{now}
+
+	if req.Title != nil {
+		query += ", title = ?"
+		args = append(args, *req.Title)
+		if slug != "" {
+			query += ", slug = CASE WHEN title = ? THEN slug ELSE ? END"
+			args = append(args, *req.Title, slug)
+		}
+	}
+	if req.Description != nil {
+		query += ", description = ?"
+		args = append(args, *req.Description)
+	}
+	if req.Completed != nil {
+		query += ", completed = ?"
+		args = append(args, *req.Completed)
+	}
+	if req.Starred != nil {
+		query += ", starred = ?"
+		args = append(args, *req.Starred)
+	}
+	if req.RemindAt != nil {
+		query += ", remind_at = ?"
+		args = append(args, utcTime(req.RemindAt))
+	}
+	if req.ClearRemindAt {
+		query += ", remind_at = NULL"
+	}
+	if req.ScheduledAt != nil {
+		query += ", scheduled_at = ?"
+		args = append(args, utcTime(req.ScheduledAt))
+	}
+	if req.ClearScheduledAt {
+		query += ", scheduled_at = NULL"
+	}
+	if req.SnoozedUntil != nil {
+		query += ", snoozed_until = ?"
+		args = append(args, utcTime(req.SnoozedUntil))
+	}
+	if req.Draft != nil {
+		query += ", draft = ?"
+		args = append(args, *req.Draft)
+	}
+	if req.Metadata != nil {
+		metadata, err := encodeMetadata(req.Metadata)
+		if err != nil {
+			return "", nil, err
+		}
+		query += ", metadata = ?"
+		args = append(args, metadata)
+	}
+	if req.EstimateMinutes != nil {
+		query += ", estimate_minutes = ?"
+		args = append(args, *req.EstimateMinutes)
+	}
+
+	query += " WHERE id = ?"
+	args = append(args, id)
+
+	// Checking the precondition in the same statement keeps another update
+	// from slipping in between the check and the write
+	if req.IfUnmodifiedSince != nil {
+		query += " AND julianday(updated_at) <= julianday(?)"
+		args = append(args, req.IfUnmodifiedSince.UTC())
+	}
+
+	return query, args, nil
+}
+
+// Update updates a todo. Completing a todo that depends on an incomplete
+// todo returns a *BlockedError.
+func (r *TodoRepository) Update(ctx context.Context, id int64, req models.UpdateTodoRequest) (updated *models.Todo, err error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				err = fmt.Errorf("rollback failed: %v (original error: %w)", rbErr, err)
+			}
+		}
+	}()
+
+	// First, get the existing title, which decides whether the slug changes
+	var title string
+	err = tx.QueryRowContext(ctx, "SELECT title FROM todos WHERE id = ?", id).Scan(&title)
+	if err == sql.ErrNoRows {
+		// The deferred rollback only runs on error
+		_ = tx.Rollback()
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get todo: %w", err)
+	}
+
+	if req.Completed != nil && *req.Completed {
+		if err = checkBlockers(ctx, tx, id); err != nil {
+			return nil, err
+		}
+	}
+	if err = resolveMetadataPatch(ctx, tx, id, &req); err != nil {
+		return nil, fmt.Errorf("failed to get todo: %w", err)
+	}
+
+	now := utcNow()
+	var todo models.Todo
+	update := func(slug string) error {
+		query, args, err := updateStatement(id, req, slug, now)
+		if err != nil {
+			return err
+		}
+		return scanTodo(tx.QueryRowContext(ctx, query+" RETURNING "+todoColumns, args...), &todo)
+	}
+	if req.Title != nil && *req.Title != title && !r.stableSlugs {
+		err = withUniqueSlug(ctx, tx, *req.Title, id, update)
+	} else {
+		err = update("")
+	}
+	if err == sql.ErrNoRows {
+		// Only the IfUnmodifiedSince precondition can skip an existing todo
+		err = ErrStaleUpdate
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update todo: %w", mapConstraintError(err))
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	r.invalidate(id)
+
+	return &todo, nil
+}
+
+// Upsert creates a todo with externalID from req or, if one exists, replaces
+// its fields with req's, atomically. It reports whether the todo was created.
+// Completing an existing todo that depends on an incomplete todo returns a
+// *BlockedError.
+func (r *TodoRepository) Upsert(ctx context.Context, externalID string, req models.UpsertTodoRequest) (upserted *models.Todo, created bool, err error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	metadata, err := encodeMetadata(req.Metadata)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to upsert todo: %w", err)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				err = fmt.Errorf("rollback failed: %v (original error: %w)", rbErr, err)
+			}
+		}
+	}()
+
+	// Whether the todo exists decides both the outcome and, since the slug
+	// must not count the todo being replaced as a conflict, the slug
+	var existingID int64
+	err = tx.QueryRowContext(ctx, "SELECT id FROM todos WHERE external_id = ?", externalID).Scan(&existingID)
+	switch {
+	case err == sql.ErrNoRows:
+		created = true
+	case err != nil:
+		return nil, false, fmt.Errorf("failed to upsert todo: %w", err)
+	case req.Completed:
+		// A new todo has no dependencies, but completing an existing one is
+		// held to the same rule as an update
+		if err = checkBlockers(ctx, tx, existingID); err != nil {
+			return nil, false, err
+		}
+	}
+
+	now := utcNow()
+	var todo models.Todo
+	err = withUniqueSlug(ctx, tx, req.Title, existingID, func(slug string) error {
+		return scanTodo(tx.QueryRowContext(ctx, upsertTodoQuery,
+			newPublicID(), externalID, slug, req.Title, req.CreateDescription(), req.Completed, req.Starred, utcTime(req.RemindAt), utcTime(req.ScheduledAt), req.Draft, metadata, req.EstimateMinutes, now, now,
+			r.stableSlugs, req.Description,
+		), &todo)
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to upsert todo: %w", mapConstraintError(err))
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	r.invalidate(todo.ID)
+
+	return &todo, created, nil
+}
+
+// UpdateMany applies each update in items, in order, in a single
+// transaction, returning the updated todos and the ids that do not exist.
+// With atomic, a missing id rolls the whole batch back and ErrTodosNotFound
+// is returned along with notFound. A stale or invalid update, or one that
+// completes a todo blocked by an incomplete todo, always fails the batch.
+func (r *TodoRepository) UpdateMany(ctx context.Context, items []models.BatchUpdateItem, atomic bool) (updated []models.Todo, notFound []int64, err error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				err = fmt.Errorf("rollback failed: %v (original error: %w)", rbErr, err)
+			}
+		}
+	}()
+
+	// Todos completed together may depend on each other
+	var completing []int64
+	for _, item := range items {
+		if item.Completed != nil && *item.Completed {
+			completing = append(completing, int64(item.ID))
+		}
+	}
+	if err = checkBlockers(ctx, tx, completing...); err != nil {
+		return nil, nil, err
+	}
+
+	now := utcNow()
+	ids := make([]int64, 0, len(items))
+	for _, item := range items {
+		id := int64(item.ID)
+		ids = append(ids, id)
+
+		req := item.UpdateTodoRequest
+		if err = resolveMetadataPatch(ctx, tx, id, &req); err != nil {
+			return nil, nil, fmt.Errorf("failed to get todo %d: %w", id, err)
+		}
+
+		var todo models.Todo
+		update := func(slug string) error {
+			query, args, err := updateStatement(id, req, slug, now)
+			if err != nil {
+				return err
+			}
+			return scanTodo(tx.QueryRowContext(ctx, query+" RETURNING "+todoColumns, args...), &todo)
+		}
+		if item.Title != nil && !r.stableSlugs {
+			err = withUniqueSlug(ctx, tx, *item.Title, id, update)
+		} else {
+			err = update("")
+		}
+		if err == sql.ErrNoRows {
+			var exists bool
+			if err = tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM todos WHERE id = ?)", id).Scan(&exists); err != nil {
+				return nil, nil, fmt.Errorf("failed to check todo %d: %w", id, err)
+			}
+			if exists {
+				// Only the IfUnmodifiedSince precondition can skip an
+				// existing todo
+				return nil, nil, fmt.Errorf("failed to update todo %d: %w", id, ErrStaleUpdate)
+			}
+			notFound = append(notFound, id)
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to update todo %d: %w", id, mapConstraintError(err))
+		}
+		updated = append(updated, todo)
+	}
+
+	if atomic && len(notFound) > 0 {
+		return nil, notFound, ErrTodosNotFound
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	r.invalidate(ids...)
+
+	return updated, notFound, nil
+}
+
+// Delete deletes a todo by ID and returns the deleted todo. It returns
+// sql.ErrNoRows if the todo does not exist.
+func (r *TodoRepository) Delete(ctx context.Context, id int64) (*models.Todo, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	// DELETE ... RETURNING reads and removes the row in a single statement
+	var todo models.Todo
+	err := scanTodo(r.deleteStmt.QueryRowContext(ctx, id), &todo)
+	r.invalidate(id)
+
+	if err == sql.ErrNoRows {
+		return nil, sql.ErrNoRows
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete todo: %w", err)
+	}
+
+	return &todo, nil
+}
+
+// DeleteAll deletes every todo, along with their attachments and comments,
+// and returns how many todos were deleted
+func (r *TodoRepository) DeleteAll(ctx context.Context) (int64, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, "DELETE FROM todos")
+	if r.cache != nil {
+		r.cache.Clear()
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete todos: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return deleted, nil
+}
+
+// SetCompleted sets the completed flag on each of the given todos in a single
+// transaction. It returns the updated todos along with any ids that do not exist.
+// Completing a todo that depends on an incomplete todo outside ids returns a
+// *BlockedError and changes nothing.
+func (r *TodoRepository) SetCompleted(ctx context.Context, ids []int64, completed bool) (updated []models.Todo, notFound []int64, err error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				err = fmt.Errorf("rollback failed: %v (original error: %w)", rbErr, err)
+			}
+		}
+	}()
+
+	if completed {
+		if err = checkBlockers(ctx, tx, ids...); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	query := `
+		UPDATE todos SET completed = ?, updated_at = ?
+		WHERE id = ?
+		RETURNING ` + todoColumns + `
+	`
+
+	now := utcNow()
+	for _, id := range ids {
+		var todo models.Todo
+		err = scanTodo(tx.QueryRowContext(ctx, query, completed, now, id), &todo)
+		if err == sql.ErrNoRows {
+			notFound = append(notFound, id)
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to update todo %d: %w", id, err)
+		}
+		updated = append(updated, todo)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	r.invalidate(ids...)
+
+	return updated, notFound, nil
+}
+
+// SetFocus sets whether the todo with id is the focused todo, returning it,
+// or nil and no error if it does not exist. Focusing a todo unfocuses any
+// other in the same transaction, so at most one is focused at a time.
+func (r *TodoRepository) SetFocus(ctx context.Context, id int64, focused bool) (todo *models.Todo, err error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				err = fmt.Errorf("rollback failed: %v (original error: %w)", rbErr, err)
+			}
+		}
+	}()
+
+	var exists bool
+	err = tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM todos WHERE id = ?)", id).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get todo: %w", err)
+	}
+	if !exists {
+		// The deferred rollback only runs on error
+		_ = tx.Rollback()
+		return nil, nil
+	}
+
+	now := utcNow()
+	ids := []int64{id}
+	if focused {
+		// The unique index allows only one focused todo to clear
+		var previous int64
+		err = tx.QueryRowContext(ctx, "UPDATE todos SET focused = 0, updated_at = ? WHERE focused = 1 AND id != ? RETURNING id", now, id).Scan(&previous)
+		switch {
+		case err == sql.ErrNoRows:
+			err = nil
+		case err != nil:
+			return nil, fmt.Errorf("failed to unfocus todo: %w", err)
+		default:
+			ids = append(ids, previous)
+		}
+	}
+
+	var updated models.Todo
+	query := "UPDATE todos SET focused = ?, updated_at = ? WHERE id = ? RETURNING " + todoColumns
+	if err = scanTodo(tx.QueryRowContext(ctx, query, focused, now, id), &updated); err != nil {
+		return nil, fmt.Errorf("failed to focus todo: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	r.invalidate(ids...)
+
+	return &updated, nil
+}
+
+// Focused returns the focused todo, or nil and no error if there is none
+func (r *TodoRepository) Focused(ctx context.Context) (*models.Todo, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	var todo models.Todo
+	err := scanTodo(r.db.QueryRowContext(ctx, "SELECT "+todoColumns+" FROM todos WHERE focused = 1"), &todo)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get focused todo: %w", err)
+	}
+
+	return &todo, nil
+}
+
+// ToggleCompleted flips the completed flag of each todo in ids to its
+// opposite in a single transaction. It returns the updated todos and the ids
+// that do not exist. Completing a todo that depends on an incomplete todo
+// outside ids returns a *BlockedError and changes nothing.
+func (r *TodoRepository) ToggleCompleted(ctx context.Context, ids []int64) (updated []models.Todo, notFound []int64, err error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				err = fmt.Errorf("rollback failed: %v (original error: %w)", rbErr, err)
+			}
+		}
+	}()
+
+	// The incomplete todos are the ones being completed
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	completing, err := scanIDs(ctx, tx, "SELECT id FROM todos WHERE id IN ("+placeholders+") AND completed = 0", args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = checkBlockers(ctx, tx, completing...); err != nil {
+		return nil, nil, err
+	}
+
+	query := `
+		UPDATE todos SET completed = NOT completed, updated_at = ?
+		WHERE id = ?
+		RETURNING ` + todoColumns + `
+	`
+
+	now := utcNow()
+	for _, id := range ids {
+		var todo models.Todo
+		err = scanTodo(tx.QueryRowContext(ctx, query, now, id), &todo)
+		if err == sql.ErrNoRows {
+			notFound = append(notFound, id)
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to toggle todo %d: %w", id, err)
+		}
+		updated = append(updated, todo)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	r.invalidate(ids...)
+
+	return updated, notFound, nil
+}
+
+// CompleteMatching marks every incomplete todo matching the filters in opts
+// as completed, in a single statement, and returns how many it completed.
+// Todos that depend on an incomplete todo are skipped. Sorting and
+// pagination in opts are ignored.
+func (r *TodoRepository) CompleteMatching(ctx context.Context, opts FilterOptions) (int64, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	where, args := buildFilter(opts)
+	query := `UPDATE todos SET completed = 1, updated_at = ? WHERE ` + where + ` AND completed = 0
+		AND NOT EXISTS (
+			SELECT 1 FROM todo_dependencies d JOIN todos b ON b.id = d.depends_on_id
+			WHERE d.todo_id = todos.id AND b.completed = 0
+		)
+		RETURNING id`
+	args = append([]interface{}{utcNow()}, args...)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to complete todos: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return 0, fmt.Errorf("failed to scan id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to complete todos: %w", err)
+	}
+	r.invalidate(ids...)
+
+	return int64(len(ids)), nil
+}
+
+// Move places a todo directly after the todo with id after, or first in
+// the list when after is nil. Only the moved row is rewritten, unless the
+// gap it moves into is exhausted and every position is respaced first. It
+// returns nil if the todo does not exist and ErrMoveAfterNotFound if the
+// todo to move after does not. Moving a todo after itself changes nothing.
+func (r *TodoRepository) Move(ctx context.Context, id int64, after *int64) (moved *models.Todo, err error) {
+	if after != nil && *after == id {
+		return r.GetByID(ctx, id)
+	}
+
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				err = fmt.Errorf("rollback failed: %v (original error: %w)", rbErr, err)
+			}
+		}
+	}()
+
+	var exists bool
+	err = tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM todos WHERE id = ?)", id).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get todo: %w", err)
+	}
+	if !exists {
+		// The deferred rollback only runs on error
+		_ = tx.Rollback()
+		return nil, nil
+	}
+
+	now := utcNow()
+	prev, next, err := moveNeighbours(ctx, tx, id, after)
+	if err != nil {
+		return nil, err
+	}
+	position, ok := positionBetween(prev, next)
+	if !ok {
+		if err = rebalancePositions(ctx, tx, now); err != nil {
+			return nil, err
+		}
+		if prev, next, err = moveNeighbours(ctx, tx, id, after); err != nil {
+			return nil, err
+		}
+		position, _ = positionBetween(prev, next)
+	}
+
+	var todo models.Todo
+	query := `
+		UPDATE todos SET position = ?, updated_at = ?
+		WHERE id = ?
+		RETURNING ` + todoColumns + `
+	`
+	if err = scanTodo(tx.QueryRowContext(ctx, query, position, now, id), &todo); err != nil {
+		return nil, fmt.Errorf("failed to move todo: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	if ok {
+		r.invalidate(id)
+	} else if r.cache != nil {
+		r.cache.Clear()
+	}
+
+	return &todo, nil
+}
+
+// moveNeighbours returns the positions id would sit between after being
+// moved after the todo with id after, or to the front when after is nil. A
+// nil bound is an end of the list.
+func moveNeighbours(ctx context.Context, tx *sql.Tx, id int64, after *int64) (prev, next *int64, err error) {
+	// The list is ordered by (position, id), so the next todo is the first
+	// one past that pair
+	nextQuery := `
+		SELECT position FROM todos
+		WHERE id != ? AND (position > ? OR (position = ? AND id > ?))
+		ORDER BY position, id
+		LIMIT 1
+	`
+	var nextArgs []interface{}
+
+	if after != nil {
+		var afterPosition int64
+		err := tx.QueryRowContext(ctx, "SELECT position FROM todos WHERE id = ?", *after).Scan(&afterPosition)
+		if err == sql.ErrNoRows {
+			return nil, nil, ErrMoveAfterNotFound
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get todo to move after: %w", err)
+		}
+		prev = &afterPosition
+		nextArgs = []interface{}{id, afterPosition, afterPosition, *after}
+	} else {
+		nextQuery = "SELECT position FROM todos WHERE id != ? ORDER BY position, id LIMIT 1"
+		nextArgs = []interface{}{id}
+	}
+
+	var nextPosition int64
+	err = tx.QueryRowContext(ctx, nextQuery, nextArgs...).Scan(&nextPosition)
+	if err == sql.ErrNoRows {
+		return prev, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get next todo: %w", err)
+	}
+
+	return prev, &nextPosition, nil
+}
+
+// rebalancePositions respaces every todo positionGap apart, keeping their
+// order. Respaced todos are stamped with now so sync clients pick up their
+// new positions.
+func rebalancePositions(ctx context.Context, tx *sql.Tx, now time.Time) error {
+	query := `
+		UPDATE todos SET position = ranked.n * ?, updated_at = ?
+		FROM (SELECT id, ROW_NUMBER() OVER (ORDER BY position, id) AS n FROM todos) AS ranked
+		WHERE todos.id = ranked.id AND todos.position != ranked.n * ?
+	`
+	if _, err := tx.ExecContext(ctx, query, positionGap, now, positionGap); err != nil {
+		return fmt.Errorf("failed to rebalance positions: %w", err)
+	}
 	return nil
 }
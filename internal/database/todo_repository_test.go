@@ -0,0 +1,636 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/larryhudson/go-todo-list-claude/internal/models"
+)
+
+func setupBenchRepo(b *testing.B) *TodoRepository {
+	b.Helper()
+
+	db, err := New(":memory:", DefaultConfig())
+	if err != nil {
+		b.Fatalf("Failed to create database: %v", err)
+	}
+	b.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			b.Errorf("Failed to close database: %v", err)
+		}
+	})
+
+	if err := db.Initialize(); err != nil {
+		b.Fatalf("Failed to initialize database: %v", err)
+	}
+
+	repo, err := NewTodoRepository(db)
+	if err != nil {
+		b.Fatalf("Failed to create repository: %v", err)
+	}
+	b.Cleanup(func() {
+		if err := repo.Close(); err != nil {
+			b.Errorf("Failed to close repository: %v", err)
+		}
+	})
+
+	return repo
+}
+
+func BenchmarkCreate(b *testing.B) {
+	repo := setupBenchRepo(b)
+	ctx := context.Background()
+
+	for b.Loop() {
+		if _, err := repo.Create(ctx, models.CreateTodoRequest{Title: "Benchmark"}); err != nil {
+			b.Fatalf("Failed to create todo: %v", err)
+		}
+	}
+}
+
+func BenchmarkGetByID(b *testing.B) {
+	repo := setupBenchRepo(b)
+	ctx := context.Background()
+
+	todo, err := repo.Create(ctx, models.CreateTodoRequest{Title: "Benchmark"})
+	if err != nil {
+		b.Fatalf("Failed to create todo: %v", err)
+	}
+
+	for b.Loop() {
+		if _, err := repo.GetByID(ctx, todo.ID); err != nil {
+			b.Fatalf("Failed to get todo: %v", err)
+		}
+	}
+}
+
+func setupTestRepo(t *testing.T) *TodoRepository {
+	t.Helper()
+
+	db, err := New(":memory:", DefaultConfig())
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	})
+
+	if err := db.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+
+	repo, err := NewTodoRepository(db)
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := repo.Close(); err != nil {
+			t.Errorf("Failed to close repository: %v", err)
+		}
+	})
+
+	return repo
+}
+
+func TestGetByID_Cache(t *testing.T) {
+	repo := setupTestRepo(t)
+	repo.SetCache(NewTodoCache(10, time.Minute))
+	ctx := context.Background()
+
+	created, err := repo.Create(ctx, models.CreateTodoRequest{Title: "Original"})
+	if err != nil {
+		t.Fatalf("Failed to create todo: %v", err)
+	}
+
+	if _, err := repo.GetByID(ctx, created.ID); err != nil {
+		t.Fatalf("Failed to get todo: %v", err)
+	}
+
+	// A write that bypasses the repository is not seen while cached
+	if _, err := repo.db.ExecContext(ctx, "UPDATE todos SET title = 'Bypassed' WHERE id = ?", created.ID); err != nil {
+		t.Fatalf("Failed to update todo directly: %v", err)
+	}
+	todo, err := repo.GetByID(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("Failed to get todo: %v", err)
+	}
+	if todo.Title != "Original" {
+		t.Errorf("Expected cached title 'Original', got %q", todo.Title)
+	}
+
+	// Updating through the repository invalidates the entry
+	title := "Updated"
+	if _, err := repo.Update(ctx, created.ID, models.UpdateTodoRequest{Title: &title}); err != nil {
+		t.Fatalf("Failed to update todo: %v", err)
+	}
+	todo, err = repo.GetByID(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("Failed to get todo: %v", err)
+	}
+	if todo.Title != "Updated" {
+		t.Errorf("Expected title 'Updated' after invalidation, got %q", todo.Title)
+	}
+
+	// Deleting invalidates the entry too
+	if _, err := repo.Delete(ctx, created.ID); err != nil {
+		t.Fatalf("Failed to delete todo: %v", err)
+	}
+	todo, err = repo.GetByID(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("Failed to get todo: %v", err)
+	}
+	if todo != nil {
+		t.Error("Expected deleted todo not to be served from cache")
+	}
+}
+
+func TestPurgeTombstones(t *testing.T) {
+	repo := setupTestRepo(t)
+	ctx := context.Background()
+
+	for _, title := range []string{"Old", "Recent"} {
+		todo, err := repo.Create(ctx, models.CreateTodoRequest{Title: title})
+		if err != nil {
+			t.Fatalf("Failed to create todo: %v", err)
+		}
+		if _, err := repo.Delete(ctx, todo.ID); err != nil {
+			t.Fatalf("Failed to delete todo: %v", err)
+		}
+	}
+
+	// Backdate the first tombstone past the retention period
+	old := utcNow().Add(-48 * time.Hour)
+	if _, err := repo.db.ExecContext(ctx, "UPDATE deleted_todos SET deleted_at = ? WHERE id = 1", old); err != nil {
+		t.Fatalf("Failed to backdate tombstone: %v", err)
+	}
+
+	purged, err := repo.PurgeTombstones(ctx, utcNow().Add(-24*time.Hour))
+	if err != nil {
+		t.Fatalf("PurgeTombstones failed: %v", err)
+	}
+	if purged != 1 {
+		t.Errorf("Expected 1 tombstone purged, got %d", purged)
+	}
+
+	changes, err := repo.Changes(ctx, old.Add(-time.Hour), nil, 0)
+	if err != nil {
+		t.Fatalf("Changes failed: %v", err)
+	}
+	if len(changes) != 1 || changes[0].ID != 2 || !changes[0].Deleted {
+		t.Errorf("Expected only the recent deletion to remain, got %+v", changes)
+	}
+}
+
+// uuidPattern matches a lowercase version 4 UUID
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestPublicID(t *testing.T) {
+	repo := setupTestRepo(t)
+	ctx := context.Background()
+
+	created, err := repo.Create(ctx, models.CreateTodoRequest{Title: "Public"})
+	if err != nil {
+		t.Fatalf("Failed to create todo: %v", err)
+	}
+	if !uuidPattern.MatchString(created.PublicID) {
+		t.Errorf("Expected a version 4 UUID, got %q", created.PublicID)
+	}
+
+	got, err := repo.GetByPublicID(ctx, created.PublicID)
+	if err != nil || got == nil || got.ID != created.ID {
+		t.Errorf("Expected to find the todo by public id, got %+v, %v", got, err)
+	}
+	if got, err := repo.GetByPublicID(ctx, newPublicID()); got != nil || err != nil {
+		t.Errorf("Expected nil, nil for an unknown public id, got %+v, %v", got, err)
+	}
+
+	// Rows inserted outside the repository get one from the trigger
+	var raw string
+	if _, err := repo.db.ExecContext(ctx, "INSERT INTO todos (title, description) VALUES ('Raw', '')"); err != nil {
+		t.Fatalf("Failed to insert todo: %v", err)
+	}
+	if err := repo.db.QueryRowContext(ctx, "SELECT public_id FROM todos WHERE title = 'Raw'").Scan(&raw); err != nil {
+		t.Fatalf("Failed to read public id: %v", err)
+	}
+	if !uuidPattern.MatchString(raw) {
+		t.Errorf("Expected the trigger to assign a UUID, got %q", raw)
+	}
+
+	// Tombstones keep the public id for sync clients
+	if _, err := repo.Delete(ctx, created.ID); err != nil {
+		t.Fatalf("Failed to delete todo: %v", err)
+	}
+	changes, err := repo.Changes(ctx, created.CreatedAt.Add(-time.Second), nil, 0)
+	if err != nil {
+		t.Fatalf("Changes failed: %v", err)
+	}
+	found := false
+	for _, change := range changes {
+		if change.Deleted && change.ID == created.ID {
+			found = change.PublicID == created.PublicID
+		}
+	}
+	if !found {
+		t.Errorf("Expected the deletion to carry public id %s, got %+v", created.PublicID, changes)
+	}
+}
+
+func TestPublicIDMigration_Backfills(t *testing.T) {
+	db, err := New(":memory:", DefaultConfig())
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	ctx := context.Background()
+	if err := NewMigrator(db, migrationsBefore(t, "011")).Run(); err != nil {
+		t.Fatalf("Failed to run earlier migrations: %v", err)
+	}
+	for _, title := range []string{"One", "Two"} {
+		if _, err := db.ExecContext(ctx, "INSERT INTO todos (title) VALUES (?)", title); err != nil {
+			t.Fatalf("Failed to insert todo: %v", err)
+		}
+	}
+	if err := NewMigrator(db, Migrations).Run(); err != nil {
+		t.Fatalf("Failed to run migrations: %v", err)
+	}
+
+	var ids []string
+	rows, err := db.QueryContext(ctx, "SELECT public_id FROM todos")
+	if err != nil {
+		t.Fatalf("Failed to query public ids: %v", err)
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			t.Fatalf("Failed to scan public id: %v", err)
+		}
+		if !uuidPattern.MatchString(id) {
+			t.Errorf("Expected a version 4 UUID, got %q", id)
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) != 2 || ids[0] == ids[1] {
+		t.Errorf("Expected two distinct public ids, got %v", ids)
+	}
+}
+
+func TestNullDescription(t *testing.T) {
+	repo := setupTestRepo(t)
+	ctx := context.Background()
+
+	var id int64
+	err := repo.db.QueryRowContext(ctx, "INSERT INTO todos (title, description) VALUES ('Imported', NULL) RETURNING id").Scan(&id)
+	if err != nil {
+		t.Fatalf("Failed to insert todo: %v", err)
+	}
+
+	todo, err := repo.GetByID(ctx, id)
+	if err != nil {
+		t.Fatalf("GetByID failed: %v", err)
+	}
+	if todo == nil || todo.Description != "" {
+		t.Errorf("Expected an empty description, got %+v", todo)
+	}
+
+	all, err := repo.GetAll(ctx)
+	if err != nil {
+		t.Fatalf("GetAll failed: %v", err)
+	}
+	if len(all) != 1 || all[0].Description != "" {
+		t.Errorf("Expected one todo with an empty description, got %+v", all)
+	}
+
+	found, err := repo.Search(ctx, FilterOptions{Search: "import"})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(found) != 1 || found[0].ID != id {
+		t.Errorf("Expected the search to find todo %d, got %+v", id, found)
+	}
+}
+
+func TestWarmCache(t *testing.T) {
+	repo := setupTestRepo(t)
+	ctx := context.Background()
+
+	if n, err := repo.WarmCache(ctx, 10); err != nil || n != 0 {
+		t.Errorf("Expected warming without a cache to do nothing, got %d, %v", n, err)
+	}
+
+	var ids []int64
+	for _, title := range []string{"Oldest", "Middle", "Newest"} {
+		todo, err := repo.Create(ctx, models.CreateTodoRequest{Title: title})
+		if err != nil {
+			t.Fatalf("Failed to create todo: %v", err)
+		}
+		ids = append(ids, todo.ID)
+	}
+
+	cache := NewTodoCache(2, time.Minute)
+	repo.SetCache(cache)
+
+	n, err := repo.WarmCache(ctx, 10)
+	if err != nil {
+		t.Fatalf("WarmCache failed: %v", err)
+	}
+	if n != 2 || cache.Len() != 2 {
+		t.Fatalf("Expected the cache to be filled to its size of 2, loaded %d and holds %d", n, cache.Len())
+	}
+	if _, ok := cache.Get(ids[0]); ok {
+		t.Error("Expected the least recently updated todo not to be cached")
+	}
+	for _, id := range ids[1:] {
+		if todo, ok := cache.Get(id); !ok || todo.ID != id {
+			t.Errorf("Expected todo %d to be cached, got %+v", id, todo)
+		}
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := repo.WarmCache(canceled, 10); err == nil {
+		t.Error("Expected an error when the context is done")
+	}
+}
+
+func TestTodoCache_EvictsAndExpires(t *testing.T) {
+	cache := NewTodoCache(2, time.Minute)
+	cache.Put(models.Todo{ID: 1})
+	cache.Put(models.Todo{ID: 2})
+
+	// Touch 1 so 2 becomes least recently used
+	if _, ok := cache.Get(1); !ok {
+		t.Fatal("Expected todo 1 to be cached")
+	}
+	cache.Put(models.Todo{ID: 3})
+
+	if _, ok := cache.Get(2); ok {
+		t.Error("Expected todo 2 to be evicted")
+	}
+	if cache.Len() != 2 {
+		t.Errorf("Expected 2 cached todos, got %d", cache.Len())
+	}
+
+	expiring := NewTodoCache(2, time.Nanosecond)
+	expiring.Put(models.Todo{ID: 1})
+	time.Sleep(time.Millisecond)
+	if _, ok := expiring.Get(1); ok {
+		t.Error("Expected expired todo not to be returned")
+	}
+}
+
+func TestTodoCache_DropsFillsRacingInvalidation(t *testing.T) {
+	// Entries never expire, so a stale fill would be served indefinitely
+	cache := NewTodoCache(2, 0)
+
+	// A read loads the old row, then an update commits and invalidates
+	// before the read fills the cache
+	generation := cache.Generation()
+	cache.Invalidate(1)
+	if cache.PutIfCurrent(models.Todo{ID: 1, Title: "Old"}, generation) {
+		t.Error("Expected a fill that raced an invalidation to be dropped")
+	}
+	if _, ok := cache.Get(1); ok {
+		t.Error("Expected the stale todo not to be cached")
+	}
+
+	generation = cache.Generation()
+	cache.Clear()
+	if cache.PutIfCurrent(models.Todo{ID: 2}, generation) {
+		t.Error("Expected a fill that raced a clear to be dropped")
+	}
+
+	generation = cache.Generation()
+	if !cache.PutIfCurrent(models.Todo{ID: 1, Title: "New"}, generation) {
+		t.Fatal("Expected a fill with no invalidation since it started to be stored")
+	}
+	if todo, ok := cache.Get(1); !ok || todo.Title != "New" {
+		t.Errorf("Expected the fresh todo to be cached, got %+v", todo)
+	}
+}
+
+func TestSetDefaultSort(t *testing.T) {
+	repo := setupTestRepo(t)
+	ctx := context.Background()
+
+	for _, title := range []string{"Banana", "Apple", "Cherry"} {
+		if _, err := repo.Create(ctx, models.CreateTodoRequest{Title: title}); err != nil {
+			t.Fatalf("Failed to create todo: %v", err)
+		}
+	}
+
+	if err := repo.SetDefaultSort("title", "asc"); err != nil {
+		t.Fatalf("Failed to set default sort: %v", err)
+	}
+
+	todos, err := repo.GetAll(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get todos: %v", err)
+	}
+	if todos[0].Title != "Apple" || todos[2].Title != "Cherry" {
+		t.Errorf("Expected default sort by title ascending, got %v", todos)
+	}
+
+	// Request options override the default
+	todos, err = repo.Search(ctx, FilterOptions{SortOrder: "desc"})
+	if err != nil {
+		t.Fatalf("Failed to search todos: %v", err)
+	}
+	if todos[0].Title != "Cherry" {
+		t.Errorf("Expected explicit order to override default, got %v", todos)
+	}
+
+	if err := repo.SetDefaultSort("due_date", "asc"); err == nil {
+		t.Error("Expected error for unknown sort field")
+	}
+	if err := repo.SetDefaultSort("title", "sideways"); err == nil {
+		t.Error("Expected error for unknown sort order")
+	}
+}
+
+func TestStream_SlowReaderReleasesConnection(t *testing.T) {
+	// The default pool has one connection and a query timeout, so Stream
+	// must not hold the connection while fn waits on a slow client
+	cfg := DefaultConfig()
+	cfg.QueryTimeout = 200 * time.Millisecond
+
+	db, err := New(filepath.Join(t.TempDir(), "todos.db"), cfg)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if err := db.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	repo, err := NewTodoRepository(db)
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+	t.Cleanup(func() { _ = repo.Close() })
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		if _, err := repo.Create(ctx, models.CreateTodoRequest{Title: fmt.Sprintf("Todo %d", i)}); err != nil {
+			t.Fatalf("Failed to create todo: %v", err)
+		}
+	}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	streamErr := make(chan error, 1)
+	var streamed int
+	go func() {
+		streamErr <- repo.Stream(ctx, FilterOptions{}, func(models.Todo) error {
+			if streamed == 0 {
+				close(started)
+				<-release
+			}
+			streamed++
+			return nil
+		})
+	}()
+
+	<-started
+	// Another request must get a connection while the reader is stalled
+	countCtx, cancel := context.WithTimeout(ctx, cfg.QueryTimeout/2)
+	count, err := repo.Count(countCtx, FilterOptions{})
+	cancel()
+	if err != nil {
+		close(release)
+		t.Fatalf("Expected a concurrent query to run during a slow stream, got %v", err)
+	}
+	if count != 3 {
+		t.Errorf("Expected count 3, got %d", count)
+	}
+
+	// Outlasting the query timeout must not cut the list short
+	time.Sleep(2 * cfg.QueryTimeout)
+	close(release)
+
+	if err := <-streamErr; err != nil {
+		t.Fatalf("Expected the stream to finish despite the slow reader, got %v", err)
+	}
+	if streamed != 3 {
+		t.Errorf("Expected 3 todos streamed, got %d", streamed)
+	}
+}
+
+func TestUpsert_BlockedByDependency(t *testing.T) {
+	repo := setupTestRepo(t)
+	ctx := context.Background()
+
+	paint, err := repo.Create(ctx, models.CreateTodoRequest{Title: "Buy paint"})
+	if err != nil {
+		t.Fatalf("Failed to create todo: %v", err)
+	}
+	fence, created, err := repo.Upsert(ctx, "ext-1", models.UpsertTodoRequest{Title: "Paint fence"})
+	if err != nil || !created {
+		t.Fatalf("Expected the upsert to create the todo, got %v, %v", created, err)
+	}
+	if err := NewDependencyRepository(repo.db).Add(ctx, fence.ID, paint.ID); err != nil {
+		t.Fatalf("Failed to add dependency: %v", err)
+	}
+
+	_, _, err = repo.Upsert(ctx, "ext-1", models.UpsertTodoRequest{Title: "Paint fence", Completed: true})
+	var blocked *BlockedError
+	if !errors.As(err, &blocked) || fmt.Sprint(blocked.BlockedBy) != fmt.Sprint([]int64{paint.ID}) {
+		t.Fatalf("Expected the upsert to be blocked by todo %d, got %v", paint.ID, err)
+	}
+	if todo, err := repo.GetByID(ctx, fence.ID); err != nil || todo.Completed {
+		t.Errorf("Expected the blocked todo to stay incomplete, got %+v, %v", todo, err)
+	}
+
+	// The same request replaces the todo once its dependency is done
+	completed := true
+	if _, err := repo.Update(ctx, paint.ID, models.UpdateTodoRequest{Completed: &completed}); err != nil {
+		t.Fatalf("Failed to complete todo: %v", err)
+	}
+	todo, created, err := repo.Upsert(ctx, "ext-1", models.UpsertTodoRequest{Title: "Paint fence", Completed: true})
+	if err != nil || created || !todo.Completed {
+		t.Errorf("Expected the upsert to complete the todo, got %+v, %v, %v", todo, created, err)
+	}
+}
+
+func TestCompletion_BlockedByDependency(t *testing.T) {
+	repo := setupTestRepo(t)
+	ctx := context.Background()
+
+	var ids []int64
+	for _, title := range []string{"Buy paint", "Paint fence", "Invite neighbours"} {
+		todo, err := repo.Create(ctx, models.CreateTodoRequest{Title: title})
+		if err != nil {
+			t.Fatalf("Failed to create todo: %v", err)
+		}
+		ids = append(ids, todo.ID)
+	}
+	paint, fence, party := ids[0], ids[1], ids[2]
+	deps := NewDependencyRepository(repo.db)
+	if err := deps.Add(ctx, fence, paint); err != nil {
+		t.Fatalf("Failed to add dependency: %v", err)
+	}
+	if err := deps.Add(ctx, party, fence); err != nil {
+		t.Fatalf("Failed to add dependency: %v", err)
+	}
+
+	completed := true
+	tests := []struct {
+		name  string
+		write func() error
+	}{
+		{"Update", func() error {
+			_, err := repo.Update(ctx, fence, models.UpdateTodoRequest{Completed: &completed})
+			return err
+		}},
+		{"UpdateMany", func() error {
+			_, _, err := repo.UpdateMany(ctx, []models.BatchUpdateItem{
+				{ID: models.FlexibleID(party), UpdateTodoRequest: models.UpdateTodoRequest{Completed: &completed}},
+				{ID: models.FlexibleID(fence), UpdateTodoRequest: models.UpdateTodoRequest{Completed: &completed}},
+			}, false)
+			return err
+		}},
+		{"SetCompleted", func() error {
+			_, _, err := repo.SetCompleted(ctx, []int64{fence, party}, true)
+			return err
+		}},
+		{"ToggleCompleted", func() error {
+			_, _, err := repo.ToggleCompleted(ctx, []int64{fence, party})
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The party depends on the fence, which is being completed with
+			// it, so only the paint blocks
+			var blocked *BlockedError
+			if err := tt.write(); !errors.As(err, &blocked) || fmt.Sprint(blocked.BlockedBy) != fmt.Sprint([]int64{paint}) {
+				t.Fatalf("Expected the write to be blocked by todo %d, got %v", paint, err)
+			}
+
+			todos, err := repo.GetByIDs(ctx, []int64{fence, party})
+			if err != nil {
+				t.Fatalf("GetByIDs failed: %v", err)
+			}
+			for _, todo := range todos {
+				if todo.Completed {
+					t.Errorf("Expected todo %d to stay incomplete", todo.ID)
+				}
+			}
+		})
+	}
+
+	if _, _, err := repo.SetCompleted(ctx, []int64{paint, fence, party}, true); err != nil {
+		t.Errorf("Expected todos to complete along with their dependencies, got %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/larryhudson/go-todo-list-claude/internal/database"
+)
+
+// AdminHandler handles database maintenance requests
+type AdminHandler struct {
+	db *database.DB
+
+	// allowVacuum enables POST /admin/vacuum
+	allowVacuum bool
+
+	// allowMigrations enables GET /admin/migrations
+	allowMigrations bool
+}
+
+// NewAdminHandler creates a new AdminHandler
+func NewAdminHandler(db *database.DB) *AdminHandler {
+	return &AdminHandler{db: db}
+}
+
+// SetAllowVacuum enables or disables the vacuum endpoint
+func (h *AdminHandler) SetAllowVacuum(allow bool) {
+	h.allowVacuum = allow
+}
+
+// SetAllowMigrations enables or disables the migration history endpoint
+func (h *AdminHandler) SetAllowMigrations(allow bool) {
+	h.allowMigrations = allow
+}
+
+// VacuumResponse reports the database size before and after a vacuum
+type VacuumResponse struct {
+	BeforeBytes int64 `json:"beforeBytes"`
+	AfterBytes  int64 `json:"afterBytes"`
+}
+
+// Vacuum handles POST /admin/vacuum
+// @Summary Vacuum the database
+// @Description Rebuild the SQLite database to reclaim space freed by deletes. Only available when the server runs with ALLOW_VACUUM=true. Other requests wait while it runs.
+// @Tags admin
+// @Produce json
+// @Success 200 {object} VacuumResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /admin/vacuum [post]
+func (h *AdminHandler) Vacuum(w http.ResponseWriter, r *http.Request) {
+	if !h.allowVacuum {
+		writeError(w, r, http.StatusForbidden, "Vacuum is disabled")
+		return
+	}
+
+	before, after, err := h.db.Vacuum(r.Context())
+	if err != nil {
+		writeRepoError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, VacuumResponse{BeforeBytes: before, AfterBytes: after})
+}
+
+// MigrationsResponse lists the applied and pending migrations, each sorted
+// by filename
+type MigrationsResponse struct {
+	Applied []database.AppliedMigration `json:"applied"`
+	Pending []string                    `json:"pending"`
+}
+
+// Migrations handles GET /admin/migrations
+// @Summary List migrations
+// @Description Get the migrations recorded as applied, with when they were applied, and the migration files still pending. Only available when the server runs with ALLOW_MIGRATIONS=true.
+// @Tags admin
+// @Produce json
+// @Success 200 {object} MigrationsResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /admin/migrations [get]
+func (h *AdminHandler) Migrations(w http.ResponseWriter, r *http.Request) {
+	if !h.allowMigrations {
+		writeError(w, r, http.StatusForbidden, "Migration history is disabled")
+		return
+	}
+
+	history, err := database.NewMigrator(h.db, database.Migrations).History(r.Context())
+	if err != nil {
+		writeRepoError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, MigrationsResponse{Applied: history.Applied, Pending: history.Pending})
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/larryhudson/go-todo-list-claude/internal/database"
+	"github.com/larryhudson/go-todo-list-claude/internal/models"
+)
+
+func TestVacuum_Disabled(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	handler := NewAdminHandler(db)
+
+	req := httptest.NewRequest("POST", "/admin/vacuum", nil)
+	w := httptest.NewRecorder()
+
+	handler.Vacuum(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403, got %d", w.Code)
+	}
+}
+
+func TestVacuum_ShrinksDatabase(t *testing.T) {
+	db, err := database.New(filepath.Join(t.TempDir(), "todos.db"), database.DefaultConfig())
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+	if err := db.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+
+	repo := newTestRepo(t, db)
+	ctx := context.Background()
+	description := strings.Repeat("x", 4000)
+	for i := 0; i < 200; i++ {
+		if _, err := repo.Create(ctx, models.CreateTodoRequest{Title: fmt.Sprintf("Todo %d", i), Description: description}); err != nil {
+			t.Fatalf("Failed to create todo: %v", err)
+		}
+	}
+	if _, err := repo.DeleteAll(ctx); err != nil {
+		t.Fatalf("Failed to delete todos: %v", err)
+	}
+
+	handler := NewAdminHandler(db)
+	handler.SetAllowVacuum(true)
+
+	req := httptest.NewRequest("POST", "/admin/vacuum", nil)
+	w := httptest.NewRecorder()
+
+	handler.Vacuum(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp VacuumResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.AfterBytes >= resp.BeforeBytes {
+		t.Errorf("Expected the database to shrink, got %d bytes before and %d after", resp.BeforeBytes, resp.AfterBytes)
+	}
+}
+
+func TestMigrations_Disabled(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	handler := NewAdminHandler(db)
+
+	req := httptest.NewRequest("GET", "/admin/migrations", nil)
+	w := httptest.NewRecorder()
+
+	handler.Migrations(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403, got %d", w.Code)
+	}
+}
+
+func TestMigrations(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	handler := NewAdminHandler(db)
+	handler.SetAllowMigrations(true)
+
+	req := httptest.NewRequest("GET", "/admin/migrations", nil)
+	w := httptest.NewRecorder()
+
+	handler.Migrations(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var resp MigrationsResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(resp.Pending) != 0 {
+		t.Errorf("Expected no pending migrations, got %v", resp.Pending)
+	}
+	if len(resp.Applied) == 0 || resp.Applied[0].Filename != "001_initial_schema.sql" || resp.Applied[0].AppliedAt.IsZero() {
+		t.Errorf("Expected the applied migrations in filename order, got %+v", resp.Applied)
+	}
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/larryhudson/go-todo-list-claude/internal/database"
+	"github.com/larryhudson/go-todo-list-claude/internal/models"
+)
+
+// maxAttachmentNameLength caps the length of an attachment name
+const maxAttachmentNameLength = 255
+
+// AttachmentHandler handles HTTP requests for todo attachments
+type AttachmentHandler struct {
+	attachments *database.AttachmentRepository
+	todos       database.TodoStore
+}
+
+// NewAttachmentHandler creates a new AttachmentHandler
+func NewAttachmentHandler(attachments *database.AttachmentRepository, todos database.TodoStore) *AttachmentHandler {
+	return &AttachmentHandler{attachments: attachments, todos: todos}
+}
+
+// CreateAttachment handles POST /api/todos/{id}/attachments
+// @Summary Add an attachment to a todo
+// @Description Store attachment metadata and a URL for a todo
+// @Tags attachments
+// @Accept json
+// @Produce json
+// @Param id path string true "Todo ID, publicId or slug"
+// @Param attachment body models.CreateAttachmentRequest true "Attachment to add"
+// @Success 201 {object} models.Attachment
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 415 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 504 {object} ErrorResponse
+// @Router /api/todos/{id}/attachments [post]
+func (h *AttachmentHandler) CreateAttachment(w http.ResponseWriter, r *http.Request) {
+	todoID, ok := parseTodoID(w, r, h.todos)
+	if !ok {
+		return
+	}
+
+	if !requireJSON(w, r) {
+		return
+	}
+
+	var req models.CreateAttachmentRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.Name == "" {
+		writeError(w, r, http.StatusBadRequest, "Name is required")
+		return
+	}
+	if len(req.Name) > maxAttachmentNameLength {
+		writeError(w, r, http.StatusBadRequest, "Name is too long")
+		return
+	}
+	if !isHTTPURL(req.URL) {
+		writeError(w, r, http.StatusBadRequest, "URL must be an absolute http or https URL")
+		return
+	}
+
+	todo, err := h.todos.GetByID(r.Context(), todoID)
+	if err != nil {
+		writeRepoError(w, r, err)
+		return
+	}
+	if todo == nil {
+		writeError(w, r, http.StatusNotFound, "Todo not found")
+		return
+	}
+
+	attachment, err := h.attachments.Create(r.Context(), todoID, req)
+	if err != nil {
+		writeRepoError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusCreated, attachment)
+}
+
+// ListAttachments handles GET /api/todos/{id}/attachments
+// @Summary List a todo's attachments
+// @Description Get the attachments of a todo, oldest first
+// @Tags attachments
+// @Produce json
+// @Param id path string true "Todo ID, publicId or slug"
+// @Success 200 {array} models.Attachment
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 504 {object} ErrorResponse
+// @Router /api/todos/{id}/attachments [get]
+func (h *AttachmentHandler) ListAttachments(w http.ResponseWriter, r *http.Request) {
+	todoID, ok := parseTodoID(w, r, h.todos)
+	if !ok {
+		return
+	}
+
+	todo, err := h.todos.GetByID(r.Context(), todoID)
+	if err != nil {
+		writeRepoError(w, r, err)
+		return
+	}
+	if todo == nil {
+		writeError(w, r, http.StatusNotFound, "Todo not found")
+		return
+	}
+
+	attachments, err := h.attachments.ListByTodo(r.Context(), todoID)
+	if err != nil {
+		writeRepoError(w, r, err)
+		return
+	}
+
+	if attachments == nil {
+		attachments = []models.Attachment{}
+	}
+
+	writeJSON(w, r, http.StatusOK, attachments)
+}
+
+// DeleteAttachment handles DELETE /api/attachments/{id}
+// @Summary Delete an attachment
+// @Description Delete an attachment by ID
+// @Tags attachments
+// @Param id path int true "Attachment ID"
+// @Success 204
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 504 {object} ErrorResponse
+// @Router /api/attachments/{id} [delete]
+func (h *AttachmentHandler) DeleteAttachment(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+
+	err = h.attachments.Delete(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, r, http.StatusNotFound, "Attachment not found")
+		return
+	}
+	if err != nil {
+		writeRepoError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// isHTTPURL reports whether s is an absolute http or https URL
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	if err != nil {
+		return false
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
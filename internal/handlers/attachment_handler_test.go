@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/larryhudson/go-todo-list-claude/internal/database"
+	"github.com/larryhudson/go-todo-list-claude/internal/models"
+)
+
+func TestAttachments_CreateAndList(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewAttachmentHandler(database.NewAttachmentRepository(db), repo)
+
+	todo, err := repo.Create(context.Background(), models.CreateTodoRequest{Title: "Read report"})
+	if err != nil {
+		t.Fatalf("Failed to create todo: %v", err)
+	}
+
+	body, _ := json.Marshal(models.CreateAttachmentRequest{
+		Name:        "report.pdf",
+		URL:         "https://example.com/report.pdf",
+		ContentType: "application/pdf",
+	})
+	req := httptest.NewRequest("POST", "/api/todos/1/attachments", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+
+	handler.CreateAttachment(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var created models.Attachment
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if created.TodoID != todo.ID || created.Name != "report.pdf" || created.ContentType != "application/pdf" {
+		t.Errorf("Unexpected attachment: %+v", created)
+	}
+
+	req = httptest.NewRequest("GET", "/api/todos/1/attachments", nil)
+	req.SetPathValue("id", "1")
+	w = httptest.NewRecorder()
+
+	handler.ListAttachments(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var attachments []models.Attachment
+	if err := json.NewDecoder(w.Body).Decode(&attachments); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(attachments) != 1 || attachments[0].ID != created.ID {
+		t.Errorf("Expected the created attachment, got %+v", attachments)
+	}
+}
+
+func TestAttachments_Validation(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewAttachmentHandler(database.NewAttachmentRepository(db), repo)
+
+	if _, err := repo.Create(context.Background(), models.CreateTodoRequest{Title: "Read report"}); err != nil {
+		t.Fatalf("Failed to create todo: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		todoID string
+		req    models.CreateAttachmentRequest
+		status int
+	}{
+		{"missing name", "1", models.CreateAttachmentRequest{URL: "https://example.com/a"}, http.StatusBadRequest},
+		{"relative url", "1", models.CreateAttachmentRequest{Name: "a", URL: "/a"}, http.StatusBadRequest},
+		{"non-http url", "1", models.CreateAttachmentRequest{Name: "a", URL: "ftp://example.com/a"}, http.StatusBadRequest},
+		{"missing todo", "99", models.CreateAttachmentRequest{Name: "a", URL: "https://example.com/a"}, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		body, _ := json.Marshal(tt.req)
+		req := httptest.NewRequest("POST", "/api/todos/"+tt.todoID+"/attachments", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.SetPathValue("id", tt.todoID)
+		w := httptest.NewRecorder()
+
+		handler.CreateAttachment(w, req)
+
+		if w.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.status, w.Code)
+		}
+	}
+}
+
+func TestAttachments_DeleteAndCascade(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	attachmentRepo := database.NewAttachmentRepository(db)
+	handler := NewAttachmentHandler(attachmentRepo, repo)
+
+	todo, err := repo.Create(context.Background(), models.CreateTodoRequest{Title: "Read report"})
+	if err != nil {
+		t.Fatalf("Failed to create todo: %v", err)
+	}
+	first, err := attachmentRepo.Create(context.Background(), todo.ID, models.CreateAttachmentRequest{Name: "a", URL: "https://example.com/a"})
+	if err != nil {
+		t.Fatalf("Failed to create attachment: %v", err)
+	}
+	if _, err := attachmentRepo.Create(context.Background(), todo.ID, models.CreateAttachmentRequest{Name: "b", URL: "https://example.com/b"}); err != nil {
+		t.Fatalf("Failed to create attachment: %v", err)
+	}
+
+	req := httptest.NewRequest("DELETE", "/api/attachments/1", nil)
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+
+	handler.DeleteAttachment(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	handler.DeleteAttachment(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 deleting %d twice, got %d", first.ID, w.Code)
+	}
+
+	if _, err := repo.Delete(context.Background(), todo.ID); err != nil {
+		t.Fatalf("Failed to delete todo: %v", err)
+	}
+
+	remaining, err := attachmentRepo.ListByTodo(context.Background(), todo.ID)
+	if err != nil {
+		t.Fatalf("Failed to list attachments: %v", err)
+	}
+	if len(remaining) != 0 {
+		t.Errorf("Expected attachments to be deleted with their todo, got %d", len(remaining))
+	}
+}
+
+func TestGetTodo_ExpandAttachments(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	attachmentRepo := database.NewAttachmentRepository(db)
+	handler := NewTodoHandler(repo)
+	handler.SetAttachmentRepository(attachmentRepo)
+
+	todo, err := repo.Create(context.Background(), models.CreateTodoRequest{Title: "Read report"})
+	if err != nil {
+		t.Fatalf("Failed to create todo: %v", err)
+	}
+	if _, err := attachmentRepo.Create(context.Background(), todo.ID, models.CreateAttachmentRequest{Name: "a", URL: "https://example.com/a"}); err != nil {
+		t.Fatalf("Failed to create attachment: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/api/todos/1?expand=attachments", nil)
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+
+	handler.GetTodo(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var detail models.TodoDetail
+	if err := json.NewDecoder(w.Body).Decode(&detail); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if detail.Title != "Read report" || len(detail.Attachments) != 1 {
+		t.Errorf("Expected todo with one attachment, got %+v", detail)
+	}
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/larryhudson/go-todo-list-claude/internal/database"
+	"github.com/larryhudson/go-todo-list-claude/internal/models"
+)
+
+// maxCommentLength caps the length of a comment body in characters
+const maxCommentLength = 2000
+
+// CommentHandler handles HTTP requests for todo comments
+type CommentHandler struct {
+	comments *database.CommentRepository
+	todos    database.TodoStore
+}
+
+// NewCommentHandler creates a new CommentHandler
+func NewCommentHandler(comments *database.CommentRepository, todos database.TodoStore) *CommentHandler {
+	return &CommentHandler{comments: comments, todos: todos}
+}
+
+// CreateComment handles POST /api/todos/{id}/comments
+// @Summary Add a comment to a todo
+// @Description Leave a note on a todo
+// @Tags comments
+// @Accept json
+// @Produce json
+// @Param id path string true "Todo ID, publicId or slug"
+// @Param comment body models.CreateCommentRequest true "Comment to add"
+// @Success 201 {object} models.Comment
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 415 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 504 {object} ErrorResponse
+// @Router /api/todos/{id}/comments [post]
+func (h *CommentHandler) CreateComment(w http.ResponseWriter, r *http.Request) {
+	todoID, ok := parseTodoID(w, r, h.todos)
+	if !ok {
+		return
+	}
+
+	if !requireJSON(w, r) {
+		return
+	}
+
+	var req models.CreateCommentRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if strings.TrimSpace(req.Body) == "" {
+		writeError(w, r, http.StatusBadRequest, "Body is required")
+		return
+	}
+	if utf8.RuneCountInString(req.Body) > maxCommentLength {
+		writeError(w, r, http.StatusBadRequest, fmt.Sprintf("Body must be at most %d characters", maxCommentLength))
+		return
+	}
+
+	todo, err := h.todos.GetByID(r.Context(), todoID)
+	if err != nil {
+		writeRepoError(w, r, err)
+		return
+	}
+	if todo == nil {
+		writeError(w, r, http.StatusNotFound, "Todo not found")
+		return
+	}
+
+	comment, err := h.comments.Create(r.Context(), todoID, req)
+	if err != nil {
+		writeRepoError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusCreated, comment)
+}
+
+// ListComments handles GET /api/todos/{id}/comments
+// @Summary List a todo's comments
+// @Description Get the comments on a todo, newest first
+// @Tags comments
+// @Produce json
+// @Param id path string true "Todo ID, publicId or slug"
+// @Success 200 {array} models.Comment
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 504 {object} ErrorResponse
+// @Router /api/todos/{id}/comments [get]
+func (h *CommentHandler) ListComments(w http.ResponseWriter, r *http.Request) {
+	todoID, ok := parseTodoID(w, r, h.todos)
+	if !ok {
+		return
+	}
+
+	todo, err := h.todos.GetByID(r.Context(), todoID)
+	if err != nil {
+		writeRepoError(w, r, err)
+		return
+	}
+	if todo == nil {
+		writeError(w, r, http.StatusNotFound, "Todo not found")
+		return
+	}
+
+	comments, err := h.comments.ListByTodo(r.Context(), todoID)
+	if err != nil {
+		writeRepoError(w, r, err)
+		return
+	}
+
+	if comments == nil {
+		comments = []models.Comment{}
+	}
+
+	writeJSON(w, r, http.StatusOK, comments)
+}
+
+// DeleteComment handles DELETE /api/comments/{id}
+// @Summary Delete a comment
+// @Description Delete a comment by ID
+// @Tags comments
+// @Param id path int true "Comment ID"
+// @Success 204
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 504 {object} ErrorResponse
+// @Router /api/comments/{id} [delete]
+func (h *CommentHandler) DeleteComment(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+
+	err = h.comments.Delete(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, r, http.StatusNotFound, "Comment not found")
+		return
+	}
+	if err != nil {
+		writeRepoError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/larryhudson/go-todo-list-claude/internal/database"
+	"github.com/larryhudson/go-todo-list-claude/internal/models"
+)
+
+func TestComments_CreateAndListNewestFirst(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewCommentHandler(database.NewCommentRepository(db), repo)
+
+	if _, err := repo.Create(context.Background(), models.CreateTodoRequest{Title: "Plan trip"}); err != nil {
+		t.Fatalf("Failed to create todo: %v", err)
+	}
+
+	for _, text := range []string{"first", "second"} {
+		body, _ := json.Marshal(models.CreateCommentRequest{Body: text})
+		req := httptest.NewRequest("POST", "/api/todos/1/comments", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.SetPathValue("id", "1")
+		w := httptest.NewRecorder()
+
+		handler.CreateComment(w, req)
+
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/api/todos/1/comments", nil)
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+
+	handler.ListComments(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var comments []models.Comment
+	if err := json.NewDecoder(w.Body).Decode(&comments); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(comments) != 2 || comments[0].Body != "second" || comments[1].Body != "first" {
+		t.Errorf("Expected comments newest first, got %+v", comments)
+	}
+}
+
+func TestComments_Validation(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewCommentHandler(database.NewCommentRepository(db), repo)
+
+	if _, err := repo.Create(context.Background(), models.CreateTodoRequest{Title: "Plan trip"}); err != nil {
+		t.Fatalf("Failed to create todo: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		todoID string
+		body   string
+		status int
+	}{
+		{"empty body", "1", "", http.StatusBadRequest},
+		{"blank body", "1", "   ", http.StatusBadRequest},
+		{"too long", "1", strings.Repeat("a", maxCommentLength+1), http.StatusBadRequest},
+		{"max length", "1", strings.Repeat("é", maxCommentLength), http.StatusCreated},
+		{"missing todo", "99", "hello", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		body, _ := json.Marshal(models.CreateCommentRequest{Body: tt.body})
+		req := httptest.NewRequest("POST", "/api/todos/"+tt.todoID+"/comments", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.SetPathValue("id", tt.todoID)
+		w := httptest.NewRecorder()
+
+		handler.CreateComment(w, req)
+
+		if w.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.status, w.Code)
+		}
+	}
+}
+
+func TestComments_DeleteAndCascade(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	commentRepo := database.NewCommentRepository(db)
+	handler := NewCommentHandler(commentRepo, repo)
+
+	todo, err := repo.Create(context.Background(), models.CreateTodoRequest{Title: "Plan trip"})
+	if err != nil {
+		t.Fatalf("Failed to create todo: %v", err)
+	}
+	for _, text := range []string{"first", "second"} {
+		if _, err := commentRepo.Create(context.Background(), todo.ID, models.CreateCommentRequest{Body: text}); err != nil {
+			t.Fatalf("Failed to create comment: %v", err)
+		}
+	}
+
+	req := httptest.NewRequest("DELETE", "/api/comments/1", nil)
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+
+	handler.DeleteComment(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	handler.DeleteComment(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 deleting twice, got %d", w.Code)
+	}
+
+	if _, err := repo.Delete(context.Background(), todo.ID); err != nil {
+		t.Fatalf("Failed to delete todo: %v", err)
+	}
+
+	count, err := commentRepo.CountByTodo(context.Background(), todo.ID)
+	if err != nil {
+		t.Fatalf("Failed to count comments: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("Expected comments to be deleted with their todo, got %d", count)
+	}
+}
+
+func TestGetTodo_ExpandComments(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	commentRepo := database.NewCommentRepository(db)
+	handler := NewTodoHandler(repo)
+	handler.SetCommentRepository(commentRepo)
+
+	todo, err := repo.Create(context.Background(), models.CreateTodoRequest{Title: "Plan trip"})
+	if err != nil {
+		t.Fatalf("Failed to create todo: %v", err)
+	}
+	for _, text := range []string{"first", "second"} {
+		if _, err := commentRepo.Create(context.Background(), todo.ID, models.CreateCommentRequest{Body: text}); err != nil {
+			t.Fatalf("Failed to create comment: %v", err)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/api/todos/1?expand=comments", nil)
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+
+	handler.GetTodo(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var detail models.TodoDetail
+	if err := json.NewDecoder(w.Body).Decode(&detail); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if detail.CommentCount == nil || *detail.CommentCount != 2 {
+		t.Errorf("Expected commentCount 2, got %v", detail.CommentCount)
+	}
+	if len(detail.Comments) != 2 || detail.Comments[0].Body != "second" {
+		t.Errorf("Expected both comments newest first, got %+v", detail.Comments)
+	}
+}
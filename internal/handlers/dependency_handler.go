@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/larryhudson/go-todo-list-claude/internal/database"
+	"github.com/larryhudson/go-todo-list-claude/internal/models"
+)
+
+// BlockedResponse is returned with 409 when a todo cannot be completed
+// because todos it depends on are still incomplete
+type BlockedResponse struct {
+	Error     string  `json:"error"`
+	BlockedBy []int64 `json:"blockedBy"`
+}
+
+// DependencyHandler handles HTTP requests for dependencies between todos
+type DependencyHandler struct {
+	deps  *database.DependencyRepository
+	todos database.TodoStore
+}
+
+// NewDependencyHandler creates a new DependencyHandler
+func NewDependencyHandler(deps *database.DependencyRepository, todos database.TodoStore) *DependencyHandler {
+	return &DependencyHandler{deps: deps, todos: todos}
+}
+
+// AddDependency handles POST /api/todos/{id}/dependencies
+// @Summary Add a dependency
+// @Description Record that a todo cannot be completed before another one. Adding an existing dependency changes nothing; one that would make a todo depend on itself, directly or through other todos, is rejected with 409.
+// @Tags dependencies
+// @Accept json
+// @Produce json
+// @Param id path string true "Todo ID, publicId or slug"
+// @Param dependency body models.CreateDependencyRequest true "Todo to depend on"
+// @Success 201 {object} models.Dependencies
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 415 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 504 {object} ErrorResponse
+// @Router /api/todos/{id}/dependencies [post]
+func (h *DependencyHandler) AddDependency(w http.ResponseWriter, r *http.Request) {
+	todoID, ok := parseTodoID(w, r, h.todos)
+	if !ok {
+		return
+	}
+
+	if !requireJSON(w, r) {
+		return
+	}
+
+	var req models.CreateDependencyRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.DependsOn <= 0 {
+		writeError(w, r, http.StatusBadRequest, "dependsOn must be a todo ID")
+		return
+	}
+
+	for _, id := range []int64{todoID, req.DependsOn} {
+		todo, err := h.todos.GetByID(r.Context(), id)
+		if err != nil {
+			writeRepoError(w, r, err)
+			return
+		}
+		if todo == nil {
+			writeError(w, r, http.StatusNotFound, "Todo not found")
+			return
+		}
+	}
+
+	if err := h.deps.Add(r.Context(), todoID, req.DependsOn); err != nil {
+		writeRepoError(w, r, err)
+		return
+	}
+
+	h.writeDependencies(w, r, http.StatusCreated, todoID)
+}
+
+// ListDependencies handles GET /api/todos/{id}/dependencies
+// @Summary List a todo's dependencies
+// @Description Get the ids of the todos a todo is blocked by and of those it is blocking
+// @Tags dependencies
+// @Produce json
+// @Param id path string true "Todo ID, publicId or slug"
+// @Success 200 {object} models.Dependencies
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 504 {object} ErrorResponse
+// @Router /api/todos/{id}/dependencies [get]
+func (h *DependencyHandler) ListDependencies(w http.ResponseWriter, r *http.Request) {
+	todoID, ok := parseTodoID(w, r, h.todos)
+	if !ok {
+		return
+	}
+
+	todo, err := h.todos.GetByID(r.Context(), todoID)
+	if err != nil {
+		writeRepoError(w, r, err)
+		return
+	}
+	if todo == nil {
+		writeError(w, r, http.StatusNotFound, "Todo not found")
+		return
+	}
+
+	h.writeDependencies(w, r, http.StatusOK, todoID)
+}
+
+// RemoveDependency handles DELETE /api/todos/{id}/dependencies/{dependsOn}
+// @Summary Remove a dependency
+// @Description Stop a todo depending on another one
+// @Tags dependencies
+// @Param id path string true "Todo ID, publicId or slug"
+// @Param dependsOn path int true "ID of the todo depended on"
+// @Success 204
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 504 {object} ErrorResponse
+// @Router /api/todos/{id}/dependencies/{dependsOn} [delete]
+func (h *DependencyHandler) RemoveDependency(w http.ResponseWriter, r *http.Request) {
+	todoID, ok := parseTodoID(w, r, h.todos)
+	if !ok {
+		return
+	}
+
+	dependsOn, err := strconv.ParseInt(r.PathValue("dependsOn"), 10, 64)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+
+	err = h.deps.Remove(r.Context(), todoID, dependsOn)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, r, http.StatusNotFound, "Dependency not found")
+		return
+	}
+	if err != nil {
+		writeRepoError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeDependencies writes the dependencies of the todo with id
+func (h *DependencyHandler) writeDependencies(w http.ResponseWriter, r *http.Request, status int, id int64) {
+	deps, err := loadDependencies(r, h.deps, id)
+	if err != nil {
+		writeRepoError(w, r, err)
+		return
+	}
+	writeJSON(w, r, status, deps)
+}
+
+// loadDependencies reads both directions of a todo's dependencies
+func loadDependencies(r *http.Request, deps *database.DependencyRepository, id int64) (*models.Dependencies, error) {
+	blockedBy, err := deps.BlockedBy(r.Context(), id)
+	if err != nil {
+		return nil, err
+	}
+	blocking, err := deps.Blocking(r.Context(), id)
+	if err != nil {
+		return nil, err
+	}
+	return &models.Dependencies{BlockedBy: blockedBy, Blocking: blocking}, nil
+}
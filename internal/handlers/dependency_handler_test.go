@@ -0,0 +1,279 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/larryhudson/go-todo-list-claude/internal/database"
+	"github.com/larryhudson/go-todo-list-claude/internal/models"
+)
+
+// setupDependencies creates the todos titled titles, with ids from 1, and
+// returns handlers sharing one dependency repository
+func setupDependencies(t *testing.T, titles ...string) (*TodoHandler, *DependencyHandler) {
+	t.Helper()
+
+	db := setupTestDB(t)
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	})
+
+	repo := newTestRepo(t, db)
+	for _, title := range titles {
+		if _, err := repo.Create(context.Background(), models.CreateTodoRequest{Title: title}); err != nil {
+			t.Fatalf("Failed to create todo: %v", err)
+		}
+	}
+
+	deps := database.NewDependencyRepository(db)
+	todoHandler := NewTodoHandler(repo)
+	todoHandler.SetDependencyRepository(deps)
+	return todoHandler, NewDependencyHandler(deps, repo)
+}
+
+// addDependency makes todo id depend on dependsOn
+func addDependency(handler *DependencyHandler, id string, dependsOn int64) *httptest.ResponseRecorder {
+	body := fmt.Sprintf(`{"dependsOn":%d}`, dependsOn)
+	req := httptest.NewRequest("POST", "/api/todos/"+id+"/dependencies", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.SetPathValue("id", id)
+	w := httptest.NewRecorder()
+	handler.AddDependency(w, req)
+	return w
+}
+
+func TestDependencies_BlockCompletion(t *testing.T) {
+	todoHandler, handler := setupDependencies(t, "Buy paint", "Paint fence", "Invite neighbours")
+
+	// Painting needs the paint, and the party needs the fence painted
+	if w := addDependency(handler, "2", 1); w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := addDependency(handler, "3", 2); w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	complete := func(id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/todos/"+id+"/complete", nil)
+		req.SetPathValue("id", id)
+		w := httptest.NewRecorder()
+		todoHandler.CompleteTodo(w, req)
+		return w
+	}
+
+	w := complete("2")
+	if w.Code != http.StatusConflict {
+		t.Fatalf("Expected status 409, got %d", w.Code)
+	}
+	var blocked BlockedResponse
+	if err := json.NewDecoder(w.Body).Decode(&blocked); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !reflect.DeepEqual(blocked.BlockedBy, []int64{1}) {
+		t.Errorf("Expected todo 2 to be blocked by [1], got %v", blocked.BlockedBy)
+	}
+
+	// A PATCH is held to the same rule
+	req := httptest.NewRequest("PATCH", "/api/todos/2", strings.NewReader(`{"completed":true}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.SetPathValue("id", "2")
+	w = httptest.NewRecorder()
+	todoHandler.UpdateTodo(w, req)
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected PATCH to be rejected with 409, got %d", w.Code)
+	}
+
+	// Todo 3 depends on 2, which is in the batch, so only 1 blocks it
+	req = httptest.NewRequest("PATCH", "/api/todos/bulk", strings.NewReader(`{"ids":[2,3],"completed":true}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	todoHandler.BulkUpdateTodos(w, req)
+	if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), `"blockedBy":[1]`) {
+		t.Errorf("Expected the bulk update to be blocked by [1], got %d: %s", w.Code, w.Body.String())
+	}
+
+	// Toggling completes the incomplete todos, so it is checked too
+	req = httptest.NewRequest("POST", "/api/todos/bulk-toggle", strings.NewReader(`{"ids":[2]}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	todoHandler.BulkToggleTodos(w, req)
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected the toggle to be rejected with 409, got %d", w.Code)
+	}
+
+	if w := complete("1"); w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if w := complete("2"); w.Code != http.StatusOK {
+		t.Errorf("Expected todo 2 to complete once unblocked, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest("GET", "/api/todos/2?expand=dependencies", nil)
+	req.SetPathValue("id", "2")
+	w = httptest.NewRecorder()
+	todoHandler.GetTodo(w, req)
+
+	var detail struct {
+		BlockedBy []int64 `json:"blockedBy"`
+		Blocking  []int64 `json:"blocking"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&detail); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !reflect.DeepEqual(detail.BlockedBy, []int64{1}) || !reflect.DeepEqual(detail.Blocking, []int64{3}) {
+		t.Errorf("Expected blockedBy [1] and blocking [3], got %+v", detail)
+	}
+}
+
+func TestDependencies_CompleteMatchingSkipsBlocked(t *testing.T) {
+	todoHandler, handler := setupDependencies(t, "Buy brushes", "Paint fence", "Paint shed")
+
+	// The fence needs brushes, which the filter does not match
+	if w := addDependency(handler, "2", 1); w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	completeMatching := func() CompleteMatchingResponse {
+		req := httptest.NewRequest("POST", "/api/todos/complete-matching?search=paint", nil)
+		w := httptest.NewRecorder()
+		todoHandler.CompleteMatchingTodos(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var resp CompleteMatchingResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return resp
+	}
+	completed := func(id string) bool {
+		req := httptest.NewRequest("GET", "/api/todos/"+id, nil)
+		req.SetPathValue("id", id)
+		w := httptest.NewRecorder()
+		todoHandler.GetTodo(w, req)
+		var todo models.Todo
+		if err := json.NewDecoder(w.Body).Decode(&todo); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return todo.Completed
+	}
+
+	if resp := completeMatching(); resp.Updated != 1 {
+		t.Errorf("Expected only the unblocked todo to be completed, got %d", resp.Updated)
+	}
+	if completed("2") {
+		t.Error("Expected the blocked todo to stay incomplete")
+	}
+	if !completed("3") {
+		t.Error("Expected the unblocked todo to be completed")
+	}
+
+	req := httptest.NewRequest("POST", "/api/todos/1/complete", nil)
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+	todoHandler.CompleteTodo(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	if resp := completeMatching(); resp.Updated != 1 {
+		t.Errorf("Expected the todo to be completed once unblocked, got %d", resp.Updated)
+	}
+	if !completed("2") {
+		t.Error("Expected the unblocked todo to be completed")
+	}
+}
+
+func TestDependencies_UpsertBlocked(t *testing.T) {
+	todoHandler, handler := setupDependencies(t, "Buy paint")
+
+	upsert := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PUT", "/api/todos/by-external/ext-1", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.SetPathValue("externalId", "ext-1")
+		w := httptest.NewRecorder()
+		todoHandler.UpsertTodo(w, req)
+		return w
+	}
+
+	if w := upsert(`{"title":"Paint fence"}`); w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := addDependency(handler, "2", 1); w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w := upsert(`{"title":"Paint fence","completed":true}`)
+	if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), `"blockedBy":[1]`) {
+		t.Errorf("Expected the upsert to be blocked by [1], got %d: %s", w.Code, w.Body.String())
+	}
+
+	// Replacing other fields is still allowed
+	if w := upsert(`{"title":"Paint the fence"}`); w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req := httptest.NewRequest("POST", "/api/todos/1/complete", nil)
+	req.SetPathValue("id", "1")
+	w = httptest.NewRecorder()
+	todoHandler.CompleteTodo(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	if w := upsert(`{"title":"Paint fence","completed":true}`); w.Code != http.StatusOK {
+		t.Errorf("Expected the upsert to complete the todo once unblocked, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestDependencies_RejectCycles(t *testing.T) {
+	_, handler := setupDependencies(t, "A", "B", "C")
+
+	for _, dep := range [][2]int64{{2, 1}, {3, 2}} {
+		if w := addDependency(handler, fmt.Sprint(dep[0]), dep[1]); w.Code != http.StatusCreated {
+			t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+		}
+	}
+
+	tests := []struct {
+		id        string
+		dependsOn int64
+		code      int
+	}{
+		{"1", 3, http.StatusConflict},   // A -> C -> B -> A
+		{"1", 2, http.StatusConflict},   // A -> B -> A
+		{"1", 1, http.StatusConflict},   // A -> A
+		{"3", 1, http.StatusCreated},    // a shortcut is not a cycle
+		{"3", 2, http.StatusCreated},    // adding it again changes nothing
+		{"1", 99, http.StatusNotFound},  // no such todo
+		{"1", 0, http.StatusBadRequest}, // missing dependsOn
+	}
+	for _, tt := range tests {
+		w := addDependency(handler, tt.id, tt.dependsOn)
+		if w.Code != tt.code {
+			t.Errorf("%s -> %d: expected status %d, got %d: %s", tt.id, tt.dependsOn, tt.code, w.Code, w.Body.String())
+		}
+	}
+
+	req := httptest.NewRequest("DELETE", "/api/todos/3/dependencies/2", nil)
+	req.SetPathValue("id", "3")
+	req.SetPathValue("dependsOn", "2")
+	w := httptest.NewRecorder()
+	handler.RemoveDependency(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d", w.Code)
+	}
+
+	// With C no longer depending on B, B may depend on C
+	if w := addDependency(handler, "2", 3); w.Code != http.StatusCreated {
+		t.Errorf("Expected status 201 once the cycle is broken, got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/larryhudson/go-todo-list-claude/internal/database"
+)
+
+// classifyError maps a repository error to a status code and a message that
+// is safe to show clients. Constraint violations are the client's fault and
+// are reported as 400, or 409 for uniqueness conflicts, and stale
+// conditional updates and dependency cycles as 409 as well. A relevance
+// sort without a search term is also a 400. Anything unexpected is logged
+// in full and reported as a generic 500, so SQL and file paths do not leak
+// into responses.
+func classifyError(err error) (int, string) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout, "Database operation timed out"
+	}
+
+	if errors.Is(err, database.ErrStaleUpdate) {
+		return http.StatusConflict, "Todo has been modified since ifUnmodifiedSince"
+	}
+
+	if errors.Is(err, database.ErrDependencyCycle) {
+		return http.StatusConflict, "Dependency would create a cycle"
+	}
+
+	if errors.Is(err, database.ErrRelevanceWithoutSearch) {
+		return http.StatusBadRequest, "sortBy=relevance requires search"
+	}
+
+	if constraintErr, ok := database.AsConstraintError(err); ok {
+		if constraintErr.Kind == database.ConstraintUnique {
+			return http.StatusConflict, constraintErr.Message
+		}
+		return http.StatusBadRequest, constraintErr.Message
+	}
+
+	slog.Error("Internal error", "err", err)
+	return http.StatusInternalServerError, "Internal server error"
+}
+
+// writeRepoError writes the error response chosen by classifyError, or a
+// BlockedResponse for a write blocked by incomplete dependencies
+func writeRepoError(w http.ResponseWriter, r *http.Request, err error) {
+	var blocked *database.BlockedError
+	if errors.As(err, &blocked) {
+		writeJSON(w, r, http.StatusConflict, BlockedResponse{
+			Error:     "Todo is blocked by incomplete dependencies",
+			BlockedBy: blocked.BlockedBy,
+		})
+		return
+	}
+
+	status, message := classifyError(err)
+	writeError(w, r, status, message)
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestClassifyError(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	ctx := context.Background()
+	if _, err := db.ExecContext(ctx, "CREATE TABLE labels (name TEXT NOT NULL UNIQUE)"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	if _, err := db.ExecContext(ctx, "INSERT INTO labels (name) VALUES ('home')"); err != nil {
+		t.Fatalf("Failed to insert label: %v", err)
+	}
+
+	exec := func(query string) error {
+		_, err := db.ExecContext(ctx, query)
+		if err == nil {
+			t.Fatalf("Expected %q to fail", query)
+		}
+		// Repositories wrap errors, so classification must look through them
+		return fmt.Errorf("failed to write: %w", err)
+	}
+
+	tests := []struct {
+		name    string
+		err     error
+		status  int
+		message string
+	}{
+		{
+			name:    "unique",
+			err:     exec("INSERT INTO labels (name) VALUES ('home')"),
+			status:  http.StatusConflict,
+			message: "name must be unique",
+		},
+		{
+			name:    "not null",
+			err:     exec("INSERT INTO todos (title) VALUES (NULL)"),
+			status:  http.StatusBadRequest,
+			message: "title is required",
+		},
+		{
+			name:    "foreign key",
+			err:     exec("INSERT INTO comments (todo_id, body) VALUES (999, 'orphan')"),
+			status:  http.StatusBadRequest,
+			message: "a referenced record does not exist",
+		},
+		{
+			name:    "check",
+			err:     exec("INSERT INTO todos (title) VALUES ('" + strings.Repeat("a", 201) + "')"),
+			status:  http.StatusBadRequest,
+			message: "title must be at most 200 characters",
+		},
+		{
+			name:    "negative estimate",
+			err:     exec("INSERT INTO todos (title, estimate_minutes) VALUES ('Call dentist', -5)"),
+			status:  http.StatusBadRequest,
+			message: "estimateMinutes must not be negative",
+		},
+		{
+			name:    "internal",
+			err:     exec("SELECT * FROM missing_table"),
+			status:  http.StatusInternalServerError,
+			message: "Internal server error",
+		},
+		{
+			name:    "timeout",
+			err:     fmt.Errorf("failed to query todos: %w", context.DeadlineExceeded),
+			status:  http.StatusGatewayTimeout,
+			message: "Database operation timed out",
+		},
+		{
+			name:    "other",
+			err:     errors.New("disk I/O error at /var/lib/todos.db"),
+			status:  http.StatusInternalServerError,
+			message: "Internal server error",
+		},
+	}
+
+	for _, tt := range tests {
+		status, message := classifyError(tt.err)
+		if status != tt.status || message != tt.message {
+			t.Errorf("%s: got %d %q, want %d %q", tt.name, status, message, tt.status, tt.message)
+		}
+	}
+}
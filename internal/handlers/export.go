@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/larryhudson/go-todo-list-claude/internal/models"
+)
+
+// markdownEscaper backslash-escapes the characters that could turn a title
+// into inline Markdown, such as emphasis, code, links or HTML
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"`", "\\`",
+	"*", `\*`,
+	"_", `\_`,
+	"[", `\[`,
+	"]", `\]`,
+	"<", `\<`,
+	">", `\>`,
+	"~", `\~`,
+	"|", `\|`,
+	"&", `\&`,
+	"\r\n", " ",
+	"\n", " ",
+	"\r", " ",
+)
+
+// writeMarkdown writes todos as a Markdown checklist, one todo per line
+func writeMarkdown(w io.Writer, todos []models.Todo) error {
+	bw := bufio.NewWriter(w)
+	for _, todo := range todos {
+		check := " "
+		if todo.Completed {
+			check = "x"
+		}
+		if _, err := fmt.Fprintf(bw, "- [%s] %s\n", check, markdownEscaper.Replace(todo.Title)); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// csvHeader names the columns of a CSV export
+var csvHeader = []string{"id", "publicId", "title", "description", "completed", "starred", "remindAt", "createdAt", "updatedAt"}
+
+// csvText guards a free-text CSV field against formula injection. A cell
+// starting with =, +, -, @, a tab or a carriage return may be evaluated by
+// spreadsheet applications, so it is prefixed with a single quote.
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// writeCSV writes todos as CSV with a header row, one todo per row. Times
+// are RFC3339 in UTC and a missing reminder is an empty field.
+func writeCSV(w io.Writer, todos []models.Todo) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, todo := range todos {
+		remindAt := ""
+		if todo.RemindAt != nil {
+			remindAt = todo.RemindAt.UTC().Format(time.RFC3339)
+		}
+		record := []string{
+			strconv.FormatInt(todo.ID, 10),
+			todo.PublicID,
+			csvText(todo.Title),
+			csvText(todo.Description),
+			strconv.FormatBool(todo.Completed),
+			strconv.FormatBool(todo.Starred),
+			remindAt,
+			todo.CreatedAt.UTC().Format(time.RFC3339),
+			todo.UpdatedAt.UTC().Format(time.RFC3339),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// exportFormats maps each export format to its content type and writer
+var exportFormats = map[string]struct {
+	contentType string
+	write       func(io.Writer, []models.Todo) error
+}{
+	"markdown": {"text/markdown; charset=utf-8", writeMarkdown},
+	"csv":      {"text/csv; charset=utf-8", writeCSV},
+}
+
+// ExportTodos handles GET /api/todos/export
+// @Summary Export todos
+// @Description Export the todos matching the same filters and sorting as the list endpoint, either as a Markdown checklist, one "- [x] Title" or "- [ ] Title" line per todo, or as CSV with a header row
+// @Tags todos
+// @Produce text/markdown
+// @Produce text/csv
+// @Param format query string false "Export format: markdown (default) or csv"
+// @Param search query string false "Search in title and description"
+// @Param completed query boolean false "Filter by completion status"
+// @Param starred query boolean false "Filter by starred status"
+// @Param draft query boolean false "Return draft todos instead of published ones"
+// @Param createdAfter query string false "Only todos created at or after this RFC3339 time or YYYY-MM-DD date"
+// @Param createdBefore query string false "Only todos created before this RFC3339 time or YYYY-MM-DD date"
+// @Param updatedAfter query string false "Only todos updated at or after this RFC3339 time or YYYY-MM-DD date"
+// @Param updatedBefore query string false "Only todos updated before this RFC3339 time or YYYY-MM-DD date"
+// @Param tz query string false "IANA time zone for date-only filters (default UTC)"
+// @Param sortBy query string false "Sort by field (createdAt, updatedAt, title, starred, position, estimate, or relevance with search)"
+// @Param sortOrder query string false "Sort order (asc, desc)"
+// @Success 200 {string} string "Markdown checklist or CSV"
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 504 {object} ErrorResponse
+// @Router /api/todos/export [get]
+func (h *TodoHandler) ExportTodos(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "markdown"
+	}
+	export, ok := exportFormats[format]
+	if !ok {
+		writeError(w, r, http.StatusBadRequest, "invalid format: must be markdown or csv")
+		return
+	}
+
+	opts, err := parseFilterOptions(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	opts.SortBy, opts.SortOrder, err = parseSort(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	todos, err := h.repo.Search(r.Context(), opts)
+	if err != nil {
+		writeRepoError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", export.contentType)
+	w.WriteHeader(http.StatusOK)
+	if err := export.write(w, todos); err != nil {
+		// Headers are already sent, so the client just sees a short body
+		slog.Warn("Failed to write export", "err", err)
+	}
+}
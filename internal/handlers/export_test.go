@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/larryhudson/go-todo-list-claude/internal/database"
+	"github.com/larryhudson/go-todo-list-claude/internal/models"
+)
+
+func TestExportTodos_Markdown(t *testing.T) {
+	store := database.NewMemoryTodoStore()
+	handler := NewTodoHandler(store)
+
+	for _, title := range []string{"Buy milk", "Read *Dune* [book]", "Fix <b>bold</b> bug_1"} {
+		if _, err := store.Create(context.Background(), models.CreateTodoRequest{Title: title}); err != nil {
+			t.Fatalf("Failed to create todo: %v", err)
+		}
+	}
+	completed := true
+	if _, err := store.Update(context.Background(), 1, models.UpdateTodoRequest{Completed: &completed}); err != nil {
+		t.Fatalf("Failed to update todo: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/api/todos/export?format=markdown&sortBy=createdAt&sortOrder=asc", nil)
+	w := httptest.NewRecorder()
+
+	handler.ExportTodos(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Content-Type"); got != "text/markdown; charset=utf-8" {
+		t.Errorf("Expected a Markdown content type, got %q", got)
+	}
+
+	want := "- [x] Buy milk\n" +
+		"- [ ] Read \\*Dune\\* \\[book\\]\n" +
+		"- [ ] Fix \\<b\\>bold\\</b\\> bug\\_1\n"
+	if got := w.Body.String(); got != want {
+		t.Errorf("Expected:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestExportTodos_Filters(t *testing.T) {
+	store := database.NewMemoryTodoStore()
+	handler := NewTodoHandler(store)
+
+	for _, title := range []string{"Walk dog", "Feed cat"} {
+		if _, err := store.Create(context.Background(), models.CreateTodoRequest{Title: title}); err != nil {
+			t.Fatalf("Failed to create todo: %v", err)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/api/todos/export?search=dog", nil)
+	w := httptest.NewRecorder()
+
+	handler.ExportTodos(w, req)
+
+	if got := w.Body.String(); got != "- [ ] Walk dog\n" {
+		t.Errorf("Expected only the matching todo, got %q", got)
+	}
+
+	req = httptest.NewRequest("GET", "/api/todos/export?format=pdf", nil)
+	w = httptest.NewRecorder()
+
+	handler.ExportTodos(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unknown format, got %d", w.Code)
+	}
+}
+
+func TestExportTodos_CSV(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewTodoHandler(repo)
+
+	// Only the completed todo created last quarter should be exported
+	todos := []struct {
+		title     string
+		completed bool
+		createdAt time.Time
+	}{
+		{"File taxes", true, time.Date(2024, 2, 10, 9, 0, 0, 0, time.UTC)},
+		{"=SUM(A1:A2)", false, time.Date(2024, 2, 11, 9, 0, 0, 0, time.UTC)},
+		{"Renew lease", true, time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)},
+		{"Pay invoice, \"urgent\"", true, time.Date(2024, 3, 31, 23, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range todos {
+		todo, err := repo.Create(context.Background(), models.CreateTodoRequest{Title: tt.title})
+		if err != nil {
+			t.Fatalf("Failed to create todo: %v", err)
+		}
+		if _, err := db.ExecContext(context.Background(), "UPDATE todos SET completed = ?, created_at = ?, updated_at = ? WHERE id = ?", tt.completed, tt.createdAt, tt.createdAt, todo.ID); err != nil {
+			t.Fatalf("Failed to update todo: %v", err)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/api/todos/export?format=csv&completed=true&createdAfter=2024-01-01&createdBefore=2024-04-01&sortBy=createdAt&sortOrder=asc", nil)
+	w := httptest.NewRecorder()
+
+	handler.ExportTodos(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Content-Type"); got != "text/csv; charset=utf-8" {
+		t.Errorf("Expected a CSV content type, got %q", got)
+	}
+
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to parse CSV: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("Expected a header and 2 rows, got %d records: %v", len(records), records)
+	}
+	if got := strings.Join(records[0], ","); got != "id,publicId,title,description,completed,starred,remindAt,createdAt,updatedAt" {
+		t.Errorf("Unexpected header %q", got)
+	}
+	if records[1][2] != "File taxes" || records[2][2] != `Pay invoice, "urgent"` {
+		t.Errorf("Expected File taxes and Pay invoice, got %q and %q", records[1][2], records[2][2])
+	}
+	if records[1][4] != "true" || records[1][7] != "2024-02-10T09:00:00Z" || records[1][6] != "" {
+		t.Errorf("Unexpected row %v", records[1])
+	}
+}
+
+func TestCSVText(t *testing.T) {
+	tests := map[string]string{
+		"Buy milk":    "Buy milk",
+		"=SUM(A1:A2)": "'=SUM(A1:A2)",
+		"+1 call":     "'+1 call",
+		"-5 degrees":  "'-5 degrees",
+		"@mention":    "'@mention",
+		"":            "",
+	}
+	for in, want := range tests {
+		if got := csvText(in); got != want {
+			t.Errorf("csvText(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/larryhudson/go-todo-list-claude/internal/models"
+)
+
+// todoFields is the set of JSON field names exposed by models.Todo
+var todoFields = jsonFieldNames(reflect.TypeOf(models.Todo{}))
+
+// jsonFieldNames returns the JSON keys of a struct type's exported fields
+func jsonFieldNames(t reflect.Type) map[string]bool {
+	names := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag.Get("json")
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		names[name] = true
+	}
+	return names
+}
+
+// parseFields parses a comma-separated fields parameter, returning nil when
+// it is empty. Unknown field names are rejected.
+func parseFields(s string) ([]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	var fields []string
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		// snake_case names are accepted for clients using JSON_NAMING=snake
+		field := camelCase(name)
+		if !todoFields[field] {
+			return nil, fmt.Errorf("unknown field: %s", name)
+		}
+		fields = append(fields, field)
+	}
+
+	return fields, nil
+}
+
+// fieldSet is an item projected onto some of its JSON fields, keyed by
+// field name
+type fieldSet map[string]interface{}
+
+// selectFields projects each item onto the requested JSON fields
+func selectFields[T any](items []T, fields []string) ([]fieldSet, error) {
+	result := make([]fieldSet, 0, len(items))
+	for _, item := range items {
+		data, err := json.Marshal(item)
+		if err != nil {
+			return nil, err
+		}
+
+		var full map[string]interface{}
+		if err := json.Unmarshal(data, &full); err != nil {
+			return nil, err
+		}
+
+		partial := make(fieldSet, len(fields))
+		for _, name := range fields {
+			partial[name] = full[name]
+		}
+		result = append(result, partial)
+	}
+
+	return result, nil
+}
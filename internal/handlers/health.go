@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// DefaultHealthTimeout bounds the database ping made by the health check
+const DefaultHealthTimeout = 2 * time.Second
+
+// Pinger is a dependency the health check can verify is reachable
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// HealthHandler reports whether the server can reach its database
+type HealthHandler struct {
+	db      Pinger
+	timeout time.Duration
+}
+
+// NewHealthHandler creates a new HealthHandler that pings db
+func NewHealthHandler(db Pinger) *HealthHandler {
+	return &HealthHandler{db: db, timeout: DefaultHealthTimeout}
+}
+
+// SetTimeout sets how long the database ping may take before the check
+// fails. A short limit keeps a stuck database from hanging the probe.
+func (h *HealthHandler) SetTimeout(d time.Duration) {
+	h.timeout = d
+}
+
+// HealthResponse is returned when the server is healthy
+type HealthResponse struct {
+	Status string `json:"status"`
+	// LatencyMs is how long the database ping took, in milliseconds
+	LatencyMs float64 `json:"latencyMs"`
+}
+
+// Health handles GET /health
+// @Summary Health check
+// @Description Ping the database and report how long it took. Returns 503 if the ping fails or does not finish within HEALTH_TIMEOUT.
+// @Tags meta
+// @Produce json
+// @Success 200 {object} HealthResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /health [get]
+func (h *HealthHandler) Health(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := h.db.PingContext(ctx)
+	latency := time.Since(start)
+	if err != nil {
+		slog.Error("Health check failed", "err", err, "latency", latency)
+		writeError(w, r, http.StatusServiceUnavailable, "Database unavailable")
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, HealthResponse{
+		Status:    "ok",
+		LatencyMs: float64(latency.Microseconds()) / 1000,
+	})
+}
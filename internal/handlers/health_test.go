@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// slowPinger blocks until the ping's context is done, like a stuck database
+type slowPinger struct{}
+
+func (slowPinger) PingContext(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestHealth(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	handler := NewHealthHandler(db)
+
+	w := httptest.NewRecorder()
+	handler.Health(w, httptest.NewRequest("GET", "/health", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp HealthResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Status != "ok" {
+		t.Errorf("Expected status ok, got %q", resp.Status)
+	}
+	if resp.LatencyMs < 0 {
+		t.Errorf("Expected a non-negative latency, got %v", resp.LatencyMs)
+	}
+}
+
+func TestHealth_SlowPing(t *testing.T) {
+	handler := NewHealthHandler(slowPinger{})
+	handler.SetTimeout(20 * time.Millisecond)
+
+	start := time.Now()
+	w := httptest.NewRecorder()
+	handler.Health(w, httptest.NewRequest("GET", "/health", nil))
+	elapsed := time.Since(start)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected status 503, got %d: %s", w.Code, w.Body.String())
+	}
+	if elapsed > time.Second {
+		t.Errorf("Expected the check to give up after the timeout, took %v", elapsed)
+	}
+
+	var resp ErrorResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Error != "Database unavailable" {
+		t.Errorf("Expected error %q, got %q", "Database unavailable", resp.Error)
+	}
+}
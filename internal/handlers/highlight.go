@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"html"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/larryhudson/go-todo-list-claude/internal/models"
+)
+
+// highlightFields are the JSON fields added to each todo by ?highlight=true
+var highlightFields = []string{"titleHighlighted", "descriptionHighlighted"}
+
+// HighlightedTodo is a todo with its search matches marked up for display
+type HighlightedTodo struct {
+	models.Todo
+	TitleHighlighted       string `json:"titleHighlighted"`
+	DescriptionHighlighted string `json:"descriptionHighlighted"`
+
+	// DescriptionPreview is only set when ?preview is combined with highlight
+	DescriptionPreview *string `json:"descriptionPreview,omitempty"`
+}
+
+// highlightTodos marks up each todo's matches of term
+func highlightTodos(todos []models.Todo, term string) []HighlightedTodo {
+	result := make([]HighlightedTodo, 0, len(todos))
+	for _, todo := range todos {
+		result = append(result, HighlightedTodo{
+			Todo:                   todo,
+			TitleHighlighted:       highlight(todo.Title, term),
+			DescriptionHighlighted: highlight(todo.Description, term),
+		})
+	}
+	return result
+}
+
+// highlight HTML-escapes text and wraps every case-insensitive occurrence of
+// term in <mark> tags, so the result is safe to insert as HTML
+func highlight(text, term string) string {
+	if term == "" {
+		return html.EscapeString(text)
+	}
+
+	termLen := utf8.RuneCountInString(term)
+	var b strings.Builder
+	start := 0 // start of the text not yet written
+	for i := 0; i < len(text); {
+		if n := prefixLen(text[i:], termLen); n > 0 && strings.EqualFold(text[i:i+n], term) {
+			b.WriteString(html.EscapeString(text[start:i]))
+			b.WriteString("<mark>")
+			b.WriteString(html.EscapeString(text[i : i+n]))
+			b.WriteString("</mark>")
+			i += n
+			start = i
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(text[i:])
+		i += size
+	}
+	b.WriteString(html.EscapeString(text[start:]))
+
+	return b.String()
+}
+
+// prefixLen returns the byte length of the first n runes of s, or 0 if s is
+// shorter than that
+func prefixLen(s string, n int) int {
+	i := 0
+	for ; n > 0; n-- {
+		if i >= len(s) {
+			return 0
+		}
+		_, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+	}
+	return i
+}
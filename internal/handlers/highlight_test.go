@@ -0,0 +1,24 @@
+package handlers
+
+import "testing"
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		text, term, want string
+	}{
+		{"Buy milk", "milk", "Buy <mark>milk</mark>"},
+		{"Buy MILK and milk", "Milk", "Buy <mark>MILK</mark> and <mark>milk</mark>"},
+		{"no match", "milk", "no match"},
+		{"<b>milk</b> & <i>eggs</i>", "milk", "&lt;b&gt;<mark>milk</mark>&lt;/b&gt; &amp; &lt;i&gt;eggs&lt;/i&gt;"},
+		{"a<b", "<", "a<mark>&lt;</mark>b"},
+		{"Crème brûlée", "BRÛLÉE", "Crème <mark>brûlée</mark>"},
+		{"aaa", "aa", "<mark>aa</mark>a"},
+		{"plain <text>", "", "plain &lt;text&gt;"},
+	}
+
+	for _, tt := range tests {
+		if got := highlight(tt.text, tt.term); got != tt.want {
+			t.Errorf("highlight(%q, %q) = %q, want %q", tt.text, tt.term, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,360 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// SnakeCaseJSON is middleware that switches a request to snake_case JSON
+// naming, for clients that cannot consume the default camelCase: responses
+// send created_at rather than createdAt, and request bodies and query
+// parameters are read with the same names. writeJSON picks the naming as it
+// encodes each response, so the middleware may sit anywhere in the chain and
+// streamed lists still stream. Keys inside a todo's metadata are client
+// data and keep the spelling the client stored.
+func SnakeCaseJSON(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		opts := requestJSONOptions(r)
+		opts.snakeCase = true
+		r = withJSONOptions(r, opts)
+
+		if strings.Contains(r.URL.RawQuery, "_") {
+			u := *r.URL
+			u.RawQuery = camelCaseQuery(r.URL.Query()).Encode()
+			r.URL = &u
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// camelCaseQuery returns query with its parameter names in camelCase, so
+// handlers can look parameters up by their usual names
+func camelCaseQuery(query url.Values) url.Values {
+	out := make(url.Values, len(query))
+	for name, values := range query {
+		camel := camelCase(name)
+		out[camel] = append(out[camel], values...)
+	}
+	return out
+}
+
+// responseValue returns data as it should be encoded for the response to
+// r: unchanged for camelCase, or with its members renamed for snake_case
+func responseValue(r *http.Request, data interface{}) interface{} {
+	if !requestJSONOptions(r).snakeCase {
+		return data
+	}
+	return snakeCaseValue(reflect.ValueOf(data))
+}
+
+// decodeJSON decodes the JSON body of r into v, reading member names in
+// the request's naming
+func decodeJSON(r *http.Request, v interface{}) error {
+	if !requestJSONOptions(r).snakeCase {
+		return json.NewDecoder(r.Body).Decode(v)
+	}
+
+	var raw json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+		return err
+	}
+	return json.Unmarshal(camelCaseKeys(raw, reflect.TypeOf(v)), v)
+}
+
+// jsonMember is one member of a jsonObject
+type jsonMember struct {
+	name  string
+	value interface{}
+}
+
+// jsonObject is a JSON object whose members are encoded in order, used for
+// structs whose field names have been renamed
+type jsonObject []jsonMember
+
+// MarshalJSON implements json.Marshaler
+func (o jsonObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, member := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(member.name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(member.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+var (
+	marshalerType     = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	unmarshalerType   = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+)
+
+// snakeCaseValue returns v in a form that encodes like v but with every
+// struct field named in snake_case. Values that encode themselves, such as
+// times, and map keys, such as metadata, are left alone; a fieldSet is
+// renamed because its keys are field names.
+func snakeCaseValue(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	if v.Type().Implements(marshalerType) || v.Type().Implements(textMarshalerType) {
+		return v.Interface()
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return snakeCaseValue(v.Elem())
+	case reflect.Struct:
+		fields := jsonFieldsOf(v.Type())
+		obj := make(jsonObject, 0, len(fields))
+		for _, field := range fields {
+			fv, err := v.FieldByIndexErr(field.index)
+			if err != nil {
+				// A field promoted through a nil embedded pointer is omitted
+				continue
+			}
+			if field.omitEmpty && isEmptyValue(fv) {
+				continue
+			}
+			obj = append(obj, jsonMember{name: snakeCase(field.name), value: snakeCaseValue(fv)})
+		}
+		return obj
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && (v.IsNil() || v.Type().Elem().Kind() == reflect.Uint8) {
+			return v.Interface()
+		}
+		items := make([]interface{}, v.Len())
+		for i := range items {
+			items[i] = snakeCaseValue(v.Index(i))
+		}
+		return items
+	}
+
+	if set, ok := v.Interface().(fieldSet); ok {
+		renamed := make(map[string]interface{}, len(set))
+		for name, value := range set {
+			renamed[snakeCase(name)] = value
+		}
+		return renamed
+	}
+	return v.Interface()
+}
+
+// isEmptyValue reports whether encoding/json's omitempty would drop v
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	}
+	return false
+}
+
+// jsonField is a struct field as encoding/json sees it
+type jsonField struct {
+	index     []int
+	name      string
+	omitEmpty bool
+	typ       reflect.Type
+}
+
+// jsonFieldCache maps a struct type to its []jsonField
+var jsonFieldCache sync.Map
+
+// jsonFieldsOf returns the fields encoding/json encodes for struct type t,
+// in order, with the fields of embedded structs promoted into place
+func jsonFieldsOf(t reflect.Type) []jsonField {
+	if cached, ok := jsonFieldCache.Load(t); ok {
+		return cached.([]jsonField)
+	}
+
+	var all []jsonField
+	collectJSONFields(t, nil, &all)
+
+	// A name at a shallower depth hides the same name further down
+	depth := make(map[string]int, len(all))
+	for _, field := range all {
+		if d, ok := depth[field.name]; !ok || len(field.index) < d {
+			depth[field.name] = len(field.index)
+		}
+	}
+	fields := make([]jsonField, 0, len(all))
+	seen := make(map[string]bool, len(all))
+	for _, field := range all {
+		if len(field.index) == depth[field.name] && !seen[field.name] {
+			seen[field.name] = true
+			fields = append(fields, field)
+		}
+	}
+
+	jsonFieldCache.Store(t, fields)
+	return fields
+}
+
+// collectJSONFields appends the fields of t, whose index path starts with
+// prefix, to fields
+func collectJSONFields(t reflect.Type, prefix []int, fields *[]jsonField) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		index := append(append([]int(nil), prefix...), i)
+
+		ft := f.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			collectJSONFields(ft, index, fields)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+		*fields = append(*fields, jsonField{
+			index:     index,
+			name:      name,
+			omitEmpty: strings.Contains(","+options+",", ",omitempty,"),
+			typ:       f.Type,
+		})
+	}
+}
+
+// camelCaseKeys returns the JSON document raw, which is to be decoded into
+// a value of type t, with the snake_case member names of t's structs
+// renamed to their JSON names. Members that match no field, and values
+// that decode themselves, such as metadata, are left alone; anything that
+// does not fit t is passed through for json.Unmarshal to report.
+func camelCaseKeys(raw json.RawMessage, t reflect.Type) json.RawMessage {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if reflect.PointerTo(t).Implements(unmarshalerType) {
+		return raw
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		var members map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &members); err != nil || members == nil {
+			return raw
+		}
+		bySnake := make(map[string]jsonField)
+		for _, field := range jsonFieldsOf(t) {
+			bySnake[snakeCase(field.name)] = field
+		}
+		renamed := make(map[string]json.RawMessage, len(members))
+		for name, value := range members {
+			if field, ok := bySnake[name]; ok {
+				name = field.name
+				value = camelCaseKeys(value, field.typ)
+			}
+			renamed[name] = value
+		}
+		return marshalRaw(raw, renamed)
+	case reflect.Slice, reflect.Array:
+		var items []json.RawMessage
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return raw
+		}
+		for i := range items {
+			items[i] = camelCaseKeys(items[i], t.Elem())
+		}
+		return marshalRaw(raw, items)
+	}
+	return raw
+}
+
+// marshalRaw encodes v, falling back to raw if that fails
+func marshalRaw(raw json.RawMessage, v interface{}) json.RawMessage {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return raw
+	}
+	return data
+}
+
+// snakeCase converts a camelCase name to snake_case, keeping runs of
+// capitals together, e.g. publicId becomes public_id and latencyMs
+// latency_ms
+func snakeCase(name string) string {
+	var b strings.Builder
+	b.Grow(len(name) + 4)
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if !isUpper(c) {
+			b.WriteByte(c)
+			continue
+		}
+		if i > 0 {
+			prev := name[i-1]
+			nextLower := i+1 < len(name) && isLower(name[i+1])
+			if isLower(prev) || isDigit(prev) || (isUpper(prev) && nextLower) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteByte(c + 'a' - 'A')
+	}
+	return b.String()
+}
+
+// camelCase converts a snake_case name to camelCase, e.g. sort_by becomes
+// sortBy. Names without underscores are returned unchanged.
+func camelCase(name string) string {
+	if !strings.Contains(name, "_") {
+		return name
+	}
+
+	var b strings.Builder
+	upper := false
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c == '_' {
+			upper = b.Len() > 0
+			continue
+		}
+		if upper && isLower(c) {
+			c -= 'a' - 'A'
+		}
+		upper = false
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+func isUpper(c byte) bool { return 'A' <= c && c <= 'Z' }
+func isLower(c byte) bool { return 'a' <= c && c <= 'z' }
+func isDigit(c byte) bool { return '0' <= c && c <= '9' }
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/larryhudson/go-todo-list-claude/internal/models"
+)
+
+func TestSnakeCase(t *testing.T) {
+	tests := map[string]string{
+		"id":           "id",
+		"createdAt":    "created_at",
+		"publicId":     "public_id",
+		"latencyMs":    "latency_ms",
+		"commentCount": "comment_count",
+		"HTTPStatus":   "http_status",
+		"v2Name":       "v2_name",
+		"already_set":  "already_set",
+	}
+	for in, want := range tests {
+		if got := snakeCase(in); got != want {
+			t.Errorf("snakeCase(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestCamelCase(t *testing.T) {
+	tests := map[string]string{
+		"id":               "id",
+		"sort_by":          "sortBy",
+		"created_after":    "createdAfter",
+		"estimate_minutes": "estimateMinutes",
+		"sortBy":           "sortBy",
+		"_private":         "private",
+	}
+	for in, want := range tests {
+		if got := camelCase(in); got != want {
+			t.Errorf("camelCase(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestSnakeCaseJSON(t *testing.T) {
+	remindAt := time.Date(2030, 1, 2, 9, 30, 0, 0, time.UTC)
+	todo := models.Todo{
+		ID:        1,
+		PublicID:  "abc",
+		Title:     "Call dentist",
+		RemindAt:  &remindAt,
+		CreatedAt: remindAt,
+		UpdatedAt: remindAt,
+		Metadata:  models.Metadata{"iconName": "tooth"},
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSONWithETag(w, r, http.StatusOK, todo)
+	})
+
+	camel := `{"id":1,"publicId":"abc","slug":"","title":"Call dentist","description":"","completed":false,"starred":false,"remindAt":"2030-01-02T09:30:00Z","snoozedUntil":null,"scheduledAt":null,"position":0,"draft":false,"focused":false,"metadata":{"iconName":"tooth"},"estimateMinutes":0,"createdAt":"2030-01-02T09:30:00Z","updatedAt":"2030-01-02T09:30:00Z"}` + "\n"
+	snake := `{"id":1,"public_id":"abc","slug":"","title":"Call dentist","description":"","completed":false,"starred":false,"remind_at":"2030-01-02T09:30:00Z","snoozed_until":null,"scheduled_at":null,"position":0,"draft":false,"focused":false,"metadata":{"iconName":"tooth"},"estimate_minutes":0,"created_at":"2030-01-02T09:30:00Z","updated_at":"2030-01-02T09:30:00Z"}` + "\n"
+
+	tests := []struct {
+		name    string
+		handler http.Handler
+		query   string
+		want    string
+	}{
+		{"camelCase", PrettyJSON(handler), "", camel},
+		{"snake_case", SnakeCaseJSON(PrettyJSON(handler)), "", snake},
+		// The order of the middleware does not matter
+		{"snake_case inside pretty", PrettyJSON(SnakeCaseJSON(handler)), "", snake},
+		{"snake_case pretty", PrettyJSON(SnakeCaseJSON(handler)), "?pretty=true", "{\n  \"id\": 1,\n  \"public_id\": \"abc\","},
+	}
+	etags := make(map[string]string)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/todos/1"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			tt.handler.ServeHTTP(w, req)
+
+			if got := w.Body.String(); !strings.HasPrefix(got, tt.want) {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+			etag := w.Header().Get("ETag")
+			if other, ok := etags[etag]; ok && w.Body.String() != other {
+				t.Errorf("Expected different bodies to have different ETags, got %s for both", etag)
+			}
+			etags[etag] = w.Body.String()
+		})
+	}
+}
+
+func TestSnakeCaseJSON_Shapes(t *testing.T) {
+	createdAt := time.Date(2030, 1, 2, 9, 30, 0, 0, time.UTC)
+	todo := models.Todo{ID: 1, Title: "Call dentist", CreatedAt: createdAt, UpdatedAt: createdAt}
+	fields, err := selectFields([]models.Todo{todo}, []string{"id", "createdAt"})
+	if err != nil {
+		t.Fatalf("selectFields failed: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		data interface{}
+		want string
+	}{
+		{"embedded struct", previewTodo(todo, 5), `"estimate_minutes":0,"created_at":"2030-01-02T09:30:00Z","updated_at":"2030-01-02T09:30:00Z","description_preview":""}`},
+		{"envelope", ListEnvelope{Data: []models.Todo{}, Meta: ListMeta{Total: 0}}, `{"data":[],"meta":{"total":0}}`},
+		{"fields", fields, `[{"created_at":"2030-01-02T09:30:00Z","id":1}]`},
+		{"nil slice", []models.Todo(nil), `null`},
+		{"error", ErrorResponse{Error: "Not found"}, `{"error":"Not found"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := SnakeCaseJSON(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(w, r, http.StatusOK, tt.data)
+			}))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+			if got := strings.TrimSpace(w.Body.String()); !strings.HasSuffix(got, tt.want) {
+				t.Errorf("got  %s\nwant suffix %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSnakeCaseJSON_Requests(t *testing.T) {
+	var got models.CreateTodoRequest
+	var query string
+	handler := SnakeCaseJSON(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := decodeJSON(r, &got); err != nil {
+			t.Fatalf("decodeJSON failed: %v", err)
+		}
+		query = r.URL.Query().Get("sortBy") + "," + r.URL.Query().Get("createdAfter")
+	}))
+
+	body := `{"title":"Call dentist","remind_at":"2030-01-02T09:30:00Z","estimate_minutes":15,"metadata":{"icon_name":"tooth"}}`
+	req := httptest.NewRequest("POST", "/api/todos?sort_by=title&created_after=2030-01-01", strings.NewReader(body))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if got.Title != "Call dentist" || got.RemindAt == nil || got.EstimateMinutes != 15 {
+		t.Errorf("Expected snake_case members to be decoded, got %+v", got)
+	}
+	// Metadata keys are client data and keep their spelling
+	if got.Metadata["icon_name"] != "tooth" {
+		t.Errorf("Expected metadata key icon_name to be kept, got %v", got.Metadata)
+	}
+	if query != "title,2030-01-01" {
+		t.Errorf("Expected snake_case query parameters to be read, got %q", query)
+	}
+
+	// A batch is an array of the same objects
+	var batch []models.CreateTodoRequest
+	handler = SnakeCaseJSON(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := decodeJSON(r, &batch); err != nil {
+			t.Fatalf("decodeJSON failed: %v", err)
+		}
+	}))
+	req = httptest.NewRequest("POST", "/api/todos/batch", strings.NewReader(`[{"title":"A","estimate_minutes":5}]`))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if len(batch) != 1 || batch[0].EstimateMinutes != 5 {
+		t.Errorf("Expected the batch to be decoded, got %+v", batch)
+	}
+}
+
+func TestSnakeCaseJSON_ContentLength(t *testing.T) {
+	todo := models.Todo{ID: 1, Title: "Call dentist"}
+	handler := SnakeCaseJSON(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, r, http.StatusOK, todo)
+	}))
+
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read body: %v", err)
+	}
+	n, _ := strconv.Atoi(resp.Header.Get("Content-Length"))
+	if n != len(body) {
+		t.Errorf("Expected Content-Length %d to match the %d byte body", n, len(body))
+	}
+}
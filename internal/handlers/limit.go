@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// busyRetryAfter is the Retry-After value, in seconds, sent when the server
+// is at its concurrency limit
+const busyRetryAfter = 1
+
+// MaxConcurrent is middleware that lets at most limit requests run at once.
+// Requests beyond that are answered straight away with 503 Service
+// Unavailable and a Retry-After header rather than queued, so a burst cannot
+// pile up work behind SQLite's single writer. Requests for which exempt
+// returns true, such as health checks, bypass the limit. A limit of 0 or
+// less returns next unchanged.
+func MaxConcurrent(next http.Handler, limit int, exempt func(*http.Request) bool) http.Handler {
+	if limit <= 0 {
+		return next
+	}
+
+	slots := make(chan struct{}, limit)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if exempt != nil && exempt(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		select {
+		case slots <- struct{}{}:
+			defer func() { <-slots }()
+			next.ServeHTTP(w, r)
+		default:
+			w.Header().Set("Retry-After", strconv.Itoa(busyRetryAfter))
+			writeError(w, r, http.StatusServiceUnavailable, "Server is busy")
+		}
+	})
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMaxConcurrent(t *testing.T) {
+	const limit, requests = 2, 5
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		entered <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := MaxConcurrent(slow, limit, func(r *http.Request) bool {
+		return r.URL.Path == "/health"
+	})
+
+	results := make(chan *httptest.ResponseRecorder, requests)
+	for range requests {
+		go func() {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/todos", nil))
+			results <- w
+		}()
+	}
+
+	// Wait until the limit is taken up and every other request has been
+	// turned away
+	for range limit {
+		<-entered
+	}
+	for range requests - limit {
+		w := <-results
+		if w.Code != http.StatusServiceUnavailable {
+			t.Errorf("Expected status 503, got %d", w.Code)
+		}
+		if got := w.Header().Get("Retry-After"); got != "1" {
+			t.Errorf("Expected Retry-After 1, got %q", got)
+		}
+	}
+
+	// Exempt requests are served while the limit is reached
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/health", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected health check status 200, got %d", w.Code)
+	}
+
+	close(release)
+	for range limit {
+		if w := <-results; w.Code != http.StatusOK {
+			t.Errorf("Expected status 200, got %d", w.Code)
+		}
+	}
+
+	// Finished requests free their slots
+	go func() { <-entered }()
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/todos", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 once slots are free, got %d", w.Code)
+	}
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// markdown renders descriptions as CommonMark with GitHub-style
+// strikethrough. Its default mode already leaves out raw HTML in the
+// source; the sanitizer below is the real guarantee.
+var markdown = goldmark.New(goldmark.WithExtensions(extension.Strikethrough))
+
+// descriptionPolicy is the HTML subset allowed in rendered descriptions:
+// text formatting, headings, lists, quotes, code and links to http, https
+// and mailto URLs. Anything else, including scripts, event handlers,
+// styles, images and iframes, is removed.
+var descriptionPolicy = func() *bluemonday.Policy {
+	p := bluemonday.NewPolicy()
+	p.AllowElements(
+		"p", "br", "hr", "strong", "em", "del", "code", "pre", "blockquote",
+		"ul", "ol", "li", "h1", "h2", "h3", "h4", "h5", "h6",
+	)
+	p.AllowAttrs("href").OnElements("a")
+	p.AllowURLSchemes("http", "https", "mailto")
+	p.RequireParseableURLs(true)
+	p.RequireNoFollowOnLinks(true)
+	p.AddTargetBlankToFullyQualifiedLinks(true)
+	return p
+}()
+
+// parseRender validates the render query parameter, returning whether a
+// rendered HTML description was requested
+func parseRender(r *http.Request) (bool, error) {
+	switch r.URL.Query().Get("render") {
+	case "":
+		return false, nil
+	case "html":
+		return true, nil
+	default:
+		return false, errors.New("invalid render: must be html")
+	}
+}
+
+// renderDescription converts a Markdown description to sanitized HTML
+func renderDescription(description string) (string, error) {
+	var buf bytes.Buffer
+	if err := markdown.Convert([]byte(description), &buf); err != nil {
+		return "", err
+	}
+	return descriptionPolicy.Sanitize(buf.String()), nil
+}
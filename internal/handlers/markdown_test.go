@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderDescription(t *testing.T) {
+	tests := []struct {
+		markdown string
+		want     []string
+		notWant  []string
+	}{
+		{
+			markdown: "# Plan\n\n**bold** _em_ ~~gone~~ `code`",
+			want:     []string{"<h1>Plan</h1>", "<strong>bold</strong>", "<em>em</em>", "<del>gone</del>", "<code>code</code>"},
+		},
+		{
+			markdown: "- one\n- two",
+			want:     []string{"<ul>", "<li>one</li>"},
+		},
+		{
+			markdown: "[docs](https://example.com)",
+			want:     []string{`href="https://example.com"`, `rel="nofollow noopener"`, `target="_blank"`},
+		},
+		{
+			markdown: "<script>alert(1)</script>hello",
+			notWant:  []string{"<script", "alert(1)</script>"},
+		},
+		{
+			markdown: "[click](javascript:alert(1))",
+			want:     []string{"click"},
+			notWant:  []string{"javascript:", "href"},
+		},
+		{
+			markdown: `<a href="https://example.com" onclick="steal()">x</a> <img src=x onerror=alert(1)>`,
+			notWant:  []string{"onclick", "onerror", "<img"},
+		},
+		{
+			markdown: "![pic](https://example.com/a.png)",
+			notWant:  []string{"<img"},
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := renderDescription(tt.markdown)
+		if err != nil {
+			t.Fatalf("renderDescription(%q) error: %v", tt.markdown, err)
+		}
+		for _, s := range tt.want {
+			if !strings.Contains(got, s) {
+				t.Errorf("renderDescription(%q) = %q, want it to contain %q", tt.markdown, got, s)
+			}
+		}
+		for _, s := range tt.notWant {
+			if strings.Contains(got, s) {
+				t.Errorf("renderDescription(%q) = %q, want it not to contain %q", tt.markdown, got, s)
+			}
+		}
+	}
+}
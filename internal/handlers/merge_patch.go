@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+
+	"github.com/larryhudson/go-todo-list-claude/internal/models"
+)
+
+// mergePatchType is the media type of an RFC 7386 JSON Merge Patch
+const mergePatchType = "application/merge-patch+json"
+
+// mergePatchFields lists the todo fields a merge patch may change
+var mergePatchFields = []string{"title", "description", "completed", "starred", "remindAt", "scheduledAt", "draft", "metadata", "estimateMinutes"}
+
+// isJSONNull reports whether a raw JSON value is the literal null
+func isJSONNull(raw json.RawMessage) bool {
+	return bytes.Equal(bytes.TrimSpace(raw), []byte("null"))
+}
+
+// decodeMergePatch reads a JSON Merge Patch for a todo and returns the
+// equivalent update. Apart from metadata a todo is a flat object, so the
+// patch reduces to setting each member it contains, with null removing the
+// value: a null description becomes empty, a null remindAt clears the
+// reminder, a null scheduledAt unschedules the todo and a null
+// estimateMinutes becomes 0. The title and the completed, starred and draft
+// flags always have a value, so null is rejected for them, as are members
+// that are unknown or read-only. Metadata is merged recursively by the
+// store, and a null metadata removes it all. Applying the result as a
+// single update keeps a concurrent change to another field from being
+// overwritten. Member names follow the request's JSON naming; metadata keys
+// are client data and are not renamed.
+func decodeMergePatch(r *http.Request) (models.UpdateTodoRequest, error) {
+	var req models.UpdateTodoRequest
+
+	var patch map[string]json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil || patch == nil {
+		return req, fmt.Errorf("merge patch must be a JSON object")
+	}
+
+	// Report problems in a stable order
+	names := make([]string, 0, len(patch))
+	for name := range patch {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	snakeCase := requestJSONOptions(r).snakeCase
+	for _, name := range names {
+		raw := patch[name]
+		field := name
+		if snakeCase {
+			field = camelCase(name)
+		}
+		if !slices.Contains(mergePatchFields, field) {
+			return req, fmt.Errorf("field %s cannot be patched", name)
+		}
+
+		if isJSONNull(raw) {
+			switch field {
+			case "description":
+				empty := ""
+				req.Description = &empty
+			case "remindAt":
+				req.ClearRemindAt = true
+			case "scheduledAt":
+				req.ClearScheduledAt = true
+			case "metadata":
+				// An empty object removes the metadata
+				req.Metadata = models.Metadata{}
+			case "estimateMinutes":
+				var zero int64
+				req.EstimateMinutes = &zero
+			default:
+				return req, fmt.Errorf("field %s cannot be null", name)
+			}
+			continue
+		}
+
+		var dest interface{}
+		switch field {
+		case "title":
+			dest = &req.Title
+		case "description":
+			dest = &req.Description
+		case "completed":
+			dest = &req.Completed
+		case "starred":
+			dest = &req.Starred
+		case "remindAt":
+			dest = &req.RemindAt
+		case "scheduledAt":
+			dest = &req.ScheduledAt
+		case "draft":
+			dest = &req.Draft
+		case "metadata":
+			dest = &req.MetadataPatch
+		case "estimateMinutes":
+			dest = &req.EstimateMinutes
+		}
+		if err := json.Unmarshal(raw, dest); err != nil {
+			if errors.Is(err, models.ErrMetadataNotObject) {
+				return req, err
+			}
+			return req, fmt.Errorf("invalid value for %s", name)
+		}
+	}
+
+	return req, nil
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/larryhudson/go-todo-list-claude/internal/database"
+	"github.com/larryhudson/go-todo-list-claude/internal/models"
+)
+
+// defaultPageSize is used when a cursor is supplied without a limit
+const defaultPageSize = 50
+
+// defaultMaxPageSize caps page sizes unless SetMaxPageSize changes it
+const defaultMaxPageSize = 200
+
+// errInvalidCursor is returned for cursors that fail to decode or whose
+// signature does not match
+var errInvalidCursor = errors.New("invalid cursor")
+
+// newCursorKey returns a random key for signing cursors, used until
+// SetCursorKey replaces it
+func newCursorKey() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		// crypto/rand only fails if the system's entropy source is broken,
+		// and signing with a zero key would make cursors forgeable
+		panic(err)
+	}
+	return key
+}
+
+// cursorPayload is the JSON form of a pagination cursor before encoding
+type cursorPayload struct {
+	CreatedAt time.Time `json:"createdAt"`
+	ID        int64     `json:"id"`
+}
+
+// encodeCursor returns an opaque cursor pointing just after todo. The
+// payload is signed with the handler's cursor key, so a client cannot edit
+// it to point elsewhere.
+func (h *TodoHandler) encodeCursor(todo models.Todo) string {
+	data, err := json.Marshal(cursorPayload{CreatedAt: todo.CreatedAt, ID: todo.ID})
+	if err != nil {
+		// Marshaling a time and an integer cannot fail
+		return ""
+	}
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(h.signCursor(payload))
+}
+
+// decodeCursor parses an opaque cursor produced by encodeCursor, rejecting
+// it if the signature does not match
+func (h *TodoHandler) decodeCursor(s string) (*database.Cursor, error) {
+	payload, sig, ok := strings.Cut(s, ".")
+	if !ok {
+		return nil, errInvalidCursor
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(mac, h.signCursor(payload)) {
+		return nil, errInvalidCursor
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	var cursor cursorPayload
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, errInvalidCursor
+	}
+
+	if cursor.ID <= 0 || cursor.CreatedAt.IsZero() {
+		return nil, errInvalidCursor
+	}
+
+	return &database.Cursor{CreatedAt: cursor.CreatedAt, ID: cursor.ID}, nil
+}
+
+// signCursor returns the HMAC-SHA256 of an encoded cursor payload
+func (h *TodoHandler) signCursor(payload string) []byte {
+	mac := hmac.New(sha256.New, h.cursorKey)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// encodeSyncCursor returns an opaque cursor pointing just after change. It
+// shares the list cursor's encoding, carrying updatedAt in place of createdAt.
+func (h *TodoHandler) encodeSyncCursor(change models.TodoChange) string {
+	return h.encodeCursor(models.Todo{CreatedAt: change.UpdatedAt, ID: change.ID})
+}
+
+// decodeSyncCursor parses an opaque cursor produced by encodeSyncCursor
+func (h *TodoHandler) decodeSyncCursor(s string) (*database.SyncCursor, error) {
+	cursor, err := h.decodeCursor(s)
+	if err != nil {
+		return nil, err
+	}
+	return &database.SyncCursor{UpdatedAt: cursor.CreatedAt, ID: cursor.ID}, nil
+}
+
+// pageSize returns the page size for a paginated request: the requested
+// limit, or defaultPageSize when none was given, clamped to the handler's
+// maximum. It also advertises the maximum in the X-Max-Page-Size header.
+func (h *TodoHandler) pageSize(w http.ResponseWriter, limit int) int {
+	w.Header().Set("X-Max-Page-Size", strconv.Itoa(h.maxPageSize))
+	if limit == 0 {
+		limit = defaultPageSize
+	}
+	return min(limit, h.maxPageSize)
+}
+
+// parseLimit parses a positive page size, returning 0 when absent
+func parseLimit(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	limit, err := strconv.Atoi(s)
+	if err != nil || limit <= 0 {
+		return 0, errors.New("limit must be a positive integer")
+	}
+
+	return limit, nil
+}
@@ -0,0 +1,268 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/larryhudson/go-todo-list-claude/internal/database"
+	"github.com/larryhudson/go-todo-list-claude/internal/models"
+)
+
+// sortFields maps the sortBy values accepted by the API to database
+// columns. The snake_case names are accepted for backward compatibility.
+var sortFields = map[string]string{
+	"createdAt":  "created_at",
+	"updatedAt":  "updated_at",
+	"title":      "title",
+	"starred":    "starred",
+	"position":   "position",
+	"estimate":   "estimate_minutes",
+	"relevance":  database.SortRelevance,
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
+// SortColumn maps an API sortBy value such as "createdAt" to its column
+func SortColumn(name string) (string, bool) {
+	column, ok := sortFields[name]
+	return column, ok
+}
+
+// parseSort validates the sortBy and sortOrder query parameters, returning
+// the column to sort by and a normalized order ("asc" or "desc"). Either
+// result is empty when the parameter is absent.
+func parseSort(r *http.Request) (sortBy, sortOrder string, err error) {
+	if v := r.URL.Query().Get("sortBy"); v != "" {
+		column, ok := sortFields[v]
+		if !ok {
+			return "", "", fmt.Errorf("invalid sortBy: %s", v)
+		}
+		sortBy = column
+		if sortBy == database.SortRelevance && r.URL.Query().Get("search") == "" {
+			return "", "", fmt.Errorf("sortBy=relevance requires search")
+		}
+	}
+
+	if v := r.URL.Query().Get("sortOrder"); v != "" {
+		sortOrder = strings.ToLower(v)
+		if sortOrder != "asc" && sortOrder != "desc" {
+			return "", "", fmt.Errorf("invalid sortOrder: %s (must be asc or desc)", v)
+		}
+	}
+
+	return sortBy, sortOrder, nil
+}
+
+// parseBoolParam parses an optional boolean query parameter. It returns nil
+// when the parameter is absent and an error unless the value is exactly
+// "true" or "false".
+func parseBoolParam(r *http.Request, name string) (*bool, error) {
+	v := r.URL.Query().Get(name)
+	switch v {
+	case "":
+		return nil, nil
+	case "true":
+		b := true
+		return &b, nil
+	case "false":
+		b := false
+		return &b, nil
+	default:
+		return nil, fmt.Errorf("invalid %s: must be true or false", name)
+	}
+}
+
+// parseFilterOptions parses the filter query parameters shared by the list
+// endpoint and the endpoints that operate on the same set of todos. Sorting
+// and pagination are left to the caller.
+func parseFilterOptions(r *http.Request) (database.FilterOptions, error) {
+	opts := database.FilterOptions{
+		Search: r.URL.Query().Get("search"),
+	}
+
+	// Parse boolean filters if provided
+	completed, err := parseBoolParam(r, "completed")
+	if err != nil {
+		return opts, err
+	}
+	opts.Completed = completed
+
+	starred, err := parseBoolParam(r, "starred")
+	if err != nil {
+		return opts, err
+	}
+	opts.Starred = starred
+
+	drafts, err := parseBoolParam(r, "draft")
+	if err != nil {
+		return opts, err
+	}
+	opts.Drafts = drafts != nil && *drafts
+
+	loc, err := parseLocation(r)
+	if err != nil {
+		return opts, err
+	}
+
+	// Parse time range filters. A bare date means midnight in the tz zone.
+	timeParams := []struct {
+		name string
+		dest **time.Time
+	}{
+		{"createdAfter", &opts.CreatedAfter},
+		{"createdBefore", &opts.CreatedBefore},
+		{"updatedAfter", &opts.UpdatedAfter},
+		{"updatedBefore", &opts.UpdatedBefore},
+	}
+	for _, p := range timeParams {
+		v := r.URL.Query().Get(p.name)
+		if v == "" {
+			continue
+		}
+		t, err := parseTime(v, loc)
+		if err != nil {
+			return opts, fmt.Errorf("invalid %s: must be an RFC3339 time or a YYYY-MM-DD date", p.name)
+		}
+		*p.dest = &t
+	}
+
+	return opts, nil
+}
+
+// parseLocation parses the tz query parameter as an IANA time zone name such
+// as "America/New_York", defaulting to UTC
+func parseLocation(r *http.Request) (*time.Location, error) {
+	v := r.URL.Query().Get("tz")
+	if v == "" {
+		return time.UTC, nil
+	}
+
+	loc, err := time.LoadLocation(v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tz: unknown time zone %s", v)
+	}
+	return loc, nil
+}
+
+// parseTime parses an RFC3339 time, or a YYYY-MM-DD date taken as the start
+// of that day in loc
+func parseTime(v string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation(time.DateOnly, v, loc)
+}
+
+// inLocation converts the todos' timestamps to loc for the response
+func inLocation(todos []models.Todo, loc *time.Location) {
+	for i := range todos {
+		todos[i].CreatedAt = todos[i].CreatedAt.In(loc)
+		todos[i].UpdatedAt = todos[i].UpdatedAt.In(loc)
+		if todos[i].RemindAt != nil {
+			remindAt := todos[i].RemindAt.In(loc)
+			todos[i].RemindAt = &remindAt
+		}
+		if todos[i].SnoozedUntil != nil {
+			snoozedUntil := todos[i].SnoozedUntil.In(loc)
+			todos[i].SnoozedUntil = &snoozedUntil
+		}
+		if todos[i].ScheduledAt != nil {
+			scheduledAt := todos[i].ScheduledAt.In(loc)
+			todos[i].ScheduledAt = &scheduledAt
+		}
+	}
+}
+
+// expandable lists the related collections GetTodo can embed
+var expandable = []string{"attachments", "comments", "dependencies"}
+
+// parseExpand splits the comma-separated expand query parameter into a set,
+// rejecting names that are not in expandable
+func parseExpand(r *http.Request) (map[string]bool, error) {
+	v := r.URL.Query().Get("expand")
+	if v == "" {
+		return nil, nil
+	}
+
+	expand := make(map[string]bool)
+	for _, name := range strings.Split(v, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !slices.Contains(expandable, name) {
+			return nil, fmt.Errorf("unknown expand value %q; available: %s", name, strings.Join(expandable, ", "))
+		}
+		expand[name] = true
+	}
+	return expand, nil
+}
+
+// isUUID reports whether s is a UUID in the canonical 8-4-4-4-12 hex form
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i, c := range s {
+		switch {
+		case i == 8 || i == 13 || i == 18 || i == 23:
+			if c != '-' {
+				return false
+			}
+		case !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'):
+			return false
+		}
+	}
+	return true
+}
+
+// isSlug reports whether s could be a todo slug: letters, digits and
+// hyphens
+func isSlug(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c != '-' && !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseTodoID resolves the {id} path value of a todo route, which may be
+// the todo's integer id, its publicId or its slug. A publicId or slug is
+// looked up in store; an integer id is returned as is, for the handler to
+// look up. On failure it writes a 400 or 404 response and returns false.
+func parseTodoID(w http.ResponseWriter, r *http.Request, store database.TodoStore) (int64, bool) {
+	v := r.PathValue("id")
+	if id, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return id, true
+	}
+
+	var todo *models.Todo
+	var err error
+	switch {
+	case isUUID(v):
+		todo, err = store.GetByPublicID(r.Context(), strings.ToLower(v))
+	case isSlug(v):
+		todo, err = store.GetBySlug(r.Context(), strings.ToLower(v))
+	default:
+		writeError(w, r, http.StatusBadRequest, "Invalid ID")
+		return 0, false
+	}
+	if err != nil {
+		writeRepoError(w, r, err)
+		return 0, false
+	}
+	if todo == nil {
+		writeError(w, r, http.StatusNotFound, "Todo not found")
+		return 0, false
+	}
+	return todo.ID, true
+}
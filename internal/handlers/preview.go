@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/larryhudson/go-todo-list-claude/internal/models"
+)
+
+// previewField is the JSON field added to each todo by ?preview=N
+const previewField = "descriptionPreview"
+
+// ellipsis marks a truncated preview
+const ellipsis = "…"
+
+// PreviewedTodo is a todo with a shortened description for list views
+type PreviewedTodo struct {
+	models.Todo
+	DescriptionPreview string `json:"descriptionPreview"`
+}
+
+// parsePreview parses the preview query parameter, the number of runes to
+// keep from each description. It returns 0 when absent.
+func parsePreview(r *http.Request) (int, error) {
+	v := r.URL.Query().Get("preview")
+	if v == "" {
+		return 0, nil
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		return 0, errors.New("preview must be a positive integer")
+	}
+	return n, nil
+}
+
+// truncateRunes shortens s to at most n runes, so multibyte characters such
+// as emoji are never split, and appends an ellipsis if anything was cut.
+// Whitespace left before the ellipsis is dropped.
+func truncateRunes(s string, n int) string {
+	cut := prefixLen(s, n)
+	if cut == 0 || cut == len(s) {
+		// s has at most n runes
+		return s
+	}
+	return strings.TrimRightFunc(s[:cut], unicode.IsSpace) + ellipsis
+}
+
+// previewTodo adds a description preview of n runes to todo
+func previewTodo(todo models.Todo, n int) PreviewedTodo {
+	return PreviewedTodo{Todo: todo, DescriptionPreview: truncateRunes(todo.Description, n)}
+}
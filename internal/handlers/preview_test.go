@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/larryhudson/go-todo-list-claude/internal/database"
+	"github.com/larryhudson/go-todo-list-claude/internal/models"
+)
+
+func TestTruncateRunes(t *testing.T) {
+	tests := []struct {
+		in   string
+		n    int
+		want string
+	}{
+		{"", 5, ""},
+		{"Short", 5, "Short"},
+		{"Longer text", 6, "Longer…"},
+		{"Party 🎉🎉 time", 7, "Party 🎉…"},
+		{"Party 🎉🎉 time", 8, "Party 🎉🎉…"},
+		{"日本語のテキスト", 3, "日本語…"},
+		{"👍", 1, "👍"},
+	}
+	for _, tt := range tests {
+		got := truncateRunes(tt.in, tt.n)
+		if got != tt.want {
+			t.Errorf("truncateRunes(%q, %d) = %q, want %q", tt.in, tt.n, got, tt.want)
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("truncateRunes(%q, %d) returned invalid UTF-8 %q", tt.in, tt.n, got)
+		}
+	}
+}
+
+func TestGetAllTodos_Preview(t *testing.T) {
+	store := database.NewMemoryTodoStore()
+	handler := NewTodoHandler(store)
+
+	// The cut falls right after the first emoji, which is four bytes long
+	if _, err := store.Create(context.Background(), models.CreateTodoRequest{Title: "Party", Description: "Bring 🎂🎈 and snacks"}); err != nil {
+		t.Fatalf("Failed to create todo: %v", err)
+	}
+
+	for _, target := range []string{
+		"/api/todos?preview=7",                             // streamed
+		"/api/todos?preview=7&limit=10",                    // paginated
+		"/api/todos?preview=7&search=party&highlight=true", // highlighted
+	} {
+		w := httptest.NewRecorder()
+		handler.GetAllTodos(w, httptest.NewRequest("GET", target, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s: expected status 200, got %d: %s", target, w.Code, w.Body.String())
+		}
+
+		var todos []map[string]interface{}
+		if err := json.NewDecoder(w.Body).Decode(&todos); err != nil {
+			t.Fatalf("GET %s: failed to decode response: %v", target, err)
+		}
+		if len(todos) != 1 {
+			t.Fatalf("GET %s: expected 1 todo, got %d", target, len(todos))
+		}
+		if got := todos[0]["descriptionPreview"]; got != "Bring 🎂…" {
+			t.Errorf("GET %s: expected preview %q, got %q", target, "Bring 🎂…", got)
+		}
+		if got := todos[0]["description"]; got != "Bring 🎂🎈 and snacks" {
+			t.Errorf("GET %s: expected the full description to be kept, got %q", target, got)
+		}
+	}
+
+	// The field is only present when requested
+	w := httptest.NewRecorder()
+	handler.GetAllTodos(w, httptest.NewRequest("GET", "/api/todos?limit=10", nil))
+	var todos []map[string]interface{}
+	if err := json.NewDecoder(w.Body).Decode(&todos); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if _, ok := todos[0]["descriptionPreview"]; ok {
+		t.Error("Expected no descriptionPreview without ?preview")
+	}
+
+	for _, v := range []string{"0", "-3", "ten"} {
+		w := httptest.NewRecorder()
+		handler.GetAllTodos(w, httptest.NewRequest("GET", "/api/todos?preview="+v, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("preview=%s: expected status 400, got %d", v, w.Code)
+		}
+	}
+}
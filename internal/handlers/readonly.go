@@ -0,0 +1,17 @@
+package handlers
+
+import "net/http"
+
+// ReadOnly is middleware that rejects every request that could change data
+// with 403 Forbidden. Only GET, HEAD and OPTIONS requests reach next, so
+// new mutating endpoints are blocked without having to opt in.
+func ReadOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+		default:
+			writeError(w, r, http.StatusForbidden, "Server is in read-only mode")
+		}
+	})
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import "net/http"
+
+// RouteErrors answers requests that mux cannot route with a JSON
+// ErrorResponse in place of the mux's plain-text error, so clients can parse
+// every error the same way. Requests that match a route are served as usual.
+func RouteErrors(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, pattern := mux.Handler(r); pattern != "" {
+			mux.ServeHTTP(w, r)
+			return
+		}
+		mux.ServeHTTP(&routeErrorWriter{ResponseWriter: w, r: r}, r)
+	})
+}
+
+// routeErrors maps the statuses the mux reports for unroutable requests to
+// the message sent in their place
+var routeErrors = map[int]string{
+	http.StatusNotFound:         "Not found",
+	http.StatusMethodNotAllowed: "Method not allowed",
+}
+
+// routeErrorWriter replaces the body of the mux's 404 and 405 responses with
+// JSON. Headers the mux set, such as Allow, are kept.
+type routeErrorWriter struct {
+	http.ResponseWriter
+	r        *http.Request
+	replaced bool
+}
+
+func (w *routeErrorWriter) WriteHeader(code int) {
+	if message, ok := routeErrors[code]; ok {
+		w.replaced = true
+		writeError(w.ResponseWriter, w.r, code, message)
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *routeErrorWriter) Write(b []byte) (int, error) {
+	if w.replaced {
+		// Discard the plain-text body that follows the status
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// streamFlushEvery is how many elements jsonArrayWriter writes between
+// flushes, so a client starts receiving a long array before it is complete
+const streamFlushEvery = 100
+
+// jsonArrayWriter streams a JSON array to a response one element at a
+// time. The status line and opening bracket are only written along with
+// the first element, or by close, so an error before then can still be
+// reported with a normal error response. The output matches writeJSON's
+// for the same slice, including ?pretty=true indentation.
+type jsonArrayWriter struct {
+	w       http.ResponseWriter
+	r       *http.Request
+	rc      *http.ResponseController
+	pretty  bool
+	written int
+}
+
+// newJSONArrayWriter creates a jsonArrayWriter for the response to r
+func newJSONArrayWriter(w http.ResponseWriter, r *http.Request) *jsonArrayWriter {
+	return &jsonArrayWriter{w: w, r: r, rc: http.NewResponseController(w), pretty: requestJSONOptions(r).pretty}
+}
+
+// started reports whether the response has been committed
+func (a *jsonArrayWriter) started() bool {
+	return a.written > 0
+}
+
+// write appends v to the array
+func (a *jsonArrayWriter) write(v interface{}) error {
+	v = responseValue(a.r, v)
+
+	var data []byte
+	var err error
+	if a.pretty {
+		data, err = json.MarshalIndent(v, "  ", "  ")
+	} else {
+		data, err = json.Marshal(v)
+	}
+	if err != nil {
+		return err
+	}
+
+	sep := ","
+	if a.written == 0 {
+		a.w.Header().Set("Content-Type", "application/json")
+		a.w.WriteHeader(http.StatusOK)
+		sep = "["
+	}
+	if a.pretty {
+		sep += "\n  "
+	}
+	if _, err := a.w.Write([]byte(sep)); err != nil {
+		return err
+	}
+	if _, err := a.w.Write(data); err != nil {
+		return err
+	}
+
+	a.written++
+	if a.written%streamFlushEvery == 0 {
+		// Writers that cannot flush still deliver everything at the end
+		_ = a.rc.Flush()
+	}
+	return nil
+}
+
+// close ends the array, writing an empty one if nothing was written
+func (a *jsonArrayWriter) close() error {
+	end := "]\n"
+	if a.written == 0 {
+		a.w.Header().Set("Content-Type", "application/json")
+		a.w.WriteHeader(http.StatusOK)
+		end = "[]\n"
+	} else if a.pretty {
+		end = "\n]\n"
+	}
+	_, err := a.w.Write([]byte(end))
+	return err
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/larryhudson/go-todo-list-claude/internal/database"
+	"github.com/larryhudson/go-todo-list-claude/internal/models"
+)
+
+func TestGetAllTodos_StreamsManyRows(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewTodoHandler(repo)
+
+	reqs := make([]models.CreateTodoRequest, 1000)
+	for i := range reqs {
+		reqs[i] = models.CreateTodoRequest{Title: fmt.Sprintf("Todo %d", i)}
+	}
+	if _, err := repo.CreateMany(context.Background(), reqs); err != nil {
+		t.Fatalf("Failed to create todos: %v", err)
+	}
+
+	tests := []struct {
+		query  string
+		search string
+	}{
+		{"", ""},
+		{"?pretty=true", ""},
+		{"?search=Todo%2099", "Todo 99"},
+		{"?search=nothing", "nothing"},
+	}
+
+	for _, tt := range tests {
+		query := tt.query
+		todos, err := repo.Search(context.Background(), database.FilterOptions{Search: tt.search})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if todos == nil {
+			todos = []models.Todo{}
+		}
+
+		// The streamed body must match what writeJSON produces for the slice
+		want := httptest.NewRecorder()
+		PrettyJSON(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, r, http.StatusOK, todos)
+		})).ServeHTTP(want, httptest.NewRequest("GET", "/api/todos"+query, nil))
+
+		w := httptest.NewRecorder()
+		PrettyJSON(http.HandlerFunc(handler.GetAllTodos)).ServeHTTP(w, httptest.NewRequest("GET", "/api/todos"+query, nil))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("%q: expected status 200, got %d: %s", query, w.Code, w.Body.String())
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%q: expected a JSON content type, got %q", query, ct)
+		}
+		if !bytes.Equal(w.Body.Bytes(), want.Body.Bytes()) {
+			t.Errorf("%q: streamed body differs from the buffered encoding (%d vs %d bytes)", query, w.Body.Len(), want.Body.Len())
+		}
+	}
+}
+
+// failingStore streams a few todos and then fails, to simulate an error
+// partway through a response
+type failingStore struct {
+	*database.MemoryTodoStore
+	after int
+}
+
+func (s *failingStore) Stream(ctx context.Context, opts database.FilterOptions, fn func(models.Todo) error) error {
+	streamed := 0
+	return s.MemoryTodoStore.Stream(ctx, opts, func(todo models.Todo) error {
+		if streamed == s.after {
+			return errors.New("disk on fire")
+		}
+		streamed++
+		return fn(todo)
+	})
+}
+
+func TestGetAllTodos_StreamError(t *testing.T) {
+	memory := database.NewMemoryTodoStore()
+	for i := 0; i < 3; i++ {
+		if _, err := memory.Create(context.Background(), models.CreateTodoRequest{Title: fmt.Sprintf("Todo %d", i)}); err != nil {
+			t.Fatalf("Failed to create todo: %v", err)
+		}
+	}
+
+	// An error before anything is written is reported normally
+	w := httptest.NewRecorder()
+	NewTodoHandler(&failingStore{MemoryTodoStore: memory, after: 0}).GetAllTodos(w, httptest.NewRequest("GET", "/api/todos", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500 for an immediate error, got %d", w.Code)
+	}
+
+	// Once the array has started the status stays 200 and the array is cut
+	// short, leaving invalid JSON
+	w = httptest.NewRecorder()
+	NewTodoHandler(&failingStore{MemoryTodoStore: memory, after: 2}).GetAllTodos(w, httptest.NewRequest("GET", "/api/todos", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 for a mid-stream error, got %d", w.Code)
+	}
+	var todos []models.Todo
+	if err := json.Unmarshal(w.Body.Bytes(), &todos); err == nil {
+		t.Errorf("Expected a truncated array, got %s", w.Body.String())
+	}
+}
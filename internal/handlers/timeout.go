@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// timeoutResponseWriter labels http.TimeoutHandler's timeout response as
+// JSON. TimeoutHandler copies the wrapped handler's headers before writing
+// its response, so only the timeout response reaches WriteHeader with a 503
+// and no Content-Type.
+type timeoutResponseWriter struct {
+	http.ResponseWriter
+}
+
+// WriteHeader implements http.ResponseWriter
+func (w *timeoutResponseWriter) WriteHeader(status int) {
+	if status == http.StatusServiceUnavailable && w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap exposes the underlying ResponseWriter to http.ResponseController
+func (w *timeoutResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Timeout is middleware that gives each request at most d to complete. A
+// request that runs longer has its context canceled and is answered with
+// 503 Service Unavailable and a JSON error instead of whatever it wrote.
+// Responses are buffered until the handler returns, so streaming endpoints
+// should not be wrapped. A d of 0 or less returns next unchanged.
+func Timeout(next http.Handler, d time.Duration) http.Handler {
+	if d <= 0 {
+		return next
+	}
+
+	body, err := json.Marshal(ErrorResponse{Error: "Request timed out"})
+	if err != nil {
+		// Marshaling a string field cannot fail
+		panic(err)
+	}
+
+	handler := http.TimeoutHandler(next, d, string(body))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(&timeoutResponseWriter{ResponseWriter: w}, r)
+	})
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimeout(t *testing.T) {
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		writeError(w, r, http.StatusGatewayTimeout, "Database operation timed out")
+	})
+	fast := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, r, http.StatusCreated, CountResponse{Count: 1})
+	})
+
+	// A slow request is answered with a JSON 503
+	w := httptest.NewRecorder()
+	Timeout(slow, 10*time.Millisecond).ServeHTTP(w, httptest.NewRequest("GET", "/api/todos", nil))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected status 503, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected a JSON content type, got %q", ct)
+	}
+	var resp ErrorResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil || resp.Error != "Request timed out" {
+		t.Errorf("Expected a timeout error, got %+v, %v", resp, err)
+	}
+
+	// A fast request passes through unchanged, including ?pretty=true
+	w = httptest.NewRecorder()
+	PrettyJSON(Timeout(fast, time.Second)).ServeHTTP(w, httptest.NewRequest("GET", "/api/todos/count?pretty=true", nil))
+
+	if w.Code != http.StatusCreated {
+		t.Errorf("Expected status 201, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "\n  \"count\": 1") {
+		t.Errorf("Expected indented JSON, got %q", w.Body.String())
+	}
+
+	// A zero timeout disables the limit
+	w = httptest.NewRecorder()
+	Timeout(fast, 0).ServeHTTP(w, httptest.NewRequest("GET", "/api/todos/count", nil))
+	if w.Code != http.StatusCreated {
+		t.Errorf("Expected status 201 without a timeout, got %d", w.Code)
+	}
+}
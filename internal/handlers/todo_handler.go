@@ -1,9 +1,20 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
 	"net/http"
+	"slices"
 	"strconv"
+	"time"
 
 	"github.com/larryhudson/go-todo-list-claude/internal/database"
 	"github.com/larryhudson/go-todo-list-claude/internal/models"
@@ -11,12 +22,102 @@ import (
 
 // TodoHandler handles HTTP requests for todos
 type TodoHandler struct {
-	repo *database.TodoRepository
+	repo database.TodoStore
+
+	// cursorKey signs pagination cursors
+	cursorKey []byte
+
+	// attachments backs ?expand=attachments; nil disables the expansion
+	attachments *database.AttachmentRepository
+
+	// comments backs ?expand=comments; nil disables the expansion
+	comments *database.CommentRepository
+
+	// deps backs ?expand=dependencies; nil disables the expansion
+	deps *database.DependencyRepository
+
+	// allowDeleteAll enables DELETE /api/todos
+	allowDeleteAll bool
+
+	// maxPageSize caps the limit of paginated requests
+	maxPageSize int
+
+	// defaults fills fields that create requests leave empty
+	defaults models.TodoDefaults
+
+	// trackAccess records each GET /api/todos/{id} for GET /api/todos/recent
+	trackAccess bool
+
+	// envelope wraps lists in a ListEnvelope unless ?envelope=false
+	envelope bool
+
+	// tombstoneRetention is how long deletions are kept for sync; 0 keeps
+	// them forever
+	tombstoneRetention time.Duration
 }
 
 // NewTodoHandler creates a new TodoHandler
-func NewTodoHandler(repo *database.TodoRepository) *TodoHandler {
-	return &TodoHandler{repo: repo}
+func NewTodoHandler(repo database.TodoStore) *TodoHandler {
+	return &TodoHandler{repo: repo, maxPageSize: defaultMaxPageSize, cursorKey: newCursorKey()}
+}
+
+// SetCursorKey sets the key pagination cursors are signed with. Without it
+// a random key is used, so cursors stop working when the server restarts
+// and are not accepted by other instances.
+func (h *TodoHandler) SetCursorKey(key []byte) {
+	h.cursorKey = key
+}
+
+// SetAttachmentRepository enables embedding attachments in todo responses
+func (h *TodoHandler) SetAttachmentRepository(attachments *database.AttachmentRepository) {
+	h.attachments = attachments
+}
+
+// SetCommentRepository enables embedding comment counts in todo responses
+func (h *TodoHandler) SetCommentRepository(comments *database.CommentRepository) {
+	h.comments = comments
+}
+
+// SetDependencyRepository enables embedding dependencies in todo responses.
+// The store checks dependencies on completion either way.
+func (h *TodoHandler) SetDependencyRepository(deps *database.DependencyRepository) {
+	h.deps = deps
+}
+
+// SetAllowDeleteAll enables or disables deleting every todo at once
+func (h *TodoHandler) SetAllowDeleteAll(allow bool) {
+	h.allowDeleteAll = allow
+}
+
+// SetMaxPageSize sets the largest page a paginated request may return.
+// Larger limits are clamped to it rather than rejected.
+func (h *TodoHandler) SetMaxPageSize(size int) {
+	h.maxPageSize = size
+}
+
+// SetDefaults sets the values given to new todos for fields their create
+// request leaves empty
+func (h *TodoHandler) SetDefaults(defaults models.TodoDefaults) {
+	h.defaults = defaults
+}
+
+// SetTrackAccess enables or disables recording when todos are viewed. Each
+// view costs a database write, so it is off by default.
+func (h *TodoHandler) SetTrackAccess(track bool) {
+	h.trackAccess = track
+}
+
+// SetEnvelope sets whether lists are wrapped in a ListEnvelope when a
+// request does not say with ?envelope=
+func (h *TodoHandler) SetEnvelope(enabled bool) {
+	h.envelope = enabled
+}
+
+// SetTombstoneRetention sets how long the records of deleted todos are
+// kept. Sync requests with a since older than that could miss deletions,
+// so they are refused with 410 Gone.
+func (h *TodoHandler) SetTombstoneRetention(retention time.Duration) {
+	h.tombstoneRetention = retention
 }
 
 // ErrorResponse represents an error response
@@ -24,20 +125,148 @@ type ErrorResponse struct {
 	Error string `json:"error"`
 }
 
+// DeleteAllResponse reports how many todos were deleted
+type DeleteAllResponse struct {
+	Deleted int64 `json:"deleted"`
+}
+
+// CountResponse represents the number of todos matching a query and the
+// summed estimates of the incomplete ones
+type CountResponse struct {
+	Count            int64 `json:"count"`
+	RemainingMinutes int64 `json:"remainingMinutes"`
+}
+
+// ListEnvelope wraps a list response as {"data":[...],"meta":{...}} for
+// clients that cannot consume a bare array
+type ListEnvelope struct {
+	Data interface{} `json:"data"`
+	Meta ListMeta    `json:"meta"`
+}
+
+// ListMeta describes the list in a ListEnvelope. Total counts every
+// matching todo, not just the page. Limit and NextCursor are only set on
+// paginated requests, with NextCursor empty on the last page.
+type ListMeta struct {
+	Total      int64   `json:"total"`
+	Limit      int     `json:"limit,omitempty"`
+	NextCursor *string `json:"nextCursor,omitempty"`
+}
+
+// parseEnvelope reports whether the list response to r is wrapped in a
+// ListEnvelope: as ?envelope= says, or else the server's default
+func (h *TodoHandler) parseEnvelope(r *http.Request) (bool, error) {
+	envelope, err := parseBoolParam(r, "envelope")
+	if err != nil {
+		return false, err
+	}
+	if envelope == nil {
+		return h.envelope, nil
+	}
+	return *envelope, nil
+}
+
+// listBody returns todos as the body of an unpaginated list response,
+// wrapped in a ListEnvelope if enveloped
+func listBody(todos []models.Todo, enveloped bool) interface{} {
+	if !enveloped {
+		return todos
+	}
+	return ListEnvelope{Data: todos, Meta: ListMeta{Total: int64(len(todos))}}
+}
+
+// jsonOptionsKey is the context key under which a request's jsonOptions
+// are stored
+type jsonOptionsKey struct{}
+
+// jsonOptions controls how writeJSON encodes the response to a request
+type jsonOptions struct {
+	// pretty indents the output
+	pretty bool
+
+	// snakeCase names members in snake_case rather than camelCase, in
+	// responses and in request bodies and query parameters alike
+	snakeCase bool
+}
+
+// requestJSONOptions returns the options set on r by middleware, or the
+// zero value if none were
+func requestJSONOptions(r *http.Request) jsonOptions {
+	opts, _ := r.Context().Value(jsonOptionsKey{}).(jsonOptions)
+	return opts
+}
+
+// withJSONOptions returns a shallow copy of r whose context carries opts
+func withJSONOptions(r *http.Request, opts jsonOptions) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), jsonOptionsKey{}, opts))
+}
+
+// PrettyJSON is middleware that indents JSON responses when the request
+// has ?pretty=true. Responses are compact by default.
+func PrettyJSON(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("pretty") == "true" {
+			opts := requestJSONOptions(r)
+			opts.pretty = true
+			r = withJSONOptions(r, opts)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 // writeJSON writes a JSON response
-func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
-	if err := json.NewEncoder(w).Encode(data); err != nil {
+	encoder := json.NewEncoder(w)
+	if requestJSONOptions(r).pretty {
+		encoder.SetIndent("", "  ")
+	}
+	if err := encoder.Encode(responseValue(r, data)); err != nil {
 		// At this point headers are already sent, so we can only log the error
 		// In a production app, you'd want to use a proper logger here
 		return
 	}
 }
 
+// writeJSONWithETag writes a JSON response like writeJSON, with a strong
+// ETag computed from the body
+func writeJSONWithETag(w http.ResponseWriter, r *http.Request, status int, data interface{}) {
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	if requestJSONOptions(r).pretty {
+		encoder.SetIndent("", "  ")
+	}
+	if err := encoder.Encode(responseValue(r, data)); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Failed to encode response")
+		return
+	}
+
+	sum := sha256.Sum256(body.Bytes())
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if _, err := w.Write(body.Bytes()); err != nil {
+		// Headers are already sent, so there is nothing left to report
+		return
+	}
+}
+
 // writeError writes an error JSON response
-func writeError(w http.ResponseWriter, status int, message string) {
-	writeJSON(w, status, ErrorResponse{Error: message})
+func writeError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	writeJSON(w, r, status, ErrorResponse{Error: message})
+}
+
+// requireJSON rejects a request whose body is not declared as JSON with 415
+// Unsupported Media Type, and reports whether the handler should continue.
+// Parameters such as charset are allowed.
+func requireJSON(w http.ResponseWriter, r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		writeError(w, r, http.StatusUnsupportedMediaType, "Content-Type must be application/json")
+		return false
+	}
+	return true
 }
 
 // GetAllTodos handles GET /api/todos
@@ -47,181 +276,1708 @@ func writeError(w http.ResponseWriter, status int, message string) {
 // @Produce json
 // @Param search query string false "Search in title and description"
 // @Param completed query boolean false "Filter by completion status"
-// @Param sortBy query string false "Sort by field (createdAt, updatedAt, title)"
+// @Param starred query boolean false "Filter by starred status"
+// @Param draft query boolean false "Return draft todos instead of published ones"
+// @Param createdAfter query string false "Only todos created at or after this RFC3339 time or YYYY-MM-DD date"
+// @Param createdBefore query string false "Only todos created before this RFC3339 time or YYYY-MM-DD date"
+// @Param updatedAfter query string false "Only todos updated at or after this RFC3339 time or YYYY-MM-DD date"
+// @Param updatedBefore query string false "Only todos updated before this RFC3339 time or YYYY-MM-DD date"
+// @Param tz query string false "IANA time zone for date-only filters and returned timestamps (default UTC)"
+// @Param sortBy query string false "Sort by field (createdAt, updatedAt, title, starred, position, estimate, or relevance with search)"
 // @Param sortOrder query string false "Sort order (asc, desc)"
+// @Param limit query int false "Maximum number of todos to return, clamped to the server's maximum page size"
+// @Param cursor query string false "Opaque cursor from a previous X-Next-Cursor header"
+// @Param fields query string false "Comma-separated list of fields to include (e.g. id,title,completed)"
+// @Param highlight query boolean false "Add titleHighlighted and descriptionHighlighted with search matches wrapped in <mark>"
+// @Param preview query int false "Add descriptionPreview, the description cut to this many characters with an ellipsis if shortened"
+// @Param envelope query boolean false "Wrap the list as {data, meta} with the total and pagination details (default set by the server)"
 // @Success 200 {array} models.Todo
+// @Header 200 {string} X-Next-Cursor "Cursor for the next page, empty when there are no more results"
+// @Header 200 {integer} X-Max-Page-Size "Largest page size the server returns, on paginated requests"
+// @Failure 400 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
+// @Failure 504 {object} ErrorResponse
 // @Router /api/todos [get]
 func (h *TodoHandler) GetAllTodos(w http.ResponseWriter, r *http.Request) {
-	// Parse query parameters
-	search := r.URL.Query().Get("search")
-	completedStr := r.URL.Query().Get("completed")
-	sortBy := r.URL.Query().Get("sortBy")
-	sortOrder := r.URL.Query().Get("sortOrder")
+	// Parse filters and sorting
+	opts, err := parseFilterOptions(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	sortBy, sortOrder, err := parseSort(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	opts.SortBy = sortBy
+	opts.SortOrder = sortOrder
+
+	// Parse sparse fieldset
+	fields, err := parseFields(r.URL.Query().Get("fields"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	highlight, err := parseBoolParam(r, "highlight")
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	preview, err := parsePreview(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	enveloped, err := h.parseEnvelope(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
 
-	// Build filter options
-	opts := database.FilterOptions{
-		Search:    search,
-		SortBy:    sortBy,
-		SortOrder: sortOrder,
+	// Parse pagination parameters
+	limit, err := parseLimit(r.URL.Query().Get("limit"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
 	}
 
-	// Parse completed filter if provided
-	if completedStr != "" {
-		completed := completedStr == "true"
-		opts.Completed = &completed
+	cursorStr := r.URL.Query().Get("cursor")
+	paginated := limit > 0 || cursorStr != ""
+	if paginated {
+		if sortBy != "" && sortBy != "created_at" {
+			writeError(w, r, http.StatusBadRequest, "Pagination requires sorting by createdAt")
+			return
+		}
+		opts.SortBy = "created_at"
+
+		if cursorStr != "" {
+			cursor, err := h.decodeCursor(cursorStr)
+			if err != nil {
+				writeError(w, r, http.StatusBadRequest, "Invalid cursor")
+				return
+			}
+			opts.After = cursor
+		}
+
+		limit = h.pageSize(w, limit)
+		// Fetch one extra row to detect whether another page exists
+		opts.Limit = limit + 1
 	}
 
-	// If no filters provided, use GetAll for backward compatibility
-	var todos []models.Todo
-	var err error
+	loc, err := parseLocation(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
 
-	if search == "" && opts.Completed == nil && sortBy == "" {
-		todos, err = h.repo.GetAll()
-	} else {
-		todos, err = h.repo.Search(opts)
+	// Unpaginated lists can be arbitrarily long, so stream them rather than
+	// holding every todo in memory. Projections and envelopes still need
+	// the whole slice.
+	if !paginated && fields == nil && (highlight == nil || !*highlight) && !enveloped {
+		h.streamTodos(w, r, opts, loc, preview)
+		return
 	}
 
+	// Search rather than GetAll even without filters, so drafts stay hidden
+	todos, err := h.repo.Search(r.Context(), opts)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		writeRepoError(w, r, err)
 		return
 	}
 
+	nextCursor := ""
+	if paginated {
+		if len(todos) > limit {
+			todos = todos[:limit]
+			nextCursor = h.encodeCursor(todos[limit-1])
+		}
+		w.Header().Set("X-Next-Cursor", nextCursor)
+	}
+
 	if todos == nil {
 		todos = []models.Todo{}
 	}
 
-	writeJSON(w, http.StatusOK, todos)
+	inLocation(todos, loc)
+
+	var body interface{}
+	switch {
+	case highlight != nil && *highlight:
+		highlighted := highlightTodos(todos, opts.Search)
+		extra := slices.Clone(highlightFields)
+		if preview > 0 {
+			for i := range highlighted {
+				p := truncateRunes(highlighted[i].Description, preview)
+				highlighted[i].DescriptionPreview = &p
+			}
+			extra = append(extra, previewField)
+		}
+		if fields != nil {
+			fields = append(fields, extra...)
+		}
+		body, err = projectFields(highlighted, fields)
+	case preview > 0:
+		previewed := make([]PreviewedTodo, 0, len(todos))
+		for _, todo := range todos {
+			previewed = append(previewed, previewTodo(todo, preview))
+		}
+		if fields != nil {
+			fields = append(fields, previewField)
+		}
+		body, err = projectFields(previewed, fields)
+	default:
+		body, err = projectFields(todos, fields)
+	}
+	if err != nil {
+		writeRepoError(w, r, err)
+		return
+	}
+
+	if enveloped {
+		meta := ListMeta{Total: int64(len(todos))}
+		if paginated {
+			// The page holds only part of the list, so count all of it
+			total, err := h.repo.Count(r.Context(), opts)
+			if err != nil {
+				writeRepoError(w, r, err)
+				return
+			}
+			meta.Total = total
+			meta.Limit = limit
+			meta.NextCursor = &nextCursor
+		}
+		body = ListEnvelope{Data: body, Meta: meta}
+	}
+
+	writeJSON(w, r, http.StatusOK, body)
 }
 
-// GetTodo handles GET /api/todos/{id}
-// @Summary Get a todo by ID
-// @Description Get a single todo item by ID
+// streamTodos writes the todos matching opts as a JSON array while they are
+// read from the database. An error before the first todo gets a normal
+// error response; once the array has started the status is already sent,
+// so the error is logged and the response ends with a truncated array.
+// A positive preview adds a description preview of that many runes.
+func (h *TodoHandler) streamTodos(w http.ResponseWriter, r *http.Request, opts database.FilterOptions, loc *time.Location, preview int) {
+	out := newJSONArrayWriter(w, r)
+
+	err := h.repo.Stream(r.Context(), opts, func(todo models.Todo) error {
+		todos := []models.Todo{todo}
+		inLocation(todos, loc)
+		if preview > 0 {
+			return out.write(previewTodo(todos[0], preview))
+		}
+		return out.write(todos[0])
+	})
+	if err != nil {
+		if !out.started() {
+			writeRepoError(w, r, err)
+			return
+		}
+		slog.Error("Error streaming todos, response truncated", "err", err, "written", out.written)
+		return
+	}
+
+	if err := out.close(); err != nil {
+		slog.Error("Error streaming todos", "err", err)
+	}
+}
+
+// projectFields returns items projected onto the requested JSON fields, or
+// items unchanged when fields is nil
+func projectFields[T any](items []T, fields []string) (interface{}, error) {
+	if fields == nil {
+		return items, nil
+	}
+	return selectFields(items, fields)
+}
+
+// CountTodos handles GET /api/todos/count
+// @Summary Count todos
+// @Description Count the todos matching the same filters as the list endpoint, and sum the estimateMinutes of the incomplete ones
+// @Tags todos
+// @Produce json
+// @Param search query string false "Search in title and description"
+// @Param completed query boolean false "Filter by completion status"
+// @Param starred query boolean false "Filter by starred status"
+// @Param draft query boolean false "Return draft todos instead of published ones"
+// @Param createdAfter query string false "Only todos created at or after this RFC3339 time or YYYY-MM-DD date"
+// @Param createdBefore query string false "Only todos created before this RFC3339 time or YYYY-MM-DD date"
+// @Param updatedAfter query string false "Only todos updated at or after this RFC3339 time or YYYY-MM-DD date"
+// @Param updatedBefore query string false "Only todos updated before this RFC3339 time or YYYY-MM-DD date"
+// @Param tz query string false "IANA time zone for date-only filters (default UTC)"
+// @Success 200 {object} CountResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 504 {object} ErrorResponse
+// @Router /api/todos/count [get]
+func (h *TodoHandler) CountTodos(w http.ResponseWriter, r *http.Request) {
+	opts, err := parseFilterOptions(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	count, err := h.repo.Count(r.Context(), opts)
+	if err != nil {
+		writeRepoError(w, r, err)
+		return
+	}
+
+	remaining, err := h.repo.RemainingMinutes(r.Context(), opts)
+	if err != nil {
+		writeRepoError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, CountResponse{Count: count, RemainingMinutes: remaining})
+}
+
+// GetRandomTodo handles GET /api/todos/random
+// @Summary Get a random incomplete todo
+// @Description Pick one incomplete todo at random, optionally narrowed by the list endpoint's filters. The completed filter is always false.
 // @Tags todos
 // @Produce json
-// @Param id path int true "Todo ID"
+// @Param search query string false "Search in title and description"
+// @Param starred query boolean false "Filter by starred status"
+// @Param draft query boolean false "Return draft todos instead of published ones"
+// @Param createdAfter query string false "Only todos created at or after this RFC3339 time or YYYY-MM-DD date"
+// @Param createdBefore query string false "Only todos created before this RFC3339 time or YYYY-MM-DD date"
+// @Param updatedAfter query string false "Only todos updated at or after this RFC3339 time or YYYY-MM-DD date"
+// @Param updatedBefore query string false "Only todos updated before this RFC3339 time or YYYY-MM-DD date"
+// @Param tz query string false "IANA time zone for date-only filters (default UTC)"
 // @Success 200 {object} models.Todo
+// @Failure 400 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
-// @Router /api/todos/{id} [get]
-func (h *TodoHandler) GetTodo(w http.ResponseWriter, r *http.Request) {
-	idStr := r.PathValue("id")
-	id, err := strconv.ParseInt(idStr, 10, 64)
+// @Failure 504 {object} ErrorResponse
+// @Router /api/todos/random [get]
+func (h *TodoHandler) GetRandomTodo(w http.ResponseWriter, r *http.Request) {
+	opts, err := parseFilterOptions(r)
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "Invalid ID")
+		writeError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
+	incomplete := false
+	opts.Completed = &incomplete
 
-	todo, err := h.repo.GetByID(id)
+	todo, err := h.repo.Random(r.Context(), opts)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		writeRepoError(w, r, err)
 		return
 	}
 
 	if todo == nil {
-		writeError(w, http.StatusNotFound, "Todo not found")
+		writeError(w, r, http.StatusNotFound, "No incomplete todos found")
 		return
 	}
 
-	writeJSON(w, http.StatusOK, todo)
+	writeJSON(w, r, http.StatusOK, todo)
 }
 
-// CreateTodo handles POST /api/todos
-// @Summary Create a new todo
-// @Description Create a new todo item
+// recordAccess records a view of the todo with id in the background, so the
+// write does not delay the response. Failures are only logged.
+func (h *TodoHandler) recordAccess(r *http.Request, id int64) {
+	// The request's context ends with the response, so detach from it
+	ctx := context.WithoutCancel(r.Context())
+	go func() {
+		if err := h.repo.RecordAccess(ctx, id); err != nil {
+			slog.Warn("Failed to record todo access", "id", id, "err", err)
+		}
+	}()
+}
+
+// defaultRecentLimit is how many todos GetRecent returns by default
+const defaultRecentLimit = 10
+
+// GetRecent handles GET /api/todos/recent
+// @Summary List recently viewed todos
+// @Description Get the todos most recently fetched with GET /api/todos/{id}, most recent first. Views are only recorded when the server runs with TRACK_ACCESS=true.
 // @Tags todos
-// @Accept json
 // @Produce json
-// @Param todo body models.CreateTodoRequest true "Todo to create"
-// @Success 201 {object} models.Todo
+// @Param limit query int false "Maximum number of todos to return (default 10), clamped to the server's maximum page size"
+// @Param tz query string false "IANA time zone to return timestamps in (default UTC)"
+// @Param envelope query boolean false "Wrap the list as {data, meta} with the total (default set by the server)"
+// @Success 200 {array} models.Todo
 // @Failure 400 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
-// @Router /api/todos [post]
-func (h *TodoHandler) CreateTodo(w http.ResponseWriter, r *http.Request) {
-	var req models.CreateTodoRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "Invalid request body")
+// @Failure 504 {object} ErrorResponse
+// @Router /api/todos/recent [get]
+func (h *TodoHandler) GetRecent(w http.ResponseWriter, r *http.Request) {
+	limit, err := parseLimit(r.URL.Query().Get("limit"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
+	if limit == 0 {
+		limit = defaultRecentLimit
+	}
+	limit = min(limit, h.maxPageSize)
 
-	if req.Title == "" {
-		writeError(w, http.StatusBadRequest, "Title is required")
+	loc, err := parseLocation(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	enveloped, err := h.parseEnvelope(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	todo, err := h.repo.Create(req)
+	todos, err := h.repo.Recent(r.Context(), limit)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		writeRepoError(w, r, err)
 		return
 	}
 
-	writeJSON(w, http.StatusCreated, todo)
+	if todos == nil {
+		todos = []models.Todo{}
+	}
+	inLocation(todos, loc)
+
+	writeJSON(w, r, http.StatusOK, listBody(todos, enveloped))
 }
 
-// UpdateTodo handles PATCH /api/todos/{id}
-// @Summary Update a todo
-// @Description Update an existing todo item
+// defaultReminderWindow is how far ahead GetReminders looks by default
+const defaultReminderWindow = 24 * time.Hour
+
+// GetReminders handles GET /api/todos/reminders
+// @Summary List upcoming reminders
+// @Description Get incomplete todos with a reminder due within the given duration from now, soonest first
 // @Tags todos
-// @Accept json
 // @Produce json
-// @Param id path int true "Todo ID"
-// @Param todo body models.UpdateTodoRequest true "Todo updates"
-// @Success 200 {object} models.Todo
+// @Param within query string false "How far ahead to look, as a Go duration such as 90m or 48h (default 24h)"
+// @Param includeSnoozed query bool false "Include todos that are snoozed (default false)"
+// @Param tz query string false "IANA time zone to return timestamps in (default UTC)"
+// @Param envelope query boolean false "Wrap the list as {data, meta} with the total (default set by the server)"
+// @Success 200 {array} models.Todo
 // @Failure 400 {object} ErrorResponse
-// @Failure 404 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
-// @Router /api/todos/{id} [patch]
-func (h *TodoHandler) UpdateTodo(w http.ResponseWriter, r *http.Request) {
-	idStr := r.PathValue("id")
-	id, err := strconv.ParseInt(idStr, 10, 64)
+// @Failure 504 {object} ErrorResponse
+// @Router /api/todos/reminders [get]
+func (h *TodoHandler) GetReminders(w http.ResponseWriter, r *http.Request) {
+	within := defaultReminderWindow
+	if v := r.URL.Query().Get("within"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			writeError(w, r, http.StatusBadRequest, "invalid within: must be a positive duration such as 24h")
+			return
+		}
+		within = d
+	}
+
+	loc, err := parseLocation(r)
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "Invalid ID")
+		writeError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	var req models.UpdateTodoRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "Invalid request body")
+	includeSnoozed, err := parseBoolParam(r, "includeSnoozed")
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	todo, err := h.repo.Update(id, req)
+	enveloped, err := h.parseEnvelope(r)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		writeError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	if todo == nil {
-		writeError(w, http.StatusNotFound, "Todo not found")
+	now := time.Now()
+	todos, err := h.repo.Reminders(r.Context(), now, now.Add(within), includeSnoozed != nil && *includeSnoozed)
+	if err != nil {
+		writeRepoError(w, r, err)
+		return
+	}
+
+	if todos == nil {
+		todos = []models.Todo{}
+	}
+	inLocation(todos, loc)
+
+	writeJSON(w, r, http.StatusOK, listBody(todos, enveloped))
+}
+
+// GetSchedule handles GET /api/todos/schedule
+// @Summary List scheduled todos
+// @Description Get the todos scheduled within a time window, earliest first, for calendar and agenda views. Drafts are left out; completed todos are included.
+// @Tags todos
+// @Produce json
+// @Param from query string true "Start of the window, as an RFC3339 time or a YYYY-MM-DD date"
+// @Param to query string true "End of the window, exclusive, as an RFC3339 time or a YYYY-MM-DD date"
+// @Param tz query string false "IANA time zone for dates and returned timestamps (default UTC)"
+// @Param envelope query boolean false "Wrap the list as {data, meta} with the total (default set by the server)"
+// @Success 200 {array} models.Todo
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 504 {object} ErrorResponse
+// @Router /api/todos/schedule [get]
+func (h *TodoHandler) GetSchedule(w http.ResponseWriter, r *http.Request) {
+	loc, err := parseLocation(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var window [2]time.Time
+	for i, name := range []string{"from", "to"} {
+		v := r.URL.Query().Get(name)
+		if v == "" {
+			writeError(w, r, http.StatusBadRequest, name+" is required")
+			return
+		}
+		if window[i], err = parseTime(v, loc); err != nil {
+			writeError(w, r, http.StatusBadRequest, "invalid "+name+": must be an RFC3339 time or a YYYY-MM-DD date")
+			return
+		}
+	}
+	from, to := window[0], window[1]
+	if !to.After(from) {
+		writeError(w, r, http.StatusBadRequest, "to must be after from")
+		return
+	}
+
+	enveloped, err := h.parseEnvelope(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	todos, err := h.repo.Schedule(r.Context(), from, to)
+	if err != nil {
+		writeRepoError(w, r, err)
 		return
 	}
 
-	writeJSON(w, http.StatusOK, todo)
+	if todos == nil {
+		todos = []models.Todo{}
+	}
+	inLocation(todos, loc)
+
+	writeJSON(w, r, http.StatusOK, listBody(todos, enveloped))
 }
 
-// DeleteTodo handles DELETE /api/todos/{id}
-// @Summary Delete a todo
-// @Description Delete a todo item by ID
+// SyncTodos handles GET /api/todos/sync
+// @Summary List changes for incremental sync
+// @Description Get the todos created, updated or deleted after since, oldest change first. Deleted todos have deleted set and only carry their id and deletion time. Once every page has been read, use serverTime as the next since.
 // @Tags todos
-// @Param id path int true "Todo ID"
-// @Success 204
+// @Produce json
+// @Param since query string false "Only changes after this RFC3339 time (default: all)"
+// @Param limit query int false "Maximum number of changes to return, clamped to the server's maximum page size"
+// @Param cursor query string false "Opaque cursor from a previous X-Next-Cursor header"
+// @Success 200 {object} models.SyncResponse
+// @Header 200 {string} X-Next-Cursor "Cursor for the next page, empty when there are no more changes"
+// @Header 200 {integer} X-Max-Page-Size "Largest page size the server returns, on paginated requests"
 // @Failure 400 {object} ErrorResponse
-// @Failure 404 {object} ErrorResponse
+// @Failure 410 {object} ErrorResponse "since is older than the tombstone retention"
 // @Failure 500 {object} ErrorResponse
-// @Router /api/todos/{id} [delete]
-func (h *TodoHandler) DeleteTodo(w http.ResponseWriter, r *http.Request) {
-	idStr := r.PathValue("id")
-	id, err := strconv.ParseInt(idStr, 10, 64)
+// @Failure 504 {object} ErrorResponse
+// @Router /api/todos/sync [get]
+func (h *TodoHandler) SyncTodos(w http.ResponseWriter, r *http.Request) {
+	var since time.Time
+	if v := r.URL.Query().Get("since"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "invalid since: must be an RFC3339 time")
+			return
+		}
+		since = t
+	}
+
+	// Deletions older than the retention have been purged, so a client that
+	// last synced before then would keep todos that no longer exist
+	if h.tombstoneRetention > 0 && !since.IsZero() && since.Before(time.Now().Add(-h.tombstoneRetention)) {
+		writeError(w, r, http.StatusGone, "since is older than the tombstone retention; discard local data and sync again without since")
+		return
+	}
+
+	limit, err := parseLimit(r.URL.Query().Get("limit"))
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "Invalid ID")
+		writeError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	err = h.repo.Delete(id)
+	var after *database.SyncCursor
+	cursorStr := r.URL.Query().Get("cursor")
+	if cursorStr != "" {
+		if after, err = h.decodeSyncCursor(cursorStr); err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
+	}
+
+	paginated := limit > 0 || cursorStr != ""
+	if paginated {
+		limit = h.pageSize(w, limit)
+	}
+
+	// Take the server time before querying, so a change made while the
+	// query runs is returned again next time rather than missed
+	serverTime := time.Now().UTC()
+
+	fetch := 0
+	if paginated {
+		// Fetch one extra row to detect whether another page exists
+		fetch = limit + 1
+	}
+	changes, err := h.repo.Changes(r.Context(), since, after, fetch)
 	if err != nil {
-		writeError(w, http.StatusNotFound, "Todo not found")
+		writeRepoError(w, r, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	if paginated {
+		nextCursor := ""
+		if len(changes) > limit {
+			changes = changes[:limit]
+			nextCursor = h.encodeSyncCursor(changes[limit-1])
+		}
+		w.Header().Set("X-Next-Cursor", nextCursor)
+	}
+
+	if changes == nil {
+		changes = []models.TodoChange{}
+	}
+
+	writeJSON(w, r, http.StatusOK, models.SyncResponse{Changes: changes, ServerTime: serverTime})
+}
+
+// GetTodo handles GET /api/todos/{id}
+// @Summary Get a todo by ID
+// @Description Get a single todo item by ID
+// @Tags todos
+// @Produce json
+// @Param id path string true "Todo ID, publicId or slug"
+// @Param If-Modified-Since header string false "Return 304 if the todo has not changed since this HTTP date"
+// @Param expand query string false "Comma-separated related data to embed (attachments, comments, dependencies)"
+// @Param render query string false "html adds descriptionHtml, the description rendered from Markdown to sanitized HTML"
+// @Success 200 {object} models.TodoDetail
+// @Success 304
+// @Header 200 {string} Last-Modified "Time the todo was last updated"
+// @Header 200 {string} ETag "Hash of the response body"
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 504 {object} ErrorResponse
+// @Router /api/todos/{id} [get]
+func (h *TodoHandler) GetTodo(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseTodoID(w, r, h.repo)
+	if !ok {
+		return
+	}
+
+	expand, err := parseExpand(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	renderHTML, err := parseRender(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	todo, err := h.repo.GetByID(r.Context(), id)
+	if err != nil {
+		writeRepoError(w, r, err)
+		return
+	}
+
+	if todo == nil {
+		writeError(w, r, http.StatusNotFound, "Todo not found")
+		return
+	}
+
+	if h.trackAccess {
+		h.recordAccess(r, id)
+	}
+
+	// HTTP dates have one-second precision, so truncate before comparing
+	lastModified := todo.UpdatedAt.UTC().Truncate(time.Second)
+	w.Header().Set("Last-Modified", lastModified.Format(http.TimeFormat))
+
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" {
+		if since, err := http.ParseTime(ims); err == nil && !lastModified.After(since) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	if len(expand) > 0 || renderHTML {
+		detail := models.TodoDetail{Todo: *todo}
+		if renderHTML {
+			html, err := renderDescription(todo.Description)
+			if err != nil {
+				writeRepoError(w, r, err)
+				return
+			}
+			detail.DescriptionHTML = &html
+		}
+		if expand["attachments"] && h.attachments != nil {
+			if detail.Attachments, err = h.attachments.ListByTodo(r.Context(), id); err != nil {
+				writeRepoError(w, r, err)
+				return
+			}
+		}
+		if expand["comments"] && h.comments != nil {
+			if detail.Comments, err = h.comments.ListByTodo(r.Context(), id); err != nil {
+				writeRepoError(w, r, err)
+				return
+			}
+			count := int64(len(detail.Comments))
+			detail.CommentCount = &count
+		}
+		if expand["dependencies"] && h.deps != nil {
+			if detail.Dependencies, err = loadDependencies(r, h.deps, id); err != nil {
+				writeRepoError(w, r, err)
+				return
+			}
+		}
+		writeJSONWithETag(w, r, http.StatusOK, detail)
+		return
+	}
+
+	writeJSONWithETag(w, r, http.StatusOK, todo)
+}
+
+// headResponseWriter discards the body while recording its length, so a
+// GET handler can serve HEAD requests with accurate headers
+type headResponseWriter struct {
+	http.ResponseWriter
+	status int
+	length int
+}
+
+// WriteHeader records the status until the body length is known
+func (w *headResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+// Write counts and discards body bytes
+func (w *headResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.length += len(b)
+	return len(b), nil
+}
+
+// flush sends the recorded status with a Content-Length header
+func (w *headResponseWriter) flush() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.status != http.StatusNotModified {
+		w.Header().Set("Content-Length", strconv.Itoa(w.length))
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+}
+
+// HeadTodo handles HEAD /api/todos/{id}
+// @Summary Check a todo exists
+// @Description Returns the same headers as GET /api/todos/{id} without a body, including its ETag and Content-Length.
+// @Tags todos
+// @Param id path string true "Todo ID, publicId or slug"
+// @Success 200
+// @Failure 400
+// @Failure 404
+// @Failure 500
+// @Router /api/todos/{id} [head]
+func (h *TodoHandler) HeadTodo(w http.ResponseWriter, r *http.Request) {
+	hw := &headResponseWriter{ResponseWriter: w}
+	h.GetTodo(hw, r)
+	hw.flush()
+}
+
+// CreateTodo handles POST /api/todos
+// @Summary Create a new todo
+// @Description Create a new todo item
+// @Tags todos
+// @Accept json
+// @Produce json
+// @Param todo body models.CreateTodoRequest true "Todo to create"
+// @Success 201 {object} models.Todo
+// @Failure 400 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 415 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 504 {object} ErrorResponse
+// @Router /api/todos [post]
+func (h *TodoHandler) CreateTodo(w http.ResponseWriter, r *http.Request) {
+	if !requireJSON(w, r) {
+		return
+	}
+
+	var req models.CreateTodoRequest
+	if err := decodeJSON(r, &req); err != nil {
+		if errors.Is(err, models.ErrMetadataNotObject) {
+			writeError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	req = h.defaults.Apply(req)
+
+	if err := validateCreateTodo(req); err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	todo, err := h.repo.Create(r.Context(), req)
+	if err != nil {
+		writeRepoError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusCreated, todo)
+}
+
+// UpsertTodo handles PUT /api/todos/by-external/{externalId}
+// @Summary Create or replace a todo by external id
+// @Description Create a todo with the given external id or, if one exists, replace its title, description, completed, starred, remindAt, scheduledAt, draft, metadata and estimateMinutes, in one atomic statement. Other fields, such as position, are kept. A created todo without a description gets the server's default one. Completing an existing todo that depends on incomplete todos returns 409.
+// @Tags todos
+// @Accept json
+// @Produce json
+// @Param externalId path string true "Id of the todo in the external system"
+// @Param todo body models.UpsertTodoRequest true "Todo fields"
+// @Success 200 {object} models.Todo "replaced"
+// @Success 201 {object} models.Todo "created"
+// @Failure 400 {object} ErrorResponse
+// @Failure 409 {object} BlockedResponse
+// @Failure 415 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 504 {object} ErrorResponse
+// @Router /api/todos/by-external/{externalId} [put]
+func (h *TodoHandler) UpsertTodo(w http.ResponseWriter, r *http.Request) {
+	if !requireJSON(w, r) {
+		return
+	}
+
+	var req models.UpsertTodoRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.Title == "" {
+		writeError(w, r, http.StatusBadRequest, "title is required")
+		return
+	}
+	if req.EstimateMinutes < 0 {
+		writeError(w, r, http.StatusBadRequest, errEstimateNegative.Error())
+		return
+	}
+	req = h.defaults.ApplyUpsert(req)
+
+	todo, created, err := h.repo.Upsert(r.Context(), r.PathValue("externalId"), req)
+	if err != nil {
+		writeRepoError(w, r, err)
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	writeJSON(w, r, status, todo)
+}
+
+// validateCreateTodo checks a create request before it reaches the database
+func validateCreateTodo(req models.CreateTodoRequest) error {
+	if req.Title == "" {
+		return errors.New("title is required")
+	}
+	if req.EstimateMinutes < 0 {
+		return errEstimateNegative
+	}
+	return nil
+}
+
+// validateUpdateTodo checks an update request before it reaches the database
+func validateUpdateTodo(req models.UpdateTodoRequest) error {
+	if req.EstimateMinutes != nil && *req.EstimateMinutes < 0 {
+		return errEstimateNegative
+	}
+	return nil
+}
+
+// errEstimateNegative rejects an estimateMinutes below zero
+var errEstimateNegative = errors.New("estimateMinutes must not be negative")
+
+// UpdateTodo handles PATCH /api/todos/{id}
+// @Summary Update a todo
+// @Description Update an existing todo item. If ifUnmodifiedSince is given and the todo was updated after it, nothing is changed and 409 is returned. With Content-Type application/merge-patch+json the body is an RFC 7386 merge patch of the todo, where null clears a field and metadata is merged member by member.
+// @Tags todos
+// @Accept json
+// @Accept application/merge-patch+json
+// @Produce json
+// @Param id path string true "Todo ID, publicId or slug"
+// @Param todo body models.UpdateTodoRequest true "Todo updates"
+// @Success 200 {object} models.Todo
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 415 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 504 {object} ErrorResponse
+// @Router /api/todos/{id} [patch]
+func (h *TodoHandler) UpdateTodo(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseTodoID(w, r, h.repo)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateTodoRequest
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/json":
+		if err := decodeJSON(r, &req); err != nil {
+			if errors.Is(err, models.ErrMetadataNotObject) {
+				writeError(w, r, http.StatusBadRequest, err.Error())
+				return
+			}
+			writeError(w, r, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	case mergePatchType:
+		patch, err := decodeMergePatch(r)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		req = patch
+	default:
+		writeError(w, r, http.StatusUnsupportedMediaType, "Content-Type must be application/json or "+mergePatchType)
+		return
+	}
+
+	if err := validateUpdateTodo(req); err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	todo, err := h.repo.Update(r.Context(), id, req)
+	if err != nil {
+		writeRepoError(w, r, err)
+		return
+	}
+
+	if todo == nil {
+		writeError(w, r, http.StatusNotFound, "Todo not found")
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, todo)
+}
+
+// CompleteTodo handles POST /api/todos/{id}/complete
+// @Summary Mark a todo as completed
+// @Description Set a todo's completed flag without a request body
+// @Tags todos
+// @Produce json
+// @Param id path string true "Todo ID, publicId or slug"
+// @Success 200 {object} models.Todo
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} BlockedResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 504 {object} ErrorResponse
+// @Router /api/todos/{id}/complete [post]
+func (h *TodoHandler) CompleteTodo(w http.ResponseWriter, r *http.Request) {
+	h.setCompleted(w, r, true)
+}
+
+// IncompleteTodo handles POST /api/todos/{id}/incomplete
+// @Summary Mark a todo as incomplete
+// @Description Clear a todo's completed flag without a request body
+// @Tags todos
+// @Produce json
+// @Param id path string true "Todo ID, publicId or slug"
+// @Success 200 {object} models.Todo
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 504 {object} ErrorResponse
+// @Router /api/todos/{id}/incomplete [post]
+func (h *TodoHandler) IncompleteTodo(w http.ResponseWriter, r *http.Request) {
+	h.setCompleted(w, r, false)
+}
+
+// setCompleted updates a single todo's completed flag via Update
+func (h *TodoHandler) setCompleted(w http.ResponseWriter, r *http.Request, completed bool) {
+	id, ok := parseTodoID(w, r, h.repo)
+	if !ok {
+		return
+	}
+
+	todo, err := h.repo.Update(r.Context(), id, models.UpdateTodoRequest{Completed: &completed})
+	if err != nil {
+		writeRepoError(w, r, err)
+		return
+	}
+
+	if todo == nil {
+		writeError(w, r, http.StatusNotFound, "Todo not found")
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, todo)
+}
+
+// GetDrafts handles GET /api/todos/drafts
+// @Summary List draft todos
+// @Description Get the draft todos, which the list endpoint leaves out by default. Equivalent to GET /api/todos?draft=true and accepts the same parameters.
+// @Tags todos
+// @Produce json
+// @Param envelope query boolean false "Wrap the list as {data, meta} with the total and pagination details (default set by the server)"
+// @Success 200 {array} models.Todo
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 504 {object} ErrorResponse
+// @Router /api/todos/drafts [get]
+func (h *TodoHandler) GetDrafts(w http.ResponseWriter, r *http.Request) {
+	r = r.Clone(r.Context())
+	query := r.URL.Query()
+	query.Set("draft", "true")
+	r.URL.RawQuery = query.Encode()
+
+	h.GetAllTodos(w, r)
+}
+
+// PublishTodo handles POST /api/todos/{id}/publish
+// @Summary Publish a draft todo
+// @Description Clear a todo's draft flag so it appears in the list endpoint's default results
+// @Tags todos
+// @Produce json
+// @Param id path string true "Todo ID, publicId or slug"
+// @Success 200 {object} models.Todo
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 504 {object} ErrorResponse
+// @Router /api/todos/{id}/publish [post]
+func (h *TodoHandler) PublishTodo(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseTodoID(w, r, h.repo)
+	if !ok {
+		return
+	}
+
+	draft := false
+	todo, err := h.repo.Update(r.Context(), id, models.UpdateTodoRequest{Draft: &draft})
+	if err != nil {
+		writeRepoError(w, r, err)
+		return
+	}
+
+	if todo == nil {
+		writeError(w, r, http.StatusNotFound, "Todo not found")
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, todo)
+}
+
+// FocusTodo handles POST /api/todos/{id}/focus
+// @Summary Focus a todo
+// @Description Make a todo the focused one. Any other focused todo is unfocused in the same transaction, so at most one todo is focused at a time.
+// @Tags todos
+// @Produce json
+// @Param id path string true "Todo ID, publicId or slug"
+// @Success 200 {object} models.Todo
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 504 {object} ErrorResponse
+// @Router /api/todos/{id}/focus [post]
+func (h *TodoHandler) FocusTodo(w http.ResponseWriter, r *http.Request) {
+	h.setFocus(w, r, true)
+}
+
+// UnfocusTodo handles DELETE /api/todos/{id}/focus
+// @Summary Unfocus a todo
+// @Description Clear a todo's focused flag, leaving no todo focused. Unfocusing a todo that is not focused succeeds.
+// @Tags todos
+// @Produce json
+// @Param id path string true "Todo ID, publicId or slug"
+// @Success 200 {object} models.Todo
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 504 {object} ErrorResponse
+// @Router /api/todos/{id}/focus [delete]
+func (h *TodoHandler) UnfocusTodo(w http.ResponseWriter, r *http.Request) {
+	h.setFocus(w, r, false)
+}
+
+// setFocus sets or clears a single todo's focused flag
+func (h *TodoHandler) setFocus(w http.ResponseWriter, r *http.Request, focused bool) {
+	id, ok := parseTodoID(w, r, h.repo)
+	if !ok {
+		return
+	}
+
+	todo, err := h.repo.SetFocus(r.Context(), id, focused)
+	if err != nil {
+		writeRepoError(w, r, err)
+		return
+	}
+
+	if todo == nil {
+		writeError(w, r, http.StatusNotFound, "Todo not found")
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, todo)
+}
+
+// GetFocusedTodo handles GET /api/todos/focused
+// @Summary Get the focused todo
+// @Description Get the todo set with POST /api/todos/{id}/focus
+// @Tags todos
+// @Produce json
+// @Success 200 {object} models.Todo
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 504 {object} ErrorResponse
+// @Router /api/todos/focused [get]
+func (h *TodoHandler) GetFocusedTodo(w http.ResponseWriter, r *http.Request) {
+	todo, err := h.repo.Focused(r.Context())
+	if err != nil {
+		writeRepoError(w, r, err)
+		return
+	}
+
+	if todo == nil {
+		writeError(w, r, http.StatusNotFound, "No todo is focused")
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, todo)
+}
+
+// SnoozeTodo handles POST /api/todos/{id}/snooze
+// @Summary Snooze a todo
+// @Description Keep a todo out of the reminders list until the given time. Pass includeSnoozed=true to the reminders endpoint to see snoozed todos anyway.
+// @Tags todos
+// @Accept json
+// @Produce json
+// @Param id path string true "Todo ID, publicId or slug"
+// @Param request body models.SnoozeRequest true "Time to snooze until, in the future"
+// @Success 200 {object} models.Todo
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 415 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 504 {object} ErrorResponse
+// @Router /api/todos/{id}/snooze [post]
+func (h *TodoHandler) SnoozeTodo(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseTodoID(w, r, h.repo)
+	if !ok {
+		return
+	}
+
+	if !requireJSON(w, r) {
+		return
+	}
+
+	var req models.SnoozeRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.Until == nil {
+		writeError(w, r, http.StatusBadRequest, "until is required")
+		return
+	}
+	if !req.Until.After(time.Now()) {
+		writeError(w, r, http.StatusBadRequest, "until must be in the future")
+		return
+	}
+
+	todo, err := h.repo.Update(r.Context(), id, models.UpdateTodoRequest{SnoozedUntil: req.Until})
+	if err != nil {
+		writeRepoError(w, r, err)
+		return
+	}
+
+	if todo == nil {
+		writeError(w, r, http.StatusNotFound, "Todo not found")
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, todo)
+}
+
+// MoveTodo handles POST /api/todos/{id}/move
+// @Summary Move a todo
+// @Description Reorder a todo by placing it directly after another one, or first when after is omitted. List todos with sortBy=position to see the manual order.
+// @Tags todos
+// @Produce json
+// @Param id path string true "Todo ID, publicId or slug"
+// @Param after query int false "ID of the todo to place this one after"
+// @Success 200 {object} models.Todo
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 504 {object} ErrorResponse
+// @Router /api/todos/{id}/move [post]
+func (h *TodoHandler) MoveTodo(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseTodoID(w, r, h.repo)
+	if !ok {
+		return
+	}
+
+	var after *int64
+	if v := r.URL.Query().Get("after"); v != "" {
+		afterID, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid after")
+			return
+		}
+		after = &afterID
+	}
+
+	todo, err := h.repo.Move(r.Context(), id, after)
+	if errors.Is(err, database.ErrMoveAfterNotFound) {
+		writeError(w, r, http.StatusBadRequest, "Todo to move after not found")
+		return
+	}
+	if err != nil {
+		writeRepoError(w, r, err)
+		return
+	}
+
+	if todo == nil {
+		writeError(w, r, http.StatusNotFound, "Todo not found")
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, todo)
+}
+
+// maxBulkIDs caps the number of todos a single bulk request may touch
+const maxBulkIDs = 100
+
+// validateBulkIDs checks a list of ids from a bulk request and returns it
+// with duplicates removed, preserving order
+func validateBulkIDs(ids []models.FlexibleID) ([]int64, error) {
+	if len(ids) == 0 {
+		return nil, errors.New("ids must not be empty")
+	}
+	if len(ids) > maxBulkIDs {
+		return nil, fmt.Errorf("at most %d ids may be given", maxBulkIDs)
+	}
+
+	seen := make(map[int64]bool, len(ids))
+	unique := make([]int64, 0, len(ids))
+	for _, flexID := range ids {
+		id := int64(flexID)
+		if id <= 0 {
+			return nil, fmt.Errorf("invalid id: %d", id)
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		unique = append(unique, id)
+	}
+
+	return unique, nil
+}
+
+// BulkUpdateTodos handles PATCH /api/todos/bulk
+// @Summary Update the status of many todos
+// @Description Set the completed flag on many todos in one transaction
+// @Tags todos
+// @Accept json
+// @Produce json
+// @Param request body models.BulkUpdateRequest true "Todo ids and completion status"
+// @Success 200 {object} models.BulkUpdateResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 409 {object} BlockedResponse
+// @Failure 415 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 504 {object} ErrorResponse
+// @Router /api/todos/bulk [patch]
+func (h *TodoHandler) BulkUpdateTodos(w http.ResponseWriter, r *http.Request) {
+	if !requireJSON(w, r) {
+		return
+	}
+
+	var req models.BulkUpdateRequest
+	if err := decodeJSON(r, &req); err != nil {
+		if errors.Is(err, models.ErrInvalidID) {
+			writeError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	ids, err := validateBulkIDs(req.IDs)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if req.Completed == nil {
+		writeError(w, r, http.StatusBadRequest, "completed is required")
+		return
+	}
+
+	updated, notFound, err := h.repo.SetCompleted(r.Context(), ids, *req.Completed)
+	if err != nil {
+		writeRepoError(w, r, err)
+		return
+	}
+
+	if updated == nil {
+		updated = []models.Todo{}
+	}
+	if notFound == nil {
+		notFound = []int64{}
+	}
+
+	writeJSON(w, r, http.StatusOK, models.BulkUpdateResponse{Updated: updated, NotFound: notFound})
+}
+
+// BulkToggleTodos handles POST /api/todos/bulk-toggle
+// @Summary Toggle the status of many todos
+// @Description Flip the completed flag of each todo to its opposite in one transaction
+// @Tags todos
+// @Accept json
+// @Produce json
+// @Param request body models.BulkToggleRequest true "Todo ids"
+// @Success 200 {object} models.BulkUpdateResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 409 {object} BlockedResponse
+// @Failure 415 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 504 {object} ErrorResponse
+// @Router /api/todos/bulk-toggle [post]
+func (h *TodoHandler) BulkToggleTodos(w http.ResponseWriter, r *http.Request) {
+	if !requireJSON(w, r) {
+		return
+	}
+
+	var req models.BulkToggleRequest
+	if err := decodeJSON(r, &req); err != nil {
+		if errors.Is(err, models.ErrInvalidID) {
+			writeError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	ids, err := validateBulkIDs(req.IDs)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	updated, notFound, err := h.repo.ToggleCompleted(r.Context(), ids)
+	if err != nil {
+		writeRepoError(w, r, err)
+		return
+	}
+
+	if updated == nil {
+		updated = []models.Todo{}
+	}
+	if notFound == nil {
+		notFound = []int64{}
+	}
+
+	writeJSON(w, r, http.StatusOK, models.BulkUpdateResponse{Updated: updated, NotFound: notFound})
+}
+
+// BatchUpdateTodos handles PATCH /api/todos/batch
+// @Summary Update many todos
+// @Description Apply up to 100 partial updates, each an id with the fields to change as in PATCH /api/todos/{id}, in order and in one transaction. Ids that do not exist are listed in notFound while the rest are updated, unless atomic=true, in which case nothing is updated and 404 is returned. If any item is invalid nothing is updated and the errors are reported by index.
+// @Tags todos
+// @Accept json
+// @Produce json
+// @Param atomic query boolean false "Update nothing if any todo does not exist"
+// @Param request body []models.BatchUpdateItem true "Partial updates"
+// @Success 200 {object} models.BatchUpdateResponse
+// @Failure 400 {object} models.BatchUpdateResponse
+// @Failure 404 {object} models.BatchUpdateResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 415 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 504 {object} ErrorResponse
+// @Router /api/todos/batch [patch]
+func (h *TodoHandler) BatchUpdateTodos(w http.ResponseWriter, r *http.Request) {
+	atomic, err := parseBoolParam(r, "atomic")
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !requireJSON(w, r) {
+		return
+	}
+
+	var items []models.BatchUpdateItem
+	if err := decodeJSON(r, &items); err != nil {
+		if errors.Is(err, models.ErrInvalidID) || errors.Is(err, models.ErrMetadataNotObject) {
+			writeError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if len(items) == 0 {
+		writeError(w, r, http.StatusBadRequest, "updates must not be empty")
+		return
+	}
+	if len(items) > maxBatchSize {
+		writeError(w, r, http.StatusBadRequest, fmt.Sprintf("at most %d todos may be updated at once", maxBatchSize))
+		return
+	}
+
+	resp := models.BatchUpdateResponse{
+		Updated:  []models.Todo{},
+		NotFound: []int64{},
+		Errors:   []models.BatchError{},
+	}
+
+	for i, item := range items {
+		if item.ID <= 0 {
+			resp.Errors = append(resp.Errors, models.BatchError{Index: i, Error: "id must be a positive integer"})
+			continue
+		}
+		if err := validateUpdateTodo(item.UpdateTodoRequest); err != nil {
+			resp.Errors = append(resp.Errors, models.BatchError{Index: i, Error: err.Error()})
+			continue
+		}
+	}
+	if len(resp.Errors) > 0 {
+		writeJSON(w, r, http.StatusBadRequest, resp)
+		return
+	}
+
+	updated, notFound, err := h.repo.UpdateMany(r.Context(), items, atomic != nil && *atomic)
+	if errors.Is(err, database.ErrTodosNotFound) {
+		resp.NotFound = notFound
+		writeJSON(w, r, http.StatusNotFound, resp)
+		return
+	}
+	if err != nil {
+		writeRepoError(w, r, err)
+		return
+	}
+
+	if updated != nil {
+		resp.Updated = updated
+	}
+	if notFound != nil {
+		resp.NotFound = notFound
+	}
+
+	writeJSON(w, r, http.StatusOK, resp)
+}
+
+// BatchGetTodos handles POST /api/todos/batch-get
+// @Summary Get many todos by ID
+// @Description Get up to 100 todos in one request. Todos are returned in the order their ids were given and ids that do not exist are listed in missing.
+// @Tags todos
+// @Accept json
+// @Produce json
+// @Param request body models.BatchGetRequest true "Todo ids"
+// @Success 200 {object} models.BatchGetResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 415 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 504 {object} ErrorResponse
+// @Router /api/todos/batch-get [post]
+func (h *TodoHandler) BatchGetTodos(w http.ResponseWriter, r *http.Request) {
+	if !requireJSON(w, r) {
+		return
+	}
+
+	var req models.BatchGetRequest
+	if err := decodeJSON(r, &req); err != nil {
+		if errors.Is(err, models.ErrInvalidID) {
+			writeError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	ids, err := validateBulkIDs(req.IDs)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	todos, err := h.repo.GetByIDs(r.Context(), ids)
+	if err != nil {
+		writeRepoError(w, r, err)
+		return
+	}
+
+	found := make(map[int64]bool, len(todos))
+	for _, todo := range todos {
+		found[todo.ID] = true
+	}
+
+	resp := models.BatchGetResponse{Todos: todos, Missing: []int64{}}
+	if resp.Todos == nil {
+		resp.Todos = []models.Todo{}
+	}
+	for _, id := range ids {
+		if !found[id] {
+			resp.Missing = append(resp.Missing, id)
+		}
+	}
+
+	writeJSON(w, r, http.StatusOK, resp)
+}
+
+// maxBatchSize caps the number of todos a single batch create or update may
+// contain
+const maxBatchSize = 100
+
+// BatchCreateTodos handles POST /api/todos/batch
+// @Summary Create many todos
+// @Description Create up to 100 todos. In the default atomic mode every todo is created or, if any is invalid, none are and the errors are reported by index. In best-effort mode valid todos are created one by one and invalid ones are skipped.
+// @Tags todos
+// @Accept json
+// @Produce json
+// @Param mode query string false "atomic (default) or best-effort"
+// @Param request body models.BatchCreateRequest true "Todos to create"
+// @Success 200 {object} models.BatchCreateResponse "best-effort mode"
+// @Success 201 {object} models.BatchCreateResponse "atomic mode"
+// @Failure 400 {object} models.BatchCreateResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 415 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 504 {object} ErrorResponse
+// @Router /api/todos/batch [post]
+func (h *TodoHandler) BatchCreateTodos(w http.ResponseWriter, r *http.Request) {
+	mode := r.URL.Query().Get("mode")
+	if mode != "" && mode != "atomic" && mode != "best-effort" {
+		writeError(w, r, http.StatusBadRequest, "invalid mode: must be atomic or best-effort")
+		return
+	}
+
+	if !requireJSON(w, r) {
+		return
+	}
+
+	var req models.BatchCreateRequest
+	if err := decodeJSON(r, &req); err != nil {
+		if errors.Is(err, models.ErrMetadataNotObject) {
+			writeError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if len(req.Todos) == 0 {
+		writeError(w, r, http.StatusBadRequest, "todos must not be empty")
+		return
+	}
+	if len(req.Todos) > maxBatchSize {
+		writeError(w, r, http.StatusBadRequest, fmt.Sprintf("at most %d todos may be created at once", maxBatchSize))
+		return
+	}
+	for i := range req.Todos {
+		req.Todos[i] = h.defaults.Apply(req.Todos[i])
+	}
+
+	resp := models.BatchCreateResponse{
+		Created: []models.Todo{},
+		Errors:  []models.BatchError{},
+	}
+
+	if mode == "best-effort" {
+		// Each todo is created in its own transaction, so a failure only
+		// affects that item
+		for i, todo := range req.Todos {
+			if err := validateCreateTodo(todo); err != nil {
+				resp.Errors = append(resp.Errors, models.BatchError{Index: i, Error: err.Error()})
+				continue
+			}
+			created, err := h.repo.Create(r.Context(), todo)
+			if err != nil {
+				_, message := classifyError(err)
+				resp.Errors = append(resp.Errors, models.BatchError{Index: i, Error: message})
+				continue
+			}
+			resp.Created = append(resp.Created, *created)
+		}
+
+		writeJSON(w, r, http.StatusOK, resp)
+		return
+	}
+
+	for i, todo := range req.Todos {
+		if err := validateCreateTodo(todo); err != nil {
+			resp.Errors = append(resp.Errors, models.BatchError{Index: i, Error: err.Error()})
+		}
+	}
+	if len(resp.Errors) > 0 {
+		writeJSON(w, r, http.StatusBadRequest, resp)
+		return
+	}
+
+	created, err := h.repo.CreateMany(r.Context(), req.Todos)
+	if err != nil {
+		writeRepoError(w, r, err)
+		return
+	}
+	resp.Created = created
+
+	writeJSON(w, r, http.StatusCreated, resp)
+}
+
+// DeleteTodo handles DELETE /api/todos/{id}
+// @Summary Delete a todo
+// @Description Delete a todo item by ID. With ?return=true the deleted todo is returned.
+// @Tags todos
+// @Produce json
+// @Param id path string true "Todo ID, publicId or slug"
+// @Param return query boolean false "Return the deleted todo with status 200"
+// @Success 200 {object} models.Todo
+// @Success 204
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 504 {object} ErrorResponse
+// @Router /api/todos/{id} [delete]
+func (h *TodoHandler) DeleteTodo(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseTodoID(w, r, h.repo)
+	if !ok {
+		return
+	}
+
+	returnDeleted, err := parseBoolParam(r, "return")
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	todo, err := h.repo.Delete(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, r, http.StatusNotFound, "Todo not found")
+		return
+	}
+	if err != nil {
+		writeRepoError(w, r, err)
+		return
+	}
+
+	// Return the deleted todo when asked, e.g. to support undo
+	if returnDeleted != nil && *returnDeleted {
+		writeJSON(w, r, http.StatusOK, todo)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CompleteMatchingResponse reports how many todos were completed
+type CompleteMatchingResponse struct {
+	Updated int64 `json:"updated"`
+}
+
+// hasFilter reports whether opts narrows the todos down from every
+// published one
+func hasFilter(opts database.FilterOptions) bool {
+	return opts.Search != "" || opts.Completed != nil || opts.Starred != nil || opts.Drafts ||
+		opts.CreatedAfter != nil || opts.CreatedBefore != nil ||
+		opts.UpdatedAfter != nil || opts.UpdatedBefore != nil
+}
+
+// CompleteMatchingTodos handles POST /api/todos/complete-matching
+// @Summary Complete todos matching a filter
+// @Description Mark every incomplete todo matching the list endpoint's filters as completed, in one statement. Todos that depend on an incomplete todo are skipped and left out of the count. Without any filter the request is rejected unless all=true is passed, to avoid completing everything by accident.
+// @Tags todos
+// @Produce json
+// @Param search query string false "Search term for title and description"
+// @Param starred query bool false "Filter by starred status"
+// @Param draft query bool false "Complete drafts instead of published todos (default false)"
+// @Param createdAfter query string false "Only todos created at or after this RFC3339 time or YYYY-MM-DD date"
+// @Param createdBefore query string false "Only todos created before this RFC3339 time or YYYY-MM-DD date"
+// @Param updatedAfter query string false "Only todos updated at or after this RFC3339 time or YYYY-MM-DD date"
+// @Param updatedBefore query string false "Only todos updated before this RFC3339 time or YYYY-MM-DD date"
+// @Param tz query string false "IANA time zone for bare dates (default UTC)"
+// @Param all query bool false "Set to true to complete every todo when no filter is given"
+// @Success 200 {object} CompleteMatchingResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 504 {object} ErrorResponse
+// @Router /api/todos/complete-matching [post]
+func (h *TodoHandler) CompleteMatchingTodos(w http.ResponseWriter, r *http.Request) {
+	opts, err := parseFilterOptions(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	all, err := parseBoolParam(r, "all")
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !hasFilter(opts) && (all == nil || !*all) {
+		writeError(w, r, http.StatusBadRequest, "A filter is required; pass all=true to complete every todo")
+		return
+	}
+
+	updated, err := h.repo.CompleteMatching(r.Context(), opts)
+	if err != nil {
+		writeRepoError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, CompleteMatchingResponse{Updated: updated})
+}
+
+// DeleteAllTodos handles DELETE /api/todos
+// @Summary Delete all todos
+// @Description Delete every todo. Only available when the server runs with ALLOW_DELETE_ALL=true, and the request must send X-Confirm-Delete-All: true.
+// @Tags todos
+// @Produce json
+// @Param X-Confirm-Delete-All header string true "Must be true"
+// @Success 200 {object} DeleteAllResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 504 {object} ErrorResponse
+// @Router /api/todos [delete]
+func (h *TodoHandler) DeleteAllTodos(w http.ResponseWriter, r *http.Request) {
+	if !h.allowDeleteAll {
+		writeError(w, r, http.StatusForbidden, "Deleting all todos is disabled")
+		return
+	}
+	if r.Header.Get("X-Confirm-Delete-All") != "true" {
+		writeError(w, r, http.StatusForbidden, "X-Confirm-Delete-All: true is required to delete all todos")
+		return
+	}
+
+	deleted, err := h.repo.DeleteAll(r.Context())
+	if err != nil {
+		writeRepoError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, DeleteAllResponse{Deleted: deleted})
 }
@@ -2,17 +2,26 @@ package handlers
 
 import (
 	"bytes"
+	"context"
+	"database/sql"
+	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/larryhudson/go-todo-list-claude/internal/database"
 	"github.com/larryhudson/go-todo-list-claude/internal/models"
 )
 
 func setupTestDB(t *testing.T) *database.DB {
-	db, err := database.New(":memory:")
+	db, err := database.New(":memory:", database.DefaultConfig())
 	if err != nil {
 		t.Fatalf("Failed to create test database: %v", err)
 	}
@@ -24,6 +33,20 @@ func setupTestDB(t *testing.T) *database.DB {
 	return db
 }
 
+func newTestRepo(t *testing.T, db *database.DB) *database.TodoRepository {
+	repo, err := database.NewTodoRepository(db)
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := repo.Close(); err != nil {
+			t.Errorf("Failed to close repository: %v", err)
+		}
+	})
+
+	return repo
+}
+
 func TestGetAllTodos_Empty(t *testing.T) {
 	db := setupTestDB(t)
 	defer func() {
@@ -32,7 +55,7 @@ func TestGetAllTodos_Empty(t *testing.T) {
 		}
 	}()
 
-	repo := database.NewTodoRepository(db)
+	repo := newTestRepo(t, db)
 	handler := NewTodoHandler(repo)
 
 	req := httptest.NewRequest("GET", "/api/todos", nil)
@@ -62,7 +85,7 @@ func TestCreateTodo(t *testing.T) {
 		}
 	}()
 
-	repo := database.NewTodoRepository(db)
+	repo := newTestRepo(t, db)
 	handler := NewTodoHandler(repo)
 
 	reqBody := models.CreateTodoRequest{
@@ -72,6 +95,7 @@ func TestCreateTodo(t *testing.T) {
 
 	body, _ := json.Marshal(reqBody)
 	req := httptest.NewRequest("POST", "/api/todos", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 
 	handler.CreateTodo(w, req)
@@ -98,6 +122,67 @@ func TestCreateTodo(t *testing.T) {
 	}
 }
 
+func TestCreateTodo_Defaults(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewTodoHandler(repo)
+	handler.SetDefaults(models.TodoDefaults{Description: "Add details"})
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"omitted description gets the default", `{"title":"Quick"}`, "Add details"},
+		{"request description wins", `{"title":"Detailed","description":"Mine"}`, "Mine"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/todos", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			handler.CreateTodo(w, req)
+
+			if w.Code != http.StatusCreated {
+				t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+			}
+			var todo models.Todo
+			if err := json.NewDecoder(w.Body).Decode(&todo); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if todo.Description != tt.want {
+				t.Errorf("Expected description %q, got %q", tt.want, todo.Description)
+			}
+		})
+	}
+
+	// Batches get the same defaults
+	req := httptest.NewRequest("POST", "/api/todos/batch", strings.NewReader(`{"todos":[{"title":"One"},{"title":"Two","description":"Own"}]}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.BatchCreateTodos(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp models.BatchCreateResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(resp.Created) != 2 || resp.Created[0].Description != "Add details" || resp.Created[1].Description != "Own" {
+		t.Errorf("Expected descriptions [Add details Own], got %+v", resp.Created)
+	}
+}
+
 func TestCreateTodo_MissingTitle(t *testing.T) {
 	db := setupTestDB(t)
 	defer func() {
@@ -106,7 +191,7 @@ func TestCreateTodo_MissingTitle(t *testing.T) {
 		}
 	}()
 
-	repo := database.NewTodoRepository(db)
+	repo := newTestRepo(t, db)
 	handler := NewTodoHandler(repo)
 
 	reqBody := models.CreateTodoRequest{
@@ -115,6 +200,7 @@ func TestCreateTodo_MissingTitle(t *testing.T) {
 
 	body, _ := json.Marshal(reqBody)
 	req := httptest.NewRequest("POST", "/api/todos", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 
 	handler.CreateTodo(w, req)
@@ -132,11 +218,11 @@ func TestGetTodo(t *testing.T) {
 		}
 	}()
 
-	repo := database.NewTodoRepository(db)
+	repo := newTestRepo(t, db)
 	handler := NewTodoHandler(repo)
 
 	// Create a todo first
-	created, err := repo.Create(models.CreateTodoRequest{
+	created, err := repo.Create(context.Background(), models.CreateTodoRequest{
 		Title:       "Test Todo",
 		Description: "Test Description",
 	})
@@ -164,6 +250,260 @@ func TestGetTodo(t *testing.T) {
 	}
 }
 
+func TestCreateTodo_Metadata(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewTodoHandler(repo)
+
+	body := `{"title":"Tagged","metadata":{"color":"teal","external":{"jira":"OPS-7","ids":[1,2]}}}`
+	req := httptest.NewRequest("POST", "/api/todos", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.CreateTodo(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest("GET", "/api/todos/1", nil)
+	req.SetPathValue("id", "1")
+	w = httptest.NewRecorder()
+
+	handler.GetTodo(w, req)
+
+	var todo struct {
+		Metadata json.RawMessage `json:"metadata"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&todo); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if want := `{"color":"teal","external":{"ids":[1,2],"jira":"OPS-7"}}`; string(todo.Metadata) != want {
+		t.Errorf("Expected metadata %s, got %s", want, todo.Metadata)
+	}
+
+	for _, metadata := range []string{`["a"]`, `"teal"`, `3`} {
+		req := httptest.NewRequest("POST", "/api/todos", strings.NewReader(`{"title":"Bad","metadata":`+metadata+`}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		handler.CreateTodo(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", metadata, w.Code)
+		}
+		if !strings.Contains(w.Body.String(), models.ErrMetadataNotObject.Error()) {
+			t.Errorf("%s: expected an object error, got %s", metadata, w.Body.String())
+		}
+	}
+}
+
+func TestEstimateMinutes_Validation(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewTodoHandler(repo)
+
+	req := httptest.NewRequest("POST", "/api/todos", strings.NewReader(`{"title":"Too quick","estimateMinutes":-5}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.CreateTodo(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400 for a negative estimate, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("POST", "/api/todos", strings.NewReader(`{"title":"Plan trip","estimateMinutes":90}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+
+	handler.CreateTodo(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	tests := []struct {
+		contentType string
+		body        string
+		status      int
+		estimate    int64
+	}{
+		{"application/json", `{"estimateMinutes":-1}`, http.StatusBadRequest, 90},
+		{mergePatchType, `{"estimateMinutes":-1}`, http.StatusBadRequest, 90},
+		{"application/json", `{"estimateMinutes":45}`, http.StatusOK, 45},
+		{"application/json", `{"title":"Plan holiday"}`, http.StatusOK, 45},
+		{mergePatchType, `{"estimateMinutes":null}`, http.StatusOK, 0},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("PATCH", "/api/todos/1", strings.NewReader(tt.body))
+		req.SetPathValue("id", "1")
+		req.Header.Set("Content-Type", tt.contentType)
+		w := httptest.NewRecorder()
+
+		handler.UpdateTodo(w, req)
+
+		if w.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d", tt.body, tt.status, w.Code)
+		}
+
+		todo, err := repo.GetByID(context.Background(), 1)
+		if err != nil {
+			t.Fatalf("Failed to get todo: %v", err)
+		}
+		if todo.EstimateMinutes != tt.estimate {
+			t.Errorf("%s: expected estimate %d, got %d", tt.body, tt.estimate, todo.EstimateMinutes)
+		}
+	}
+}
+
+func TestUpsertTodo(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewTodoHandler(repo)
+	handler.SetDefaults(models.TodoDefaults{Description: "Add details"})
+
+	upsert := func(externalID, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PUT", "/api/todos/by-external/"+externalID, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.SetPathValue("externalId", externalID)
+		w := httptest.NewRecorder()
+		handler.UpsertTodo(w, req)
+		return w
+	}
+
+	w := upsert("ext-1", `{"title":"From sync","starred":true}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var created models.Todo
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if created.ExternalID == nil || *created.ExternalID != "ext-1" || !created.Starred || created.Description != "Add details" {
+		t.Errorf("Unexpected created todo: %+v", created)
+	}
+
+	w = upsert("ext-1", `{"title":"Updated from sync","completed":true}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var updated models.Todo
+	if err := json.NewDecoder(w.Body).Decode(&updated); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if updated.ID != created.ID || updated.Title != "Updated from sync" || !updated.Completed || updated.Starred {
+		t.Errorf("Unexpected updated todo: %+v", updated)
+	}
+	if updated.Description != "" {
+		t.Errorf("Expected a replaced todo not to get the default description, got %q", updated.Description)
+	}
+
+	for _, body := range []string{`{"description":"No title"}`, `{"title":"Negative","estimateMinutes":-1}`, `not json`} {
+		if w := upsert("ext-2", body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", body, w.Code)
+		}
+	}
+	if todo, err := repo.Search(context.Background(), database.FilterOptions{}); err != nil || len(todo) != 1 {
+		t.Errorf("Expected rejected upserts to create nothing, got %d todos, %v", len(todo), err)
+	}
+}
+
+func TestFocusTodo(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewTodoHandler(repo)
+
+	for _, title := range []string{"First", "Second"} {
+		if _, err := repo.Create(context.Background(), models.CreateTodoRequest{Title: title}); err != nil {
+			t.Fatalf("Failed to create todo: %v", err)
+		}
+	}
+
+	focus := func(method, id string) int {
+		req := httptest.NewRequest(method, "/api/todos/"+id+"/focus", nil)
+		req.SetPathValue("id", id)
+		w := httptest.NewRecorder()
+		if method == "DELETE" {
+			handler.UnfocusTodo(w, req)
+		} else {
+			handler.FocusTodo(w, req)
+		}
+		return w.Code
+	}
+	current := func() (int, models.Todo) {
+		req := httptest.NewRequest("GET", "/api/todos/focused", nil)
+		w := httptest.NewRecorder()
+		handler.GetFocusedTodo(w, req)
+
+		var todo models.Todo
+		if w.Code == http.StatusOK {
+			if err := json.NewDecoder(w.Body).Decode(&todo); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+		}
+		return w.Code, todo
+	}
+
+	if code, _ := current(); code != http.StatusNotFound {
+		t.Errorf("Expected status 404 with nothing focused, got %d", code)
+	}
+
+	// Focus moves from one todo to the other
+	for _, id := range []int64{1, 2} {
+		if code := focus("POST", strconv.FormatInt(id, 10)); code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", code)
+		}
+		code, todo := current()
+		if code != http.StatusOK || todo.ID != id {
+			t.Errorf("Expected todo %d to be focused, got %d %+v", id, code, todo)
+		}
+	}
+	first, err := repo.GetByID(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("Failed to get todo: %v", err)
+	}
+	if first.Focused {
+		t.Error("Expected focusing todo 2 to unfocus todo 1")
+	}
+
+	if code := focus("POST", "42"); code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a missing todo, got %d", code)
+	}
+
+	if code := focus("DELETE", "2"); code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", code)
+	}
+	if code, _ := current(); code != http.StatusNotFound {
+		t.Errorf("Expected status 404 after unfocusing, got %d", code)
+	}
+}
+
 func TestGetTodo_NotFound(t *testing.T) {
 	db := setupTestDB(t)
 	defer func() {
@@ -172,7 +512,7 @@ func TestGetTodo_NotFound(t *testing.T) {
 		}
 	}()
 
-	repo := database.NewTodoRepository(db)
+	repo := newTestRepo(t, db)
 	handler := NewTodoHandler(repo)
 
 	req := httptest.NewRequest("GET", "/api/todos/999", nil)
@@ -194,11 +534,11 @@ func TestUpdateTodo(t *testing.T) {
 		}
 	}()
 
-	repo := database.NewTodoRepository(db)
+	repo := newTestRepo(t, db)
 	handler := NewTodoHandler(repo)
 
 	// Create a todo first
-	_, err := repo.Create(models.CreateTodoRequest{
+	_, err := repo.Create(context.Background(), models.CreateTodoRequest{
 		Title:       "Test Todo",
 		Description: "Test Description",
 	})
@@ -215,6 +555,7 @@ func TestUpdateTodo(t *testing.T) {
 
 	body, _ := json.Marshal(reqBody)
 	req := httptest.NewRequest("PATCH", "/api/todos/1", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
 	req.SetPathValue("id", "1")
 	w := httptest.NewRecorder()
 
@@ -238,6 +579,57 @@ func TestUpdateTodo(t *testing.T) {
 	}
 }
 
+func TestUpdateTodo_IfUnmodifiedSince(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewTodoHandler(repo)
+
+	created, err := repo.Create(context.Background(), models.CreateTodoRequest{Title: "Original"})
+	if err != nil {
+		t.Fatalf("Failed to create todo: %v", err)
+	}
+
+	patch := func(title string, since time.Time) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(models.UpdateTodoRequest{Title: &title, IfUnmodifiedSince: &since})
+		req := httptest.NewRequest("PATCH", "/api/todos/1", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.SetPathValue("id", strconv.FormatInt(created.ID, 10))
+		w := httptest.NewRecorder()
+		handler.UpdateTodo(w, req)
+		return w
+	}
+
+	// Comparisons have millisecond precision, so let the clock move on
+	// before the first update
+	time.Sleep(5 * time.Millisecond)
+
+	// A client that read the latest version may update it
+	w := patch("Fresh", created.UpdatedAt)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 for a fresh update, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// Another client still holding the original version is rejected
+	w = patch("Stale", created.UpdatedAt)
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 for a stale update, got %d", w.Code)
+	}
+
+	todo, err := repo.GetByID(context.Background(), created.ID)
+	if err != nil {
+		t.Fatalf("Failed to get todo: %v", err)
+	}
+	if todo.Title != "Fresh" {
+		t.Errorf("Expected the stale update to change nothing, got title %q", todo.Title)
+	}
+}
+
 func TestDeleteTodo(t *testing.T) {
 	db := setupTestDB(t)
 	defer func() {
@@ -246,11 +638,11 @@ func TestDeleteTodo(t *testing.T) {
 		}
 	}()
 
-	repo := database.NewTodoRepository(db)
+	repo := newTestRepo(t, db)
 	handler := NewTodoHandler(repo)
 
 	// Create a todo first
-	_, err := repo.Create(models.CreateTodoRequest{
+	_, err := repo.Create(context.Background(), models.CreateTodoRequest{
 		Title:       "Test Todo",
 		Description: "Test Description",
 	})
@@ -269,7 +661,7 @@ func TestDeleteTodo(t *testing.T) {
 	}
 
 	// Verify it's deleted
-	todo, err := repo.GetByID(1)
+	todo, err := repo.GetByID(context.Background(), 1)
 	if err != nil {
 		t.Fatalf("Failed to get todo: %v", err)
 	}
@@ -287,19 +679,19 @@ func TestGetAllTodos_WithSearch(t *testing.T) {
 		}
 	}()
 
-	repo := database.NewTodoRepository(db)
+	repo := newTestRepo(t, db)
 	handler := NewTodoHandler(repo)
 
 	// Create multiple todos
-	_, _ = repo.Create(models.CreateTodoRequest{
+	_, _ = repo.Create(context.Background(), models.CreateTodoRequest{
 		Title:       "Buy groceries",
 		Description: "Milk, eggs, bread",
 	})
-	_, _ = repo.Create(models.CreateTodoRequest{
+	_, _ = repo.Create(context.Background(), models.CreateTodoRequest{
 		Title:       "Write report",
 		Description: "Q4 sales report",
 	})
-	_, _ = repo.Create(models.CreateTodoRequest{
+	_, _ = repo.Create(context.Background(), models.CreateTodoRequest{
 		Title:       "Call customer",
 		Description: "Follow up on order",
 	})
@@ -336,15 +728,15 @@ func TestGetAllTodos_WithSearchInDescription(t *testing.T) {
 		}
 	}()
 
-	repo := database.NewTodoRepository(db)
+	repo := newTestRepo(t, db)
 	handler := NewTodoHandler(repo)
 
 	// Create multiple todos
-	_, _ = repo.Create(models.CreateTodoRequest{
+	_, _ = repo.Create(context.Background(), models.CreateTodoRequest{
 		Title:       "Todo 1",
 		Description: "Contains search term",
 	})
-	_, _ = repo.Create(models.CreateTodoRequest{
+	_, _ = repo.Create(context.Background(), models.CreateTodoRequest{
 		Title:       "Todo 2",
 		Description: "Different description",
 	})
@@ -377,16 +769,16 @@ func TestGetAllTodos_FilterByCompleted(t *testing.T) {
 		}
 	}()
 
-	repo := database.NewTodoRepository(db)
+	repo := newTestRepo(t, db)
 	handler := NewTodoHandler(repo)
 
 	// Create todos
 	completed := true
-	_, _ = repo.Create(models.CreateTodoRequest{Title: "Todo 1"})
-	_, _ = repo.Create(models.CreateTodoRequest{Title: "Todo 2"})
+	_, _ = repo.Create(context.Background(), models.CreateTodoRequest{Title: "Todo 1"})
+	_, _ = repo.Create(context.Background(), models.CreateTodoRequest{Title: "Todo 2"})
 
 	// Mark first one as completed
-	_, err := repo.Update(1, models.UpdateTodoRequest{Completed: &completed})
+	_, err := repo.Update(context.Background(), 1, models.UpdateTodoRequest{Completed: &completed})
 	if err != nil {
 		t.Fatalf("Failed to update todo: %v", err)
 	}
@@ -423,16 +815,16 @@ func TestGetAllTodos_FilterByIncomplete(t *testing.T) {
 		}
 	}()
 
-	repo := database.NewTodoRepository(db)
+	repo := newTestRepo(t, db)
 	handler := NewTodoHandler(repo)
 
 	// Create todos
 	completed := true
-	_, _ = repo.Create(models.CreateTodoRequest{Title: "Todo 1"})
-	_, _ = repo.Create(models.CreateTodoRequest{Title: "Todo 2"})
+	_, _ = repo.Create(context.Background(), models.CreateTodoRequest{Title: "Todo 1"})
+	_, _ = repo.Create(context.Background(), models.CreateTodoRequest{Title: "Todo 2"})
 
 	// Mark first one as completed
-	_, err := repo.Update(1, models.UpdateTodoRequest{Completed: &completed})
+	_, err := repo.Update(context.Background(), 1, models.UpdateTodoRequest{Completed: &completed})
 	if err != nil {
 		t.Fatalf("Failed to update todo: %v", err)
 	}
@@ -469,13 +861,13 @@ func TestGetAllTodos_SortByTitle(t *testing.T) {
 		}
 	}()
 
-	repo := database.NewTodoRepository(db)
+	repo := newTestRepo(t, db)
 	handler := NewTodoHandler(repo)
 
 	// Create todos
-	_, _ = repo.Create(models.CreateTodoRequest{Title: "Zebra"})
-	_, _ = repo.Create(models.CreateTodoRequest{Title: "Apple"})
-	_, _ = repo.Create(models.CreateTodoRequest{Title: "Banana"})
+	_, _ = repo.Create(context.Background(), models.CreateTodoRequest{Title: "Zebra"})
+	_, _ = repo.Create(context.Background(), models.CreateTodoRequest{Title: "Apple"})
+	_, _ = repo.Create(context.Background(), models.CreateTodoRequest{Title: "Banana"})
 
 	// Test sort by title ascending
 	req := httptest.NewRequest("GET", "/api/todos?sortBy=title&sortOrder=asc", nil)
@@ -517,21 +909,21 @@ func TestGetAllTodos_CombinedFiltersAndSort(t *testing.T) {
 		}
 	}()
 
-	repo := database.NewTodoRepository(db)
+	repo := newTestRepo(t, db)
 	handler := NewTodoHandler(repo)
 
 	// Create todos
 	completed := true
-	_, _ = repo.Create(models.CreateTodoRequest{Title: "Buy milk", Description: "grocery item"})
-	_, _ = repo.Create(models.CreateTodoRequest{Title: "Buy bread", Description: "grocery item"})
-	_, _ = repo.Create(models.CreateTodoRequest{Title: "Write email", Description: "work task"})
+	_, _ = repo.Create(context.Background(), models.CreateTodoRequest{Title: "Buy milk", Description: "grocery item"})
+	_, _ = repo.Create(context.Background(), models.CreateTodoRequest{Title: "Buy bread", Description: "grocery item"})
+	_, _ = repo.Create(context.Background(), models.CreateTodoRequest{Title: "Write email", Description: "work task"})
 
 	// Mark first two as completed
-	_, err := repo.Update(1, models.UpdateTodoRequest{Completed: &completed})
+	_, err := repo.Update(context.Background(), 1, models.UpdateTodoRequest{Completed: &completed})
 	if err != nil {
 		t.Fatalf("Failed to update todo: %v", err)
 	}
-	_, err = repo.Update(2, models.UpdateTodoRequest{Completed: &completed})
+	_, err = repo.Update(context.Background(), 2, models.UpdateTodoRequest{Completed: &completed})
 	if err != nil {
 		t.Fatalf("Failed to update todo: %v", err)
 	}
@@ -564,3 +956,2876 @@ func TestGetAllTodos_CombinedFiltersAndSort(t *testing.T) {
 		t.Errorf("Expected second title 'Buy milk', got '%s'", todos[1].Title)
 	}
 }
+
+func TestGetAllTodos_Envelope(t *testing.T) {
+	handler := NewTodoHandler(database.NewMemoryTodoStore())
+	for _, title := range []string{"One", "Two", "Three"} {
+		if _, err := handler.repo.Create(context.Background(), models.CreateTodoRequest{Title: title}); err != nil {
+			t.Fatalf("Failed to create todo: %v", err)
+		}
+	}
+
+	get := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/todos?"+query, nil)
+		w := httptest.NewRecorder()
+		handler.GetAllTodos(w, req)
+		return w
+	}
+	type envelope struct {
+		Data []models.Todo `json:"data"`
+		Meta struct {
+			Total      int64   `json:"total"`
+			Limit      int     `json:"limit"`
+			NextCursor *string `json:"nextCursor"`
+		} `json:"meta"`
+	}
+	getEnveloped := func(query string) envelope {
+		w := get(query)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%q: expected status 200, got %d: %s", query, w.Code, w.Body.String())
+		}
+		var resp envelope
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("%q: failed to decode envelope: %v", query, err)
+		}
+		return resp
+	}
+
+	if body := get("").Body.String(); !strings.HasPrefix(body, "[") {
+		t.Errorf("Expected a bare array by default, got %s", body)
+	}
+
+	resp := getEnveloped("envelope=true")
+	if len(resp.Data) != 3 || resp.Meta.Total != 3 || resp.Meta.Limit != 0 || resp.Meta.NextCursor != nil {
+		t.Errorf("Unexpected unpaginated envelope: %+v", resp)
+	}
+
+	resp = getEnveloped("envelope=true&limit=2")
+	if len(resp.Data) != 2 || resp.Meta.Total != 3 || resp.Meta.Limit != 2 {
+		t.Errorf("Unexpected first page: %+v", resp)
+	}
+	if resp.Meta.NextCursor == nil || *resp.Meta.NextCursor == "" {
+		t.Fatalf("Expected a next cursor on the first page, got %v", resp.Meta.NextCursor)
+	}
+
+	resp = getEnveloped("envelope=true&limit=2&cursor=" + url.QueryEscape(*resp.Meta.NextCursor))
+	if len(resp.Data) != 1 || resp.Meta.Total != 3 {
+		t.Errorf("Unexpected last page: %+v", resp)
+	}
+	if resp.Meta.NextCursor == nil || *resp.Meta.NextCursor != "" {
+		t.Errorf("Expected an empty next cursor on the last page, got %v", resp.Meta.NextCursor)
+	}
+
+	// Fields are projected inside the envelope
+	w := get("envelope=true&fields=title")
+	if want := `{"data":[{"title":"Three"},{"title":"Two"},{"title":"One"}],"meta":{"total":3}}`; strings.TrimSpace(w.Body.String()) != want {
+		t.Errorf("Expected %s, got %s", want, w.Body.String())
+	}
+
+	handler.SetEnvelope(true)
+	if resp := getEnveloped(""); len(resp.Data) != 3 {
+		t.Errorf("Expected the server default to envelope lists, got %+v", resp)
+	}
+	if body := get("envelope=false").Body.String(); !strings.HasPrefix(body, "[") {
+		t.Errorf("Expected envelope=false to override the default, got %s", body)
+	}
+
+	if w := get("envelope=yes"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid envelope, got %d", w.Code)
+	}
+}
+
+func TestListEndpoints_Envelope(t *testing.T) {
+	handler := NewTodoHandler(database.NewMemoryTodoStore())
+	handler.SetEnvelope(true)
+	ctx := context.Background()
+
+	remindAt := time.Now().Add(time.Hour)
+	scheduledAt := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+	todo, err := handler.repo.Create(ctx, models.CreateTodoRequest{Title: "Call dentist", RemindAt: &remindAt, ScheduledAt: &scheduledAt})
+	if err != nil {
+		t.Fatalf("Failed to create todo: %v", err)
+	}
+	if err := handler.repo.RecordAccess(ctx, todo.ID); err != nil {
+		t.Fatalf("Failed to record access: %v", err)
+	}
+	if _, err := handler.repo.Create(ctx, models.CreateTodoRequest{Title: "Plan trip", Draft: true}); err != nil {
+		t.Fatalf("Failed to create todo: %v", err)
+	}
+
+	endpoints := []struct {
+		name    string
+		target  string
+		handler http.HandlerFunc
+	}{
+		{"drafts", "/api/todos/drafts", handler.GetDrafts},
+		{"recent", "/api/todos/recent", handler.GetRecent},
+		{"reminders", "/api/todos/reminders", handler.GetReminders},
+		{"schedule", "/api/todos/schedule?from=2030-01-06&to=2030-01-13", handler.GetSchedule},
+	}
+	for _, e := range endpoints {
+		t.Run(e.name, func(t *testing.T) {
+			get := func(query string) *httptest.ResponseRecorder {
+				target := e.target
+				if query != "" {
+					sep := "?"
+					if strings.Contains(target, "?") {
+						sep = "&"
+					}
+					target += sep + query
+				}
+				w := httptest.NewRecorder()
+				e.handler(w, httptest.NewRequest("GET", target, nil))
+				return w
+			}
+
+			var resp struct {
+				Data []models.Todo `json:"data"`
+				Meta ListMeta      `json:"meta"`
+			}
+			w := get("")
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("Failed to decode envelope: %v", err)
+			}
+			if len(resp.Data) != 1 || resp.Meta.Total != 1 {
+				t.Errorf("Expected an envelope holding 1 todo, got %+v", resp)
+			}
+
+			if body := get("envelope=false").Body.String(); !strings.HasPrefix(body, "[") {
+				t.Errorf("Expected envelope=false to override the default, got %s", body)
+			}
+			if w := get("envelope=yes"); w.Code != http.StatusBadRequest {
+				t.Errorf("Expected status 400 for an invalid envelope, got %d", w.Code)
+			}
+		})
+	}
+}
+
+func TestGetAllTodos_QueryTimeout(t *testing.T) {
+	cfg := database.DefaultConfig()
+	cfg.QueryTimeout = time.Nanosecond
+
+	db, err := database.New(":memory:", cfg)
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+
+	if err := db.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize test database: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewTodoHandler(repo)
+
+	req := httptest.NewRequest("GET", "/api/todos", nil)
+	w := httptest.NewRecorder()
+
+	handler.GetAllTodos(w, req)
+
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("Expected status 504, got %d", w.Code)
+	}
+}
+
+func TestGetAllTodos_CursorPagination(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewTodoHandler(repo)
+
+	for i := 1; i <= 5; i++ {
+		if _, err := repo.Create(context.Background(), models.CreateTodoRequest{Title: fmt.Sprintf("Todo %d", i)}); err != nil {
+			t.Fatalf("Failed to create todo: %v", err)
+		}
+	}
+
+	seen := make(map[int64]bool)
+	cursor := ""
+	pages := 0
+	for {
+		url := "/api/todos?limit=2"
+		if cursor != "" {
+			url += "&cursor=" + cursor
+		}
+		req := httptest.NewRequest("GET", url, nil)
+		w := httptest.NewRecorder()
+
+		handler.GetAllTodos(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", w.Code)
+		}
+
+		var todos []models.Todo
+		if err := json.NewDecoder(w.Body).Decode(&todos); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+
+		for _, todo := range todos {
+			if seen[todo.ID] {
+				t.Errorf("Todo %d returned on more than one page", todo.ID)
+			}
+			seen[todo.ID] = true
+		}
+
+		pages++
+		cursor = w.Header().Get("X-Next-Cursor")
+		if cursor == "" || pages > 5 {
+			break
+		}
+	}
+
+	if pages != 3 {
+		t.Errorf("Expected 3 pages, got %d", pages)
+	}
+	if len(seen) != 5 {
+		t.Errorf("Expected 5 distinct todos, got %d", len(seen))
+	}
+}
+
+func TestGetAllTodos_ClampsLimit(t *testing.T) {
+	handler := NewTodoHandler(database.NewMemoryTodoStore())
+	handler.SetMaxPageSize(3)
+
+	for i := 1; i <= 5; i++ {
+		if _, err := handler.repo.Create(context.Background(), models.CreateTodoRequest{Title: fmt.Sprintf("Todo %d", i)}); err != nil {
+			t.Fatalf("Failed to create todo: %v", err)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/api/todos?limit=100000", nil)
+	w := httptest.NewRecorder()
+
+	handler.GetAllTodos(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var todos []models.Todo
+	if err := json.NewDecoder(w.Body).Decode(&todos); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if len(todos) != 3 {
+		t.Errorf("Expected the limit to be clamped to 3 todos, got %d", len(todos))
+	}
+	if got := w.Header().Get("X-Max-Page-Size"); got != "3" {
+		t.Errorf("Expected X-Max-Page-Size 3, got %q", got)
+	}
+	if w.Header().Get("X-Next-Cursor") == "" {
+		t.Error("Expected a cursor for the remaining todos")
+	}
+}
+
+func TestGetAllTodos_TamperedCursor(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewTodoHandler(repo)
+	handler.SetCursorKey([]byte("secret"))
+
+	for _, title := range []string{"First", "Second", "Third"} {
+		if _, err := repo.Create(context.Background(), models.CreateTodoRequest{Title: title}); err != nil {
+			t.Fatalf("Failed to create todo: %v", err)
+		}
+	}
+
+	list := func(handler *TodoHandler, cursor string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/todos?limit=1&cursor="+url.QueryEscape(cursor), nil)
+		w := httptest.NewRecorder()
+		handler.GetAllTodos(w, req)
+		return w
+	}
+
+	req := httptest.NewRequest("GET", "/api/todos?limit=1", nil)
+	w := httptest.NewRecorder()
+	handler.GetAllTodos(w, req)
+	cursor := w.Header().Get("X-Next-Cursor")
+	if cursor == "" {
+		t.Fatal("Expected a next cursor")
+	}
+
+	// Point the cursor at another todo while keeping its signature
+	payload, sig, _ := strings.Cut(cursor, ".")
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		t.Fatalf("Failed to decode cursor: %v", err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatalf("Failed to decode cursor payload: %v", err)
+	}
+	fields["id"] = fields["id"].(float64) + 1
+	if data, err = json.Marshal(fields); err != nil {
+		t.Fatalf("Failed to encode cursor payload: %v", err)
+	}
+	edited := base64.RawURLEncoding.EncodeToString(data)
+
+	sameKey := NewTodoHandler(repo)
+	sameKey.SetCursorKey([]byte("secret"))
+
+	tests := []struct {
+		name    string
+		handler *TodoHandler
+		cursor  string
+		status  int
+	}{
+		{"genuine", handler, cursor, http.StatusOK},
+		{"another handler with the same key", sameKey, cursor, http.StatusOK},
+		{"garbage", handler, "not-a-real-cursor", http.StatusBadRequest},
+		{"unsigned", handler, payload, http.StatusBadRequest},
+		{"edited payload", handler, edited + "." + sig, http.StatusBadRequest},
+		{"another key", NewTodoHandler(repo), cursor, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if w := list(tt.handler, tt.cursor); w.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d: %s", tt.name, tt.status, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestGetAllTodos_SparseFields(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewTodoHandler(repo)
+
+	if _, err := repo.Create(context.Background(), models.CreateTodoRequest{Title: "Test Todo", Description: "Long description"}); err != nil {
+		t.Fatalf("Failed to create todo: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/api/todos?fields=id,title,completed", nil)
+	w := httptest.NewRecorder()
+
+	handler.GetAllTodos(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var todos []map[string]interface{}
+	if err := json.NewDecoder(w.Body).Decode(&todos); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if len(todos) != 1 {
+		t.Fatalf("Expected 1 todo, got %d", len(todos))
+	}
+
+	if len(todos[0]) != 3 {
+		t.Errorf("Expected 3 fields, got %v", todos[0])
+	}
+	for _, field := range []string{"id", "title", "completed"} {
+		if _, ok := todos[0][field]; !ok {
+			t.Errorf("Expected field %q in response", field)
+		}
+	}
+	if _, ok := todos[0]["description"]; ok {
+		t.Error("Expected description to be omitted")
+	}
+}
+
+func TestGetAllTodos_UnknownField(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewTodoHandler(repo)
+
+	req := httptest.NewRequest("GET", "/api/todos?fields=id,bogus", nil)
+	w := httptest.NewRecorder()
+
+	handler.GetAllTodos(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+}
+
+func TestPrettyJSON(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewTodoHandler(repo)
+
+	if _, err := repo.Create(context.Background(), models.CreateTodoRequest{Title: "Test Todo"}); err != nil {
+		t.Fatalf("Failed to create todo: %v", err)
+	}
+
+	wrapped := PrettyJSON(http.HandlerFunc(handler.GetAllTodos))
+
+	req := httptest.NewRequest("GET", "/api/todos?pretty=true", nil)
+	w := httptest.NewRecorder()
+	wrapped.ServeHTTP(w, req)
+
+	if !strings.Contains(w.Body.String(), "\n  {\n    \"id\": 1,") {
+		t.Errorf("Expected indented JSON, got %q", w.Body.String())
+	}
+
+	req = httptest.NewRequest("GET", "/api/todos", nil)
+	w = httptest.NewRecorder()
+	wrapped.ServeHTTP(w, req)
+
+	if strings.Count(w.Body.String(), "\n") != 1 {
+		t.Errorf("Expected compact JSON by default, got %q", w.Body.String())
+	}
+}
+
+func TestHeadTodo(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewTodoHandler(repo)
+
+	if _, err := repo.Create(context.Background(), models.CreateTodoRequest{Title: "Test Todo"}); err != nil {
+		t.Fatalf("Failed to create todo: %v", err)
+	}
+
+	// Compare against the GET response for the same todo
+	getReq := httptest.NewRequest("GET", "/api/todos/1", nil)
+	getReq.SetPathValue("id", "1")
+	getW := httptest.NewRecorder()
+	handler.GetTodo(getW, getReq)
+
+	req := httptest.NewRequest("HEAD", "/api/todos/1", nil)
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+
+	handler.HeadTodo(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("Expected empty body, got %q", w.Body.String())
+	}
+	if got, want := w.Header().Get("Content-Length"), strconv.Itoa(getW.Body.Len()); got != want {
+		t.Errorf("Expected Content-Length %s, got %s", want, got)
+	}
+}
+
+func TestHeadTodo_NotFound(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewTodoHandler(repo)
+
+	req := httptest.NewRequest("HEAD", "/api/todos/999", nil)
+	req.SetPathValue("id", "999")
+	w := httptest.NewRecorder()
+
+	handler.HeadTodo(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("Expected empty body, got %q", w.Body.String())
+	}
+}
+
+func TestHeadTodo_MatchesGet(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewTodoHandler(repo)
+
+	if _, err := repo.Create(context.Background(), models.CreateTodoRequest{Title: "Test Todo", Description: "Compare headers"}); err != nil {
+		t.Fatalf("Failed to create todo: %v", err)
+	}
+
+	snakeCase := func(next http.Handler) http.Handler { return SnakeCaseJSON(PrettyJSON(next)) }
+	tests := []struct {
+		name  string
+		query string
+		wrap  func(http.Handler) http.Handler
+	}{
+		{"compact", "", PrettyJSON},
+		{"pretty", "?pretty=true", PrettyJSON},
+		{"snake case", "", snakeCase},
+		{"snake case pretty", "?pretty=true", snakeCase},
+	}
+
+	etags := make(map[string]string)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serve := func(method string, h http.HandlerFunc) *httptest.ResponseRecorder {
+				req := httptest.NewRequest(method, "/api/todos/1"+tt.query, nil)
+				req.SetPathValue("id", "1")
+				w := httptest.NewRecorder()
+				tt.wrap(h).ServeHTTP(w, req)
+				return w
+			}
+			get := serve("GET", handler.GetTodo)
+			head := serve("HEAD", handler.HeadTodo)
+
+			if get.Code != http.StatusOK || head.Code != http.StatusOK {
+				t.Fatalf("Expected status 200 for GET and HEAD, got %d and %d", get.Code, head.Code)
+			}
+			if head.Body.Len() != 0 {
+				t.Errorf("Expected empty HEAD body, got %q", head.Body.String())
+			}
+			for _, name := range []string{"ETag", "Last-Modified", "Content-Type"} {
+				if got, want := head.Header().Get(name), get.Header().Get(name); got != want || want == "" {
+					t.Errorf("Expected HEAD %s %q to match GET, got %q", name, want, got)
+				}
+			}
+
+			length := head.Header().Get("Content-Length")
+			if want := strconv.Itoa(get.Body.Len()); length != want {
+				t.Errorf("Expected Content-Length %s, got %s", want, length)
+			}
+
+			etag := get.Header().Get("ETag")
+			if other, ok := etags[etag]; ok {
+				t.Errorf("Expected a different ETag from %s, both got %s", other, etag)
+			}
+			etags[etag] = tt.name
+		})
+	}
+}
+
+func TestGetTodo_LastModified(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewTodoHandler(repo)
+
+	todo, err := repo.Create(context.Background(), models.CreateTodoRequest{Title: "Test Todo"})
+	if err != nil {
+		t.Fatalf("Failed to create todo: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/api/todos/1", nil)
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+
+	handler.GetTodo(w, req)
+
+	lastModified := w.Header().Get("Last-Modified")
+	if want := todo.UpdatedAt.UTC().Format(http.TimeFormat); lastModified != want {
+		t.Errorf("Expected Last-Modified %q, got %q", want, lastModified)
+	}
+
+	// Not modified since the reported time
+	req = httptest.NewRequest("GET", "/api/todos/1", nil)
+	req.SetPathValue("id", "1")
+	req.Header.Set("If-Modified-Since", lastModified)
+	w = httptest.NewRecorder()
+
+	handler.GetTodo(w, req)
+
+	if w.Code != http.StatusNotModified {
+		t.Errorf("Expected status 304, got %d", w.Code)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("Expected empty body, got %q", w.Body.String())
+	}
+
+	// Modified since an earlier time
+	req = httptest.NewRequest("GET", "/api/todos/1", nil)
+	req.SetPathValue("id", "1")
+	req.Header.Set("If-Modified-Since", todo.UpdatedAt.Add(-time.Hour).UTC().Format(http.TimeFormat))
+	w = httptest.NewRecorder()
+
+	handler.GetTodo(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+}
+
+func TestBulkUpdateTodos(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewTodoHandler(repo)
+
+	for i := 1; i <= 3; i++ {
+		if _, err := repo.Create(context.Background(), models.CreateTodoRequest{Title: fmt.Sprintf("Todo %d", i)}); err != nil {
+			t.Fatalf("Failed to create todo: %v", err)
+		}
+	}
+
+	body := []byte(`{"ids":[1,3,42],"completed":true}`)
+	req := httptest.NewRequest("PATCH", "/api/todos/bulk", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.BulkUpdateTodos(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp models.BulkUpdateResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if len(resp.Updated) != 2 {
+		t.Fatalf("Expected 2 updated todos, got %d", len(resp.Updated))
+	}
+	for _, todo := range resp.Updated {
+		if !todo.Completed {
+			t.Errorf("Expected todo %d to be completed", todo.ID)
+		}
+	}
+
+	if len(resp.NotFound) != 1 || resp.NotFound[0] != 42 {
+		t.Errorf("Expected notFound [42], got %v", resp.NotFound)
+	}
+
+	todo, err := repo.GetByID(context.Background(), 2)
+	if err != nil {
+		t.Fatalf("Failed to get todo: %v", err)
+	}
+	if todo.Completed {
+		t.Error("Expected todo 2 to be unchanged")
+	}
+}
+
+func TestBulkUpdateTodos_StringIDs(t *testing.T) {
+	handler := NewTodoHandler(database.NewMemoryTodoStore())
+	store := handler.repo
+
+	for i := 1; i <= 3; i++ {
+		if _, err := store.Create(context.Background(), models.CreateTodoRequest{Title: fmt.Sprintf("Todo %d", i)}); err != nil {
+			t.Fatalf("Failed to create todo: %v", err)
+		}
+	}
+
+	// Numbers and numeric strings may be mixed
+	body := []byte(`{"ids":[1,"3"],"completed":true}`)
+	req := httptest.NewRequest("PATCH", "/api/todos/bulk", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.BulkUpdateTodos(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp models.BulkUpdateResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if len(resp.Updated) != 2 || resp.Updated[0].ID != 1 || resp.Updated[1].ID != 3 {
+		t.Errorf("Expected todos 1 and 3 to be updated, got %+v", resp.Updated)
+	}
+}
+
+func TestBulkToggleTodos(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewTodoHandler(repo)
+
+	for i := 1; i <= 4; i++ {
+		if _, err := repo.Create(context.Background(), models.CreateTodoRequest{Title: fmt.Sprintf("Todo %d", i)}); err != nil {
+			t.Fatalf("Failed to create todo: %v", err)
+		}
+	}
+	if _, _, err := repo.SetCompleted(context.Background(), []int64{1, 3}, true); err != nil {
+		t.Fatalf("Failed to complete todos: %v", err)
+	}
+
+	// Duplicates are only toggled once
+	body := []byte(`{"ids":[1,2,3,"2",42]}`)
+	req := httptest.NewRequest("POST", "/api/todos/bulk-toggle", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.BulkToggleTodos(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp models.BulkUpdateResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(resp.NotFound) != 1 || resp.NotFound[0] != 42 {
+		t.Errorf("Expected notFound [42], got %v", resp.NotFound)
+	}
+
+	want := map[int64]bool{1: false, 2: true, 3: false, 4: false}
+	if len(resp.Updated) != 3 {
+		t.Fatalf("Expected 3 updated todos, got %d", len(resp.Updated))
+	}
+	for _, todo := range resp.Updated {
+		if todo.Completed != want[todo.ID] {
+			t.Errorf("Expected todo %d in the response to have completed=%v", todo.ID, want[todo.ID])
+		}
+	}
+	for id, completed := range want {
+		todo, err := repo.GetByID(context.Background(), id)
+		if err != nil {
+			t.Fatalf("Failed to get todo: %v", err)
+		}
+		if todo.Completed != completed {
+			t.Errorf("Expected todo %d to have completed=%v", id, completed)
+		}
+	}
+
+	for _, body := range []string{`{"ids":[]}`, `{"ids":["abc"]}`, `{"ids":[0]}`} {
+		req := httptest.NewRequest("POST", "/api/todos/bulk-toggle", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		handler.BulkToggleTodos(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", body, w.Code)
+		}
+	}
+}
+
+func TestBatchUpdateTodos(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewTodoHandler(repo)
+
+	for i := 1; i <= 3; i++ {
+		if _, err := repo.Create(context.Background(), models.CreateTodoRequest{Title: fmt.Sprintf("Todo %d", i)}); err != nil {
+			t.Fatalf("Failed to create todo: %v", err)
+		}
+	}
+
+	batch := func(query, body string) (int, models.BatchUpdateResponse) {
+		req := httptest.NewRequest("PATCH", "/api/todos/batch"+query, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		handler.BatchUpdateTodos(w, req)
+
+		// Error responses decode to an empty BatchUpdateResponse
+		var resp models.BatchUpdateResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return w.Code, resp
+	}
+
+	// Mixed success: the missing todo is reported and the rest are updated
+	code, resp := batch("", `[{"id":1,"title":"Renamed"},{"id":42,"completed":true},{"id":"2","completed":true}]`)
+	if code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", code)
+	}
+	if len(resp.Updated) != 2 || resp.Updated[0].Title != "Renamed" || !resp.Updated[1].Completed {
+		t.Errorf("Unexpected updated todos: %+v", resp.Updated)
+	}
+	if len(resp.NotFound) != 1 || resp.NotFound[0] != 42 {
+		t.Errorf("Expected notFound [42], got %v", resp.NotFound)
+	}
+
+	// Atomic failure: nothing is updated when a todo is missing
+	code, resp = batch("?atomic=true", `[{"id":3,"title":"Lost"},{"id":42,"title":"Missing"}]`)
+	if code != http.StatusNotFound {
+		t.Fatalf("Expected status 404, got %d", code)
+	}
+	if len(resp.NotFound) != 1 || resp.NotFound[0] != 42 || len(resp.Updated) != 0 {
+		t.Errorf("Unexpected atomic response: %+v", resp)
+	}
+	todo, err := repo.GetByID(context.Background(), 3)
+	if err != nil {
+		t.Fatalf("Failed to get todo: %v", err)
+	}
+	if todo.Title != "Todo 3" {
+		t.Errorf("Expected the atomic batch to be rolled back, got title %q", todo.Title)
+	}
+
+	// Each item is validated before anything is applied
+	code, resp = batch("", `[{"id":3,"title":"Valid"},{"title":"No id"},{"id":3,"estimateMinutes":-1}]`)
+	if code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d", code)
+	}
+	if len(resp.Errors) != 2 || resp.Errors[0].Index != 1 || resp.Errors[1].Index != 2 {
+		t.Errorf("Expected errors for items 1 and 2, got %+v", resp.Errors)
+	}
+
+	for _, body := range []string{`[]`, `{"id":1}`, `[{"id":"abc"}]`} {
+		if code, _ := batch("", body); code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", body, code)
+		}
+	}
+	if code, _ := batch("?atomic=yes", `[{"id":1}]`); code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid atomic, got %d", code)
+	}
+}
+
+func TestTodoRoutes_PublicID(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewTodoHandler(repo)
+	comments := NewCommentHandler(database.NewCommentRepository(db), repo)
+
+	todo, err := repo.Create(context.Background(), models.CreateTodoRequest{Title: "By UUID"})
+	if err != nil {
+		t.Fatalf("Failed to create todo: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		method         string
+		id             string
+		body           string
+		handle         http.HandlerFunc
+		expectedStatus int
+	}{
+		{"get by public id", "GET", todo.PublicID, "", handler.GetTodo, http.StatusOK},
+		{"get by upper-case public id", "GET", strings.ToUpper(todo.PublicID), "", handler.GetTodo, http.StatusOK},
+		{"get by integer id", "GET", strconv.FormatInt(todo.ID, 10), "", handler.GetTodo, http.StatusOK},
+		{"update by public id", "PATCH", todo.PublicID, `{"completed":true}`, handler.UpdateTodo, http.StatusOK},
+		{"list comments by public id", "GET", todo.PublicID, "", comments.ListComments, http.StatusOK},
+		{"unknown public id", "GET", "00000000-0000-4000-8000-000000000000", "", handler.GetTodo, http.StatusNotFound},
+		{"get by slug", "GET", todo.Slug, "", handler.GetTodo, http.StatusOK},
+		{"unknown slug", "GET", "not-a-todo", "", handler.GetTodo, http.StatusNotFound},
+		{"no form", "GET", "not_an_id", "", handler.GetTodo, http.StatusBadRequest},
+		{"delete by public id", "DELETE", todo.PublicID, "", handler.DeleteTodo, http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "/api/todos/"+tt.id, strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		req.SetPathValue("id", tt.id)
+		w := httptest.NewRecorder()
+
+		tt.handle(w, req)
+
+		if w.Code != tt.expectedStatus {
+			t.Errorf("%s: expected status %d, got %d: %s", tt.name, tt.expectedStatus, w.Code, w.Body.String())
+			continue
+		}
+		if strings.HasPrefix(tt.name, "get") && w.Code == http.StatusOK {
+			var got models.Todo
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil || got.ID != todo.ID || got.PublicID != todo.PublicID {
+				t.Errorf("%s: expected todo %d with public id %s, got %+v, %v", tt.name, todo.ID, todo.PublicID, got, err)
+			}
+		}
+	}
+}
+
+func TestMoveTodo(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewTodoHandler(repo)
+
+	for i := 1; i <= 3; i++ {
+		if _, err := repo.Create(context.Background(), models.CreateTodoRequest{Title: fmt.Sprintf("Todo %d", i)}); err != nil {
+			t.Fatalf("Failed to create todo: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name           string
+		id             string
+		query          string
+		expectedStatus int
+	}{
+		{"after another todo", "3", "?after=1", http.StatusOK},
+		{"to the front", "2", "", http.StatusOK},
+		{"invalid id", "1.5", "", http.StatusBadRequest},
+		{"invalid after", "1", "?after=abc", http.StatusBadRequest},
+		{"missing after", "1", "?after=42", http.StatusBadRequest},
+		{"missing todo", "42", "", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/api/todos/"+tt.id+"/move"+tt.query, nil)
+		req.SetPathValue("id", tt.id)
+		w := httptest.NewRecorder()
+
+		handler.MoveTodo(w, req)
+
+		if w.Code != tt.expectedStatus {
+			t.Errorf("%s: expected status %d, got %d: %s", tt.name, tt.expectedStatus, w.Code, w.Body.String())
+		}
+	}
+
+	todos, err := repo.Search(context.Background(), database.FilterOptions{SortBy: "position", SortOrder: "asc"})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	var ids []int64
+	for _, todo := range todos {
+		ids = append(ids, todo.ID)
+	}
+	if fmt.Sprint(ids) != "[2 1 3]" {
+		t.Errorf("Expected order [2 1 3], got %v", ids)
+	}
+}
+
+func TestBatchGetTodos(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewTodoHandler(repo)
+
+	for i := 1; i <= 3; i++ {
+		if _, err := repo.Create(context.Background(), models.CreateTodoRequest{Title: fmt.Sprintf("Todo %d", i)}); err != nil {
+			t.Fatalf("Failed to create todo: %v", err)
+		}
+	}
+
+	body := []byte(`{"ids":[3,42,1,"2",3]}`)
+	req := httptest.NewRequest("POST", "/api/todos/batch-get", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.BatchGetTodos(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp models.BatchGetResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	var ids []int64
+	for _, todo := range resp.Todos {
+		ids = append(ids, todo.ID)
+	}
+	if fmt.Sprint(ids) != "[3 1 2]" {
+		t.Errorf("Expected todos [3 1 2] in request order, got %v", ids)
+	}
+	if len(resp.Missing) != 1 || resp.Missing[0] != 42 {
+		t.Errorf("Expected missing [42], got %v", resp.Missing)
+	}
+
+	// Requests over the cap are rejected
+	tooMany, _ := json.Marshal(map[string]interface{}{"ids": make([]int64, maxBulkIDs+1)})
+	req = httptest.NewRequest("POST", "/api/todos/batch-get", bytes.NewBuffer(tooMany))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+
+	handler.BatchGetTodos(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for too many ids, got %d", w.Code)
+	}
+}
+
+func TestBulkUpdateTodos_Validation(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewTodoHandler(repo)
+
+	tooMany := make([]int64, maxBulkIDs+1)
+	for i := range tooMany {
+		tooMany[i] = int64(i + 1)
+	}
+	tooManyBody, err := json.Marshal(map[string]interface{}{"ids": tooMany, "completed": true})
+	if err != nil {
+		t.Fatalf("Failed to marshal body: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"empty ids", `{"ids":[],"completed":true}`},
+		{"missing completed", `{"ids":[1]}`},
+		{"invalid id", `{"ids":[0],"completed":true}`},
+		{"too many ids", string(tooManyBody)},
+		{"malformed", `{"ids":`},
+		{"non-numeric string id", `{"ids":["abc"],"completed":true}`},
+		{"fractional id", `{"ids":[1.5],"completed":true}`},
+		{"boolean id", `{"ids":[true],"completed":true}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("PATCH", "/api/todos/bulk", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			handler.BulkUpdateTodos(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("Expected status 400, got %d", w.Code)
+			}
+		})
+	}
+}
+
+func TestGetAllTodos_Starred(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewTodoHandler(repo)
+
+	for i := 1; i <= 3; i++ {
+		if _, err := repo.Create(context.Background(), models.CreateTodoRequest{Title: fmt.Sprintf("Todo %d", i)}); err != nil {
+			t.Fatalf("Failed to create todo: %v", err)
+		}
+	}
+
+	// Star the oldest todo so it would normally sort last
+	starred := true
+	if _, err := repo.Update(context.Background(), 1, models.UpdateTodoRequest{Starred: &starred}); err != nil {
+		t.Fatalf("Failed to update todo: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/api/todos?starred=true", nil)
+	w := httptest.NewRecorder()
+	handler.GetAllTodos(w, req)
+
+	var todos []models.Todo
+	if err := json.NewDecoder(w.Body).Decode(&todos); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(todos) != 1 || todos[0].ID != 1 || !todos[0].Starred {
+		t.Errorf("Expected only starred todo 1, got %+v", todos)
+	}
+
+	req = httptest.NewRequest("GET", "/api/todos?sortBy=starred", nil)
+	w = httptest.NewRecorder()
+	handler.GetAllTodos(w, req)
+
+	todos = nil
+	if err := json.NewDecoder(w.Body).Decode(&todos); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(todos) != 3 {
+		t.Fatalf("Expected 3 todos, got %d", len(todos))
+	}
+	if todos[0].ID != 1 {
+		t.Errorf("Expected starred todo first, got todo %d", todos[0].ID)
+	}
+}
+
+func TestGetAllTodos_CreatedRange(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewTodoHandler(repo)
+
+	for _, title := range []string{"Old todo", "New todo"} {
+		if _, err := repo.Create(context.Background(), models.CreateTodoRequest{Title: title}); err != nil {
+			t.Fatalf("Failed to create todo: %v", err)
+		}
+	}
+
+	lastWeek := time.Now().Add(-7 * 24 * time.Hour)
+	if _, err := db.ExecContext(context.Background(), "UPDATE todos SET created_at = ? WHERE id = 1", lastWeek); err != nil {
+		t.Fatalf("Failed to backdate todo: %v", err)
+	}
+
+	cutoff := url.QueryEscape(time.Now().Add(-3 * 24 * time.Hour).Format(time.RFC3339))
+
+	tests := []struct {
+		query string
+		title string
+	}{
+		{"createdAfter=" + cutoff, "New todo"},
+		{"createdBefore=" + cutoff, "Old todo"},
+		{"createdBefore=" + cutoff + "&search=todo", "Old todo"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/api/todos?"+tt.query, nil)
+		w := httptest.NewRecorder()
+
+		handler.GetAllTodos(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d", tt.query, w.Code)
+		}
+
+		var todos []models.Todo
+		if err := json.NewDecoder(w.Body).Decode(&todos); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+
+		if len(todos) != 1 || todos[0].Title != tt.title {
+			t.Errorf("%s: expected only %q, got %+v", tt.query, tt.title, todos)
+		}
+	}
+}
+
+func TestGetAllTodos_InvalidDate(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewTodoHandler(repo)
+
+	req := httptest.NewRequest("GET", "/api/todos?updatedAfter=last-tuesday", nil)
+	w := httptest.NewRecorder()
+
+	handler.GetAllTodos(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+}
+
+func TestGetAllTodos_InvalidBoolFilter(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewTodoHandler(repo)
+
+	for _, query := range []string{"completed=maybe", "starred=1"} {
+		req := httptest.NewRequest("GET", "/api/todos?"+query, nil)
+		w := httptest.NewRecorder()
+
+		handler.GetAllTodos(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", query, w.Code)
+		}
+	}
+}
+
+func TestGetAllTodos_InvalidSort(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewTodoHandler(repo)
+
+	for _, query := range []string{"sortOrder=ascending", "sortBy=priority", "sortBy=title;DROP TABLE todos"} {
+		req := httptest.NewRequest("GET", "/api/todos?"+url.PathEscape(query), nil)
+		w := httptest.NewRecorder()
+
+		handler.GetAllTodos(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", query, w.Code)
+		}
+	}
+}
+
+func TestGetAllTodos_SortOrderCaseInsensitive(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewTodoHandler(repo)
+
+	for _, title := range []string{"Banana", "Apple", "Cherry"} {
+		if _, err := repo.Create(context.Background(), models.CreateTodoRequest{Title: title}); err != nil {
+			t.Fatalf("Failed to create todo: %v", err)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/api/todos?sortBy=title&sortOrder=ASC", nil)
+	w := httptest.NewRecorder()
+
+	handler.GetAllTodos(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var todos []models.Todo
+	if err := json.NewDecoder(w.Body).Decode(&todos); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if len(todos) != 3 || todos[0].Title != "Apple" || todos[2].Title != "Cherry" {
+		t.Errorf("Expected todos sorted by title ascending, got %+v", todos)
+	}
+}
+
+func TestDeleteTodo_ReturnDeleted(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewTodoHandler(repo)
+
+	if _, err := repo.Create(context.Background(), models.CreateTodoRequest{Title: "Undo me", Description: "Restore later"}); err != nil {
+		t.Fatalf("Failed to create todo: %v", err)
+	}
+
+	req := httptest.NewRequest("DELETE", "/api/todos/1?return=true", nil)
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+
+	handler.DeleteTodo(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var todo models.Todo
+	if err := json.NewDecoder(w.Body).Decode(&todo); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if todo.ID != 1 || todo.Title != "Undo me" || todo.Description != "Restore later" {
+		t.Errorf("Expected deleted todo in response, got %+v", todo)
+	}
+
+	deleted, err := repo.GetByID(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("Failed to get todo: %v", err)
+	}
+	if deleted != nil {
+		t.Error("Expected todo to be deleted")
+	}
+}
+
+func TestCompleteAndIncompleteTodo(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewTodoHandler(repo)
+
+	if _, err := repo.Create(context.Background(), models.CreateTodoRequest{Title: "Test Todo"}); err != nil {
+		t.Fatalf("Failed to create todo: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		handle    http.HandlerFunc
+		completed bool
+	}{
+		{"complete", handler.CompleteTodo, true},
+		{"incomplete", handler.IncompleteTodo, false},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/api/todos/1/"+tt.name, nil)
+		req.SetPathValue("id", "1")
+		w := httptest.NewRecorder()
+
+		tt.handle(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d", tt.name, w.Code)
+		}
+
+		var todo models.Todo
+		if err := json.NewDecoder(w.Body).Decode(&todo); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if todo.Completed != tt.completed {
+			t.Errorf("%s: expected completed %v, got %v", tt.name, tt.completed, todo.Completed)
+		}
+	}
+
+	req := httptest.NewRequest("POST", "/api/todos/999/complete", nil)
+	req.SetPathValue("id", "999")
+	w := httptest.NewRecorder()
+
+	handler.CompleteTodo(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
+
+func TestCountTodos(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewTodoHandler(repo)
+
+	for _, create := range []models.CreateTodoRequest{
+		{Title: "Buy milk", EstimateMinutes: 30},
+		{Title: "Buy bread", EstimateMinutes: 45},
+		{Title: "Walk dog", EstimateMinutes: 20},
+	} {
+		if _, err := repo.Create(context.Background(), create); err != nil {
+			t.Fatalf("Failed to create todo: %v", err)
+		}
+	}
+	completed := true
+	if _, err := repo.Update(context.Background(), 1, models.UpdateTodoRequest{Completed: &completed}); err != nil {
+		t.Fatalf("Failed to update todo: %v", err)
+	}
+
+	tests := []struct {
+		query     string
+		count     int64
+		remaining int64
+	}{
+		{"", 3, 65},
+		{"search=buy", 2, 45},
+		{"search=buy&completed=false", 1, 45},
+		{"completed=true", 1, 0},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/api/todos/count?"+tt.query, nil)
+		w := httptest.NewRecorder()
+
+		handler.CountTodos(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("%q: expected status 200, got %d", tt.query, w.Code)
+		}
+
+		var resp CountResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if resp.Count != tt.count {
+			t.Errorf("%q: expected count %d, got %d", tt.query, tt.count, resp.Count)
+		}
+		if resp.RemainingMinutes != tt.remaining {
+			t.Errorf("%q: expected remainingMinutes %d, got %d", tt.query, tt.remaining, resp.RemainingMinutes)
+		}
+	}
+}
+
+func TestGetSchedule(t *testing.T) {
+	store := database.NewMemoryTodoStore()
+	handler := NewTodoHandler(store)
+
+	at := func(v string) *time.Time {
+		t, _ := time.Parse(time.RFC3339, v)
+		return &t
+	}
+	for _, req := range []models.CreateTodoRequest{
+		{Title: "Afternoon", ScheduledAt: at("2030-01-07T14:00:00Z")},
+		{Title: "Morning", ScheduledAt: at("2030-01-07T09:00:00Z")},
+		{Title: "Next day", ScheduledAt: at("2030-01-08T09:00:00Z")},
+		{Title: "Unscheduled"},
+	} {
+		if _, err := store.Create(context.Background(), req); err != nil {
+			t.Fatalf("Failed to create todo: %v", err)
+		}
+	}
+
+	tests := []struct {
+		query  string
+		titles []string
+	}{
+		{"from=2030-01-07&to=2030-01-08", []string{"Morning", "Afternoon"}},
+		{"from=2030-01-07T12:00:00Z&to=2030-01-09", []string{"Afternoon", "Next day"}},
+		{"from=2030-01-07&to=2030-01-08&tz=Pacific/Honolulu", []string{"Afternoon", "Next day"}},
+		{"from=2030-02-01&to=2030-03-01", nil},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/api/todos/schedule?"+tt.query, nil)
+		w := httptest.NewRecorder()
+
+		handler.GetSchedule(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("%q: expected status 200, got %d: %s", tt.query, w.Code, w.Body.String())
+		}
+
+		var todos []models.Todo
+		if err := json.NewDecoder(w.Body).Decode(&todos); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		var titles []string
+		for _, todo := range todos {
+			titles = append(titles, todo.Title)
+		}
+		if strings.Join(titles, ",") != strings.Join(tt.titles, ",") {
+			t.Errorf("%q: expected %v, got %v", tt.query, tt.titles, titles)
+		}
+	}
+
+	for _, query := range []string{
+		"",
+		"from=2030-01-07",
+		"to=2030-01-08",
+		"from=monday&to=2030-01-08",
+		"from=2030-01-08&to=2030-01-07",
+		"from=2030-01-07&to=2030-01-07",
+	} {
+		req := httptest.NewRequest("GET", "/api/todos/schedule?"+query, nil)
+		w := httptest.NewRecorder()
+
+		handler.GetSchedule(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected status 400, got %d", query, w.Code)
+		}
+	}
+}
+
+func TestGetReminders(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewTodoHandler(repo)
+
+	now := time.Now()
+	at := func(d time.Duration) *time.Time {
+		remindAt := now.Add(d)
+		return &remindAt
+	}
+
+	for _, req := range []models.CreateTodoRequest{
+		{Title: "In two hours", RemindAt: at(2 * time.Hour)},
+		{Title: "In one hour", RemindAt: at(time.Hour)},
+		{Title: "In two days", RemindAt: at(48 * time.Hour)},
+		{Title: "Already passed", RemindAt: at(-time.Hour)},
+		{Title: "No reminder"},
+		{Title: "Done", RemindAt: at(time.Hour)},
+	} {
+		todo, err := repo.Create(context.Background(), req)
+		if err != nil {
+			t.Fatalf("Failed to create todo: %v", err)
+		}
+		if req.Title == "Done" {
+			completed := true
+			if _, err := repo.Update(context.Background(), todo.ID, models.UpdateTodoRequest{Completed: &completed}); err != nil {
+				t.Fatalf("Failed to update todo: %v", err)
+			}
+		}
+	}
+
+	tests := []struct {
+		query  string
+		titles []string
+	}{
+		{"", []string{"In one hour", "In two hours"}},
+		{"within=90m", []string{"In one hour"}},
+		{"within=72h", []string{"In one hour", "In two hours", "In two days"}},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/api/todos/reminders?"+tt.query, nil)
+		w := httptest.NewRecorder()
+
+		handler.GetReminders(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("%q: expected status 200, got %d", tt.query, w.Code)
+		}
+
+		var todos []models.Todo
+		if err := json.NewDecoder(w.Body).Decode(&todos); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		var titles []string
+		for _, todo := range todos {
+			titles = append(titles, todo.Title)
+		}
+		if strings.Join(titles, ",") != strings.Join(tt.titles, ",") {
+			t.Errorf("%q: expected %v, got %v", tt.query, tt.titles, titles)
+		}
+	}
+
+	for _, within := range []string{"tomorrow", "-1h", "0s"} {
+		req := httptest.NewRequest("GET", "/api/todos/reminders?within="+within, nil)
+		w := httptest.NewRecorder()
+
+		handler.GetReminders(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("within=%s: expected status 400, got %d", within, w.Code)
+		}
+	}
+}
+
+func TestSnoozeTodo(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewTodoHandler(repo)
+
+	remindAt := time.Now().Add(time.Hour)
+	for _, title := range []string{"Water plants", "Pay rent"} {
+		if _, err := repo.Create(context.Background(), models.CreateTodoRequest{Title: title, RemindAt: &remindAt}); err != nil {
+			t.Fatalf("Failed to create todo: %v", err)
+		}
+	}
+
+	snooze := func(id, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/todos/"+id+"/snooze", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.SetPathValue("id", id)
+		w := httptest.NewRecorder()
+		handler.SnoozeTodo(w, req)
+		return w
+	}
+	reminders := func(query string) []string {
+		req := httptest.NewRequest("GET", "/api/todos/reminders?"+query, nil)
+		w := httptest.NewRecorder()
+		handler.GetReminders(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", w.Code)
+		}
+		var todos []models.Todo
+		if err := json.NewDecoder(w.Body).Decode(&todos); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		var titles []string
+		for _, todo := range todos {
+			titles = append(titles, todo.Title)
+		}
+		return titles
+	}
+
+	until := time.Now().Add(2 * time.Hour).UTC().Format(time.RFC3339)
+	w := snooze("1", `{"until":"`+until+`"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var todo models.Todo
+	if err := json.NewDecoder(w.Body).Decode(&todo); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if todo.SnoozedUntil == nil || todo.SnoozedUntil.UTC().Format(time.RFC3339) != until {
+		t.Errorf("Expected snoozedUntil %s, got %v", until, todo.SnoozedUntil)
+	}
+
+	if got := reminders(""); strings.Join(got, ",") != "Pay rent" {
+		t.Errorf("Expected the snoozed todo to be left out, got %v", got)
+	}
+	if got := reminders("includeSnoozed=true"); len(got) != 2 {
+		t.Errorf("Expected includeSnoozed to return both todos, got %v", got)
+	}
+
+	past := time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+	tests := []struct {
+		id   string
+		body string
+		code int
+	}{
+		{"2", `{"until":"` + past + `"}`, http.StatusBadRequest},
+		{"2", `{}`, http.StatusBadRequest},
+		{"2", `{"until":"tomorrow"}`, http.StatusBadRequest},
+		{"99", `{"until":"` + until + `"}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if w := snooze(tt.id, tt.body); w.Code != tt.code {
+			t.Errorf("%s %s: expected status %d, got %d", tt.id, tt.body, tt.code, w.Code)
+		}
+	}
+}
+
+func TestUpdateTodo_RemindAt(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewTodoHandler(repo)
+
+	if _, err := repo.Create(context.Background(), models.CreateTodoRequest{Title: "Call dentist"}); err != nil {
+		t.Fatalf("Failed to create todo: %v", err)
+	}
+
+	req := httptest.NewRequest("PATCH", "/api/todos/1", strings.NewReader(`{"remindAt":"2030-01-02T09:30:00Z"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+
+	handler.UpdateTodo(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var todo models.Todo
+	if err := json.NewDecoder(w.Body).Decode(&todo); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	want := time.Date(2030, 1, 2, 9, 30, 0, 0, time.UTC)
+	if todo.RemindAt == nil || !todo.RemindAt.Equal(want) {
+		t.Errorf("Expected remindAt %v, got %v", want, todo.RemindAt)
+	}
+}
+
+func TestUpdateTodo_MergePatchMetadata(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewTodoHandler(repo)
+
+	_, err := repo.Create(context.Background(), models.CreateTodoRequest{
+		Title: "Paint fence",
+		Metadata: models.Metadata{
+			"color": "white",
+			"owner": "sam",
+			"paint": map[string]any{"brand": "Acme", "litres": 2.0},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create todo: %v", err)
+	}
+
+	patch := func(body string) (*httptest.ResponseRecorder, models.Todo) {
+		req := httptest.NewRequest("PATCH", "/api/todos/1", strings.NewReader(body))
+		req.Header.Set("Content-Type", mergePatchType)
+		req.SetPathValue("id", "1")
+		w := httptest.NewRecorder()
+		handler.UpdateTodo(w, req)
+
+		var todo models.Todo
+		if w.Code == http.StatusOK {
+			if err := json.Unmarshal(w.Body.Bytes(), &todo); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+		}
+		return w, todo
+	}
+
+	// Members are merged recursively and null removes one
+	w, todo := patch(`{"metadata":{"color":"red","owner":null,"paint":{"litres":null,"finish":"gloss"}}}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	want := models.Metadata{
+		"color": "red",
+		"paint": map[string]any{"brand": "Acme", "finish": "gloss"},
+	}
+	if !reflect.DeepEqual(todo.Metadata, want) {
+		t.Errorf("Expected metadata %v, got %v", want, todo.Metadata)
+	}
+
+	// A member that is not an object is replaced by the patch's object
+	if _, todo = patch(`{"metadata":{"color":{"name":"red"}}}`); !reflect.DeepEqual(todo.Metadata["color"], map[string]any{"name": "red"}) {
+		t.Errorf("Expected color to become an object, got %v", todo.Metadata["color"])
+	}
+
+	if w, _ := patch(`{"metadata":"red"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for metadata that is not an object, got %d", w.Code)
+	}
+
+	// null removes all of it
+	if _, todo = patch(`{"metadata":null}`); todo.Metadata != nil {
+		t.Errorf("Expected metadata to be removed, got %v", todo.Metadata)
+	}
+}
+
+func TestUpdateTodo_MergePatch(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewTodoHandler(repo)
+
+	remindAt := time.Date(2030, 1, 2, 9, 30, 0, 0, time.UTC)
+	created, err := repo.Create(context.Background(), models.CreateTodoRequest{
+		Title:       "Call dentist",
+		Description: "Ask about Tuesday",
+		RemindAt:    &remindAt,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create todo: %v", err)
+	}
+
+	patch := func(contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PATCH", "/api/todos/1", strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		req.SetPathValue("id", "1")
+		w := httptest.NewRecorder()
+		handler.UpdateTodo(w, req)
+		return w
+	}
+
+	// null clears the field; absent fields are left untouched
+	w := patch("application/merge-patch+json", `{"remindAt":null,"completed":true}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var todo models.Todo
+	if err := json.NewDecoder(w.Body).Decode(&todo); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if todo.RemindAt != nil {
+		t.Errorf("Expected remindAt to be cleared, got %v", todo.RemindAt)
+	}
+	if !todo.Completed {
+		t.Error("Expected todo to be completed")
+	}
+	if todo.Title != created.Title || todo.Description != created.Description {
+		t.Errorf("Expected title and description unchanged, got %q and %q", todo.Title, todo.Description)
+	}
+
+	w = patch("application/merge-patch+json; charset=utf-8", `{"description":null}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	todo = models.Todo{}
+	if err := json.NewDecoder(w.Body).Decode(&todo); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if todo.Description != "" {
+		t.Errorf("Expected description to be cleared, got %q", todo.Description)
+	}
+
+	// Plain JSON keeps its existing semantics, where null means "no change"
+	if _, err := repo.Update(context.Background(), created.ID, models.UpdateTodoRequest{RemindAt: &remindAt}); err != nil {
+		t.Fatalf("Failed to set remindAt: %v", err)
+	}
+	w = patch("application/json", `{"remindAt":null}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	todo = models.Todo{}
+	if err := json.NewDecoder(w.Body).Decode(&todo); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if todo.RemindAt == nil || !todo.RemindAt.Equal(remindAt) {
+		t.Errorf("Expected remindAt %v to be kept, got %v", remindAt, todo.RemindAt)
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+	}{
+		{"null title", "application/merge-patch+json", `{"title":null}`, http.StatusBadRequest},
+		{"null completed", "application/merge-patch+json", `{"completed":null}`, http.StatusBadRequest},
+		{"read-only field", "application/merge-patch+json", `{"id":5}`, http.StatusBadRequest},
+		{"wrong type", "application/merge-patch+json", `{"starred":"yes"}`, http.StatusBadRequest},
+		{"not an object", "application/merge-patch+json", `["title"]`, http.StatusBadRequest},
+		{"unsupported type", "text/plain", `{"title":"x"}`, http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := patch(tt.contentType, tt.body)
+			if w.Code != tt.status {
+				t.Errorf("Expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	got, err := repo.GetByID(context.Background(), created.ID)
+	if err != nil {
+		t.Fatalf("Failed to get todo: %v", err)
+	}
+	if got.Title != created.Title {
+		t.Errorf("Expected rejected patches to leave title %q, got %q", created.Title, got.Title)
+	}
+}
+
+func TestGetRandomTodo(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewTodoHandler(repo)
+
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.GetRandomTodo(w, httptest.NewRequest("GET", "/api/todos/random", nil))
+		return w
+	}
+
+	for _, title := range []string{"Done", "Still open"} {
+		if _, err := repo.Create(context.Background(), models.CreateTodoRequest{Title: title}); err != nil {
+			t.Fatalf("Failed to create todo: %v", err)
+		}
+	}
+	if _, _, err := repo.SetCompleted(context.Background(), []int64{1}, true); err != nil {
+		t.Fatalf("Failed to complete todo: %v", err)
+	}
+
+	for range 10 {
+		w := get()
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var todo models.Todo
+		if err := json.NewDecoder(w.Body).Decode(&todo); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if todo.Completed || todo.Title != "Still open" {
+			t.Fatalf("Expected the incomplete todo, got %+v", todo)
+		}
+	}
+
+	if _, _, err := repo.SetCompleted(context.Background(), []int64{2}, true); err != nil {
+		t.Fatalf("Failed to complete todo: %v", err)
+	}
+	if w := get(); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 with every todo completed, got %d", w.Code)
+	}
+}
+
+func TestDrafts(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewTodoHandler(repo)
+
+	for _, body := range []string{`{"title":"Published"}`, `{"title":"Idea","draft":true}`} {
+		req := httptest.NewRequest("POST", "/api/todos", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handler.CreateTodo(w, req)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+		}
+	}
+
+	list := func(handle http.HandlerFunc, target string) []string {
+		t.Helper()
+		w := httptest.NewRecorder()
+		handle(w, httptest.NewRequest("GET", target, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s: expected status 200, got %d: %s", target, w.Code, w.Body.String())
+		}
+		var todos []models.Todo
+		if err := json.NewDecoder(w.Body).Decode(&todos); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		titles := make([]string, len(todos))
+		for i, todo := range todos {
+			titles[i] = todo.Title
+		}
+		return titles
+	}
+
+	if got := list(handler.GetAllTodos, "/api/todos"); len(got) != 1 || got[0] != "Published" {
+		t.Errorf("Expected drafts to be hidden by default, got %v", got)
+	}
+	if got := list(handler.GetAllTodos, "/api/todos?draft=true&limit=10"); len(got) != 1 || got[0] != "Idea" {
+		t.Errorf("Expected only the draft with ?draft=true, got %v", got)
+	}
+	if got := list(handler.GetDrafts, "/api/todos/drafts"); len(got) != 1 || got[0] != "Idea" {
+		t.Errorf("Expected only the draft from /drafts, got %v", got)
+	}
+
+	req := httptest.NewRequest("POST", "/api/todos/2/publish", nil)
+	req.SetPathValue("id", "2")
+	w := httptest.NewRecorder()
+	handler.PublishTodo(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var todo models.Todo
+	if err := json.NewDecoder(w.Body).Decode(&todo); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if todo.Draft {
+		t.Error("Expected the published todo not to be a draft")
+	}
+
+	if got := list(handler.GetAllTodos, "/api/todos"); len(got) != 2 {
+		t.Errorf("Expected both todos after publishing, got %v", got)
+	}
+	if got := list(handler.GetDrafts, "/api/todos/drafts"); len(got) != 0 {
+		t.Errorf("Expected no drafts after publishing, got %v", got)
+	}
+
+	req = httptest.NewRequest("POST", "/api/todos/99/publish", nil)
+	req.SetPathValue("id", "99")
+	w = httptest.NewRecorder()
+	handler.PublishTodo(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
+
+func TestGetAllTodos_SortByRelevance(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewTodoHandler(repo)
+
+	// The description-only match is newer, so it would come first by default
+	for _, req := range []models.CreateTodoRequest{
+		{Title: "Call the plumber"},
+		{Title: "Weekend", Description: "Remind the plumber about Monday"},
+	} {
+		if _, err := repo.Create(context.Background(), req); err != nil {
+			t.Fatalf("Failed to create todo: %v", err)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	handler.GetAllTodos(w, httptest.NewRequest("GET", "/api/todos?search=plumber&sortBy=relevance", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var todos []models.Todo
+	if err := json.NewDecoder(w.Body).Decode(&todos); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(todos) != 2 || todos[0].Title != "Call the plumber" {
+		t.Errorf("Expected the title match before the description match, got %+v", todos)
+	}
+
+	w = httptest.NewRecorder()
+	handler.GetAllTodos(w, httptest.NewRequest("GET", "/api/todos?sortBy=relevance", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 without search, got %d", w.Code)
+	}
+}
+
+func TestGetTodo_TrackAccess(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewTodoHandler(repo)
+
+	for _, title := range []string{"Viewed", "Not viewed"} {
+		if _, err := repo.Create(context.Background(), models.CreateTodoRequest{Title: title}); err != nil {
+			t.Fatalf("Failed to create todo: %v", err)
+		}
+	}
+
+	get := func() {
+		req := httptest.NewRequest("GET", "/api/todos/1", nil)
+		req.SetPathValue("id", "1")
+		w := httptest.NewRecorder()
+		handler.GetTodo(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+	}
+	lastAccessed := func() sql.NullString {
+		var at sql.NullString
+		if err := db.QueryRowContext(context.Background(), "SELECT last_accessed_at FROM todos WHERE id = 1").Scan(&at); err != nil {
+			t.Fatalf("Failed to read last_accessed_at: %v", err)
+		}
+		return at
+	}
+
+	// Tracking is off by default
+	get()
+	time.Sleep(20 * time.Millisecond)
+	if at := lastAccessed(); at.Valid {
+		t.Fatalf("Expected no access to be recorded, got %v", at.String)
+	}
+
+	// The access is recorded asynchronously, so wait for it
+	handler.SetTrackAccess(true)
+	get()
+	deadline := time.Now().Add(2 * time.Second)
+	for !lastAccessed().Valid {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected last_accessed_at to be set")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	w := httptest.NewRecorder()
+	handler.GetRecent(w, httptest.NewRequest("GET", "/api/todos/recent?limit=10", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var todos []models.Todo
+	if err := json.NewDecoder(w.Body).Decode(&todos); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(todos) != 1 || todos[0].Title != "Viewed" {
+		t.Errorf("Expected only the viewed todo, got %+v", todos)
+	}
+}
+
+func TestGetAllTodos_TimeZone(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewTodoHandler(repo)
+
+	// 03:30 UTC on March 10th is still the evening of March 9th in New York
+	// (UTC-5), so the two todos fall on different local days
+	created := map[string]time.Time{
+		"Late evening": time.Date(2024, 3, 10, 3, 30, 0, 0, time.UTC),
+		"Next morning": time.Date(2024, 3, 10, 13, 0, 0, 0, time.UTC),
+	}
+	for title, at := range created {
+		todo, err := repo.Create(context.Background(), models.CreateTodoRequest{Title: title})
+		if err != nil {
+			t.Fatalf("Failed to create todo: %v", err)
+		}
+		if _, err := db.ExecContext(context.Background(), "UPDATE todos SET created_at = ? WHERE id = ?", at, todo.ID); err != nil {
+			t.Fatalf("Failed to set created_at: %v", err)
+		}
+	}
+
+	tests := []struct {
+		query  string
+		titles []string
+	}{
+		{"createdAfter=2024-03-10", []string{"Late evening", "Next morning"}},
+		{"createdAfter=2024-03-10&tz=America/New_York", []string{"Next morning"}},
+		{"createdBefore=2024-03-10&tz=America/New_York", []string{"Late evening"}},
+		{"createdBefore=2024-03-10", nil},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/api/todos?sortBy=createdAt&sortOrder=asc&"+tt.query, nil)
+		w := httptest.NewRecorder()
+
+		handler.GetAllTodos(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("%q: expected status 200, got %d: %s", tt.query, w.Code, w.Body.String())
+		}
+
+		var todos []models.Todo
+		if err := json.NewDecoder(w.Body).Decode(&todos); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		var titles []string
+		for _, todo := range todos {
+			titles = append(titles, todo.Title)
+		}
+		if strings.Join(titles, ",") != strings.Join(tt.titles, ",") {
+			t.Errorf("%q: expected %v, got %v", tt.query, tt.titles, titles)
+		}
+	}
+
+	// Timestamps come back as RFC3339 in the requested zone
+	req := httptest.NewRequest("GET", "/api/todos?sortBy=createdAt&sortOrder=asc&tz=America/New_York", nil)
+	w := httptest.NewRecorder()
+
+	handler.GetAllTodos(w, req)
+
+	var todos []map[string]interface{}
+	if err := json.NewDecoder(w.Body).Decode(&todos); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(todos) != 2 || todos[0]["createdAt"] != "2024-03-09T22:30:00-05:00" {
+		t.Errorf("Expected createdAt in New York time, got %v", todos)
+	}
+
+	req = httptest.NewRequest("GET", "/api/todos?tz=Mars/Olympus", nil)
+	w = httptest.NewRecorder()
+
+	handler.GetAllTodos(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unknown zone, got %d", w.Code)
+	}
+}
+
+func TestCreateTodo_StoresUTC(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewTodoHandler(repo)
+
+	req := httptest.NewRequest("POST", "/api/todos", strings.NewReader(`{"title":"Call","remindAt":"2030-01-02T09:30:00+10:00"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.CreateTodo(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var remindAt, createdAt string
+	err := db.QueryRowContext(context.Background(), "SELECT CAST(remind_at AS TEXT), CAST(created_at AS TEXT) FROM todos WHERE id = 1").Scan(&remindAt, &createdAt)
+	if err != nil {
+		t.Fatalf("Failed to read todo: %v", err)
+	}
+	if remindAt != "2030-01-01 23:30:00+00:00" {
+		t.Errorf("Expected remind_at stored in UTC, got %q", remindAt)
+	}
+	if !strings.HasSuffix(createdAt, "+00:00") {
+		t.Errorf("Expected created_at stored in UTC, got %q", createdAt)
+	}
+}
+
+func TestCreateTodo_ContentType(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewTodoHandler(repo)
+
+	tests := []struct {
+		contentType string
+		body        string
+		status      int
+	}{
+		{"", `{"title":"Test"}`, http.StatusUnsupportedMediaType},
+		{"application/x-www-form-urlencoded", "title=Test", http.StatusUnsupportedMediaType},
+		{"text/plain", `{"title":"Test"}`, http.StatusUnsupportedMediaType},
+		{"application/json", `{"title":"Test"}`, http.StatusCreated},
+		{"application/json; charset=utf-8", `{"title":"Test"}`, http.StatusCreated},
+		{"Application/JSON", `{"title":"Test"}`, http.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/api/todos", strings.NewReader(tt.body))
+		if tt.contentType != "" {
+			req.Header.Set("Content-Type", tt.contentType)
+		}
+		w := httptest.NewRecorder()
+
+		handler.CreateTodo(w, req)
+
+		if w.Code != tt.status {
+			t.Errorf("%q: expected status %d, got %d", tt.contentType, tt.status, w.Code)
+		}
+	}
+
+	req := httptest.NewRequest("PATCH", "/api/todos/1", strings.NewReader("completed=true"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+
+	handler.UpdateTodo(w, req)
+
+	if w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("Expected status 415 for a form-encoded update, got %d", w.Code)
+	}
+}
+
+func TestDeleteAllTodos(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewTodoHandler(repo)
+
+	for _, title := range []string{"One", "Two", "Three"} {
+		if _, err := repo.Create(context.Background(), models.CreateTodoRequest{Title: title}); err != nil {
+			t.Fatalf("Failed to create todo: %v", err)
+		}
+	}
+
+	deleteAll := func(confirm string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("DELETE", "/api/todos", nil)
+		if confirm != "" {
+			req.Header.Set("X-Confirm-Delete-All", confirm)
+		}
+		w := httptest.NewRecorder()
+		handler.DeleteAllTodos(w, req)
+		return w
+	}
+
+	// Disabled unless the server opts in, even with the header
+	if w := deleteAll("true"); w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 when disabled, got %d", w.Code)
+	}
+
+	handler.SetAllowDeleteAll(true)
+
+	for _, confirm := range []string{"", "yes"} {
+		if w := deleteAll(confirm); w.Code != http.StatusForbidden {
+			t.Errorf("Expected status 403 with confirmation %q, got %d", confirm, w.Code)
+		}
+	}
+
+	w := deleteAll("true")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var resp DeleteAllResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Deleted != 3 {
+		t.Errorf("Expected 3 deleted, got %d", resp.Deleted)
+	}
+
+	count, err := repo.Count(context.Background(), database.FilterOptions{})
+	if err != nil {
+		t.Fatalf("Failed to count todos: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("Expected no todos left, got %d", count)
+	}
+}
+
+func TestBatchCreateTodos(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewTodoHandler(repo)
+
+	batch := func(query, body string) (int, models.BatchCreateResponse) {
+		req := httptest.NewRequest("POST", "/api/todos/batch"+query, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		handler.BatchCreateTodos(w, req)
+
+		// Plain error responses decode to an empty BatchCreateResponse
+		var resp models.BatchCreateResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return w.Code, resp
+	}
+	count := func() int64 {
+		n, err := repo.Count(context.Background(), database.FilterOptions{})
+		if err != nil {
+			t.Fatalf("Failed to count todos: %v", err)
+		}
+		return n
+	}
+
+	mixed := `{"todos":[{"title":"One"},{"title":""},{"title":"Three"},{"description":"no title"}]}`
+
+	// Atomic mode rejects the whole batch and reports every bad index
+	code, resp := batch("", mixed)
+	if code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d", code)
+	}
+	if len(resp.Errors) != 2 || resp.Errors[0].Index != 1 || resp.Errors[1].Index != 3 {
+		t.Errorf("Expected errors at indexes 1 and 3, got %+v", resp.Errors)
+	}
+	if n := count(); n != 0 {
+		t.Errorf("Expected nothing created, got %d todos", n)
+	}
+
+	code, resp = batch("?mode=atomic", `{"todos":[{"title":"One"},{"title":"Two"}]}`)
+	if code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d", code)
+	}
+	if len(resp.Created) != 2 || resp.Created[1].Title != "Two" || len(resp.Errors) != 0 {
+		t.Errorf("Unexpected atomic response: %+v", resp)
+	}
+
+	// Best-effort mode creates the valid todos and skips the rest
+	code, resp = batch("?mode=best-effort", mixed)
+	if code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", code)
+	}
+	if len(resp.Created) != 2 || resp.Created[0].Title != "One" || resp.Created[1].Title != "Three" {
+		t.Errorf("Expected One and Three created, got %+v", resp.Created)
+	}
+	if len(resp.Errors) != 2 || resp.Errors[0].Index != 1 || resp.Errors[1].Index != 3 {
+		t.Errorf("Expected errors at indexes 1 and 3, got %+v", resp.Errors)
+	}
+	if n := count(); n != 4 {
+		t.Errorf("Expected 4 todos, got %d", n)
+	}
+
+	for _, tt := range []struct{ query, body string }{
+		{"?mode=sometimes", `{"todos":[{"title":"One"}]}`},
+		{"", `{"todos":[]}`},
+	} {
+		if code, _ := batch(tt.query, tt.body); code != http.StatusBadRequest {
+			t.Errorf("%s %s: expected status 400, got %d", tt.query, tt.body, code)
+		}
+	}
+}
+
+func TestGetAllTodos_Highlight(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewTodoHandler(repo)
+
+	if _, err := repo.Create(context.Background(), models.CreateTodoRequest{
+		Title:       "Buy Milk",
+		Description: "<script>milk</script>",
+	}); err != nil {
+		t.Fatalf("Failed to create todo: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/api/todos?search=milk&highlight=true", nil)
+	w := httptest.NewRecorder()
+
+	handler.GetAllTodos(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var todos []HighlightedTodo
+	if err := json.NewDecoder(w.Body).Decode(&todos); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(todos) != 1 {
+		t.Fatalf("Expected 1 todo, got %d", len(todos))
+	}
+	if todos[0].TitleHighlighted != "Buy <mark>Milk</mark>" {
+		t.Errorf("Unexpected titleHighlighted: %q", todos[0].TitleHighlighted)
+	}
+	if todos[0].DescriptionHighlighted != "&lt;script&gt;<mark>milk</mark>&lt;/script&gt;" {
+		t.Errorf("Unexpected descriptionHighlighted: %q", todos[0].DescriptionHighlighted)
+	}
+	if todos[0].Title != "Buy Milk" {
+		t.Errorf("Expected the raw title to be unchanged, got %q", todos[0].Title)
+	}
+
+	// Without highlight=true the extra fields are omitted
+	req = httptest.NewRequest("GET", "/api/todos?search=milk", nil)
+	w = httptest.NewRecorder()
+
+	handler.GetAllTodos(w, req)
+
+	if strings.Contains(w.Body.String(), "Highlighted") {
+		t.Errorf("Expected no highlight fields, got %s", w.Body.String())
+	}
+
+	// Highlight fields are kept alongside a sparse fieldset
+	req = httptest.NewRequest("GET", "/api/todos?search=milk&highlight=true&fields=id", nil)
+	w = httptest.NewRecorder()
+
+	handler.GetAllTodos(w, req)
+
+	var partial []map[string]interface{}
+	if err := json.NewDecoder(w.Body).Decode(&partial); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(partial) != 1 || len(partial[0]) != 3 || partial[0]["titleHighlighted"] != "Buy <mark>Milk</mark>" {
+		t.Errorf("Unexpected sparse highlighted response: %v", partial)
+	}
+}
+
+func TestSyncTodos(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewTodoHandler(repo)
+
+	sync := func(query string) (models.SyncResponse, string) {
+		req := httptest.NewRequest("GET", "/api/todos/sync?"+query, nil)
+		w := httptest.NewRecorder()
+
+		handler.SyncTodos(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("%q: expected status 200, got %d: %s", query, w.Code, w.Body.String())
+		}
+		var resp models.SyncResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return resp, w.Header().Get("X-Next-Cursor")
+	}
+
+	for _, title := range []string{"One", "Two", "Three"} {
+		if _, err := repo.Create(context.Background(), models.CreateTodoRequest{Title: title}); err != nil {
+			t.Fatalf("Failed to create todo: %v", err)
+		}
+	}
+
+	initial, _ := sync("")
+	if len(initial.Changes) != 3 {
+		t.Fatalf("Expected 3 changes on first sync, got %d", len(initial.Changes))
+	}
+
+	// Timestamps have sub-millisecond precision, so make sure the next
+	// changes land strictly after serverTime
+	time.Sleep(5 * time.Millisecond)
+
+	title := "One (edited)"
+	if _, err := repo.Update(context.Background(), 1, models.UpdateTodoRequest{Title: &title}); err != nil {
+		t.Fatalf("Failed to update todo: %v", err)
+	}
+	if _, err := repo.Delete(context.Background(), 2); err != nil {
+		t.Fatalf("Failed to delete todo: %v", err)
+	}
+
+	since := url.QueryEscape(initial.ServerTime.Format(time.RFC3339Nano))
+	delta, _ := sync("since=" + since)
+	if len(delta.Changes) != 2 {
+		t.Fatalf("Expected 2 changes since the first sync, got %+v", delta.Changes)
+	}
+	if delta.Changes[0].ID != 1 || delta.Changes[0].Title != title || delta.Changes[0].Deleted {
+		t.Errorf("Expected the edited todo first, got %+v", delta.Changes[0])
+	}
+	if delta.Changes[1].ID != 2 || !delta.Changes[1].Deleted {
+		t.Errorf("Expected the deleted todo second, got %+v", delta.Changes[1])
+	}
+
+	// Walk the full history one change at a time
+	var ids []int64
+	cursor := ""
+	for page := 0; page < 10; page++ {
+		resp, next := sync("limit=1&cursor=" + cursor)
+		for _, change := range resp.Changes {
+			ids = append(ids, change.ID)
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	if fmt.Sprint(ids) != "[3 1 2]" {
+		t.Errorf("Expected changes [3 1 2], got %v", ids)
+	}
+
+	req := httptest.NewRequest("GET", "/api/todos/sync?since=yesterday", nil)
+	w := httptest.NewRecorder()
+
+	handler.SyncTodos(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid since, got %d", w.Code)
+	}
+}
+
+func TestSyncTodos_TombstoneRetention(t *testing.T) {
+	handler := NewTodoHandler(database.NewMemoryTodoStore())
+	handler.SetTombstoneRetention(24 * time.Hour)
+
+	tests := []struct {
+		name  string
+		since string
+		want  int
+	}{
+		{"no since", "", http.StatusOK},
+		{"within retention", time.Now().Add(-time.Hour).UTC().Format(time.RFC3339), http.StatusOK},
+		{"before retention", time.Now().Add(-48 * time.Hour).UTC().Format(time.RFC3339), http.StatusGone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/todos/sync?since="+url.QueryEscape(tt.since), nil)
+			w := httptest.NewRecorder()
+
+			handler.SyncTodos(w, req)
+
+			if w.Code != tt.want {
+				t.Errorf("Expected status %d, got %d: %s", tt.want, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestTodoHandler_MemoryStore(t *testing.T) {
+	handler := NewTodoHandler(database.NewMemoryTodoStore())
+
+	body, _ := json.Marshal(models.CreateTodoRequest{Title: "In memory"})
+	req := httptest.NewRequest("POST", "/api/todos", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.CreateTodo(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d", w.Code)
+	}
+
+	var created models.Todo
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	req = httptest.NewRequest("GET", "/api/todos/"+strconv.FormatInt(created.ID, 10), nil)
+	req.SetPathValue("id", strconv.FormatInt(created.ID, 10))
+	w = httptest.NewRecorder()
+
+	handler.GetTodo(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var todo models.Todo
+	if err := json.NewDecoder(w.Body).Decode(&todo); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if todo.Title != "In memory" {
+		t.Errorf("Expected title 'In memory', got '%s'", todo.Title)
+	}
+}
+
+func TestGetTodo_UnknownExpand(t *testing.T) {
+	handler := NewTodoHandler(database.NewMemoryTodoStore())
+
+	req := httptest.NewRequest("GET", "/api/todos/1?expand=comments,subtasks", nil)
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+
+	handler.GetTodo(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+
+	if !strings.Contains(w.Body.String(), "subtasks") {
+		t.Errorf("Expected the error to name the unknown value, got %s", w.Body.String())
+	}
+}
+
+func TestGetTodo_RenderHTML(t *testing.T) {
+	store := database.NewMemoryTodoStore()
+	handler := NewTodoHandler(store)
+
+	description := "**Buy** milk <script>alert(1)</script>"
+	if _, err := store.Create(context.Background(), models.CreateTodoRequest{Title: "Shop", Description: description}); err != nil {
+		t.Fatalf("Failed to create todo: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/api/todos/1?render=html", nil)
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+
+	handler.GetTodo(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var detail models.TodoDetail
+	if err := json.NewDecoder(w.Body).Decode(&detail); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if detail.Description != description {
+		t.Errorf("Expected the raw description to be unchanged, got %q", detail.Description)
+	}
+	if detail.DescriptionHTML == nil {
+		t.Fatal("Expected descriptionHtml to be set")
+	}
+	if !strings.Contains(*detail.DescriptionHTML, "<strong>Buy</strong>") {
+		t.Errorf("Expected rendered Markdown, got %q", *detail.DescriptionHTML)
+	}
+	if strings.Contains(*detail.DescriptionHTML, "<script") {
+		t.Errorf("Expected scripts to be removed, got %q", *detail.DescriptionHTML)
+	}
+
+	// Without render the field is left out
+	req = httptest.NewRequest("GET", "/api/todos/1", nil)
+	req.SetPathValue("id", "1")
+	w = httptest.NewRecorder()
+
+	handler.GetTodo(w, req)
+
+	if strings.Contains(w.Body.String(), "descriptionHtml") {
+		t.Errorf("Expected no descriptionHtml without render, got %s", w.Body.String())
+	}
+}
+
+func TestGetTodo_InvalidRender(t *testing.T) {
+	handler := NewTodoHandler(database.NewMemoryTodoStore())
+
+	req := httptest.NewRequest("GET", "/api/todos/1?render=pdf", nil)
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+
+	handler.GetTodo(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+}
+
+func TestCreateTodo_TitleTooLong(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewTodoHandler(repo)
+
+	body, _ := json.Marshal(models.CreateTodoRequest{Title: strings.Repeat("a", database.MaxTitleLength+1)})
+	req := httptest.NewRequest("POST", "/api/todos", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.CreateTodo(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+
+	if !strings.Contains(w.Body.String(), "title must be at most 200 characters") {
+		t.Errorf("Expected a length error, got %s", w.Body.String())
+	}
+}
+
+func TestCreateTodo_DuplicateTitle(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	if err := repo.SetUniqueTitles(context.Background(), true); err != nil {
+		t.Fatalf("Failed to enable unique titles: %v", err)
+	}
+	handler := NewTodoHandler(repo)
+
+	create := func() *httptest.ResponseRecorder {
+		body, _ := json.Marshal(models.CreateTodoRequest{Title: "Pay rent"})
+		req := httptest.NewRequest("POST", "/api/todos", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handler.CreateTodo(w, req)
+		return w
+	}
+
+	if w := create(); w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201 for the first todo, got %d", w.Code)
+	}
+
+	w := create()
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 for the duplicate, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "a todo with this title already exists") {
+		t.Errorf("Expected a conflict message, got %s", w.Body.String())
+	}
+}
+
+func TestCompleteMatchingTodos(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	repo := newTestRepo(t, db)
+	handler := NewTodoHandler(repo)
+
+	for _, title := range []string{"Buy milk", "Buy bread", "Walk dog"} {
+		if _, err := repo.Create(context.Background(), models.CreateTodoRequest{Title: title}); err != nil {
+			t.Fatalf("Failed to create todo: %v", err)
+		}
+	}
+
+	completeMatching := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/todos/complete-matching?"+query, nil)
+		w := httptest.NewRecorder()
+		handler.CompleteMatchingTodos(w, req)
+		return w
+	}
+	countIncomplete := func() int64 {
+		incomplete := false
+		count, err := repo.Count(context.Background(), database.FilterOptions{Completed: &incomplete})
+		if err != nil {
+			t.Fatalf("Count failed: %v", err)
+		}
+		return count
+	}
+
+	for _, query := range []string{"", "all=false", "sortBy=title"} {
+		if w := completeMatching(query); w.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected status 400 without a filter, got %d", query, w.Code)
+		}
+	}
+	if n := countIncomplete(); n != 3 {
+		t.Fatalf("Expected the guarded requests to change nothing, got %d incomplete", n)
+	}
+
+	w := completeMatching("search=buy")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp CompleteMatchingResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Updated != 2 {
+		t.Errorf("Expected 2 todos updated, got %d", resp.Updated)
+	}
+	if n := countIncomplete(); n != 1 {
+		t.Errorf("Expected 1 incomplete todo left, got %d", n)
+	}
+
+	w = completeMatching("all=true")
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if w.Code != http.StatusOK || resp.Updated != 1 {
+		t.Errorf("Expected all=true to complete the last todo, got %d, %+v", w.Code, resp)
+	}
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"net/http"
+	"runtime"
+)
+
+// BuildInfo identifies the running build
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"buildTime"`
+	GoVersion string `json:"goVersion"`
+}
+
+// VersionHandler reports which build is running
+type VersionHandler struct {
+	info BuildInfo
+}
+
+// NewVersionHandler creates a new VersionHandler for info. GoVersion is
+// filled in from the runtime when empty.
+func NewVersionHandler(info BuildInfo) *VersionHandler {
+	if info.GoVersion == "" {
+		info.GoVersion = runtime.Version()
+	}
+	return &VersionHandler{info: info}
+}
+
+// GetVersion handles GET /version
+// @Summary Get build information
+// @Description Report the version, commit and build time the server was built with, and the Go version it was compiled by
+// @Tags meta
+// @Produce json
+// @Success 200 {object} BuildInfo
+// @Router /version [get]
+func (h *VersionHandler) GetVersion(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, http.StatusOK, h.info)
+}
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+)
+
+func TestGetVersion(t *testing.T) {
+	handler := NewVersionHandler(BuildInfo{Version: "1.2.0", Commit: "abc123", BuildTime: "2024-03-10T09:30:00Z"})
+
+	w := httptest.NewRecorder()
+	handler.GetVersion(w, httptest.NewRequest("GET", "/version", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var info BuildInfo
+	if err := json.NewDecoder(w.Body).Decode(&info); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	want := BuildInfo{Version: "1.2.0", Commit: "abc123", BuildTime: "2024-03-10T09:30:00Z", GoVersion: runtime.Version()}
+	if info != want {
+		t.Errorf("Expected %+v, got %+v", want, info)
+	}
+}
@@ -0,0 +1,77 @@
+// Package logging configures the server's structured logger
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Format selects how log records are written
+type Format string
+
+const (
+	// FormatText writes human-readable key=value lines
+	FormatText Format = "text"
+	// FormatJSON writes one JSON object per line, for log ingestion
+	FormatJSON Format = "json"
+)
+
+// Config holds the logger settings
+type Config struct {
+	Format Format
+	Level  slog.Level
+}
+
+// ConfigFromEnv builds a Config from LOG_FORMAT ("text" or "json") and
+// LOG_LEVEL ("debug", "info", "warn" or "error"). When LOG_FORMAT is unset
+// the format is text if the output is a terminal and JSON otherwise; the
+// level defaults to info.
+func ConfigFromEnv(terminal bool) (Config, error) {
+	cfg := Config{Format: FormatJSON, Level: slog.LevelInfo}
+	if terminal {
+		cfg.Format = FormatText
+	}
+
+	if v := os.Getenv("LOG_FORMAT"); v != "" {
+		switch format := Format(strings.ToLower(v)); format {
+		case FormatText, FormatJSON:
+			cfg.Format = format
+		default:
+			return cfg, fmt.Errorf("invalid LOG_FORMAT %q: must be text or json", v)
+		}
+	}
+
+	if v := os.Getenv("LOG_LEVEL"); v != "" {
+		if err := cfg.Level.UnmarshalText([]byte(v)); err != nil {
+			return cfg, fmt.Errorf("invalid LOG_LEVEL %q: must be debug, info, warn or error", v)
+		}
+	}
+
+	return cfg, nil
+}
+
+// NewHandler returns the slog handler for cfg writing to w
+func NewHandler(w io.Writer, cfg Config) slog.Handler {
+	opts := &slog.HandlerOptions{Level: cfg.Level}
+	if cfg.Format == FormatJSON {
+		return slog.NewJSONHandler(w, opts)
+	}
+	return slog.NewTextHandler(w, opts)
+}
+
+// New returns a logger for cfg writing to w
+func New(w io.Writer, cfg Config) *slog.Logger {
+	return slog.New(NewHandler(w, cfg))
+}
+
+// IsTerminal reports whether f is attached to a terminal
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
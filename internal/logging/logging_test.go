@@ -0,0 +1,101 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestConfigFromEnv_Defaults(t *testing.T) {
+	t.Setenv("LOG_FORMAT", "")
+	t.Setenv("LOG_LEVEL", "")
+
+	tests := []struct {
+		terminal bool
+		want     Format
+	}{
+		{terminal: true, want: FormatText},
+		{terminal: false, want: FormatJSON},
+	}
+
+	for _, tt := range tests {
+		cfg, err := ConfigFromEnv(tt.terminal)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if cfg.Format != tt.want {
+			t.Errorf("terminal=%v: expected format %q, got %q", tt.terminal, tt.want, cfg.Format)
+		}
+		if cfg.Level != slog.LevelInfo {
+			t.Errorf("Expected level info, got %v", cfg.Level)
+		}
+	}
+}
+
+func TestConfigFromEnv_Overrides(t *testing.T) {
+	t.Setenv("LOG_FORMAT", "JSON")
+	t.Setenv("LOG_LEVEL", "warn")
+
+	cfg, err := ConfigFromEnv(true)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cfg.Format != FormatJSON {
+		t.Errorf("Expected LOG_FORMAT to override the terminal default, got %q", cfg.Format)
+	}
+	if cfg.Level != slog.LevelWarn {
+		t.Errorf("Expected level warn, got %v", cfg.Level)
+	}
+}
+
+func TestConfigFromEnv_Invalid(t *testing.T) {
+	tests := []struct {
+		name, format, level string
+	}{
+		{"format", "xml", ""},
+		{"level", "", "verbose"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("LOG_FORMAT", tt.format)
+			t.Setenv("LOG_LEVEL", tt.level)
+
+			if _, err := ConfigFromEnv(false); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestNewHandler(t *testing.T) {
+	var buf bytes.Buffer
+
+	if _, ok := NewHandler(&buf, Config{Format: FormatText}).(*slog.TextHandler); !ok {
+		t.Error("Expected a TextHandler for the text format")
+	}
+
+	handler, ok := NewHandler(&buf, Config{Format: FormatJSON, Level: slog.LevelWarn}).(*slog.JSONHandler)
+	if !ok {
+		t.Fatal("Expected a JSONHandler for the json format")
+	}
+
+	logger := slog.New(handler)
+	logger.Info("dropped")
+	logger.Warn("kept", "id", 1)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Expected only the warning to be logged, got %q", buf.String())
+	}
+
+	var record map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("Expected a JSON record, got %q: %v", lines[0], err)
+	}
+	if record["msg"] != "kept" || record["level"] != "WARN" {
+		t.Errorf("Unexpected record %v", record)
+	}
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Attachment represents a link or file associated with a todo. Only
+// metadata and a URL are stored, not the file contents.
+type Attachment struct {
+	ID          int64     `json:"id"`
+	TodoID      int64     `json:"todoId"`
+	Name        string    `json:"name"`
+	URL         string    `json:"url"`
+	ContentType string    `json:"contentType"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// CreateAttachmentRequest represents the request body for adding an attachment
+type CreateAttachmentRequest struct {
+	Name        string `json:"name" validate:"required"`
+	URL         string `json:"url" validate:"required"`
+	ContentType string `json:"contentType"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// Comment represents a note left on a todo
+type Comment struct {
+	ID        int64     `json:"id"`
+	TodoID    int64     `json:"todoId"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// CreateCommentRequest represents the request body for adding a comment
+type CreateCommentRequest struct {
+	Body string `json:"body" validate:"required"`
+}
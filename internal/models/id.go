@@ -0,0 +1,35 @@
+package models
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidID is returned when decoding a FlexibleID that is not an integer
+var ErrInvalidID = errors.New("ids must be integers or numeric strings")
+
+// FlexibleID is a todo ID in a request body. It accepts a JSON number such
+// as 42 or a numeric string such as "42", since some JavaScript clients send
+// IDs as strings. It always encodes as a number.
+type FlexibleID int64
+
+// UnmarshalJSON implements json.Unmarshaler
+func (id *FlexibleID) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if strings.HasPrefix(s, `"`) {
+		unquoted, err := strconv.Unquote(s)
+		if err != nil {
+			return ErrInvalidID
+		}
+		s = unquoted
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return ErrInvalidID
+	}
+
+	*id = FlexibleID(n)
+	return nil
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+)
+
+// ErrMetadataNotObject is returned when decoding Metadata that is not a
+// JSON object
+var ErrMetadataNotObject = errors.New("metadata must be a JSON object")
+
+// Metadata holds arbitrary client-defined fields for a todo, such as a
+// color or an external reference. Values may be any JSON, including nested
+// objects, but the top level must be an object.
+type Metadata map[string]any
+
+// UnmarshalJSON implements json.Unmarshaler
+func (m *Metadata) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	if len(data) == 0 || data[0] != '{' {
+		return ErrMetadataNotObject
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	*m = fields
+	return nil
+}
+
+// Merge returns m with patch applied as an RFC 7386 JSON Merge Patch: each
+// member of patch replaces the member of m with the same name, objects are
+// merged member by member, and null removes a member. m is not modified.
+func (m Metadata) Merge(patch Metadata) Metadata {
+	return mergeObject(m, patch)
+}
+
+// mergeObject applies the merge patch patch to target
+func mergeObject(target, patch map[string]any) map[string]any {
+	merged := make(map[string]any, len(target)+len(patch))
+	for name, value := range target {
+		merged[name] = value
+	}
+	for name, value := range patch {
+		switch value := value.(type) {
+		case nil:
+			delete(merged, name)
+		case map[string]any:
+			// A member that is not an object is replaced by the patch's
+			existing, _ := merged[name].(map[string]any)
+			merged[name] = mergeObject(existing, value)
+		default:
+			merged[name] = value
+		}
+	}
+	return merged
+}
@@ -5,23 +5,228 @@ import "time"
 // Todo represents a todo item in the system
 // This model is used throughout the application for todo management
 type Todo struct {
-	ID          int64     `json:"id"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	Completed   bool      `json:"completed"`
-	CreatedAt   time.Time `json:"createdAt"`
-	UpdatedAt   time.Time `json:"updatedAt"`
+	ID           int64      `json:"id"`
+	PublicID     string     `json:"publicId"`
+	ExternalID   *string    `json:"externalId,omitempty"` // set by upserts from another system
+	Slug         string     `json:"slug"`                 // human-readable id derived from the title
+	Title        string     `json:"title"`
+	Description  string     `json:"description"`
+	Completed    bool       `json:"completed"`
+	Starred      bool       `json:"starred"`
+	RemindAt     *time.Time `json:"remindAt"`
+	SnoozedUntil *time.Time `json:"snoozedUntil"` // kept out of reminders until then
+	ScheduledAt  *time.Time `json:"scheduledAt"`  // when it is planned to be worked on
+	Position     int64      `json:"position"`     // manual order, ascending
+	Draft        bool       `json:"draft"`
+	Focused      bool       `json:"focused"` // at most one todo is focused
+	Metadata     Metadata   `json:"metadata,omitempty"`
+	// EstimateMinutes is the estimated effort, 0 when not estimated
+	EstimateMinutes int64     `json:"estimateMinutes"`
+	CreatedAt       time.Time `json:"createdAt"`
+	UpdatedAt       time.Time `json:"updatedAt"`
 }
 
 // CreateTodoRequest represents the request body for creating a todo
 type CreateTodoRequest struct {
-	Title       string `json:"title" validate:"required"`
-	Description string `json:"description"`
+	Title       string     `json:"title" validate:"required"`
+	Description string     `json:"description"`
+	RemindAt    *time.Time `json:"remindAt,omitempty"`
+	ScheduledAt *time.Time `json:"scheduledAt,omitempty"`
+	Draft       bool       `json:"draft,omitempty"`
+	Metadata    Metadata   `json:"metadata,omitempty"`
+	// EstimateMinutes must not be negative
+	EstimateMinutes int64 `json:"estimateMinutes,omitempty"`
+}
+
+// TodoDefaults holds server-side defaults for new todos. They only fill
+// fields a create request leaves empty; values in the request always win.
+type TodoDefaults struct {
+	Description string
+}
+
+// Apply returns req with its empty fields filled in from d
+func (d TodoDefaults) Apply(req CreateTodoRequest) CreateTodoRequest {
+	if req.Description == "" {
+		req.Description = d.Description
+	}
+	return req
+}
+
+// ApplyUpsert returns req with the defaults to use if the upsert creates
+// the todo. A todo that is replaced takes req's fields as given.
+func (d TodoDefaults) ApplyUpsert(req UpsertTodoRequest) UpsertTodoRequest {
+	req.DefaultDescription = d.Description
+	return req
 }
 
 // UpdateTodoRequest represents the request body for updating a todo
 type UpdateTodoRequest struct {
-	Title       *string `json:"title,omitempty"`
-	Description *string `json:"description,omitempty"`
-	Completed   *bool   `json:"completed,omitempty"`
+	Title       *string    `json:"title,omitempty"`
+	Description *string    `json:"description,omitempty"`
+	Completed   *bool      `json:"completed,omitempty"`
+	Starred     *bool      `json:"starred,omitempty"`
+	RemindAt    *time.Time `json:"remindAt,omitempty"`
+	ScheduledAt *time.Time `json:"scheduledAt,omitempty"`
+	Draft       *bool      `json:"draft,omitempty"`
+	// Metadata replaces the todo's metadata; an empty object removes it
+	Metadata Metadata `json:"metadata,omitempty"`
+	// EstimateMinutes must not be negative; 0 removes the estimate
+	EstimateMinutes *int64 `json:"estimateMinutes,omitempty"`
+
+	// IfUnmodifiedSince rejects the update if the todo changed after this
+	// time, to prevent overwriting someone else's changes
+	IfUnmodifiedSince *time.Time `json:"ifUnmodifiedSince,omitempty"`
+
+	// ClearRemindAt removes the reminder. A merge patch sets it for
+	// "remindAt": null; plain JSON updates cannot express it.
+	ClearRemindAt bool `json:"-"`
+
+	// ClearScheduledAt unschedules the todo, for "scheduledAt": null in a
+	// merge patch
+	ClearScheduledAt bool `json:"-"`
+
+	// MetadataPatch is merged into the todo's metadata with Metadata.Merge,
+	// in the same transaction as the rest of the update, for "metadata" in
+	// a merge patch. It is ignored if Metadata is set.
+	MetadataPatch Metadata `json:"-"`
+
+	// SnoozedUntil is set by the snooze endpoint rather than by updates
+	SnoozedUntil *time.Time `json:"-"`
+}
+
+// UpsertTodoRequest represents the request body for creating or replacing a
+// todo by external id. Fields it does not carry, such as position, are kept
+// when an existing todo is replaced.
+type UpsertTodoRequest struct {
+	Title           string     `json:"title" validate:"required"`
+	Description     string     `json:"description"`
+	Completed       bool       `json:"completed"`
+	Starred         bool       `json:"starred"`
+	RemindAt        *time.Time `json:"remindAt,omitempty"`
+	ScheduledAt     *time.Time `json:"scheduledAt,omitempty"`
+	Draft           bool       `json:"draft,omitempty"`
+	Metadata        Metadata   `json:"metadata,omitempty"`
+	EstimateMinutes int64      `json:"estimateMinutes,omitempty"`
+
+	// DefaultDescription replaces an empty description when the upsert
+	// creates the todo, set from the server's TodoDefaults
+	DefaultDescription string `json:"-"`
+}
+
+// CreateDescription returns the description a todo created by the upsert
+// gets
+func (r UpsertTodoRequest) CreateDescription() string {
+	if r.Description == "" {
+		return r.DefaultDescription
+	}
+	return r.Description
+}
+
+// SnoozeRequest represents the request body for snoozing a todo
+type SnoozeRequest struct {
+	Until *time.Time `json:"until"`
+}
+
+// BulkUpdateRequest represents the request body for updating many todos at
+// once. IDs may be given as numbers or numeric strings.
+type BulkUpdateRequest struct {
+	IDs       []FlexibleID `json:"ids" swaggertype:"array,integer"`
+	Completed *bool        `json:"completed"`
+}
+
+// BulkToggleRequest represents the request body for flipping the completed
+// flag of many todos at once. IDs may be given as numbers or numeric strings.
+type BulkToggleRequest struct {
+	IDs []FlexibleID `json:"ids" swaggertype:"array,integer"`
+}
+
+// BulkUpdateResponse reports the outcome of a bulk update
+type BulkUpdateResponse struct {
+	Updated  []Todo  `json:"updated"`
+	NotFound []int64 `json:"notFound"`
+}
+
+// BatchUpdateItem is one partial update in a batch update: the id of the
+// todo, as a number or numeric string, and the fields to change
+type BatchUpdateItem struct {
+	ID FlexibleID `json:"id" swaggertype:"integer"`
+	UpdateTodoRequest
+}
+
+// BatchUpdateResponse reports the outcome of a batch update. Errors lists
+// the items rejected by validation, in which case nothing is updated.
+type BatchUpdateResponse struct {
+	Updated  []Todo       `json:"updated"`
+	NotFound []int64      `json:"notFound"`
+	Errors   []BatchError `json:"errors"`
+}
+
+// BatchGetRequest represents the request body for fetching many todos at
+// once. IDs may be given as numbers or numeric strings.
+type BatchGetRequest struct {
+	IDs []FlexibleID `json:"ids" swaggertype:"array,integer"`
+}
+
+// BatchGetResponse holds the todos found by a batch get, in request order,
+// and the requested ids that do not exist
+type BatchGetResponse struct {
+	Todos   []Todo  `json:"todos"`
+	Missing []int64 `json:"missing"`
+}
+
+// BatchCreateRequest represents the request body for creating many todos at once
+type BatchCreateRequest struct {
+	Todos []CreateTodoRequest `json:"todos"`
+}
+
+// BatchError reports why an item of a batch request was rejected
+type BatchError struct {
+	Index int    `json:"index"`
+	Error string `json:"error"`
+}
+
+// BatchCreateResponse reports the outcome of a batch create
+type BatchCreateResponse struct {
+	Created []Todo       `json:"created"`
+	Errors  []BatchError `json:"errors"`
+}
+
+// TodoChange is a todo reported by the sync endpoint. A deleted todo only
+// carries its ID and PublicID, with the time it was deleted as UpdatedAt.
+type TodoChange struct {
+	Todo
+	Deleted bool `json:"deleted"`
+}
+
+// SyncResponse lists the todos changed since a point in time
+type SyncResponse struct {
+	Changes []TodoChange `json:"changes"`
+	// ServerTime is the since value to use for the next sync once every
+	// page has been read
+	ServerTime time.Time `json:"serverTime"`
+}
+
+// TodoDetail is a todo with optionally expanded related collections
+type TodoDetail struct {
+	Todo
+	*Dependencies
+	Attachments  []Attachment `json:"attachments,omitempty"`
+	Comments     []Comment    `json:"comments,omitempty"`
+	CommentCount *int64       `json:"commentCount,omitempty"`
+	// DescriptionHTML is the description rendered from Markdown to
+	// sanitized HTML, for ?render=html
+	DescriptionHTML *string `json:"descriptionHtml,omitempty"`
+}
+
+// Dependencies lists the todos a todo is blocked by, which must be
+// completed first, and the todos it is blocking in turn
+type Dependencies struct {
+	BlockedBy []int64 `json:"blockedBy"`
+	Blocking  []int64 `json:"blocking"`
+}
+
+// CreateDependencyRequest represents the request body for adding a
+// dependency: the todo in the path cannot be completed before DependsOn
+type CreateDependencyRequest struct {
+	DependsOn int64 `json:"dependsOn" validate:"required"`
 }
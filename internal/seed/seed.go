@@ -0,0 +1,94 @@
+// Package seed populates the database with sample todos for development
+package seed
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/larryhudson/go-todo-list-claude/internal/database"
+	"github.com/larryhudson/go-todo-list-claude/internal/models"
+)
+
+// sample describes a seed todo
+type sample struct {
+	title       string
+	description string
+	completed   bool
+}
+
+// samples is the pool of realistic todos the seeder draws from
+var samples = []sample{
+	{"Buy groceries", "Milk, eggs, bread and coffee", false},
+	{"Book dentist appointment", "Six-month checkup", false},
+	{"Renew passport", "Expires in three months", false},
+	{"Write project proposal", "Draft scope and timeline for Q3", false},
+	{"Review pull requests", "Two open PRs waiting on review", true},
+	{"Call the plumber", "Kitchen sink is leaking", false},
+	{"Pay electricity bill", "", true},
+	{"Plan weekend hike", "Check the weather and trail conditions", false},
+	{"Update resume", "Add the latest project", false},
+	{"Clean the garage", "", false},
+	{"Read chapter 4", "Designing Data-Intensive Applications", true},
+	{"Fix flaky test", "TestCreateTodo occasionally times out in CI", false},
+	{"Send birthday card", "Mum's birthday is next Friday", false},
+	{"Back up laptop", "Run a full backup to the external drive", true},
+	{"Prepare slides", "Team demo on Thursday", false},
+	{"Water the plants", "", true},
+	{"Cancel unused subscriptions", "Streaming services and the old gym", false},
+	{"Order new running shoes", "", false},
+	{"Schedule car service", "Due at 30,000 km", false},
+	{"Organise photos", "Sort last year's holiday photos into albums", false},
+}
+
+// Run inserts up to count sample todos through the store. Todos are
+// matched by title, so running it again does not create duplicates. When
+// count exceeds the number of samples, numbered variants are generated.
+// It returns the number of todos created.
+func Run(ctx context.Context, repo database.TodoStore, count int) (int, error) {
+	if count < 0 {
+		return 0, fmt.Errorf("count must not be negative, got %d", count)
+	}
+
+	existing, err := repo.GetAll(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load existing todos: %w", err)
+	}
+
+	titles := make(map[string]bool, len(existing))
+	for _, todo := range existing {
+		titles[todo.Title] = true
+	}
+
+	created := 0
+	for i := 0; i < count; i++ {
+		s := samples[i%len(samples)]
+		title := s.title
+		if round := i / len(samples); round > 0 {
+			title = fmt.Sprintf("%s #%d", s.title, round+1)
+		}
+
+		if titles[title] {
+			continue
+		}
+
+		todo, err := repo.Create(ctx, models.CreateTodoRequest{
+			Title:       title,
+			Description: s.description,
+		})
+		if err != nil {
+			return created, fmt.Errorf("failed to create todo %q: %w", title, err)
+		}
+
+		if s.completed {
+			completed := true
+			if _, err := repo.Update(ctx, todo.ID, models.UpdateTodoRequest{Completed: &completed}); err != nil {
+				return created, fmt.Errorf("failed to complete todo %q: %w", title, err)
+			}
+		}
+
+		titles[title] = true
+		created++
+	}
+
+	return created, nil
+}
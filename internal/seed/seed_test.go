@@ -0,0 +1,71 @@
+package seed
+
+import (
+	"context"
+	"testing"
+
+	"github.com/larryhudson/go-todo-list-claude/internal/database"
+)
+
+func TestRun_Idempotent(t *testing.T) {
+	db, err := database.New(":memory:", database.DefaultConfig())
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	}()
+
+	if err := db.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize test database: %v", err)
+	}
+
+	repo, err := database.NewTodoRepository(db)
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+	defer func() {
+		if err := repo.Close(); err != nil {
+			t.Errorf("Failed to close repository: %v", err)
+		}
+	}()
+
+	ctx := context.Background()
+	count := len(samples) + 5
+
+	created, err := Run(ctx, repo, count)
+	if err != nil {
+		t.Fatalf("Failed to seed: %v", err)
+	}
+	if created != count {
+		t.Errorf("Expected %d todos created, got %d", count, created)
+	}
+
+	created, err = Run(ctx, repo, count)
+	if err != nil {
+		t.Fatalf("Failed to re-seed: %v", err)
+	}
+	if created != 0 {
+		t.Errorf("Expected re-seeding to create 0 todos, got %d", created)
+	}
+
+	todos, err := repo.GetAll(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get todos: %v", err)
+	}
+	if len(todos) != count {
+		t.Errorf("Expected %d todos, got %d", count, len(todos))
+	}
+
+	completed := 0
+	for _, todo := range todos {
+		if todo.Completed {
+			completed++
+		}
+	}
+	if completed == 0 || completed == len(todos) {
+		t.Errorf("Expected a mix of completed and incomplete todos, got %d of %d completed", completed, len(todos))
+	}
+}